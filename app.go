@@ -6,12 +6,20 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-stock/backend/calendar"
 	"go-stock/backend/data"
 	"go-stock/backend/db"
+	"go-stock/backend/indicator"
+	"go-stock/backend/llm"
 	"go-stock/backend/logger"
 	"go-stock/backend/models"
+	"go-stock/backend/notify"
+	"go-stock/internal/domain/events"
+	"go-stock/internal/domain/services"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,10 +40,13 @@ import (
 
 // App struct
 type App struct {
-	ctx        context.Context
-	cache      *freecache.Cache
-	cron       *cron.Cron
-	cronEntrys map[string]cron.EntryID
+	ctx             context.Context
+	cache           *freecache.Cache
+	cron            *cron.Cron
+	cronEntrys      map[string]cron.EntryID
+	eventDispatcher events.EventDispatcher
+	marketWatcher   *services.MarketSessionWatcher
+	stockMonitor    *services.StockMonitorService
 }
 
 // NewApp creates a new App application struct
@@ -44,10 +55,14 @@ func NewApp() *App {
 	cache := freecache.NewCache(cacheSize)
 	c := cron.New(cron.WithSeconds())
 	c.Start()
+	dispatcher := events.NewSimpleEventDispatcher()
 	return &App{
-		cache:      cache,
-		cron:       c,
-		cronEntrys: make(map[string]cron.EntryID),
+		cache:           cache,
+		cron:            c,
+		cronEntrys:      make(map[string]cron.EntryID),
+		eventDispatcher: dispatcher,
+		marketWatcher:   services.NewMarketSessionWatcher(dispatcher, 10*time.Second),
+		stockMonitor:    services.NewStockMonitorService(nil, nil, dispatcher, nil).SetAlertRules(data.NewAlertRuleApi(), nil, nil),
 	}
 }
 
@@ -154,6 +169,8 @@ func (a *App) domReady(ctx context.Context) {
 		go initStockDataUS(a.ctx)
 	}
 	updateBasicInfo()
+	a.watchAlertRules()
+	a.watchMarketSession(ctx)
 
 	// Add your action here
 	//定时更新数据
@@ -249,6 +266,27 @@ func (a *App) domReady(ctx context.Context) {
 		go MonitorFundPrices(a)
 		go data.NewFundApi().AllFund()
 	}
+	//清理过期的行情快照
+	id, err := a.cron.AddFunc("0 30 3 * * *", func() {
+		data.NewQuoteSnapshotApi().PurgeSnapshots(data.GetConfig().QuoteSnapshotRetentionDays)
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
+	} else {
+		a.cronEntrys["PurgeSnapshots"] = id
+	}
+	//收盘后评估AI评级的准确率
+	id, err = a.cron.AddFunc("0 30 16 * * 1-5", func() {
+		data.NewAiAccuracyApi().EvaluateRatings(time.Now())
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
+	} else {
+		a.cronEntrys["EvaluateAiRatings"] = id
+	}
+	//继续上次未完成的批量分析
+	go data.NewAiBatchApi().Resume(a.ctx, a.emitAiBatchProgress)
+
 	//检查新版本
 	go func() {
 		a.CheckUpdate()
@@ -294,8 +332,16 @@ func (a *App) domReady(ctx context.Context) {
 
 func (a *App) AddCronTask(follow data.FollowedStock) func() {
 	return func() {
+		if status := data.NewAiUsageApi().BudgetStatus(data.GetConfig(), time.Now()); status.Exceeded {
+			logger.SugaredLogger.Warnf("AI费用超出预算,暂停自动分析%s_%s:%s", follow.Name, follow.StockCode, status.Message)
+			go runtime.EventsEmit(a.ctx, "warnMsg", "AI费用超出预算,暂停自动分析"+follow.Name+"_"+follow.StockCode+"："+status.Message)
+			return
+		}
 		go runtime.EventsEmit(a.ctx, "warnMsg", "开始自动分析"+follow.Name+"_"+follow.StockCode)
-		ai := data.NewDeepSeekOpenAi(a.ctx)
+		//执行时读取最新的模型设置
+		aiModelId := data.NewStockDataApi().GetFollowedStockByStockCode(follow.StockCode).AiModelId
+		ai := data.NewOpenAiWithProfile(a.ctx, aiModelId)
+		ai.Trigger = data.AiTriggerCron
 		msgs := ai.NewChatStream(follow.Name, follow.StockCode, "", nil)
 		var res strings.Builder
 
@@ -315,7 +361,7 @@ func (a *App) AddCronTask(follow data.FollowedStock) func() {
 				question = msg["question"].(string)
 			}
 		}
-		data.NewDeepSeekOpenAi(a.ctx).SaveAIResponseResult(follow.StockCode, follow.Name, res.String(), chatId, question)
+		ai.SaveAIResponseResult(follow.StockCode, follow.Name, res.String(), chatId, question)
 		go runtime.EventsEmit(a.ctx, "warnMsg", "AI分析完成："+follow.Name+"_"+follow.StockCode)
 
 	}
@@ -343,102 +389,19 @@ func refreshTelegraphList() *[]string {
 	return &telegraph
 }
 
-// isTradingDay 判断是否是交易日
-func isTradingDay(date time.Time) bool {
-	weekday := date.Weekday()
-	// 判断是否是周末
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return false
-	}
-	// 这里可以添加具体的节假日判断逻辑
-	// 例如：判断是否是春节、国庆节等
-	return true
-}
-
-// isTradingTime 判断是否是交易时间
-func isTradingTime(date time.Time) bool {
-	if !isTradingDay(date) {
-		return false
-	}
-
-	hour, minute, _ := date.Clock()
-
-	// 判断是否在9:15到11:30之间
-	if (hour == 9 && minute >= 15) || (hour == 10) || (hour == 11 && minute <= 30) {
-		return true
-	}
-
-	// 判断是否在13:00到15:00之间
-	if (hour == 13) || (hour == 14) || (hour == 15 && minute <= 0) {
-		return true
-	}
-
-	return false
+// isStockTradingTime 判断股票所在市场当前是否可交易(含节假日、半日市和各市场交易时段)
+func isStockTradingTime(stockCode string, date time.Time) bool {
+	return calendar.IsOpen(data.GetStockMarket(stockCode), date)
 }
 
 // IsHKTradingTime 判断当前时间是否在港股交易时间内
 func IsHKTradingTime(date time.Time) bool {
-	hour, minute, _ := date.Clock()
-
-	// 开市前竞价时段：09:00 - 09:30
-	if (hour == 9 && minute >= 0) || (hour == 9 && minute <= 30) {
-		return true
-	}
-
-	// 上午持续交易时段：09:30 - 12:00
-	if (hour == 9 && minute > 30) || (hour >= 10 && hour < 12) || (hour == 12 && minute == 0) {
-		return true
-	}
-
-	// 下午持续交易时段：13:00 - 16:00
-	if (hour == 13 && minute >= 0) || (hour >= 14 && hour < 16) || (hour == 16 && minute == 0) {
-		return true
-	}
-
-	// 收市竞价交易时段：16:00 - 16:10
-	if (hour == 16 && minute >= 0) || (hour == 16 && minute <= 10) {
-		return true
-	}
-	return false
+	return calendar.IsOpen(calendar.MarketHK, date)
 }
 
 // IsUSTradingTime 判断当前时间是否在美股交易时间内
 func IsUSTradingTime(date time.Time) bool {
-	// 获取美国东部时区
-	est, err := time.LoadLocation("America/New_York")
-	var estTime time.Time
-	if err != nil {
-		estTime = date.Add(time.Hour * -12)
-	} else {
-		// 将当前时间转换为美国东部时间
-		estTime = date.In(est)
-	}
-
-	// 判断是否是周末
-	weekday := estTime.Weekday()
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return false
-	}
-
-	// 获取小时和分钟
-	hour, minute, _ := estTime.Clock()
-
-	// 判断是否在4:00 AM到9:30 AM之间（盘前）
-	if (hour == 4) || (hour == 5) || (hour == 6) || (hour == 7) || (hour == 8) || (hour == 9 && minute < 30) {
-		return true
-	}
-
-	// 判断是否在9:30 AM到4:00 PM之间（盘中）
-	if (hour == 9 && minute >= 30) || (hour >= 10 && hour < 16) || (hour == 16 && minute == 0) {
-		return true
-	}
-
-	// 判断是否在4:00 PM到8:00 PM之间（盘后）
-	if (hour == 16 && minute > 0) || (hour >= 17 && hour < 20) || (hour == 20 && minute == 0) {
-		return true
-	}
-
-	return false
+	return calendar.IsOpen(calendar.MarketUS, date)
 }
 func MonitorFundPrices(a *App) {
 	dest := &[]data.FollowedFund{}
@@ -468,14 +431,14 @@ func MonitorStockPrices(a *App) {
 	//}
 
 	stockInfos := GetStockInfos(*dest...)
+	if data.GetConfig().QuoteSnapshotEnable {
+		go data.NewQuoteSnapshotApi().SaveSnapshots(*stockInfos)
+	}
+	go a.checkAlertRules(*stockInfos)
+	go a.checkLimitAlerts(*stockInfos)
+	go a.checkExitRules(*stockInfos)
 	for _, stockInfo := range *stockInfos {
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
+		if !isStockTradingTime(stockInfo.Code, time.Now()) {
 			continue
 		}
 
@@ -501,13 +464,7 @@ func GetStockInfos(follows ...data.FollowedStock) *[]data.StockInfo {
 	stockInfos := make([]data.StockInfo, 0)
 	stockCodes := make([]string, 0)
 	for _, follow := range follows {
-		if strutil.HasPrefixAny(follow.StockCode, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(follow.StockCode, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(follow.StockCode, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
+		if !isStockTradingTime(follow.StockCode, time.Now()) {
 			continue
 		}
 		stockCodes = append(stockCodes, follow.StockCode)
@@ -542,44 +499,33 @@ func getStockInfo(follow data.FollowedStock) *data.StockInfo {
 func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	stockData.PrePrice = follow.Price //上次当前价格
 	stockData.Sort = follow.Sort
-	stockData.CostPrice = follow.CostPrice //成本价
-	stockData.CostVolume = follow.Volume   //成本量
+	//有交易流水时按流水计算持仓成本,否则使用手工填写的成本价和数量
+	costPrice, costVolume := follow.CostPrice, follow.Volume
+	if position := data.GetHoldingPosition(follow.StockCode); position != nil {
+		costPrice, costVolume = position.AvgCost, position.Quantity
+		stockData.RealizedProfit = mathutil.RoundToFloat(position.RealizedPnL, 2)
+	}
+	stockData.CostPrice = costPrice   //成本价
+	stockData.CostVolume = costVolume //成本量
 	stockData.AlarmChangePercent = follow.AlarmChangePercent
 	stockData.AlarmPrice = follow.AlarmPrice
 	stockData.Groups = follow.Groups
 
-	//当前价格
-	price, _ := convertor.ToFloat(stockData.Price)
-	//当前价格为0 时 使用卖一价格作为当前价格
-	if price == 0 {
-		price, _ = convertor.ToFloat(stockData.A1P)
-	}
-	//当前价格依然为0 时 使用买一报价作为当前价格
-	if price == 0 {
-		price, _ = convertor.ToFloat(stockData.B1P)
+	quote, err := stockData.GetQuote()
+	if err != nil {
+		logger.SugaredLogger.Warnf("addStockFollowData %s", err.Error())
+		return
 	}
-
+	//当前价格(为0时依次使用卖一、买一、昨日收盘价)
+	price := quote.LastPrice().Float64()
 	//昨日收盘价
-	preClosePrice, _ := convertor.ToFloat(stockData.PreClose)
-
-	//当前价格依然为0 时 使用昨日收盘价为当前价格
-	if price == 0 {
-		price = preClosePrice
-	}
-
+	preClosePrice := quote.PreClose.Float64()
 	//今日最高价
-	highPrice, _ := convertor.ToFloat(stockData.High)
-	if highPrice == 0 {
-		highPrice, _ = convertor.ToFloat(stockData.Open)
-	}
-
+	highPrice := quote.DayHigh().Float64()
 	//今日最低价
-	lowPrice, _ := convertor.ToFloat(stockData.Low)
-	if lowPrice == 0 {
-		lowPrice, _ = convertor.ToFloat(stockData.Open)
-	}
-	//开盘价
-	//openPrice, _ := convertor.ToFloat(stockData.Open)
+	lowPrice := quote.DayLow().Float64()
+
+	data.NewPriceLimitApi().Fill(stockData, quote)
 
 	if price > 0 && preClosePrice > 0 {
 		stockData.ChangePrice = mathutil.RoundToFloat(price-preClosePrice, 2)
@@ -591,15 +537,15 @@ func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	if lowPrice > 0 && preClosePrice > 0 {
 		stockData.LowRate = mathutil.RoundToFloat(mathutil.Div(lowPrice-preClosePrice, preClosePrice)*100, 3)
 	}
-	if follow.CostPrice > 0 && follow.Volume > 0 {
+	if costPrice > 0 && costVolume > 0 {
 		if price > 0 {
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((price-follow.CostPrice)*float64(follow.Volume), 2)
-			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-costPrice, costPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((price-costPrice)*float64(costVolume), 2)
+			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(costVolume), 2)
 		} else {
 			//未开盘时当前价格为昨日收盘价
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-follow.CostPrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-costPrice, costPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-costPrice)*float64(costVolume), 2)
 			// 未开盘时，今日盈亏为 0
 			stockData.ProfitAmountToday = 0
 		}
@@ -614,6 +560,103 @@ func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	}
 }
 
+// watchMarketSession 跟踪各市场交易时段,开市/休市时通知前端并立即刷新行情
+func (a *App) watchMarketSession(ctx context.Context) {
+	notify := func(event events.Event) {
+		e, ok := event.(events.MarketEvent)
+		if !ok {
+			return
+		}
+		logger.SugaredLogger.Infof("市场交易时段变化 %s %s -> %s", e.Exchange, e.PreviousPhase, e.Phase)
+		go runtime.EventsEmit(a.ctx, "marketSession", map[string]any{
+			"market":        e.Exchange,
+			"phase":         e.Phase,
+			"previousPhase": e.PreviousPhase,
+			"isOpen":        e.IsOpen,
+		})
+	}
+	a.eventDispatcher.Register(events.MarketPhaseChanged, notify)
+	a.eventDispatcher.Register(events.MarketOpened, func(event events.Event) {
+		go MonitorStockPrices(a)
+	})
+	//收盘后保存当日持仓快照(午间休市不处理)
+	a.eventDispatcher.Register(events.MarketClosed, func(event events.Event) {
+		if e, ok := event.(events.MarketEvent); ok && e.Phase == string(calendar.PhaseClosed) {
+			go takePortfolioSnapshot(data.SnapshotDate(e.Exchange, time.Now()))
+			if e.Exchange == data.MarketCN {
+				go sendAlertDigest(a.ctx, data.SnapshotDate(e.Exchange, time.Now()))
+			}
+		}
+	})
+	if err := a.marketWatcher.Start(ctx); err != nil {
+		logger.SugaredLogger.Errorf("启动交易时段监控失败:%s", err.Error())
+	}
+}
+
+func takePortfolioSnapshot(date string) {
+	snapshots, err := data.NewPortfolioApi().TakeSnapshot(date)
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存持仓快照失败 %s:%s", date, err.Error())
+		return
+	}
+	logger.SugaredLogger.Infof("保存持仓快照 %s %d条", date, len(snapshots))
+}
+
+// sendAlertDigest A股收盘后推送当日未确认报警汇总
+func sendAlertDigest(ctx context.Context, date string) {
+	if count := data.NewAlertEventApi().SendDigest(ctx, date); count > 0 {
+		logger.SugaredLogger.Infof("推送未确认报警汇总 %s %d条", date, count)
+	}
+}
+
+// checkAlertRules 每次刷新行情后计算组合报警规则
+func (a *App) checkAlertRules(stockInfos []data.StockInfo) {
+	if len(stockInfos) == 0 {
+		return
+	}
+	values := data.NewAlertRuleApi().Values(stockInfos, time.Now())
+	if err := a.stockMonitor.CheckRules(a.ctx, values, time.Now()); err != nil {
+		logger.SugaredLogger.Errorf("计算报警规则失败:%s", err.Error())
+	}
+}
+
+func (a *App) watchAlertRules() {
+	a.eventDispatcher.Register(events.AlertRuleTriggered, func(event events.Event) {
+		e, ok := event.(events.AlertRuleTriggeredEvent)
+		if !ok {
+			return
+		}
+		title := "报警规则:" + e.Rule.Name
+		msg := fmt.Sprintf("%s %s\n当前价格:%.2f 涨跌幅:%.2f%%", e.Rule.StockCode, e.Rule.Expression, e.Values["price"], e.Values["change_pct"])
+		logger.SugaredLogger.Infof("%s %s", title, msg)
+		alert := data.NewRuleAlertEvent(e.Rule, e.Values, title, msg)
+		alerts := data.NewAlertEventApi()
+		if alerts.IsSnoozed(alert, time.Now()) {
+			logger.SugaredLogger.Infof("报警已暂停提醒:%s", title)
+			return
+		}
+		if err := data.NewAlertGuardApi().Allow(alert, 0, time.Now()); err != nil {
+			if errors.Is(err, data.ErrAlertMuted) {
+				alerts.Mute(alert)
+			}
+			logger.SugaredLogger.Infof("报警未推送 %s:%s", title, err.Error())
+			return
+		}
+		go runtime.EventsEmit(a.ctx, "alertRule", map[string]any{
+			"rule":   e.Rule,
+			"values": e.Values,
+		})
+		go data.NewAlertWindowsApi("go-stock消息通知", title, msg, "").SendNotification()
+		var dingding data.DingDingSender
+		if data.GetConfig().DingPushEnable {
+			dingding = func(retried func(error)) string {
+				return data.NewDingDingAPI().OnRetried(retried).SendAlert(title, msg, e.Rule.StockCode)
+			}
+		}
+		go alerts.Deliver(a.ctx, alert, e.Rule.Channels, dingding)
+	})
+}
+
 // beforeClose is called when the application is about to quit,
 // either by clicking the window close button or calling runtime.Quit.
 // Returning true will cause the application to continue, false will continue shutdown as normal.
@@ -706,34 +749,106 @@ func (a *App) SendDingDingMessage(message string, stockCode string) string {
 // SendDingDingMessageByType msgType 报警类型: 1 涨跌报警;2 股价报警 3 成本价报警
 func (a *App) SendDingDingMessageByType(message string, stockCode string, msgType int) string {
 
-	if strutil.HasPrefixAny(stockCode, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-		return "非A股交易时间"
-	}
-	if strutil.HasPrefixAny(stockCode, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-		return "非港股交易时间"
-	}
-	if strutil.HasPrefixAny(stockCode, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
-		return "非美股交易时间"
+	if !isStockTradingTime(stockCode, time.Now()) {
+		return "非" + calendar.MarketName(data.GetStockMarket(stockCode)) + "交易时间"
 	}
+	stockInfo := &data.StockInfo{}
+	db.Dao.Model(stockInfo).Where("code = ?", stockCode).First(stockInfo)
+	alert := data.NewStockAlertEvent(getMsgTypeName(msgType), stockInfo, GenNotificationMsg(stockInfo))
+	return a.deliverStockAlert(alert, time.Duration(getMsgTypeTTL(msgType))*time.Second, func(retried func(error)) string {
+		return data.NewDingDingAPI().OnRetried(retried).SendDingDingMessage(message)
+	})
+}
 
-	ttl, _ := a.cache.TTL([]byte(stockCode))
-	//logger.SugaredLogger.Infof("stockCode %s ttl:%d", stockCode, ttl)
-	if ttl > 0 {
+// deliverStockAlert 经过暂停/去重/免打扰检查后推送股票报警,返回钉钉推送结果
+func (a *App) deliverStockAlert(alert *data.AlertEvent, cooldown time.Duration, dingding data.DingDingSender) string {
+	alerts := data.NewAlertEventApi()
+	if alerts.IsSnoozed(alert, time.Now()) {
 		return ""
 	}
-	err := a.cache.Set([]byte(stockCode), []byte("1"), getMsgTypeTTL(msgType))
-	if err != nil {
-		logger.SugaredLogger.Errorf("set cache error:%s", err.Error())
+	if err := data.NewAlertGuardApi().Allow(alert, cooldown, time.Now()); err != nil {
+		if errors.Is(err, data.ErrAlertMuted) {
+			alerts.Mute(alert)
+			return "免打扰时段"
+		}
 		return ""
 	}
-	stockInfo := &data.StockInfo{}
-	db.Dao.Model(stockInfo).Where("code = ?", stockCode).First(stockInfo)
-	go data.NewAlertWindowsApi("go-stock消息通知", getMsgTypeName(msgType), GenNotificationMsg(stockInfo), "").SendNotification()
-	return data.NewDingDingAPI().SendDingDingMessage(message)
+	go data.NewAlertWindowsApi("go-stock消息通知", alert.Title, alert.Content, "").SendNotification()
+	res := "钉钉推送未开启"
+	var send data.DingDingSender
+	if data.GetConfig().DingPushEnable {
+		send = func(retried func(error)) string {
+			res = dingding(retried)
+			return res
+		}
+	}
+	alerts.Deliver(a.ctx, alert, nil, send)
+	return res
+}
+
+// checkLimitAlerts A股涨停/跌停/炸板/接近涨跌停报警
+func (a *App) checkLimitAlerts(stockInfos []data.StockInfo) {
+	config := data.GetConfig()
+	if !config.LimitAlertEnable {
+		return
+	}
+	stockInfoMap := make(map[string]*data.StockInfo, len(stockInfos))
+	for i := range stockInfos {
+		stockInfoMap[stockInfos[i].Code] = &stockInfos[i]
+	}
+	for _, signal := range data.NewPriceLimitApi().Signals(stockInfos, config.LimitAlertTicks) {
+		if !isStockTradingTime(signal.Code, time.Now()) {
+			continue
+		}
+		alert := data.NewStockAlertEvent(signal.Type, stockInfoMap[signal.Code], signal.Message())
+		go a.deliverStockAlert(alert, 0, func(retried func(error)) string {
+			return data.NewDingDingAPI().OnRetried(retried).SendAlert(signal.Type, signal.Message(), signal.Code)
+		})
+		go runtime.EventsEmit(a.ctx, "limitAlert", signal)
+	}
+}
+
+// checkExitRules 持仓移动止损/分档止盈/时间止损报警
+func (a *App) checkExitRules(stockInfos []data.StockInfo) {
+	trading := make([]data.StockInfo, 0, len(stockInfos))
+	for _, stockInfo := range stockInfos {
+		if isStockTradingTime(stockInfo.Code, time.Now()) {
+			trading = append(trading, stockInfo)
+		}
+	}
+	if len(trading) == 0 {
+		return
+	}
+	stockInfoMap := make(map[string]*data.StockInfo, len(trading))
+	for i := range trading {
+		stockInfoMap[trading[i].Code] = &trading[i]
+	}
+	for _, signal := range data.NewExitRuleApi().Check(trading, time.Now()) {
+		stockInfo, ok := stockInfoMap[signal.StockCode]
+		if !ok {
+			stockInfo = &data.StockInfo{Code: signal.StockCode, Name: signal.StockName, Price: strconv.FormatFloat(signal.Price, 'f', -1, 64)}
+		}
+		alert := data.NewStockAlertEvent(signal.Type, stockInfo, signal.Message())
+		go a.deliverStockAlert(alert, 0, func(retried func(error)) string {
+			return data.NewDingDingAPI().OnRetried(retried).SendAlert(signal.Type, signal.Message(), signal.StockCode)
+		})
+		go runtime.EventsEmit(a.ctx, "exitAlert", signal)
+	}
+}
+
+func (a *App) SendAIReportToDingDing(stockName, stockCode, report string) string {
+	if !data.GetConfig().DingPushEnable {
+		return "钉钉推送未开启"
+	}
+	return data.NewDingDingAPI().SendAIReport(stockName, stockCode, report)
 }
 
 func (a *App) NewChatStream(stock, stockCode, question string, sysPromptId *int) {
-	msgs := data.NewDeepSeekOpenAi(a.ctx).NewChatStream(stock, stockCode, question, sysPromptId)
+	a.NewChatStreamWithModel(stock, stockCode, question, sysPromptId, 0)
+}
+
+func (a *App) NewChatStreamWithModel(stock, stockCode, question string, sysPromptId *int, aiModelId uint) {
+	msgs := data.NewOpenAiWithProfile(a.ctx, aiModelId).NewChatStream(stock, stockCode, question, sysPromptId)
 	for msg := range msgs {
 		runtime.EventsEmit(a.ctx, "newChatStream", msg)
 	}
@@ -747,6 +862,113 @@ func (a *App) GetAIResponseResult(stock string) *models.AIResponseResult {
 	return data.NewDeepSeekOpenAi(a.ctx).GetAIResponseResult(stock)
 }
 
+func (a *App) ContinueChat(conversationId uint, question string, aiModelId uint) {
+	msgs := data.NewOpenAiWithProfile(a.ctx, aiModelId).ContinueChat(conversationId, question)
+	//追问由 ContinueChat 保存到对话中,使用单独的事件,避免前端再次保存为新对话
+	for msg := range msgs {
+		runtime.EventsEmit(a.ctx, "continueChatStream", msg)
+	}
+	runtime.EventsEmit(a.ctx, "continueChatStream", "DONE")
+}
+
+func (a *App) GetAiConversations(stockCode string) []data.AiConversation {
+	return data.NewAiConversationApi().GetConversations(stockCode)
+}
+
+func (a *App) GetAiConversationMessages(conversationId uint) []data.AiMessage {
+	return data.NewAiConversationApi().GetMessages(conversationId)
+}
+
+func (a *App) RenameAiConversation(conversationId uint, title string) string {
+	if err := data.NewAiConversationApi().RenameConversation(conversationId, title); err != nil {
+		return err.Error()
+	}
+	return "修改成功"
+}
+
+func (a *App) DeleteAiConversation(conversationId uint) string {
+	if err := data.NewAiConversationApi().DeleteConversation(conversationId); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+func (a *App) GetAiUsageStats(days int) []data.AiUsageStat {
+	if days <= 0 {
+		days = 30
+	}
+	return data.NewAiUsageApi().DailyStats(days, time.Now())
+}
+
+func (a *App) GetAiBudgetStatus() data.AiBudgetStatus {
+	return data.NewAiUsageApi().BudgetStatus(data.GetConfig(), time.Now())
+}
+
+func (a *App) GetAiRatings(query data.AiRatingQuery) []data.AiRating {
+	return data.NewAiRatingApi().Query(query)
+}
+
+func (a *App) EvaluateAiRatings() string {
+	return fmt.Sprintf("评估完成,新增%d条", data.NewAiAccuracyApi().EvaluateRatings(time.Now()))
+}
+
+func (a *App) GetAiAccuracy(groupBy string, days int) []data.AiAccuracyStat {
+	stats, err := data.NewAiAccuracyApi().Accuracy(groupBy, days)
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+	}
+	return stats
+}
+
+func (a *App) emitAiBatchProgress(progress data.AiBatchProgress) {
+	runtime.EventsEmit(a.ctx, "aiBatchProgress", progress)
+	if progress.Task == nil {
+		go runtime.EventsEmit(a.ctx, "warnMsg", fmt.Sprintf("批量分析结束:成功%d只,失败%d只", progress.Succeeded, progress.Failed))
+	}
+}
+
+func (a *App) StartAiBatchJob(groupId int, promptTemplateId int, question string, aiModelId uint, concurrency int, maxRetries int) string {
+	api := data.NewAiBatchApi()
+	job, err := api.CreateJob(groupId, promptTemplateId, question, aiModelId, concurrency, maxRetries)
+	if err != nil {
+		return err.Error()
+	}
+	if err := api.Start(a.ctx, job.ID, a.emitAiBatchProgress); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("已开始批量分析%s,共%d只", job.GroupName, job.Total)
+}
+
+func (a *App) CancelAiBatchJob(jobId uint) string {
+	if err := data.NewAiBatchApi().Cancel(jobId); err != nil {
+		return err.Error()
+	}
+	return "已取消"
+}
+
+func (a *App) GetAiBatchJobs() []data.AiBatchJob {
+	return data.NewAiBatchApi().GetJobs()
+}
+
+func (a *App) GetAiBatchJob(jobId uint) *data.AiBatchJob {
+	job, err := data.NewAiBatchApi().GetJob(jobId)
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+	}
+	return job
+}
+
+func (a *App) GetAiBatchTasks(jobId uint) []data.AiBatchTask {
+	return data.NewAiBatchApi().GetTasks(jobId)
+}
+
+func (a *App) DeleteAiBatchJob(jobId uint) string {
+	if err := data.NewAiBatchApi().DeleteJob(jobId); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
 func (a *App) GetVersionInfo() *models.VersionInfo {
 	return &models.VersionInfo{
 		Version: Version,
@@ -813,8 +1035,21 @@ func GenNotificationMsg(stockInfo *data.StockInfo) string {
 	return "[" + stockInfo.Name + "] " + stockInfo.Price + " " + convertor.ToString(RF) + "% " + stockInfo.Date + " " + stockInfo.Time
 }
 
-// msgType : 1 涨跌报警(5分钟);2 股价报警(30分钟) 3 成本价报警(30分钟)
+// msgType : 1 涨跌报警(默认5分钟);2 股价报警(默认30分钟) 3 成本价报警(默认30分钟),可在设置中修改
 func getMsgTypeTTL(msgType int) int {
+	config := data.GetConfig()
+	minutes := 0
+	switch msgType {
+	case 1:
+		minutes = config.AlertChangeCooldown
+	case 2:
+		minutes = config.AlertPriceCooldown
+	case 3:
+		minutes = config.AlertCostCooldown
+	}
+	if minutes > 0 {
+		return 60 * minutes
+	}
 	switch msgType {
 	case 1:
 		return 60 * 5
@@ -994,13 +1229,17 @@ func (a *App) GetPromptTemplates(name, promptType string) *[]models.PromptTempla
 }
 func (a *App) AddPrompt(prompt models.Prompt) string {
 	promptTemplate := models.PromptTemplate{
-		ID:      prompt.ID,
-		Content: prompt.Content,
-		Name:    prompt.Name,
-		Type:    prompt.Type,
+		ID:              prompt.ID,
+		Content:         prompt.Content,
+		Name:            prompt.Name,
+		Type:            prompt.Type,
+		ContextSections: prompt.ContextSections,
 	}
 	return data.NewPromptTemplateApi().AddPrompt(promptTemplate)
 }
+func (a *App) GetAiContextSections() []data.ContextSection {
+	return data.ContextSections
+}
 func (a *App) DelPrompt(id uint) string {
 	return data.NewPromptTemplateApi().DelPrompt(id)
 }
@@ -1079,7 +1318,7 @@ func (a *App) RemoveGroup(groupId int) string {
 }
 
 func (a *App) GetStockKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetHK_KLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, data.KLineAdjustQfq, days)
 }
 
 func (a *App) GetStockMinutePriceLineData(stockCode, stockName string) map[string]any {
@@ -1093,7 +1332,205 @@ func (a *App) GetStockMinutePriceLineData(stockCode, stockName string) map[strin
 }
 
 func (a *App) GetStockCommonKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetCommonKLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, data.KLineAdjustQfq, days)
+}
+
+func (a *App) GetStockIndicators(stockCode, period string, indicators []string) *indicator.Result {
+	return data.NewKLineStoreApi().GetIndicators(stockCode, period, data.KLineAdjustQfq, data.GetConfig().KDays, indicators)
+}
+
+func (a *App) AddTrade(trade data.Trade) string {
+	err := data.NewTradeApi().AddTrade(trade)
+	if err != nil {
+		return err.Error()
+	}
+	return "添加成功"
+}
+
+func (a *App) DeleteTrade(id uint) string {
+	err := data.NewTradeApi().DeleteTrade(id)
+	if err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+func (a *App) GetTrades(stockCode string) []data.Trade {
+	return data.NewTradeApi().GetTrades(stockCode, "")
+}
+
+func (a *App) GetPositions(stockCode string) []*data.Position {
+	positions, err := data.NewTradeApi().GetPositions(stockCode, data.GetConfig().CostMethod)
+	if err != nil {
+		logger.SugaredLogger.Errorf("GetPositions %s:%s", stockCode, err.Error())
+		return []*data.Position{}
+	}
+	return positions
+}
+
+func (a *App) TakePortfolioSnapshot() string {
+	_, err := data.NewPortfolioApi().TakeSnapshot(time.Now().Format(time.DateOnly))
+	if err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+func (a *App) GetPortfolioSnapshots(account string, groupId int, startDate, endDate string) []data.PortfolioSnapshot {
+	return data.NewPortfolioApi().GetSnapshots(account, groupId, startDate, endDate)
+}
+
+func (a *App) GetPortfolioPerformance(account string, groupId int, startDate, endDate, benchmark string) *data.PortfolioPerformance {
+	performance, err := data.NewPortfolioApi().GetPerformance(account, groupId, startDate, endDate, benchmark)
+	if err != nil {
+		logger.SugaredLogger.Warnf("GetPortfolioPerformance %s", err.Error())
+		return &data.PortfolioPerformance{Points: []data.PerformancePoint{}}
+	}
+	return performance
+}
+
+func (a *App) SaveAlertRule(rule data.AlertRule) string {
+	model := rule.ToModel()
+	if err := a.stockMonitor.SaveRule(a.ctx, model); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+func (a *App) DeleteAlertRule(id uint) string {
+	if err := a.stockMonitor.DeleteRule(a.ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+func (a *App) SetAlertRuleActive(id uint, active bool) string {
+	if err := a.stockMonitor.SetRuleActive(a.ctx, strconv.FormatUint(uint64(id), 10), active); err != nil {
+		return err.Error()
+	}
+	return "设置成功"
+}
+
+func (a *App) GetAlertRules(stockCode string) []data.AlertRule {
+	return data.NewAlertRuleApi().List(stockCode)
+}
+
+func (a *App) GetAlertRuleFields() []services.RuleField {
+	return services.RuleFields
+}
+
+func (a *App) GetAiModelProviderTypes() []string {
+	return llm.Types
+}
+
+func (a *App) GetAiModelProfiles() []data.AiModelProfile {
+	return data.NewAiModelApi().GetProfiles()
+}
+
+func (a *App) SaveAiModelProfile(profile data.AiModelProfile) string {
+	if err := data.NewAiModelApi().SaveProfile(profile); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+func (a *App) DeleteAiModelProfile(id uint) string {
+	if err := data.NewAiModelApi().DeleteProfile(id); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+func (a *App) TestAiModelProfile(id uint) string {
+	reply, err := data.NewAiModelApi().TestProfile(id)
+	if err != nil {
+		return err.Error()
+	}
+	return reply
+}
+
+func (a *App) SetStockAIModel(aiModelId uint, stockCode string) string {
+	data.NewStockDataApi().SetStockAIModel(aiModelId, stockCode)
+	return "设置成功"
+}
+
+func (a *App) GetNotificationChannelTypes() []string {
+	return notify.Types
+}
+
+func (a *App) GetNotificationChannels() []data.NotificationChannelSetting {
+	return data.NewNotificationApi().GetChannels()
+}
+
+func (a *App) SaveNotificationChannel(channel data.NotificationChannelSetting) string {
+	err := data.NewNotificationApi().SaveChannel(channel)
+	if err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+func (a *App) DeleteNotificationChannel(id uint) string {
+	err := data.NewNotificationApi().DeleteChannel(id)
+	if err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+func (a *App) TestNotificationChannel(id uint) string {
+	err := data.NewNotificationApi().TestChannel(id)
+	if err != nil {
+		return "发送失败:" + err.Error()
+	}
+	return "发送成功"
+}
+
+func (a *App) GetNotificationLogs(limit int) []data.NotificationLog {
+	return data.NewNotificationApi().GetLogs(limit)
+}
+
+func (a *App) GetAlertEvents(query data.AlertEventQuery) []data.AlertEvent {
+	return data.NewAlertEventApi().List(query)
+}
+
+func (a *App) AcknowledgeAlertEvents(ids []uint) string {
+	err := data.NewAlertEventApi().Acknowledge(ids)
+	if err != nil {
+		return err.Error()
+	}
+	return "确认成功"
+}
+
+func (a *App) SnoozeAlertEvent(id uint, minutes int) string {
+	err := data.NewAlertEventApi().Snooze(id, minutes)
+	if err != nil {
+		return err.Error()
+	}
+	return "设置成功"
+}
+
+func (a *App) GetAlertDigest(date string) string {
+	_, content, _ := data.NewAlertEventApi().Digest(date)
+	return content
+}
+
+func (a *App) GetExitRules() []data.ExitRule {
+	return data.NewExitRuleApi().GetRules()
+}
+
+func (a *App) SaveExitRule(rule data.ExitRule) string {
+	if err := data.NewExitRuleApi().SaveRule(rule); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+func (a *App) DeleteExitRule(id uint) string {
+	if err := data.NewExitRuleApi().DeleteRule(id); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
 }
 
 func (a *App) GetTelegraphList(source string) *[]*models.Telegraph {
@@ -1113,7 +1550,11 @@ func (a *App) GlobalStockIndexes() map[string]any {
 }
 
 func (a *App) SummaryStockNews(question string, sysPromptId *int) {
-	msgs := data.NewDeepSeekOpenAi(a.ctx).NewSummaryStockNewsStream(question, sysPromptId)
+	a.SummaryStockNewsWithModel(question, sysPromptId, 0)
+}
+
+func (a *App) SummaryStockNewsWithModel(question string, sysPromptId *int, aiModelId uint) {
+	msgs := data.NewOpenAiWithProfile(a.ctx, aiModelId).NewSummaryStockNewsStream(question, sysPromptId)
 	for msg := range msgs {
 		runtime.EventsEmit(a.ctx, "summaryStockNews", msg)
 	}
@@ -1137,3 +1578,12 @@ func (a *App) GetStockMoneyTrendByDay(stockCode string, days int) []map[string]a
 	slice.Reverse(res)
 	return res
 }
+
+func (a *App) GetQuoteSourceStatus() []data.QuoteSourceStatus {
+	return data.GetQuoteSourceStatus()
+}
+
+func (a *App) GetIntradaySnapshots(stockCode, from, to string) []data.QuoteSnapshot {
+	start, end := data.ParseSnapshotRange(from, to)
+	return data.NewQuoteSnapshotApi().GetIntradaySnapshots(stockCode, start, end)
+}
@@ -6,12 +6,20 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"go-stock/backend/calendar"
 	"go-stock/backend/data"
 	"go-stock/backend/db"
+	"go-stock/backend/indicator"
+	"go-stock/backend/llm"
 	"go-stock/backend/logger"
 	"go-stock/backend/models"
+	"go-stock/backend/notify"
+	"go-stock/internal/domain/events"
+	"go-stock/internal/domain/services"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,16 +35,23 @@ import (
 
 // App struct
 type App struct {
-	ctx   context.Context
-	cache *freecache.Cache
+	ctx             context.Context
+	cache           *freecache.Cache
+	eventDispatcher events.EventDispatcher
+	marketWatcher   *services.MarketSessionWatcher
+	stockMonitor    *services.StockMonitorService
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	cacheSize := 512 * 1024
 	cache := freecache.NewCache(cacheSize)
+	dispatcher := events.NewSimpleEventDispatcher()
 	return &App{
-		cache: cache,
+		cache:           cache,
+		eventDispatcher: dispatcher,
+		marketWatcher:   services.NewMarketSessionWatcher(dispatcher, 10*time.Second),
+		stockMonitor:    services.NewStockMonitorService(nil, nil, dispatcher, nil).SetAlertRules(data.NewAlertRuleApi(), nil, nil),
 	}
 }
 
@@ -71,6 +86,8 @@ func checkUpdate(a *App) {
 // domReady is called after front-end resources have been loaded
 func (a *App) domReady(ctx context.Context) {
 	// Add your action here
+	//继续上次未完成的批量分析
+	go data.NewAiBatchApi().Resume(a.ctx, a.emitAiBatchProgress)
 	//定时更新数据
 	go func() {
 		config := data.NewSettingsApi(&data.Settings{}).GetConfig()
@@ -81,9 +98,7 @@ func (a *App) domReady(ctx context.Context) {
 		ticker := time.NewTicker(time.Second * time.Duration(interval))
 		defer ticker.Stop()
 		for range ticker.C {
-			if isTradingTime(time.Now()) {
-				MonitorStockPrices(a)
-			}
+			MonitorStockPrices(a)
 		}
 	}()
 
@@ -100,6 +115,17 @@ func (a *App) domReady(ctx context.Context) {
 	}()
 	go runtime.EventsEmit(a.ctx, "telegraph", refreshTelegraphList())
 	go MonitorStockPrices(a)
+	a.watchAlertRules()
+	a.watchMarketSession(ctx)
+
+	//清理过期的行情快照
+	go func() {
+		ticker := time.NewTicker(time.Hour * 24)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			data.NewQuoteSnapshotApi().PurgeSnapshots(data.GetConfig().QuoteSnapshotRetentionDays)
+		}
+	}()
 
 	//检查新版本
 	go func() {
@@ -129,37 +155,106 @@ func refreshTelegraphList() *[]string {
 	return &telegraph
 }
 
-// isTradingDay 判断是否是交易日
-func isTradingDay(date time.Time) bool {
-	weekday := date.Weekday()
-	// 判断是否是周末
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return false
+// watchMarketSession 跟踪各市场交易时段,开市/休市时通知前端并立即刷新行情
+func (a *App) watchMarketSession(ctx context.Context) {
+	notify := func(event events.Event) {
+		e, ok := event.(events.MarketEvent)
+		if !ok {
+			return
+		}
+		logger.SugaredLogger.Infof("市场交易时段变化 %s %s -> %s", e.Exchange, e.PreviousPhase, e.Phase)
+		go runtime.EventsEmit(a.ctx, "marketSession", map[string]any{
+			"market":        e.Exchange,
+			"phase":         e.Phase,
+			"previousPhase": e.PreviousPhase,
+			"isOpen":        e.IsOpen,
+		})
+	}
+	a.eventDispatcher.Register(events.MarketPhaseChanged, notify)
+	a.eventDispatcher.Register(events.MarketOpened, func(event events.Event) {
+		go MonitorStockPrices(a)
+	})
+	//收盘后保存当日持仓快照(午间休市不处理)
+	a.eventDispatcher.Register(events.MarketClosed, func(event events.Event) {
+		if e, ok := event.(events.MarketEvent); ok && e.Phase == string(calendar.PhaseClosed) {
+			go takePortfolioSnapshot(data.SnapshotDate(e.Exchange, time.Now()))
+			if e.Exchange == data.MarketCN {
+				go sendAlertDigest(a.ctx, data.SnapshotDate(e.Exchange, time.Now()))
+			}
+		}
+	})
+	if err := a.marketWatcher.Start(ctx); err != nil {
+		logger.SugaredLogger.Errorf("启动交易时段监控失败:%s", err.Error())
 	}
-	// 这里可以添加具体的节假日判断逻辑
-	// 例如：判断是否是春节、国庆节等
-	return true
 }
 
-// isTradingTime 判断是否是交易时间
-func isTradingTime(date time.Time) bool {
-	if !isTradingDay(date) {
-		return false
+func takePortfolioSnapshot(date string) {
+	snapshots, err := data.NewPortfolioApi().TakeSnapshot(date)
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存持仓快照失败 %s:%s", date, err.Error())
+		return
 	}
+	logger.SugaredLogger.Infof("保存持仓快照 %s %d条", date, len(snapshots))
+}
 
-	hour, minute, _ := date.Clock()
-
-	// 判断是否在9:15到11:30之间
-	if (hour == 9 && minute >= 15) || (hour == 10) || (hour == 11 && minute <= 30) {
-		return true
+// sendAlertDigest A股收盘后推送当日未确认报警汇总
+func sendAlertDigest(ctx context.Context, date string) {
+	if count := data.NewAlertEventApi().SendDigest(ctx, date); count > 0 {
+		logger.SugaredLogger.Infof("推送未确认报警汇总 %s %d条", date, count)
 	}
+}
 
-	// 判断是否在13:00到15:00之间
-	if (hour == 13) || (hour == 14) || (hour == 15 && minute <= 0) {
-		return true
+// checkAlertRules 每次刷新行情后计算组合报警规则
+func (a *App) checkAlertRules(stockInfos []data.StockInfo) {
+	if len(stockInfos) == 0 {
+		return
+	}
+	values := data.NewAlertRuleApi().Values(stockInfos, time.Now())
+	if err := a.stockMonitor.CheckRules(a.ctx, values, time.Now()); err != nil {
+		logger.SugaredLogger.Errorf("计算报警规则失败:%s", err.Error())
 	}
+}
 
-	return false
+func (a *App) watchAlertRules() {
+	a.eventDispatcher.Register(events.AlertRuleTriggered, func(event events.Event) {
+		e, ok := event.(events.AlertRuleTriggeredEvent)
+		if !ok {
+			return
+		}
+		title := "报警规则:" + e.Rule.Name
+		msg := fmt.Sprintf("%s %s\n当前价格:%.2f 涨跌幅:%.2f%%", e.Rule.StockCode, e.Rule.Expression, e.Values["price"], e.Values["change_pct"])
+		logger.SugaredLogger.Infof("%s %s", title, msg)
+		alert := data.NewRuleAlertEvent(e.Rule, e.Values, title, msg)
+		alerts := data.NewAlertEventApi()
+		if alerts.IsSnoozed(alert, time.Now()) {
+			logger.SugaredLogger.Infof("报警已暂停提醒:%s", title)
+			return
+		}
+		if err := data.NewAlertGuardApi().Allow(alert, 0, time.Now()); err != nil {
+			if errors.Is(err, data.ErrAlertMuted) {
+				alerts.Mute(alert)
+			}
+			logger.SugaredLogger.Infof("报警未推送 %s:%s", title, err.Error())
+			return
+		}
+		go runtime.EventsEmit(a.ctx, "alertRule", map[string]any{
+			"rule":   e.Rule,
+			"values": e.Values,
+		})
+		go data.NewAlertWindowsApi("go-stock消息通知", title, msg, "").SendNotification()
+		var dingding data.DingDingSender
+		if data.GetConfig().DingPushEnable {
+			dingding = func(retried func(error)) string {
+				return data.NewDingDingAPI().OnRetried(retried).SendAlert(title, msg, e.Rule.StockCode)
+			}
+		}
+		go alerts.Deliver(a.ctx, alert, e.Rule.Channels, dingding)
+	})
+}
+
+// isStockTradingTime 判断股票所在市场当前是否可交易(含节假日、半日市和各市场交易时段)
+func isStockTradingTime(stockCode string, date time.Time) bool {
+	return calendar.IsOpen(data.GetStockMarket(stockCode), date)
 }
 
 func MonitorStockPrices(a *App) {
@@ -176,7 +271,16 @@ func MonitorStockPrices(a *App) {
 	//}
 
 	stockInfos := GetStockInfos(*dest...)
+	if data.GetConfig().QuoteSnapshotEnable {
+		go data.NewQuoteSnapshotApi().SaveSnapshots(*stockInfos)
+	}
+	go a.checkAlertRules(*stockInfos)
+	go a.checkLimitAlerts(*stockInfos)
+	go a.checkExitRules(*stockInfos)
 	for _, stockInfo := range *stockInfos {
+		if !isStockTradingTime(stockInfo.Code, time.Now()) {
+			continue
+		}
 		total += stockInfo.ProfitAmountToday
 		price, _ := convertor.ToFloat(stockInfo.Price)
 		if stockInfo.PrePrice != price {
@@ -195,6 +299,9 @@ func MonitorStockPrices(a *App) {
 func GetStockInfos(follows ...data.FollowedStock) *[]data.StockInfo {
 	stockCodes := make([]string, 0)
 	for _, follow := range follows {
+		if !isStockTradingTime(follow.StockCode, time.Now()) {
+			continue
+		}
 		stockCodes = append(stockCodes, follow.StockCode)
 	}
 	stockData, err := data.NewStockDataApi().GetStockCodeRealTimeData(stockCodes...)
@@ -228,43 +335,32 @@ func getStockInfo(follow data.FollowedStock) *data.StockInfo {
 func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	stockData.PrePrice = follow.Price //上次当前价格
 	stockData.Sort = follow.Sort
-	stockData.CostPrice = follow.CostPrice //成本价
-	stockData.CostVolume = follow.Volume   //成本量
+	//有交易流水时按流水计算持仓成本,否则使用手工填写的成本价和数量
+	costPrice, costVolume := follow.CostPrice, follow.Volume
+	if position := data.GetHoldingPosition(follow.StockCode); position != nil {
+		costPrice, costVolume = position.AvgCost, position.Quantity
+		stockData.RealizedProfit = mathutil.RoundToFloat(position.RealizedPnL, 2)
+	}
+	stockData.CostPrice = costPrice   //成本价
+	stockData.CostVolume = costVolume //成本量
 	stockData.AlarmChangePercent = follow.AlarmChangePercent
 	stockData.AlarmPrice = follow.AlarmPrice
 
-	//当前价格
-	price, _ := convertor.ToFloat(stockData.Price)
-	//当前价格为0 时 使用卖一价格作为当前价格
-	if price == 0 {
-		price, _ = convertor.ToFloat(stockData.A1P)
-	}
-	//当前价格依然为0 时 使用买一报价作为当前价格
-	if price == 0 {
-		price, _ = convertor.ToFloat(stockData.B1P)
+	quote, err := stockData.GetQuote()
+	if err != nil {
+		logger.SugaredLogger.Warnf("addStockFollowData %s", err.Error())
+		return
 	}
-
+	//当前价格(为0时依次使用卖一、买一、昨日收盘价)
+	price := quote.LastPrice().Float64()
 	//昨日收盘价
-	preClosePrice, _ := convertor.ToFloat(stockData.PreClose)
-
-	//当前价格依然为0 时 使用昨日收盘价为当前价格
-	if price == 0 {
-		price = preClosePrice
-	}
-
+	preClosePrice := quote.PreClose.Float64()
 	//今日最高价
-	highPrice, _ := convertor.ToFloat(stockData.High)
-	if highPrice == 0 {
-		highPrice, _ = convertor.ToFloat(stockData.Open)
-	}
-
+	highPrice := quote.DayHigh().Float64()
 	//今日最低价
-	lowPrice, _ := convertor.ToFloat(stockData.Low)
-	if lowPrice == 0 {
-		lowPrice, _ = convertor.ToFloat(stockData.Open)
-	}
-	//开盘价
-	//openPrice, _ := convertor.ToFloat(stockData.Open)
+	lowPrice := quote.DayLow().Float64()
+
+	data.NewPriceLimitApi().Fill(stockData, quote)
 
 	if price > 0 {
 		stockData.ChangePrice = mathutil.RoundToFloat(price-preClosePrice, 2)
@@ -276,15 +372,15 @@ func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	if lowPrice > 0 {
 		stockData.LowRate = mathutil.RoundToFloat(mathutil.Div(lowPrice-preClosePrice, preClosePrice)*100, 3)
 	}
-	if follow.CostPrice > 0 && follow.Volume > 0 {
+	if costPrice > 0 && costVolume > 0 {
 		if price > 0 {
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((price-follow.CostPrice)*float64(follow.Volume), 2)
-			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-costPrice, costPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((price-costPrice)*float64(costVolume), 2)
+			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(costVolume), 2)
 		} else {
 			//未开盘时当前价格为昨日收盘价
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-follow.CostPrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-costPrice, costPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-costPrice)*float64(costVolume), 2)
 			// 未开盘时，今日盈亏为 0
 			stockData.ProfitAmountToday = 0
 		}
@@ -384,30 +480,114 @@ func (a *App) SendDingDingMessage(message string, stockCode string) string {
 
 // SendDingDingMessageByType msgType 报警类型: 1 涨跌报警;2 股价报警 3 成本价报警
 func (a *App) SendDingDingMessageByType(message string, stockCode string, msgType int) string {
-	ttl, _ := a.cache.TTL([]byte(stockCode))
-	logger.SugaredLogger.Infof("stockCode %s ttl:%d", stockCode, ttl)
-	if ttl > 0 {
+	if !isStockTradingTime(stockCode, time.Now()) {
+		return "非" + calendar.MarketName(data.GetStockMarket(stockCode)) + "交易时间"
+	}
+	stockInfo := &data.StockInfo{}
+	db.Dao.Model(stockInfo).Where("code = ?", stockCode).First(stockInfo)
+	alert := data.NewStockAlertEvent(getMsgTypeName(msgType), stockInfo, GenNotificationMsg(stockInfo))
+	return a.deliverStockAlert(alert, time.Duration(getMsgTypeTTL(msgType))*time.Second, func(retried func(error)) string {
+		return data.NewDingDingAPI().OnRetried(retried).SendDingDingMessage(message)
+	})
+}
+
+// deliverStockAlert 经过暂停/去重/免打扰检查后推送股票报警,返回钉钉推送结果
+func (a *App) deliverStockAlert(alert *data.AlertEvent, cooldown time.Duration, dingding data.DingDingSender) string {
+	alerts := data.NewAlertEventApi()
+	if alerts.IsSnoozed(alert, time.Now()) {
 		return ""
 	}
-	err := a.cache.Set([]byte(stockCode), []byte("1"), getMsgTypeTTL(msgType))
-	if err != nil {
-		logger.SugaredLogger.Errorf("set cache error:%s", err.Error())
+	if err := data.NewAlertGuardApi().Allow(alert, cooldown, time.Now()); err != nil {
+		if errors.Is(err, data.ErrAlertMuted) {
+			alerts.Mute(alert)
+			return "免打扰时段"
+		}
 		return ""
 	}
-	stockInfo := &data.StockInfo{}
-	db.Dao.Model(stockInfo).Where("code = ?", stockCode).First(stockInfo)
-	go data.NewAlertWindowsApi("go-stock消息通知", getMsgTypeName(msgType), GenNotificationMsg(stockInfo), "").SendNotification()
-	return data.NewDingDingAPI().SendDingDingMessage(message)
+	go data.NewAlertWindowsApi("go-stock消息通知", alert.Title, alert.Content, "").SendNotification()
+	res := "钉钉推送未开启"
+	var send data.DingDingSender
+	if data.GetConfig().DingPushEnable {
+		send = func(retried func(error)) string {
+			res = dingding(retried)
+			return res
+		}
+	}
+	alerts.Deliver(a.ctx, alert, nil, send)
+	return res
+}
+
+// checkLimitAlerts A股涨停/跌停/炸板/接近涨跌停报警
+func (a *App) checkLimitAlerts(stockInfos []data.StockInfo) {
+	config := data.GetConfig()
+	if !config.LimitAlertEnable {
+		return
+	}
+	stockInfoMap := make(map[string]*data.StockInfo, len(stockInfos))
+	for i := range stockInfos {
+		stockInfoMap[stockInfos[i].Code] = &stockInfos[i]
+	}
+	for _, signal := range data.NewPriceLimitApi().Signals(stockInfos, config.LimitAlertTicks) {
+		if !isStockTradingTime(signal.Code, time.Now()) {
+			continue
+		}
+		alert := data.NewStockAlertEvent(signal.Type, stockInfoMap[signal.Code], signal.Message())
+		go a.deliverStockAlert(alert, 0, func(retried func(error)) string {
+			return data.NewDingDingAPI().OnRetried(retried).SendAlert(signal.Type, signal.Message(), signal.Code)
+		})
+		go runtime.EventsEmit(a.ctx, "limitAlert", signal)
+	}
+}
+
+// checkExitRules 持仓移动止损/分档止盈/时间止损报警
+func (a *App) checkExitRules(stockInfos []data.StockInfo) {
+	trading := make([]data.StockInfo, 0, len(stockInfos))
+	for _, stockInfo := range stockInfos {
+		if isStockTradingTime(stockInfo.Code, time.Now()) {
+			trading = append(trading, stockInfo)
+		}
+	}
+	if len(trading) == 0 {
+		return
+	}
+	stockInfoMap := make(map[string]*data.StockInfo, len(trading))
+	for i := range trading {
+		stockInfoMap[trading[i].Code] = &trading[i]
+	}
+	for _, signal := range data.NewExitRuleApi().Check(trading, time.Now()) {
+		stockInfo, ok := stockInfoMap[signal.StockCode]
+		if !ok {
+			stockInfo = &data.StockInfo{Code: signal.StockCode, Name: signal.StockName, Price: strconv.FormatFloat(signal.Price, 'f', -1, 64)}
+		}
+		alert := data.NewStockAlertEvent(signal.Type, stockInfo, signal.Message())
+		go a.deliverStockAlert(alert, 0, func(retried func(error)) string {
+			return data.NewDingDingAPI().OnRetried(retried).SendAlert(signal.Type, signal.Message(), signal.StockCode)
+		})
+		go runtime.EventsEmit(a.ctx, "exitAlert", signal)
+	}
 }
 
 func (a *App) NewChat(stock string) string {
 	return ""
 }
 
+// SendAIReportToDingDing 将AI分析结果推送到钉钉
+func (a *App) SendAIReportToDingDing(stockName, stockCode, report string) string {
+	if !data.GetConfig().DingPushEnable {
+		return "钉钉推送未开启"
+	}
+	return data.NewDingDingAPI().SendAIReport(stockName, stockCode, report)
+}
+
 func (a *App) NewChatStream(stock, stockCode, question string, sysPromptId *int) {
 	// macOS version implementation
 }
 
+// NewChatStreamWithModel 使用指定的模型配置分析股票
+func (a *App) NewChatStreamWithModel(stock, stockCode, question string, sysPromptId *int, aiModelId uint) {
+	// macOS version implementation
+}
+
 func GenNotificationMsg(stockInfo *data.StockInfo) string {
 	Price, err := convertor.ToFloat(stockInfo.Price)
 	if err != nil {
@@ -425,8 +605,21 @@ func GenNotificationMsg(stockInfo *data.StockInfo) string {
 	return "[" + stockInfo.Name + "] " + stockInfo.Price + " " + convertor.ToString(RF) + "% " + stockInfo.Date + " " + stockInfo.Time
 }
 
-// msgType : 1 涨跌报警(5分钟);2 股价报警(30分钟) 3 成本价报警(30分钟)
+// msgType : 1 涨跌报警(默认5分钟);2 股价报警(默认30分钟) 3 成本价报警(默认30分钟),可在设置中修改
 func getMsgTypeTTL(msgType int) int {
+	config := data.GetConfig()
+	minutes := 0
+	switch msgType {
+	case 1:
+		minutes = config.AlertChangeCooldown
+	case 2:
+		minutes = config.AlertPriceCooldown
+	case 3:
+		minutes = config.AlertCostCooldown
+	}
+	if minutes > 0 {
+		return 60 * minutes
+	}
 	switch msgType {
 	case 1:
 		return 60 * 5
@@ -557,7 +750,12 @@ func (a *App) GlobalStockIndexes() map[string]any {
 
 // SummaryStockNews 总结股票新闻
 func (a *App) SummaryStockNews(question string, sysPromptId *int) {
-	msgs := data.NewDeepSeekOpenAi(a.ctx).NewSummaryStockNewsStream(question, sysPromptId)
+	a.SummaryStockNewsWithModel(question, sysPromptId, 0)
+}
+
+// SummaryStockNewsWithModel 使用指定的模型配置总结股票新闻
+func (a *App) SummaryStockNewsWithModel(question string, sysPromptId *int, aiModelId uint) {
+	msgs := data.NewOpenAiWithProfile(a.ctx, aiModelId).NewSummaryStockNewsStream(question, sysPromptId)
 	for msg := range msgs {
 		runtime.EventsEmit(a.ctx, "summaryStockNews", msg)
 	}
@@ -572,14 +770,20 @@ func (a *App) GetPromptTemplates(name, promptType string) *[]models.PromptTempla
 // AddPrompt 添加提示模板
 func (a *App) AddPrompt(prompt models.Prompt) string {
 	promptTemplate := models.PromptTemplate{
-		ID:      prompt.ID,
-		Content: prompt.Content,
-		Name:    prompt.Name,
-		Type:    prompt.Type,
+		ID:              prompt.ID,
+		Content:         prompt.Content,
+		Name:            prompt.Name,
+		Type:            prompt.Type,
+		ContextSections: prompt.ContextSections,
 	}
 	return data.NewPromptTemplateApi().AddPrompt(promptTemplate)
 }
 
+// GetAiContextSections 获取提示模板可选择的AI分析数据
+func (a *App) GetAiContextSections() []data.ContextSection {
+	return data.ContextSections
+}
+
 // DelPrompt 删除提示模板
 func (a *App) DelPrompt(id uint) string {
 	return data.NewPromptTemplateApi().DelPrompt(id)
@@ -660,11 +864,135 @@ func (a *App) ShareAnalysis(stockCode, stockName string) string {
 	}
 }
 
-// GetAIResponseResult 获取AI响应结果
+// GetAIResponseResult 获取股票最近一次的AI对话
 func (a *App) GetAIResponseResult(stock string) *models.AIResponseResult {
 	return data.NewDeepSeekOpenAi(a.ctx).GetAIResponseResult(stock)
 }
 
+// ContinueChat 在已有AI对话上继续追问
+func (a *App) ContinueChat(conversationId uint, question string, aiModelId uint) {
+	msgs := data.NewOpenAiWithProfile(a.ctx, aiModelId).ContinueChat(conversationId, question)
+	//追问由 ContinueChat 保存到对话中,使用单独的事件,避免前端再次保存为新对话
+	for msg := range msgs {
+		runtime.EventsEmit(a.ctx, "continueChatStream", msg)
+	}
+	runtime.EventsEmit(a.ctx, "continueChatStream", "DONE")
+}
+
+// GetAiConversations 获取股票的AI对话列表
+func (a *App) GetAiConversations(stockCode string) []data.AiConversation {
+	return data.NewAiConversationApi().GetConversations(stockCode)
+}
+
+// GetAiConversationMessages 获取AI对话的消息
+func (a *App) GetAiConversationMessages(conversationId uint) []data.AiMessage {
+	return data.NewAiConversationApi().GetMessages(conversationId)
+}
+
+// RenameAiConversation 修改AI对话标题
+func (a *App) RenameAiConversation(conversationId uint, title string) string {
+	if err := data.NewAiConversationApi().RenameConversation(conversationId, title); err != nil {
+		return err.Error()
+	}
+	return "修改成功"
+}
+
+// DeleteAiConversation 删除AI对话
+func (a *App) DeleteAiConversation(conversationId uint) string {
+	if err := data.NewAiConversationApi().DeleteConversation(conversationId); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+// GetAiUsageStats 获取最近几天每天的AI用量和费用
+func (a *App) GetAiUsageStats(days int) []data.AiUsageStat {
+	if days <= 0 {
+		days = 30
+	}
+	return data.NewAiUsageApi().DailyStats(days, time.Now())
+}
+
+// GetAiBudgetStatus 获取今日和本月AI费用及预算
+func (a *App) GetAiBudgetStatus() data.AiBudgetStatus {
+	return data.NewAiUsageApi().BudgetStatus(data.GetConfig(), time.Now())
+}
+
+// GetAiRatings 按评级、模型、日期筛选自选股的AI结论,支持按置信度、目标价涨幅等排序
+func (a *App) GetAiRatings(query data.AiRatingQuery) []data.AiRating {
+	return data.NewAiRatingApi().Query(query)
+}
+
+// EvaluateAiRatings 立即评估AI评级的后续表现
+func (a *App) EvaluateAiRatings() string {
+	return fmt.Sprintf("评估完成,新增%d条", data.NewAiAccuracyApi().EvaluateRatings(time.Now()))
+}
+
+// GetAiAccuracy 按模型(model)/提示词(prompt)/股票(stock)统计AI评级命中率,days 为0时统计1/5/20个交易日
+func (a *App) GetAiAccuracy(groupBy string, days int) []data.AiAccuracyStat {
+	stats, err := data.NewAiAccuracyApi().Accuracy(groupBy, days)
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+	}
+	return stats
+}
+
+// emitAiBatchProgress 推送批量分析进度,任务结束时提示
+func (a *App) emitAiBatchProgress(progress data.AiBatchProgress) {
+	runtime.EventsEmit(a.ctx, "aiBatchProgress", progress)
+	if progress.Task == nil {
+		go runtime.EventsEmit(a.ctx, "warnMsg", fmt.Sprintf("批量分析结束:成功%d只,失败%d只", progress.Succeeded, progress.Failed))
+	}
+}
+
+// StartAiBatchJob 对分组中的每只股票运行AI分析,groupId 为0时分析全部自选股
+func (a *App) StartAiBatchJob(groupId int, promptTemplateId int, question string, aiModelId uint, concurrency int, maxRetries int) string {
+	api := data.NewAiBatchApi()
+	job, err := api.CreateJob(groupId, promptTemplateId, question, aiModelId, concurrency, maxRetries)
+	if err != nil {
+		return err.Error()
+	}
+	if err := api.Start(a.ctx, job.ID, a.emitAiBatchProgress); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("已开始批量分析%s,共%d只", job.GroupName, job.Total)
+}
+
+// CancelAiBatchJob 取消批量分析
+func (a *App) CancelAiBatchJob(jobId uint) string {
+	if err := data.NewAiBatchApi().Cancel(jobId); err != nil {
+		return err.Error()
+	}
+	return "已取消"
+}
+
+// GetAiBatchJobs 获取批量分析任务列表
+func (a *App) GetAiBatchJobs() []data.AiBatchJob {
+	return data.NewAiBatchApi().GetJobs()
+}
+
+// GetAiBatchJob 获取批量分析任务及对比报告
+func (a *App) GetAiBatchJob(jobId uint) *data.AiBatchJob {
+	job, err := data.NewAiBatchApi().GetJob(jobId)
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+	}
+	return job
+}
+
+// GetAiBatchTasks 获取批量分析中每只股票的结果
+func (a *App) GetAiBatchTasks(jobId uint) []data.AiBatchTask {
+	return data.NewAiBatchApi().GetTasks(jobId)
+}
+
+// DeleteAiBatchJob 删除批量分析任务
+func (a *App) DeleteAiBatchJob(jobId uint) string {
+	if err := data.NewAiBatchApi().DeleteJob(jobId); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
 // GetVersionInfo 获取版本信息
 func (a *App) GetVersionInfo() *models.VersionInfo {
 	return &models.VersionInfo{
@@ -688,7 +1016,7 @@ func (a *App) SaveAIResponseResult(stockCode, stockName, result, chatId, questio
 
 // GetStockKLine 获取股票K线数据
 func (a *App) GetStockKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetHK_KLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, data.KLineAdjustQfq, days)
 }
 
 // GetStockMinutePriceLineData 获取股票分钟价格数据
@@ -704,7 +1032,237 @@ func (a *App) GetStockMinutePriceLineData(stockCode, stockName string) map[strin
 
 // GetStockCommonKLine 获取股票通用K线数据
 func (a *App) GetStockCommonKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetCommonKLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, data.KLineAdjustQfq, days)
+}
+
+// GetStockIndicators 获取股票技术指标,indicators 为空时返回全部指标
+func (a *App) GetStockIndicators(stockCode, period string, indicators []string) *indicator.Result {
+	return data.NewKLineStoreApi().GetIndicators(stockCode, period, data.KLineAdjustQfq, data.GetConfig().KDays, indicators)
+}
+
+// AddTrade 添加交易流水
+func (a *App) AddTrade(trade data.Trade) string {
+	err := data.NewTradeApi().AddTrade(trade)
+	if err != nil {
+		return err.Error()
+	}
+	return "添加成功"
+}
+
+// DeleteTrade 删除交易流水
+func (a *App) DeleteTrade(id uint) string {
+	err := data.NewTradeApi().DeleteTrade(id)
+	if err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+// GetTrades 获取股票交易流水
+func (a *App) GetTrades(stockCode string) []data.Trade {
+	return data.NewTradeApi().GetTrades(stockCode, "")
+}
+
+// GetPositions 按账户获取股票持仓
+func (a *App) GetPositions(stockCode string) []*data.Position {
+	positions, err := data.NewTradeApi().GetPositions(stockCode, data.GetConfig().CostMethod)
+	if err != nil {
+		logger.SugaredLogger.Errorf("GetPositions %s:%s", stockCode, err.Error())
+		return []*data.Position{}
+	}
+	return positions
+}
+
+// TakePortfolioSnapshot 立即保存当日持仓快照
+func (a *App) TakePortfolioSnapshot() string {
+	_, err := data.NewPortfolioApi().TakeSnapshot(time.Now().Format(time.DateOnly))
+	if err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+// GetPortfolioSnapshots 获取每日持仓快照,account 为空表示全部账户,groupId 为0表示全部分组
+func (a *App) GetPortfolioSnapshots(account string, groupId int, startDate, endDate string) []data.PortfolioSnapshot {
+	return data.NewPortfolioApi().GetSnapshots(account, groupId, startDate, endDate)
+}
+
+// GetPortfolioPerformance 获取收益分析(累计收益/最大回撤/波动率/夏普比率/基准对比)
+func (a *App) GetPortfolioPerformance(account string, groupId int, startDate, endDate, benchmark string) *data.PortfolioPerformance {
+	performance, err := data.NewPortfolioApi().GetPerformance(account, groupId, startDate, endDate, benchmark)
+	if err != nil {
+		logger.SugaredLogger.Warnf("GetPortfolioPerformance %s", err.Error())
+		return &data.PortfolioPerformance{Points: []data.PerformancePoint{}}
+	}
+	return performance
+}
+
+// SaveAlertRule 新增或修改组合报警规则
+func (a *App) SaveAlertRule(rule data.AlertRule) string {
+	model := rule.ToModel()
+	if err := a.stockMonitor.SaveRule(a.ctx, model); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+// DeleteAlertRule 删除组合报警规则
+func (a *App) DeleteAlertRule(id uint) string {
+	if err := a.stockMonitor.DeleteRule(a.ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+// SetAlertRuleActive 启用或停用组合报警规则
+func (a *App) SetAlertRuleActive(id uint, active bool) string {
+	if err := a.stockMonitor.SetRuleActive(a.ctx, strconv.FormatUint(uint64(id), 10), active); err != nil {
+		return err.Error()
+	}
+	return "设置成功"
+}
+
+// GetAlertRules 获取组合报警规则,stockCode 为空时返回全部
+func (a *App) GetAlertRules(stockCode string) []data.AlertRule {
+	return data.NewAlertRuleApi().List(stockCode)
+}
+
+// GetAlertRuleFields 获取报警规则表达式可用的字段
+func (a *App) GetAlertRuleFields() []services.RuleField {
+	return services.RuleFields
+}
+
+// GetAiModelProviderTypes 获取支持的AI模型接口类型
+func (a *App) GetAiModelProviderTypes() []string {
+	return llm.Types
+}
+
+// GetAiModelProfiles 获取AI模型配置
+func (a *App) GetAiModelProfiles() []data.AiModelProfile {
+	return data.NewAiModelApi().GetProfiles()
+}
+
+// SaveAiModelProfile 保存AI模型配置
+func (a *App) SaveAiModelProfile(profile data.AiModelProfile) string {
+	if err := data.NewAiModelApi().SaveProfile(profile); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+// DeleteAiModelProfile 删除AI模型配置
+func (a *App) DeleteAiModelProfile(id uint) string {
+	if err := data.NewAiModelApi().DeleteProfile(id); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+// TestAiModelProfile 测试AI模型配置
+func (a *App) TestAiModelProfile(id uint) string {
+	reply, err := data.NewAiModelApi().TestProfile(id)
+	if err != nil {
+		return err.Error()
+	}
+	return reply
+}
+
+// SetStockAIModel 设置股票定时分析使用的AI模型
+func (a *App) SetStockAIModel(aiModelId uint, stockCode string) string {
+	data.NewStockDataApi().SetStockAIModel(aiModelId, stockCode)
+	return "设置成功"
+}
+
+// GetNotificationChannelTypes 获取支持的通知渠道类型
+func (a *App) GetNotificationChannelTypes() []string {
+	return notify.Types
+}
+
+// GetNotificationChannels 获取通知渠道配置
+func (a *App) GetNotificationChannels() []data.NotificationChannelSetting {
+	return data.NewNotificationApi().GetChannels()
+}
+
+// SaveNotificationChannel 新增或修改通知渠道
+func (a *App) SaveNotificationChannel(channel data.NotificationChannelSetting) string {
+	err := data.NewNotificationApi().SaveChannel(channel)
+	if err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+// DeleteNotificationChannel 删除通知渠道
+func (a *App) DeleteNotificationChannel(id uint) string {
+	err := data.NewNotificationApi().DeleteChannel(id)
+	if err != nil {
+		return err.Error()
+	}
+	return "删除成功"
+}
+
+// TestNotificationChannel 向通知渠道发送测试消息
+func (a *App) TestNotificationChannel(id uint) string {
+	err := data.NewNotificationApi().TestChannel(id)
+	if err != nil {
+		return "发送失败:" + err.Error()
+	}
+	return "发送成功"
+}
+
+// GetNotificationLogs 获取最近的通知发送记录
+func (a *App) GetNotificationLogs(limit int) []data.NotificationLog {
+	return data.NewNotificationApi().GetLogs(limit)
+}
+
+// GetAlertEvents 查询报警记录
+func (a *App) GetAlertEvents(query data.AlertEventQuery) []data.AlertEvent {
+	return data.NewAlertEventApi().List(query)
+}
+
+// AcknowledgeAlertEvents 确认报警
+func (a *App) AcknowledgeAlertEvents(ids []uint) string {
+	err := data.NewAlertEventApi().Acknowledge(ids)
+	if err != nil {
+		return err.Error()
+	}
+	return "确认成功"
+}
+
+// SnoozeAlertEvent 暂停提醒,minutes 为0时取消暂停
+func (a *App) SnoozeAlertEvent(id uint, minutes int) string {
+	err := data.NewAlertEventApi().Snooze(id, minutes)
+	if err != nil {
+		return err.Error()
+	}
+	return "设置成功"
+}
+
+// GetAlertDigest 获取指定日期未确认报警汇总
+func (a *App) GetAlertDigest(date string) string {
+	_, content, _ := data.NewAlertEventApi().Digest(date)
+	return content
+}
+
+// GetExitRules 获取持仓止盈止损规则
+func (a *App) GetExitRules() []data.ExitRule {
+	return data.NewExitRuleApi().GetRules()
+}
+
+// SaveExitRule 保存持仓止盈止损规则
+func (a *App) SaveExitRule(rule data.ExitRule) string {
+	if err := data.NewExitRuleApi().SaveRule(rule); err != nil {
+		return err.Error()
+	}
+	return "保存成功"
+}
+
+// DeleteExitRule 删除持仓止盈止损规则
+func (a *App) DeleteExitRule(id uint) string {
+	if err := data.NewExitRuleApi().DeleteRule(id); err != nil {
+		return err.Error()
+	}
+	return "删除成功"
 }
 
 // ExportConfig 导出配置
@@ -757,3 +1315,14 @@ func (a *App) CheckUpdate() {
 		go runtime.EventsEmit(a.ctx, "updateVersion", releaseVersion)
 	}
 }
+
+// GetQuoteSourceStatus 获取行情数据源健康状态
+func (a *App) GetQuoteSourceStatus() []data.QuoteSourceStatus {
+	return data.GetQuoteSourceStatus()
+}
+
+// GetIntradaySnapshots 获取盘中行情快照
+func (a *App) GetIntradaySnapshots(stockCode, from, to string) []data.QuoteSnapshot {
+	start, end := data.ParseSnapshotRange(from, to)
+	return data.NewQuoteSnapshotApi().GetIntradaySnapshots(stockCode, start, end)
+}
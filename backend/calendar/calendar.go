@@ -0,0 +1,332 @@
+package calendar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// @Author spark
+// @Date 2025/5/19 9:30
+// @Desc 交易日历:A股/港股/美股的节假日、半日市和交易时段
+// -----------------------------------------------------------------------------------
+
+const (
+	MarketCN = "cn"
+	MarketHK = "hk"
+	MarketUS = "us"
+)
+
+// Phase 交易时段
+type Phase string
+
+const (
+	PhaseClosed         Phase = "closed"          //休市
+	PhasePreMarket      Phase = "pre_market"      //盘前交易(美股)
+	PhaseAuction        Phase = "auction"         //开盘集合竞价
+	PhaseContinuous     Phase = "continuous"      //连续竞价
+	PhaseLunchBreak     Phase = "lunch_break"     //午间休市
+	PhaseClosingAuction Phase = "closing_auction" //收盘集合竞价
+	PhaseAfterHours     Phase = "after_hours"     //盘后交易(美股)
+)
+
+// Session 交易时段定义,Start/End 为交易所当地时间距零点的分钟数,左闭右开
+type Session struct {
+	Phase Phase
+	Start int
+	End   int
+}
+
+func hm(hour, minute int) int {
+	return hour*60 + minute
+}
+
+// Exchange 交易所
+type Exchange struct {
+	Market   string
+	Name     string
+	Location *time.Location
+	Sessions []Session //全天交易
+	HalfDay  []Session //半日市
+}
+
+var exchanges = map[string]*Exchange{
+	MarketCN: {
+		Market:   MarketCN,
+		Name:     "A股",
+		Location: loadLocation("Asia/Shanghai", 8),
+		Sessions: []Session{
+			{PhaseAuction, hm(9, 15), hm(9, 30)},
+			{PhaseContinuous, hm(9, 30), hm(11, 30)},
+			{PhaseLunchBreak, hm(11, 30), hm(13, 0)},
+			{PhaseContinuous, hm(13, 0), hm(14, 57)},
+			{PhaseClosingAuction, hm(14, 57), hm(15, 0)},
+		},
+	},
+	MarketHK: {
+		Market:   MarketHK,
+		Name:     "港股",
+		Location: loadLocation("Asia/Hong_Kong", 8),
+		Sessions: []Session{
+			{PhaseAuction, hm(9, 0), hm(9, 30)},
+			{PhaseContinuous, hm(9, 30), hm(12, 0)},
+			{PhaseLunchBreak, hm(12, 0), hm(13, 0)},
+			{PhaseContinuous, hm(13, 0), hm(16, 0)},
+			{PhaseClosingAuction, hm(16, 0), hm(16, 10)},
+		},
+		HalfDay: []Session{
+			{PhaseAuction, hm(9, 0), hm(9, 30)},
+			{PhaseContinuous, hm(9, 30), hm(12, 0)},
+			{PhaseClosingAuction, hm(12, 0), hm(12, 10)},
+		},
+	},
+	MarketUS: {
+		Market:   MarketUS,
+		Name:     "美股",
+		Location: loadLocation("America/New_York", -5),
+		Sessions: []Session{
+			{PhasePreMarket, hm(4, 0), hm(9, 30)},
+			{PhaseContinuous, hm(9, 30), hm(16, 0)},
+			{PhaseAfterHours, hm(16, 0), hm(20, 0)},
+		},
+		HalfDay: []Session{
+			{PhasePreMarket, hm(4, 0), hm(9, 30)},
+			{PhaseContinuous, hm(9, 30), hm(13, 0)},
+			{PhaseAfterHours, hm(13, 0), hm(17, 0)},
+		},
+	},
+}
+
+func loadLocation(name string, offset int) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone(name, offset*3600)
+	}
+	return location
+}
+
+// GetExchange 获取交易所定义,未知市场按A股处理
+func GetExchange(market string) *Exchange {
+	if exchange, ok := exchanges[market]; ok {
+		return exchange
+	}
+	return exchanges[MarketCN]
+}
+
+// MarketName 市场名称
+func MarketName(market string) string {
+	return GetExchange(market).Name
+}
+
+//go:embed holidays.json
+var embeddedHolidays []byte
+
+// holidayData 节假日数据,按市场区分
+type holidayData map[string]struct {
+	Holidays []string `json:"holidays"`
+	HalfDays []string `json:"halfDays"`
+}
+
+var holidays = struct {
+	sync.RWMutex
+	closed map[string]map[string]bool //market -> 日期 -> 休市
+	half   map[string]map[string]bool //market -> 日期 -> 半日市
+}{
+	closed: make(map[string]map[string]bool),
+	half:   make(map[string]map[string]bool),
+}
+
+func init() {
+	if err := Load(embeddedHolidays); err != nil {
+		panic(err)
+	}
+}
+
+// Load 加载节假日数据,数据中出现的年份整体替换已有的同年数据,其余年份保留
+func Load(content []byte) error {
+	data := holidayData{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	holidays.Lock()
+	defer holidays.Unlock()
+	for market, item := range data {
+		for _, day := range append(append([]string{}, item.Holidays...), item.HalfDays...) {
+			if _, err := time.Parse(time.DateOnly, day); err != nil {
+				return fmt.Errorf("%s 节假日日期格式错误:%s", market, day)
+			}
+		}
+		replaceYears(holidays.closed, market, item.Holidays, years(item.Holidays, item.HalfDays))
+		replaceYears(holidays.half, market, item.HalfDays, years(item.Holidays, item.HalfDays))
+	}
+	return nil
+}
+
+// LoadFile 从文件加载节假日数据,文件不存在时忽略
+func LoadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return Load(content)
+}
+
+func years(lists ...[]string) map[string]bool {
+	res := make(map[string]bool)
+	for _, list := range lists {
+		for _, day := range list {
+			res[day[:4]] = true
+		}
+	}
+	return res
+}
+
+func replaceYears(target map[string]map[string]bool, market string, days []string, years map[string]bool) {
+	if target[market] == nil {
+		target[market] = make(map[string]bool)
+	}
+	for day := range target[market] {
+		if years[day[:4]] {
+			delete(target[market], day)
+		}
+	}
+	for _, day := range days {
+		target[market][day] = true
+	}
+}
+
+// IsTradingDay 是否交易日(交易所当地日期)
+func IsTradingDay(market string, t time.Time) bool {
+	exchange := GetExchange(market)
+	local := t.In(exchange.Location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	holidays.RLock()
+	defer holidays.RUnlock()
+	return !holidays.closed[exchange.Market][local.Format(time.DateOnly)]
+}
+
+// IsHalfDay 是否半日市
+func IsHalfDay(market string, t time.Time) bool {
+	exchange := GetExchange(market)
+	holidays.RLock()
+	defer holidays.RUnlock()
+	return holidays.half[exchange.Market][t.In(exchange.Location).Format(time.DateOnly)]
+}
+
+// sessions 某个交易所当地日期的交易时段,非交易日返回 nil
+func sessions(exchange *Exchange, day time.Time) []Session {
+	if !IsTradingDay(exchange.Market, day) {
+		return nil
+	}
+	if exchange.HalfDay != nil && IsHalfDay(exchange.Market, day) {
+		return exchange.HalfDay
+	}
+	return exchange.Sessions
+}
+
+// at 交易所当地日期 day 的第 minutes 分钟
+func at(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, day.Location())
+}
+
+// PhaseAt 某一时刻所处的交易时段
+func PhaseAt(market string, t time.Time) Phase {
+	exchange := GetExchange(market)
+	local := t.In(exchange.Location)
+	for _, session := range sessions(exchange, local) {
+		if !local.Before(at(local, session.Start)) && local.Before(at(local, session.End)) {
+			return session.Phase
+		}
+	}
+	return PhaseClosed
+}
+
+// IsOpen 是否可交易(含集合竞价和美股盘前盘后,不含午间休市)
+func IsOpen(market string, t time.Time) bool {
+	return PhaseAt(market, t).IsOpen()
+}
+
+// IsOpen 该时段是否可交易
+func (p Phase) IsOpen() bool {
+	return p != PhaseClosed && p != PhaseLunchBreak
+}
+
+// 向后查找交易日的最大天数,覆盖最长的节假日
+const maxSearchDays = 30
+
+// NextOpen t 时刻或之后最近的可交易时间,t 时已开市时返回 t
+func NextOpen(market string, t time.Time) time.Time {
+	exchange := GetExchange(market)
+	local := t.In(exchange.Location)
+	for i := 0; i <= maxSearchDays; i++ {
+		day := local.AddDate(0, 0, i)
+		for _, session := range sessions(exchange, day) {
+			if !session.Phase.IsOpen() {
+				continue
+			}
+			start, end := at(day, session.Start), at(day, session.End)
+			if end.After(local) {
+				if start.After(local) {
+					return start
+				}
+				return local
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// NextClose t 时刻所在(未开市时为下一个)连续交易区间的结束时间
+func NextClose(market string, t time.Time) time.Time {
+	exchange := GetExchange(market)
+	open := NextOpen(market, t)
+	if open.IsZero() {
+		return open
+	}
+	open = open.In(exchange.Location)
+	var closeAt time.Time
+	for _, session := range sessions(exchange, open) {
+		start, end := at(open, session.Start), at(open, session.End)
+		if !end.After(open) {
+			continue
+		}
+		if !session.Phase.IsOpen() || (!closeAt.IsZero() && start.After(closeAt)) {
+			if !closeAt.IsZero() {
+				break
+			}
+			continue
+		}
+		closeAt = end
+	}
+	return closeAt
+}
+
+// SessionProgress 当日连续竞价时段已经过的比例(0~1),用于计算量比,非交易日返回0
+func SessionProgress(market string, t time.Time) float64 {
+	exchange := GetExchange(market)
+	local := t.In(exchange.Location)
+	var total, elapsed time.Duration
+	for _, session := range sessions(exchange, local) {
+		if session.Phase != PhaseContinuous {
+			continue
+		}
+		start, end := at(local, session.Start), at(local, session.End)
+		total += end.Sub(start)
+		if local.After(start) {
+			elapsed += min(local.Sub(start), end.Sub(start))
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(elapsed) / float64(total)
+}
@@ -0,0 +1,80 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cst(value string) time.Time {
+	t, _ := time.ParseInLocation(time.DateTime, value, GetExchange(MarketCN).Location)
+	return t
+}
+
+func TestCNSessions(t *testing.T) {
+	assert.Equal(t, PhaseAuction, PhaseAt(MarketCN, cst("2025-05-12 09:20:00")))
+	assert.Equal(t, PhaseContinuous, PhaseAt(MarketCN, cst("2025-05-12 10:00:00")))
+	assert.Equal(t, PhaseLunchBreak, PhaseAt(MarketCN, cst("2025-05-12 12:00:00")))
+	assert.Equal(t, PhaseClosingAuction, PhaseAt(MarketCN, cst("2025-05-12 14:58:00")))
+	assert.False(t, IsOpen(MarketCN, cst("2025-05-12 15:00:00")))
+	// 节假日和周末休市
+	assert.False(t, IsOpen(MarketCN, cst("2025-10-08 10:00:00")))
+	assert.False(t, IsTradingDay(MarketCN, cst("2025-05-10 10:00:00")))
+	// 按北京时间判断,与本机时区无关
+	assert.True(t, IsOpen(MarketCN, cst("2025-05-12 10:00:00").In(time.UTC)))
+}
+
+func TestHKSessions(t *testing.T) {
+	// 港股上午收市后到下午开市前休市
+	assert.False(t, IsOpen(MarketHK, cst("2025-05-12 12:30:00")))
+	assert.True(t, IsOpen(MarketHK, cst("2025-05-12 09:10:00")))
+	assert.False(t, IsOpen(MarketHK, cst("2025-05-12 08:50:00")))
+	assert.False(t, IsOpen(MarketHK, cst("2025-05-12 16:20:00")))
+	// 半日市
+	assert.True(t, IsHalfDay(MarketHK, cst("2025-12-24 10:00:00")))
+	assert.False(t, IsOpen(MarketHK, cst("2025-12-24 13:30:00")))
+}
+
+func TestUSSessions(t *testing.T) {
+	// 北京时间 2025-05-12 22:00 为纽约时间 10:00(夏令时)
+	assert.Equal(t, PhaseContinuous, PhaseAt(MarketUS, cst("2025-05-12 22:00:00")))
+	assert.Equal(t, PhasePreMarket, PhaseAt(MarketUS, cst("2025-05-12 17:00:00")))
+	assert.Equal(t, PhaseAfterHours, PhaseAt(MarketUS, cst("2025-05-13 05:00:00")))
+	assert.False(t, IsOpen(MarketUS, cst("2025-07-04 22:00:00")))
+	assert.Equal(t, PhaseAfterHours, PhaseAt(MarketUS, cst("2025-11-29 03:00:00")))
+}
+
+func TestNextOpenAndClose(t *testing.T) {
+	now := cst("2025-05-12 10:00:00")
+	assert.Equal(t, now, NextOpen(MarketCN, now))
+	assert.Equal(t, cst("2025-05-12 11:30:00"), NextClose(MarketCN, now))
+	assert.Equal(t, cst("2025-05-12 13:00:00"), NextOpen(MarketCN, cst("2025-05-12 12:00:00")))
+	assert.Equal(t, cst("2025-05-12 15:00:00"), NextClose(MarketCN, cst("2025-05-12 12:00:00")))
+	// 国庆长假后的第一个交易日
+	assert.True(t, cst("2025-10-09 09:15:00").Equal(NextOpen(MarketCN, cst("2025-09-30 15:30:00"))))
+	// 周五收盘后为下周一纽约时间 4:00
+	next := NextOpen(MarketUS, cst("2025-05-17 09:00:00"))
+	assert.Equal(t, "2025-05-19 04:00", next.Format("2006-01-02 15:04"))
+}
+
+func TestLoad(t *testing.T) {
+	assert.NoError(t, Load([]byte(`{"cn":{"holidays":["2025-05-12"]}}`)))
+	assert.False(t, IsTradingDay(MarketCN, cst("2025-05-12 10:00:00")))
+	// 同年份整体替换
+	assert.True(t, IsTradingDay(MarketCN, cst("2025-10-08 10:00:00")))
+	// 其余年份保留
+	assert.False(t, IsTradingDay(MarketCN, cst("2026-10-07 10:00:00")))
+	assert.Error(t, Load([]byte(`{"cn":{"holidays":["2025/05/12"]}}`)))
+	assert.NoError(t, Load(embeddedHolidays))
+	assert.True(t, IsTradingDay(MarketCN, cst("2025-05-12 10:00:00")))
+}
+
+func TestSessionProgress(t *testing.T) {
+	// A股连续竞价共237分钟(收盘前3分钟为集合竞价)
+	assert.Equal(t, 0.0, SessionProgress(MarketCN, cst("2025-05-12 09:20:00")))
+	assert.InDelta(t, 60.0/237, SessionProgress(MarketCN, cst("2025-05-12 10:30:00")), 1e-9)
+	assert.InDelta(t, 120.0/237, SessionProgress(MarketCN, cst("2025-05-12 12:00:00")), 1e-9)
+	assert.Equal(t, 1.0, SessionProgress(MarketCN, cst("2025-05-12 15:30:00")))
+	assert.Equal(t, 0.0, SessionProgress(MarketCN, cst("2025-05-10 10:30:00")))
+}
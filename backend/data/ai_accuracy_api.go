@@ -0,0 +1,283 @@
+package data
+
+import (
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"go-stock/backend/models"
+	"math"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/6/22 16:40
+// @Desc AI评级准确率:按本地前复权K线计算分析后1/5/20个交易日的收益,按模型、提示词、股票统计命中率
+// -----------------------------------------------------------------------------------
+
+// EvaluationDays 评估的交易日数
+var EvaluationDays = []int{1, 5, 20}
+
+// neutralReturnBand 持有评级在该涨跌幅(%)以内视为命中
+const neutralReturnBand = 3.0
+
+// maxEvaluationDepth 评估时最多同步的K线数量
+const maxEvaluationDepth = 1000
+
+// 准确率分组方式
+const (
+	AccuracyByModel  = "model"
+	AccuracyByPrompt = "prompt"
+	AccuracyByStock  = "stock"
+)
+
+// AiRatingEvaluation 一条评级在某个交易日数后的表现,按 评级/交易日数 唯一
+type AiRatingEvaluation struct {
+	gorm.Model
+	RatingId         uint    `json:"ratingId" gorm:"uniqueIndex:idx_ai_rating_evaluation_key,priority:1"`
+	Days             int     `json:"days" gorm:"uniqueIndex:idx_ai_rating_evaluation_key,priority:2"`
+	StockCode        string  `json:"stockCode" gorm:"index"`
+	StockName        string  `json:"stockName"`
+	ModelName        string  `json:"modelName"`
+	PromptTemplateId int     `json:"promptTemplateId"`
+	Rating           string  `json:"rating"`
+	Direction        int     `json:"direction"` //1看多 -1看空 0中性
+	BasePrice        float64 `json:"basePrice"` //前复权价格,EndPrice、TargetPrice 同
+	EndDay           string  `json:"endDay"`
+	EndPrice         float64 `json:"endPrice"`
+	ForwardReturn    float64 `json:"forwardReturn"` //涨跌幅(%)
+	Hit              bool    `json:"hit"`
+	TargetPrice      float64 `json:"targetPrice"`
+	TargetHit        bool    `json:"targetHit"` //期间最高/最低价触及目标价
+}
+
+func (AiRatingEvaluation) TableName() string {
+	return "ai_rating_evaluation"
+}
+
+// AiAccuracyStat 一个分组在某个交易日数上的准确率
+type AiAccuracyStat struct {
+	Key           string  `json:"key"`
+	Name          string  `json:"name"`
+	Days          int     `json:"days"`
+	Count         int     `json:"count"`
+	Hits          int     `json:"hits"`
+	HitRate       float64 `json:"hitRate"`       //命中率(%)
+	AvgReturn     float64 `json:"avgReturn"`     //平均涨跌幅(%)
+	AvgCallReturn float64 `json:"avgCallReturn"` //按看多/看空方向操作的平均收益(%),不含中性评级
+	TargetCount   int     `json:"targetCount"`
+	TargetHits    int     `json:"targetHits"`
+}
+
+// RatingDirection 评级对应的方向
+func RatingDirection(rating string) int {
+	switch rating {
+	case RatingStrongBuy, RatingBuy:
+		return 1
+	case RatingStrongSell, RatingSell:
+		return -1
+	}
+	return 0
+}
+
+// EvaluateRating 按分析日之后的K线计算第 days 个交易日的表现,K线不足时返回 nil。
+// K 为分析日之后的日K线,按日期升序
+func EvaluateRating(rating AiRating, basePrice float64, K []KLineData, days int) *AiRatingEvaluation {
+	if basePrice <= 0 || len(K) < days {
+		return nil
+	}
+	end, _ := convertor.ToFloat(K[days-1].Close)
+	if end <= 0 {
+		return nil
+	}
+	evaluation := &AiRatingEvaluation{
+		RatingId:         rating.ID,
+		Days:             days,
+		StockCode:        rating.StockCode,
+		StockName:        rating.StockName,
+		ModelName:        rating.ModelName,
+		PromptTemplateId: rating.PromptTemplateId,
+		Rating:           rating.Rating,
+		Direction:        RatingDirection(rating.Rating),
+		BasePrice:        basePrice,
+		EndDay:           K[days-1].Day,
+		EndPrice:         end,
+		ForwardReturn:    (end/basePrice - 1) * 100,
+		TargetPrice:      rating.TargetPrice,
+	}
+	switch evaluation.Direction {
+	case 0:
+		evaluation.Hit = math.Abs(evaluation.ForwardReturn) <= neutralReturnBand
+	default:
+		evaluation.Hit = evaluation.ForwardReturn*float64(evaluation.Direction) > 0
+	}
+	if rating.TargetPrice > 0 {
+		for _, bar := range K[:days] {
+			high, _ := convertor.ToFloat(bar.High)
+			low, _ := convertor.ToFloat(bar.Low)
+			if (rating.TargetPrice >= basePrice && high >= rating.TargetPrice) || (rating.TargetPrice < basePrice && low > 0 && low <= rating.TargetPrice) {
+				evaluation.TargetHit = true
+				break
+			}
+		}
+	}
+	return evaluation
+}
+
+type AiAccuracyApi struct {
+	dao    *gorm.DB
+	kLines *KLineStoreApi
+}
+
+func NewAiAccuracyApi() *AiAccuracyApi {
+	return &AiAccuracyApi{dao: db.Dao, kLines: NewKLineStoreApi()}
+}
+
+// pendingRatings 还有未评估交易日数的评级
+func (a AiAccuracyApi) pendingRatings() []AiRating {
+	var ratings []AiRating
+	a.dao.Where("(select count(*) from ai_rating_evaluation e where e.rating_id = ai_rating.id and e.deleted_at is null) < ?", len(EvaluationDays)).
+		Order("stock_code, id").Find(&ratings)
+	return ratings
+}
+
+// barsFrom 本地前复权日K线中 day 及之后的 n 根,K线日期可能带有时间
+func (a AiAccuracyApi) barsFrom(code, day string, n int) []KLineData {
+	var rows []StockKLine
+	a.dao.Where("code = ? and period = ? and adjust = ? and day >= ?", code, KLinePeriodDay, KLineAdjustQfq, day).
+		Order("day asc").Limit(n).Find(&rows)
+	K := make([]KLineData, 0, len(rows))
+	for _, row := range rows {
+		K = append(K, KLineData{Day: row.Day, Open: row.Open, High: row.High, Low: row.Low, Close: row.Close, Volume: row.Volume})
+	}
+	return K
+}
+
+// lastClose 本地日K线中 nextDay 之前最近的收盘价,即分析日收盘价
+func (a AiAccuracyApi) lastClose(code, adjust, nextDay string) float64 {
+	row := StockKLine{}
+	a.dao.Where("code = ? and period = ? and adjust = ? and day < ?", code, KLinePeriodDay, adjust, nextDay).
+		Order("day desc").Limit(1).Find(&row)
+	price, _ := convertor.ToFloat(row.Close)
+	return price
+}
+
+// basePrice 分析时的价格换算到前复权价格,返回基准价和分析日的复权因子(前复权收盘价/不复权收盘价)。
+// 行情价格为不复权价格,分析日之后除权除息时须按复权因子换算,才能与前复权K线比较。
+// 没有记录分析时的价格,或没有不复权K线(港美股)无法换算时,使用分析日前复权收盘价
+func (a AiAccuracyApi) basePrice(rating AiRating, nextDay string) (float64, float64) {
+	adjusted := a.lastClose(rating.StockCode, KLineAdjustQfq, nextDay)
+	if adjusted <= 0 || defaultKLineAdjust(rating.StockCode) != KLineAdjustNone {
+		return adjusted, 1
+	}
+	raw := a.lastClose(rating.StockCode, KLineAdjustNone, nextDay)
+	if raw <= 0 {
+		return adjusted, 1
+	}
+	factor := adjusted / raw
+	if rating.Price <= 0 {
+		return adjusted, factor
+	}
+	return rating.Price * factor, factor
+}
+
+// EvaluateRatings 评估所有未完成的评级,先同步本地K线覆盖最早的分析日,返回新增的评估条数。
+// 收益按前复权K线计算,A股同时同步不复权K线用于换算分析时的价格
+func (a AiAccuracyApi) EvaluateRatings(now time.Time) int {
+	maxDays := EvaluationDays[len(EvaluationDays)-1]
+	byStock := make(map[string][]AiRating)
+	codes := make([]string, 0)
+	for _, rating := range a.pendingRatings() {
+		if _, ok := byStock[rating.StockCode]; !ok {
+			codes = append(codes, rating.StockCode)
+		}
+		byStock[rating.StockCode] = append(byStock[rating.StockCode], rating)
+	}
+	count := 0
+	for _, code := range codes {
+		ratings := byStock[code]
+		//自然日数不少于交易日数,多取一些保证覆盖分析日
+		depth := min(int64(now.Sub(ratings[0].CreatedAt).Hours()/24)+int64(maxDays)+10, maxEvaluationDepth)
+		a.kLines.GetKLine(code, KLinePeriodDay, KLineAdjustQfq, depth)
+		if defaultKLineAdjust(code) == KLineAdjustNone {
+			a.kLines.GetKLine(code, KLinePeriodDay, KLineAdjustNone, depth)
+		}
+
+		var evaluations []AiRatingEvaluation
+		for _, rating := range ratings {
+			created := rating.CreatedAt.In(now.Location())
+			nextDay := time.Date(created.Year(), created.Month(), created.Day()+1, 0, 0, 0, 0, now.Location()).Format(time.DateOnly)
+			K := a.barsFrom(code, nextDay, maxDays)
+			base, factor := a.basePrice(rating, nextDay)
+			//目标价同样换算为前复权价格
+			rating.TargetPrice *= factor
+			for _, days := range EvaluationDays {
+				if evaluation := EvaluateRating(rating, base, K, days); evaluation != nil {
+					evaluations = append(evaluations, *evaluation)
+				}
+			}
+		}
+		if len(evaluations) == 0 {
+			continue
+		}
+		result := a.dao.Clauses(clause.OnConflict{DoNothing: true}).Create(&evaluations)
+		if result.Error != nil {
+			logger.SugaredLogger.Errorf("保存AI评级评估失败 code:%s %s", code, result.Error.Error())
+			continue
+		}
+		count += int(result.RowsAffected)
+	}
+	logger.SugaredLogger.Infof("AI评级评估完成,新增%d条", count)
+	return count
+}
+
+// Accuracy 按模型/提示词/股票统计命中率,days 为0时统计所有交易日数
+func (a AiAccuracyApi) Accuracy(groupBy string, days int) ([]AiAccuracyStat, error) {
+	column := map[string]string{
+		AccuracyByModel:  "model_name",
+		AccuracyByPrompt: "prompt_template_id",
+		AccuracyByStock:  "stock_code",
+	}[groupBy]
+	if column == "" {
+		return nil, fmt.Errorf("不支持的分组方式:%s", groupBy)
+	}
+	tx := a.dao.Model(&AiRatingEvaluation{}).
+		Select(column + " as `key`, max(stock_name) as name, days, count(*) as count, " +
+			"sum(case when hit then 1 else 0 end) as hits, avg(forward_return) as avg_return, " +
+			"coalesce(avg(case when direction != 0 then forward_return * direction end),0) as avg_call_return, " +
+			"sum(case when target_price > 0 then 1 else 0 end) as target_count, " +
+			"sum(case when target_hit then 1 else 0 end) as target_hits").
+		Group(column + ", days").Order(column + ", days")
+	if days > 0 {
+		tx = tx.Where("days = ?", days)
+	}
+	var stats []AiAccuracyStat
+	if err := tx.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	prompts := make(map[string]string)
+	if groupBy == AccuracyByPrompt {
+		var templates []models.PromptTemplate
+		a.dao.Find(&templates)
+		for _, template := range templates {
+			prompts[convertor.ToString(template.ID)] = template.Name
+		}
+	}
+	for i := range stats {
+		stat := &stats[i]
+		stat.HitRate = float64(stat.Hits) * 100 / float64(stat.Count)
+		switch groupBy {
+		case AccuracyByModel:
+			stat.Name = stat.Key
+		case AccuracyByPrompt:
+			stat.Name = prompts[stat.Key]
+			if stat.Key == "0" {
+				stat.Name = "默认提示词"
+			}
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateRating(t *testing.T) {
+	K := make([]KLineData, 0)
+	for i, close := range []string{"10.2", "10.4", "9.8", "10.6", "11"} {
+		K = append(K, KLineData{Day: fmt.Sprintf("2025-06-%02d", 10+i), High: "11.2", Low: "9.6", Close: close})
+	}
+	rating := AiRating{Rating: RatingBuy, TargetPrice: 11.1}
+	evaluation := EvaluateRating(rating, 10, K, 5)
+	assert.Equal(t, 1, evaluation.Direction)
+	assert.Equal(t, "2025-06-14", evaluation.EndDay)
+	assert.InDelta(t, 10.0, evaluation.ForwardReturn, 1e-9)
+	assert.True(t, evaluation.Hit)
+	assert.True(t, evaluation.TargetHit)
+
+	//看空
+	rating = AiRating{Rating: RatingSell, TargetPrice: 9}
+	evaluation = EvaluateRating(rating, 10, K, 3)
+	assert.True(t, evaluation.Hit)
+	assert.False(t, evaluation.TargetHit)
+	assert.False(t, EvaluateRating(rating, 10, K, 1).Hit)
+
+	//中性评级按波动幅度判断
+	assert.True(t, EvaluateRating(AiRating{Rating: RatingHold}, 10, K, 1).Hit)
+	assert.False(t, EvaluateRating(AiRating{Rating: RatingHold}, 10, K, 5).Hit)
+
+	//K线不足或没有价格
+	assert.Nil(t, EvaluateRating(rating, 10, K, 20))
+	assert.Nil(t, EvaluateRating(rating, 0, K, 1))
+}
+
+func TestEvaluateRatings(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiRating{}, &AiRatingEvaluation{}, &StockKLine{}, &StockKLineSync{}, &models.PromptTemplate{})
+	db.Dao.Create(&models.PromptTemplate{Name: "技术分析", ID: 3})
+
+	now := time.Now()
+	start := now.AddDate(0, 0, -40)
+	bars := make([]KLineData, 0)
+	for i := 0; i <= 40; i++ {
+		day := start.AddDate(0, 0, i)
+		close := fmt.Sprintf("%.2f", 10+float64(i)*0.1)
+		bars = append(bars, KLineData{Day: day.Format(time.DateOnly) + " 15:00:00", Open: close, High: close, Low: close, Close: close})
+	}
+	var requested []int64
+	api := AiAccuracyApi{dao: db.Dao, kLines: &KLineStoreApi{
+		dao: db.Dao,
+		fetch: func(ctx context.Context, code, period, adjust string) KLineFetcher {
+			return func(code string, n int64) *[]KLineData {
+				requested = append(requested, n)
+				return &bars
+			}
+		},
+	}}
+	ratings := []*AiRating{
+		{StockCode: "sh600000", StockName: "浦发银行", ModelName: "a", PromptTemplateId: 3, Rating: RatingBuy, Price: 10},
+		{StockCode: "sh600000", StockName: "浦发银行", ModelName: "b", Rating: RatingSell},
+		{StockCode: "sh600000", StockName: "浦发银行", ModelName: "a", Rating: RatingBuy, Price: 13},
+	}
+	for i, rating := range ratings {
+		db.Dao.Create(rating)
+		created := []time.Time{start, start, now.AddDate(0, 0, -3)}[i]
+		db.Dao.Model(rating).Update("created_at", created)
+	}
+
+	//最近的评级只有1日和部分5日表现
+	assert.Equal(t, 3+3+1, api.EvaluateRatings(now))
+	//A股同步前复权和不复权K线
+	assert.Len(t, requested, 2)
+	assert.GreaterOrEqual(t, requested[0], int64(40))
+
+	var evaluations []AiRatingEvaluation
+	db.Dao.Where("rating_id = ?", ratings[0].ID).Order("days").Find(&evaluations)
+	assert.Len(t, evaluations, 3)
+	//以分析时的价格为基准,第1个交易日为分析日的下一根K线
+	assert.InDelta(t, 1.0, evaluations[0].ForwardReturn, 1e-9)
+	assert.InDelta(t, 20.0, evaluations[2].ForwardReturn, 1e-9)
+	//没有分析时的价格使用分析日收盘价
+	evaluation := AiRatingEvaluation{}
+	db.Dao.Where("rating_id = ? and days = 1", ratings[1].ID).First(&evaluation)
+	assert.Equal(t, 10.0, evaluation.BasePrice)
+	assert.False(t, evaluation.Hit)
+
+	//已评估的不重复保存
+	assert.Equal(t, 0, api.EvaluateRatings(now))
+
+	stats, err := api.Accuracy(AccuracyByModel, 1)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "a", stats[0].Name)
+	assert.Equal(t, 2, stats[0].Count)
+	assert.Equal(t, 100.0, stats[0].HitRate)
+	assert.Equal(t, 0.0, stats[1].HitRate)
+	assert.InDelta(t, -1.0, stats[1].AvgCallReturn, 1e-9)
+
+	stats, _ = api.Accuracy(AccuracyByPrompt, 0)
+	assert.Equal(t, "默认提示词", stats[0].Name)
+	assert.Equal(t, "技术分析", stats[len(stats)-1].Name)
+
+	stats, _ = api.Accuracy(AccuracyByStock, 20)
+	assert.Equal(t, []AiAccuracyStat{{Key: "sh600000", Name: "浦发银行", Days: 20, Count: 2, Hits: 1, HitRate: 50, AvgReturn: stats[0].AvgReturn, AvgCallReturn: stats[0].AvgCallReturn}}, stats)
+
+	_, err = api.Accuracy("day", 1)
+	assert.Error(t, err)
+}
+
+func TestEvaluateRatingsAdjusted(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiRating{}, &AiRatingEvaluation{}, &StockKLine{}, &StockKLineSync{})
+
+	//第5天除息1元,股价未变动
+	now := time.Now()
+	start := now.AddDate(0, 0, -9)
+	raw, qfq := make([]KLineData, 0), make([]KLineData, 0)
+	for i := 0; i <= 9; i++ {
+		day := start.AddDate(0, 0, i).Format(time.DateOnly)
+		close, adjusted := "10", "9"
+		if i >= 5 {
+			close = "9"
+		}
+		raw = append(raw, KLineData{Day: day, Open: close, High: close, Low: close, Close: close})
+		qfq = append(qfq, KLineData{Day: day, Open: adjusted, High: adjusted, Low: adjusted, Close: adjusted})
+	}
+	api := AiAccuracyApi{dao: db.Dao, kLines: &KLineStoreApi{
+		dao: db.Dao,
+		fetch: func(ctx context.Context, code, period, adjust string) KLineFetcher {
+			return func(code string, n int64) *[]KLineData {
+				if adjust == KLineAdjustQfq {
+					return &qfq
+				}
+				return &raw
+			}
+		},
+	}}
+	rating := &AiRating{StockCode: "sh600000", ModelName: "a", Rating: RatingBuy, Price: 10, TargetPrice: 10}
+	db.Dao.Create(rating)
+	db.Dao.Model(rating).Update("created_at", start)
+
+	assert.Equal(t, 2, api.EvaluateRatings(now))
+	evaluation := AiRatingEvaluation{}
+	db.Dao.Where("rating_id = ? and days = 5", rating.ID).First(&evaluation)
+	//分析时的价格按复权因子0.9换算,除息不计为下跌
+	assert.InDelta(t, 9.0, evaluation.BasePrice, 1e-9)
+	assert.InDelta(t, 0.0, evaluation.ForwardReturn, 1e-9)
+	assert.InDelta(t, 9.0, evaluation.TargetPrice, 1e-9)
+	assert.True(t, evaluation.TargetHit)
+}
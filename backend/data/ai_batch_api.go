@@ -0,0 +1,550 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/6/25 09:20
+// @Desc 分组批量AI分析:任务持久化,可配置并发、按接口限速、失败重试、取消,完成后生成对比报告
+// -----------------------------------------------------------------------------------
+
+// AiTriggerBatch 批量分析
+const AiTriggerBatch = "batch"
+
+// 批量任务及其中每只股票的状态
+const (
+	BatchStatusPending   = "pending"
+	BatchStatusRunning   = "running"
+	BatchStatusDone      = "done"
+	BatchStatusFailed    = "failed"
+	BatchStatusCancelled = "cancelled"
+)
+
+const (
+	defaultBatchConcurrency = 2
+	maxBatchConcurrency     = 8
+	defaultBatchRetries     = 2
+)
+
+// batchRetryDelay 第 n 次重试前的等待时间
+var batchRetryDelay = func(attempt int) time.Duration {
+	return time.Duration(attempt) * 10 * time.Second
+}
+
+// AiBatchJob 一次分组批量分析
+type AiBatchJob struct {
+	gorm.Model
+	GroupId          int        `json:"groupId"`
+	GroupName        string     `json:"groupName"`
+	PromptTemplateId int        `json:"promptTemplateId"`
+	Question         string     `json:"question"` //为空时使用设置中的问题模板
+	AiModelId        uint       `json:"aiModelId"`
+	ModelName        string     `json:"modelName"`
+	Concurrency      int        `json:"concurrency"`
+	MaxRetries       int        `json:"maxRetries"`
+	Status           string     `json:"status" gorm:"index"`
+	Total            int        `json:"total"`
+	Succeeded        int        `json:"succeeded"`
+	Failed           int        `json:"failed"`
+	Report           string     `json:"report"` //markdown对比报告
+	StartedAt        *time.Time `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt"`
+}
+
+func (AiBatchJob) TableName() string {
+	return "ai_batch_job"
+}
+
+// AiBatchTask 批量分析中的一只股票
+type AiBatchTask struct {
+	gorm.Model
+	JobId          uint   `json:"jobId" gorm:"index"`
+	StockCode      string `json:"stockCode"`
+	StockName      string `json:"stockName"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	Error          string `json:"error"`
+	Content        string `json:"content"`
+	ChatId         string `json:"chatId"`
+	ConversationId uint   `json:"conversationId"`
+}
+
+func (AiBatchTask) TableName() string {
+	return "ai_batch_task"
+}
+
+// AiBatchProgress 批量分析进度,每只股票开始、完成、失败及任务结束时推送
+type AiBatchProgress struct {
+	JobId     uint         `json:"jobId"`
+	Status    string       `json:"status"`
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Task      *AiBatchTask `json:"task,omitempty"`
+}
+
+// aiBatchResult 一只股票的分析结果
+type aiBatchResult struct {
+	content        string
+	chatId         string
+	conversationId uint
+}
+
+// aiBatchAnalyzer 分析一只股票
+type aiBatchAnalyzer func(ctx context.Context, job AiBatchJob, task AiBatchTask) (aiBatchResult, error)
+
+// 运行中的批量任务,jobId -> 取消函数
+var aiBatchRunning sync.Map
+
+type AiBatchApi struct {
+	dao     *gorm.DB
+	analyze aiBatchAnalyzer
+}
+
+func NewAiBatchApi() *AiBatchApi {
+	return &AiBatchApi{dao: db.Dao, analyze: analyzeBatchTask}
+}
+
+// rateLimiter 同一接口两次请求的最小间隔
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+var aiRateLimiters sync.Map
+
+// waitRateLimit 按每分钟请求次数限制同一接口的请求,perMinute 为0时不限制
+func waitRateLimit(ctx context.Context, key string, perMinute int) error {
+	if perMinute <= 0 {
+		return nil
+	}
+	l, _ := aiRateLimiters.LoadOrStore(key, &rateLimiter{})
+	limiter := l.(*rateLimiter)
+	limiter.mu.Lock()
+	now := time.Now()
+	start := limiter.next
+	if start.Before(now) {
+		start = now
+	}
+	limiter.next = start.Add(time.Minute / time.Duration(perMinute))
+	limiter.mu.Unlock()
+
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// analyzeBatchTask 使用与单只股票分析相同的流程,结果保存为对话
+func analyzeBatchTask(ctx context.Context, job AiBatchJob, task AiBatchTask) (aiBatchResult, error) {
+	result := aiBatchResult{}
+	profile := NewAiModelApi().GetModelProfile(job.AiModelId)
+	if err := waitRateLimit(ctx, profile.Provider+"|"+profile.BaseUrl, profile.RateLimit); err != nil {
+		return result, err
+	}
+	ai := NewOpenAiWithProfile(ctx, job.AiModelId)
+	ai.Trigger = AiTriggerBatch
+	var sysPromptId *int
+	if job.PromptTemplateId > 0 {
+		sysPromptId = &job.PromptTemplateId
+	}
+	var content strings.Builder
+	question := ""
+	var failure error
+	var rating *AiRating
+	for msg := range ai.NewChatStream(task.StockName, task.StockCode, job.Question, sysPromptId) {
+		if msg["question"] != nil {
+			question = msg["question"].(string)
+		}
+		if msg["code"] == 0 {
+			failure = errors.New(fmt.Sprint(msg["content"]))
+			continue
+		}
+		if extra, ok := msg["extraContent"].(string); ok {
+			content.WriteString(extra + "\n")
+		}
+		if text, ok := msg["content"].(string); ok {
+			content.WriteString(text)
+		}
+		if id, ok := msg["chatId"].(string); ok && id != "" {
+			result.chatId = id
+		}
+		if r, ok := msg["rating"].(*AiRating); ok {
+			rating = r
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if failure != nil {
+		return result, failure
+	}
+	if content.Len() == 0 {
+		return result, errors.New("AI未返回分析内容")
+	}
+	result.content = content.String()
+	conversation, err := NewAiConversationApi().StartConversation(task.StockCode, task.StockName, ai.Model, question, result.content, result.chatId)
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存AI对话失败:%s", err.Error())
+	} else {
+		result.conversationId = conversation.ID
+		if rating != nil && rating.ID > 0 {
+			NewAiRatingApi().LinkRating(rating.ID, conversation.ID)
+		}
+	}
+	return result, nil
+}
+
+// CreateJob 为分组中的每只股票创建分析任务,groupId 为0时分析全部自选股
+func (b AiBatchApi) CreateJob(groupId, promptTemplateId int, question string, aiModelId uint, concurrency, maxRetries int) (*AiBatchJob, error) {
+	stocks := NewStockDataApi().GetFollowList(groupId)
+	if stocks == nil || len(*stocks) == 0 {
+		return nil, errors.New("分组中没有股票")
+	}
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if maxRetries < 0 {
+		maxRetries = defaultBatchRetries
+	}
+	job := &AiBatchJob{
+		GroupId:          groupId,
+		GroupName:        "全部",
+		PromptTemplateId: promptTemplateId,
+		Question:         question,
+		AiModelId:        aiModelId,
+		ModelName:        NewAiModelApi().GetProfile(aiModelId).Name,
+		Concurrency:      min(concurrency, maxBatchConcurrency),
+		MaxRetries:       maxRetries,
+		Status:           BatchStatusPending,
+		Total:            len(*stocks),
+	}
+	if groupId > 0 {
+		group := Group{}
+		if b.dao.First(&group, groupId).Error == nil {
+			job.GroupName = group.Name
+		}
+	}
+	err := b.dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		tasks := make([]AiBatchTask, 0, len(*stocks))
+		for _, stock := range *stocks {
+			tasks = append(tasks, AiBatchTask{JobId: job.ID, StockCode: stock.StockCode, StockName: stock.Name, Status: BatchStatusPending})
+		}
+		return tx.Create(&tasks).Error
+	})
+	return job, err
+}
+
+// Start 在后台运行任务,同一任务只运行一次
+func (b AiBatchApi) Start(ctx context.Context, jobId uint, progress func(AiBatchProgress)) error {
+	job, err := b.GetJob(jobId)
+	if err != nil {
+		return err
+	}
+	if job.Status != BatchStatusPending && job.Status != BatchStatusRunning {
+		return fmt.Errorf("任务已%s", batchStatusText(job.Status))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if _, loaded := aiBatchRunning.LoadOrStore(jobId, cancel); loaded {
+		cancel()
+		return errors.New("任务正在运行")
+	}
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.SugaredLogger.Errorf("AiBatchApi run panic:%s", err)
+			}
+		}()
+		defer aiBatchRunning.Delete(jobId)
+		defer cancel()
+		b.run(ctx, *job, progress)
+	}()
+	return nil
+}
+
+// Resume 启动时继续未完成的任务
+func (b AiBatchApi) Resume(ctx context.Context, progress func(AiBatchProgress)) {
+	var jobs []AiBatchJob
+	b.dao.Where("status in ?", []string{BatchStatusPending, BatchStatusRunning}).Order("id asc").Find(&jobs)
+	for _, job := range jobs {
+		logger.SugaredLogger.Infof("继续批量分析任务 %d %s", job.ID, job.GroupName)
+		if err := b.Start(ctx, job.ID, progress); err != nil {
+			logger.SugaredLogger.Errorf("继续批量分析任务失败:%s", err.Error())
+		}
+	}
+}
+
+// Cancel 取消任务,未开始的股票不再分析
+func (b AiBatchApi) Cancel(jobId uint) error {
+	if cancel, ok := aiBatchRunning.Load(jobId); ok {
+		cancel.(context.CancelFunc)()
+		return nil
+	}
+	result := b.dao.Model(&AiBatchJob{}).Where("id = ? and status = ?", jobId, BatchStatusPending).
+		Updates(map[string]any{"status": BatchStatusCancelled, "finished_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("任务未在运行")
+	}
+	return b.dao.Model(&AiBatchTask{}).Where("job_id = ? and status = ?", jobId, BatchStatusPending).Update("status", BatchStatusCancelled).Error
+}
+
+func (b AiBatchApi) GetJobs() []AiBatchJob {
+	var jobs []AiBatchJob
+	b.dao.Omit("report").Order("id desc").Find(&jobs)
+	return jobs
+}
+
+func (b AiBatchApi) GetJob(id uint) (*AiBatchJob, error) {
+	job := &AiBatchJob{}
+	if err := b.dao.First(job, id).Error; err != nil {
+		return nil, errors.New("任务不存在")
+	}
+	return job, nil
+}
+
+func (b AiBatchApi) GetTasks(jobId uint) []AiBatchTask {
+	var tasks []AiBatchTask
+	b.dao.Where("job_id = ?", jobId).Order("id asc").Find(&tasks)
+	return tasks
+}
+
+// DeleteJob 删除已结束的任务
+func (b AiBatchApi) DeleteJob(id uint) error {
+	if _, ok := aiBatchRunning.Load(id); ok {
+		return errors.New("任务正在运行,请先取消")
+	}
+	return b.dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", id).Delete(&AiBatchTask{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&AiBatchJob{}, id).Error
+	})
+}
+
+func batchStatusText(status string) string {
+	return map[string]string{
+		BatchStatusPending:   "等待",
+		BatchStatusRunning:   "运行中",
+		BatchStatusDone:      "完成",
+		BatchStatusFailed:    "失败",
+		BatchStatusCancelled: "取消",
+	}[status]
+}
+
+func (b AiBatchApi) run(ctx context.Context, job AiBatchJob, progress func(AiBatchProgress)) {
+	if progress == nil {
+		progress = func(AiBatchProgress) {}
+	}
+	now := time.Now()
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	job.Status = BatchStatusRunning
+	b.dao.Model(&AiBatchJob{}).Where("id = ?", job.ID).Updates(map[string]any{"status": job.Status, "started_at": job.StartedAt})
+	//上次运行中断的股票重新分析
+	b.dao.Model(&AiBatchTask{}).Where("job_id = ? and status = ?", job.ID, BatchStatusRunning).Update("status", BatchStatusPending)
+
+	var mu sync.Mutex
+	counts := func() AiBatchProgress {
+		var tasks []AiBatchTask
+		b.dao.Select("status").Where("job_id = ?", job.ID).Find(&tasks)
+		p := AiBatchProgress{JobId: job.ID, Status: job.Status, Total: len(tasks)}
+		for _, task := range tasks {
+			switch task.Status {
+			case BatchStatusDone:
+				p.Succeeded++
+			case BatchStatusFailed:
+				p.Failed++
+			}
+		}
+		return p
+	}
+	report := func(task AiBatchTask) {
+		mu.Lock()
+		defer mu.Unlock()
+		p := counts()
+		b.dao.Model(&AiBatchJob{}).Where("id = ?", job.ID).Updates(map[string]any{"succeeded": p.Succeeded, "failed": p.Failed})
+		p.Task = &task
+		progress(p)
+	}
+	next := func() (AiBatchTask, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		task := AiBatchTask{}
+		if ctx.Err() != nil || b.dao.Where("job_id = ? and status = ?", job.ID, BatchStatusPending).Order("id asc").First(&task).Error != nil {
+			return task, false
+		}
+		task.Status = BatchStatusRunning
+		b.dao.Model(&task).Update("status", task.Status)
+		return task, true
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < max(job.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task, ok := next(); ok; task, ok = next() {
+				report(task)
+				task = b.runTask(ctx, job, task)
+				report(task)
+			}
+		}()
+	}
+	wg.Wait()
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if ctx.Err() != nil {
+		job.Status = BatchStatusCancelled
+		b.dao.Model(&AiBatchTask{}).Where("job_id = ? and status in ?", job.ID, []string{BatchStatusPending, BatchStatusRunning}).Update("status", BatchStatusCancelled)
+	} else {
+		job.Status = BatchStatusDone
+	}
+	p := counts()
+	if p.Succeeded == 0 && job.Status == BatchStatusDone {
+		job.Status = BatchStatusFailed
+	}
+	p.Status = job.Status
+	job.Succeeded, job.Failed = p.Succeeded, p.Failed
+	job.Report = BatchReport(job, b.GetTasks(job.ID), b.ratings(job.ID))
+	b.dao.Model(&AiBatchJob{}).Where("id = ?", job.ID).Updates(map[string]any{
+		"status":      job.Status,
+		"succeeded":   p.Succeeded,
+		"failed":      p.Failed,
+		"report":      job.Report,
+		"finished_at": job.FinishedAt,
+	})
+	logger.SugaredLogger.Infof("批量分析任务 %d 结束 %s 成功%d 失败%d", job.ID, job.Status, p.Succeeded, p.Failed)
+	progress(p)
+}
+
+// runTask 分析一只股票,失败时按任务设置重试
+func (b AiBatchApi) runTask(ctx context.Context, job AiBatchJob, task AiBatchTask) AiBatchTask {
+	for {
+		task.Attempts++
+		result, err := b.analyze(ctx, job, task)
+		if err == nil {
+			task.Status = BatchStatusDone
+			task.Error = ""
+			task.Content = result.content
+			task.ChatId = result.chatId
+			task.ConversationId = result.conversationId
+			break
+		}
+		task.Error = err.Error()
+		if ctx.Err() != nil {
+			task.Status = BatchStatusCancelled
+			break
+		}
+		logger.SugaredLogger.Warnf("批量分析 %s 第%d次失败:%s", task.StockCode, task.Attempts, err.Error())
+		if task.Attempts > job.MaxRetries {
+			task.Status = BatchStatusFailed
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(batchRetryDelay(task.Attempts)):
+		}
+	}
+	b.dao.Model(&AiBatchTask{}).Where("id = ?", task.ID).Updates(map[string]any{
+		"status":          task.Status,
+		"attempts":        task.Attempts,
+		"error":           task.Error,
+		"content":         task.Content,
+		"chat_id":         task.ChatId,
+		"conversation_id": task.ConversationId,
+	})
+	return task
+}
+
+// ratings 任务中各股票的结构化结论
+func (b AiBatchApi) ratings(jobId uint) map[uint]AiRating {
+	var ratings []AiRating
+	b.dao.Where("conversation_id in (?)", b.dao.Model(&AiBatchTask{}).Select("conversation_id").Where("job_id = ? and conversation_id > 0", jobId)).Find(&ratings)
+	res := make(map[uint]AiRating, len(ratings))
+	for _, rating := range ratings {
+		res[rating.ConversationId] = rating
+	}
+	return res
+}
+
+// BatchReport 对比报告:有结构化结论时按评级和置信度排序,其余按分析顺序列出
+func BatchReport(job AiBatchJob, tasks []AiBatchTask, ratings map[uint]AiRating) string {
+	slices.SortStableFunc(tasks, func(a, b AiBatchTask) int {
+		ra, oka := ratings[a.ConversationId]
+		rb, okb := ratings[b.ConversationId]
+		switch {
+		case oka && okb:
+			if d := RatingDirection(rb.Rating) - RatingDirection(ra.Rating); d != 0 {
+				return d
+			}
+			return rb.Confidence - ra.Confidence
+		case oka:
+			return -1
+		case okb:
+			return 1
+		}
+		return 0
+	})
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("## %s 批量分析对比\n\n", job.GroupName))
+	report.WriteString(fmt.Sprintf("模型:%s 共%d只 成功%d只 失败%d只\n\n", job.ModelName, len(tasks), job.Succeeded, job.Failed))
+	report.WriteString("| 股票 | 评级 | 置信度 | 目标价 | 目标涨幅(%) | 周期 | 结论 |\n| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, task := range tasks {
+		stock := fmt.Sprintf("%s(%s)", task.StockName, task.StockCode)
+		if task.Status != BatchStatusDone {
+			report.WriteString(fmt.Sprintf("| %s | - | - | - | - | - | %s:%s |\n", stock, batchStatusText(task.Status), markdownCell(firstSentence(task.Error, 60))))
+			continue
+		}
+		summary := markdownCell(batchSummary(task.Content))
+		rating, ok := ratings[task.ConversationId]
+		if !ok {
+			report.WriteString(fmt.Sprintf("| %s | - | - | - | - | - | %s |\n", stock, summary))
+			continue
+		}
+		target, upside := "-", "-"
+		if rating.TargetPrice > 0 {
+			target = fmt.Sprintf("%.2f", rating.TargetPrice)
+			if rating.Price > 0 {
+				upside = fmt.Sprintf("%.2f", rating.Upside)
+			}
+		}
+		report.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %s | %s | %s |\n",
+			stock, rating.Rating, rating.Confidence, target, upside, rating.Horizon, summary))
+	}
+	return report.String()
+}
+
+// batchSummary 回答的第一句话,去掉markdown标记和JSON结论
+func batchSummary(content string) string {
+	content = jsonBlockPattern.ReplaceAllString(content, "")
+	content = strings.NewReplacer("#", "", "*", "", ">", "", "<hr>", "").Replace(content)
+	return firstSentence(strings.TrimSpace(content), 60)
+}
+
+// markdownCell 表格单元格内容,去掉换行和竖线
+func markdownCell(text string) string {
+	return strings.NewReplacer("\n", " ", "\r", "", "|", "/").Replace(strings.TrimSpace(text))
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go-stock/backend/db"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBatchTest(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&Settings{}, &AiModelProfile{}, &FollowedStock{}, &Group{}, &GroupStock{}, &AiBatchJob{}, &AiBatchTask{}, &AiRating{})
+	db.Dao.Create(&[]FollowedStock{{StockCode: "sh600000", Name: "浦发银行"}, {StockCode: "sz000001", Name: "平安银行"}, {StockCode: "sh601398", Name: "工商银行"}})
+	db.Dao.Create(&Group{Name: "银行"})
+	db.Dao.Create(&[]GroupStock{{StockCode: "sh600000", GroupId: 1}, {StockCode: "sz000001", GroupId: 1}, {StockCode: "sh601398", GroupId: 1}})
+	delay := batchRetryDelay
+	batchRetryDelay = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { batchRetryDelay = delay })
+}
+
+// runBatch 运行任务直到结束,返回推送的进度
+func runBatch(t *testing.T, api *AiBatchApi, ctx context.Context, jobId uint, cancel func()) []AiBatchProgress {
+	var mu sync.Mutex
+	var progress []AiBatchProgress
+	done := make(chan struct{})
+	err := api.Start(ctx, jobId, func(p AiBatchProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, p)
+		if p.Task == nil {
+			close(done)
+		}
+	})
+	assert.NoError(t, err)
+	if cancel != nil {
+		cancel()
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("批量分析未结束")
+	}
+	for {
+		if _, running := aiBatchRunning.Load(jobId); !running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return progress
+}
+
+func TestAiBatchJob(t *testing.T) {
+	setupBatchTest(t)
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	api := &AiBatchApi{dao: db.Dao, analyze: func(ctx context.Context, job AiBatchJob, task AiBatchTask) (aiBatchResult, error) {
+		mu.Lock()
+		attempts[task.StockCode]++
+		n := attempts[task.StockCode]
+		mu.Unlock()
+		switch {
+		case task.StockCode == "sh601398":
+			return aiBatchResult{}, errors.New("接口超时")
+		case task.StockCode == "sz000001" && n == 1:
+			return aiBatchResult{}, errors.New("限流")
+		}
+		rating := &AiRating{StockCode: task.StockCode, Rating: RatingBuy, Confidence: 60 + n*10, ConversationId: task.ID}
+		if task.StockCode == "sh600000" {
+			rating.Rating = RatingHold
+		}
+		db.Dao.Create(rating)
+		return aiBatchResult{content: "## 结论\n" + task.StockName + "短期震荡为主,关注支撑位。", conversationId: task.ID}, nil
+	}}
+
+	job, err := api.CreateJob(1, 0, "", 0, 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "银行", job.GroupName)
+	assert.Equal(t, 3, job.Total)
+
+	progress := runBatch(t, api, context.Background(), job.ID, nil)
+	last := progress[len(progress)-1]
+	assert.Equal(t, AiBatchProgress{JobId: job.ID, Status: BatchStatusDone, Total: 3, Succeeded: 2, Failed: 1}, last)
+	//每只股票开始和结束各推送一次
+	assert.Len(t, progress, 3*2+1)
+
+	tasks := api.GetTasks(job.ID)
+	assert.Equal(t, []string{BatchStatusDone, BatchStatusDone, BatchStatusFailed}, []string{tasks[0].Status, tasks[1].Status, tasks[2].Status})
+	assert.Equal(t, 2, tasks[1].Attempts)
+	assert.Equal(t, 2, tasks[2].Attempts)
+	assert.Equal(t, "接口超时", tasks[2].Error)
+
+	job, _ = api.GetJob(job.ID)
+	assert.Equal(t, 2, job.Succeeded)
+	assert.NotNil(t, job.FinishedAt)
+	//看多的排在前面,失败的排在最后
+	lines := strings.Split(strings.TrimSpace(job.Report), "\n")
+	assert.Contains(t, lines[len(lines)-3], "平安银行(sz000001) | buy | 80")
+	assert.Contains(t, lines[len(lines)-2], "浦发银行(sh600000) | hold | 70")
+	assert.Contains(t, lines[len(lines)-2], "浦发银行短期震荡为主,关注支撑位。")
+	assert.Contains(t, lines[len(lines)-1], "失败:接口超时")
+
+	//已结束的任务不能再次运行
+	assert.Error(t, api.Start(context.Background(), job.ID, nil))
+	assert.NoError(t, api.DeleteJob(job.ID))
+	assert.Empty(t, api.GetTasks(job.ID))
+}
+
+func TestAiBatchJobCancel(t *testing.T) {
+	setupBatchTest(t)
+	started := make(chan struct{}, 3)
+	api := &AiBatchApi{dao: db.Dao, analyze: func(ctx context.Context, job AiBatchJob, task AiBatchTask) (aiBatchResult, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return aiBatchResult{}, ctx.Err()
+	}}
+	job, _ := api.CreateJob(0, 0, "", 0, 1, 2)
+	assert.Equal(t, "全部", job.GroupName)
+
+	progress := runBatch(t, api, context.Background(), job.ID, func() {
+		<-started
+		assert.NoError(t, api.Cancel(job.ID))
+	})
+	assert.Equal(t, BatchStatusCancelled, progress[len(progress)-1].Status)
+	for _, task := range api.GetTasks(job.ID) {
+		assert.Equal(t, BatchStatusCancelled, task.Status)
+	}
+	assert.Error(t, api.Cancel(job.ID))
+
+	//未运行的任务直接取消
+	pending, _ := api.CreateJob(1, 0, "", 0, 1, 0)
+	assert.NoError(t, api.Cancel(pending.ID))
+	pending, _ = api.GetJob(pending.ID)
+	assert.Equal(t, BatchStatusCancelled, pending.Status)
+}
+
+func TestAiBatchJobResume(t *testing.T) {
+	setupBatchTest(t)
+	api := &AiBatchApi{dao: db.Dao, analyze: func(ctx context.Context, job AiBatchJob, task AiBatchTask) (aiBatchResult, error) {
+		return aiBatchResult{content: "结论"}, nil
+	}}
+	job, _ := api.CreateJob(1, 0, "", 0, 2, 0)
+	//模拟上次运行时程序退出
+	db.Dao.Model(&AiBatchJob{}).Where("id = ?", job.ID).Update("status", BatchStatusRunning)
+	db.Dao.Model(&AiBatchTask{}).Where("job_id = ? and stock_code = ?", job.ID, "sh600000").Update("status", BatchStatusRunning)
+	db.Dao.Model(&AiBatchTask{}).Where("job_id = ? and stock_code = ?", job.ID, "sz000001").Updates(map[string]any{"status": BatchStatusDone, "attempts": 1})
+
+	done := make(chan AiBatchProgress, 8)
+	api.Resume(context.Background(), func(p AiBatchProgress) {
+		if p.Task == nil {
+			done <- p
+		}
+	})
+	select {
+	case p := <-done:
+		assert.Equal(t, 3, p.Succeeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("批量分析未继续")
+	}
+	tasks := api.GetTasks(job.ID)
+	assert.Equal(t, 1, tasks[0].Attempts)
+	//已完成的不重复分析
+	assert.Equal(t, 1, tasks[1].Attempts)
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, waitRateLimit(context.Background(), "test|rate", 1200))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, waitRateLimit(ctx, "test|none", 0))
+	waitRateLimit(ctx, "test|cancel", 1)
+	assert.Error(t, waitRateLimit(ctx, "test|cancel", 1))
+}
@@ -0,0 +1,482 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-stock/backend/indicator"
+	"go-stock/backend/llm"
+	"go-stock/backend/logger"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+)
+
+// @Author spark
+// @Date 2025/6/12 21:30
+// @Desc AI分析上下文:各类数据并发获取,按固定顺序组装,获取失败时给出提示
+// -----------------------------------------------------------------------------------
+
+const (
+	ContextPrice      = "price"
+	ContextIndex      = "index"
+	ContextKLine      = "kline"
+	ContextMoneyFlow  = "moneyFlow"
+	ContextFinancial  = "financial"
+	ContextStockNews  = "stockNews"
+	ContextMarketNews = "marketNews"
+)
+
+// ContextSection 可选的分析数据
+type ContextSection struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// ContextSections 可选的分析数据,按组装顺序排列
+var ContextSections = []ContextSection{
+	{Name: ContextPrice, Label: "股价数据"},
+	{Name: ContextIndex, Label: "市场指数"},
+	{Name: ContextKLine, Label: "日K数据"},
+	{Name: ContextMoneyFlow, Label: "资金流向"},
+	{Name: ContextFinancial, Label: "财报数据"},
+	{Name: ContextStockNews, Label: "个股资讯"},
+	{Name: ContextMarketNews, Label: "市场资讯"},
+}
+
+// ContextProvider 一类分析数据,Fetch 返回空内容时表示不需要该数据
+type ContextProvider struct {
+	Name     string
+	Title    string        //发送给模型时的标题
+	Timeout  time.Duration //超时时间,0表示不限制
+	Critical bool          //获取失败时提示分析结果可能不准确
+	Weight   int           //分配token预算的权重,默认10
+	Fetch    func(ctx context.Context) (string, error)
+	Compress func(content string, budget int) string //超出预算时压缩到 budget 个token以内,为空时按行截断
+}
+
+// ContextResult 获取到的一段数据
+type ContextResult struct {
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+
+	weight   int
+	compress func(content string, budget int) string
+}
+
+// ContextWarning 数据获取失败
+type ContextWarning struct {
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Critical bool   `json:"critical"`
+}
+
+func (w ContextWarning) Error() string {
+	return fmt.Sprintf("获取%s失败:%s", w.Title, w.Message)
+}
+
+// run 在超时时间内执行 Fetch,超时后不再等待结果
+func (p ContextProvider) run(ctx context.Context) (string, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	type result struct {
+		content string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- result{err: fmt.Errorf("%v", err)}
+			}
+		}()
+		content, err := p.Fetch(ctx)
+		done <- result{content: content, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.content, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("超时(%s)", p.Timeout)
+		}
+		return "", ctx.Err()
+	}
+}
+
+// BuildContext 并发获取各类数据,结果按 providers 的顺序返回
+func BuildContext(ctx context.Context, providers []ContextProvider) ([]ContextResult, []ContextWarning) {
+	results := make([]*ContextResult, len(providers))
+	warnings := make([]*ContextWarning, len(providers))
+	wg := &sync.WaitGroup{}
+	for i, provider := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			content, err := provider.run(ctx)
+			if err != nil {
+				warnings[i] = &ContextWarning{Name: provider.Name, Title: provider.Title, Message: err.Error(), Critical: provider.Critical}
+				logger.SugaredLogger.Errorf("BuildContext %s error:%s", provider.Name, err.Error())
+				return
+			}
+			logger.SugaredLogger.Infof("BuildContext %s 耗时:%s", provider.Name, time.Since(start))
+			if strings.TrimSpace(content) != "" {
+				results[i] = &ContextResult{Name: provider.Name, Title: provider.Title, Content: content, weight: provider.Weight, compress: provider.Compress}
+			}
+		}()
+	}
+	wg.Wait()
+	return compact(results), compact(warnings)
+}
+
+func compact[T any](items []*T) []T {
+	list := make([]T, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			list = append(list, *item)
+		}
+	}
+	return list
+}
+
+// SelectContextProviders 按名称选择数据,names 为空时全部选择,顺序不变
+func SelectContextProviders(providers []ContextProvider, names []string) []ContextProvider {
+	if len(names) == 0 {
+		return providers
+	}
+	return slices.DeleteFunc(slices.Clone(providers), func(provider ContextProvider) bool {
+		return !slices.Contains(names, provider.Name)
+	})
+}
+
+// StockContextProviders 个股分析使用的数据
+func (o OpenAi) StockContextProviders(stock, stockCode string) []ContextProvider {
+	crawlTimeOut := time.Duration(o.CrawlTimeOut) * time.Second
+	isIndex := sync.OnceValue(func() bool {
+		return checkIsIndexBasic(stock)
+	})
+	kLineTitle := stock + "日K数据"
+	if o.IndicatorSummary {
+		kLineTitle = stock + "日K技术指标"
+	}
+	var bars []KLineData
+	providers := []ContextProvider{
+		{Name: ContextPrice, Title: stock + "股价数据", Timeout: crawlTimeOut + 10*time.Second, Critical: true, Weight: 5,
+			Fetch: func(ctx context.Context) (string, error) {
+				messages := SearchStockPriceInfo(ctx, stock, stockCode, o.CrawlTimeOut)
+				if messages == nil || len(*messages) == 0 {
+					return "", errors.New("未获取到数据")
+				}
+				return "\n## " + stock + "股价数据：\n" + strings.Join(*messages, ";") + ";", nil
+			}},
+		{Name: ContextIndex, Title: "市场指数", Timeout: 30 * time.Second, Weight: 5,
+			Fetch: func(ctx context.Context) (string, error) {
+				var market strings.Builder
+				market.WriteString(getZSInfo(ctx, "创业板指数", "sz399006", 30) + "\n")
+				market.WriteString(getZSInfo(ctx, "上证综合指数", "sh000001", 30) + "\n")
+				market.WriteString(getZSInfo(ctx, "沪深300指数", "sh000300", 30) + "\n")
+				return "市场指数情况如下：\n" + market.String(), nil
+			}},
+		{Name: ContextKLine, Title: kLineTitle, Timeout: 30 * time.Second, Weight: 30,
+			Fetch: func(ctx context.Context) (string, error) {
+				if !strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
+					return "", nil
+				}
+				adjust := KLineAdjustNone
+				if strutil.HasPrefixAny(stockCode, []string{"hk", "us", "gb_"}) {
+					adjust = KLineAdjustQfq
+				}
+				K := NewKLineStoreApi().GetKLineContext(ctx, stockCode, KLinePeriodDay, adjust, o.KDays)
+				if len(*K) == 0 {
+					return "", errors.New("未获取到K线数据")
+				}
+				bars = *K
+				if o.IndicatorSummary {
+					return "## " + kLineTitle + "如下：\n" + indicator.Compute(KLineBars(*K)).Summary(), nil
+				}
+				return "## " + kLineTitle + "如下：\n" + kLineMarkdown(*K), nil
+			},
+			Compress: func(content string, budget int) string {
+				if o.IndicatorSummary {
+					return truncateLines(content, budget)
+				}
+				return compressKLine(bars, kLineTitle, budget)
+			}},
+		{Name: ContextMoneyFlow, Title: stock + "资金流向", Timeout: 10 * time.Second, Weight: 8,
+			Fetch: func(ctx context.Context) (string, error) {
+				if isIndex() || !strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
+					return "", nil
+				}
+				markdown, err := moneyTrendMarkdown(ctx, stockCode, 10)
+				if err != nil {
+					return "", err
+				}
+				return "## " + stock + "近10个交易日资金流向：\n" + markdown, nil
+			}},
+		{Name: ContextFinancial, Title: stock + "财报数据", Timeout: crawlTimeOut + 10*time.Second, Critical: true, Weight: 20,
+			Fetch: func(ctx context.Context) (string, error) {
+				if isIndex() {
+					return "", nil
+				}
+				messages := GetFinancialReportsByXUEQIU(ctx, stockCode, o.CrawlTimeOut)
+				if messages == nil || len(*messages) == 0 {
+					return "", errors.New("未获取到数据")
+				}
+				return stock + strings.Join(*messages, "\n"+stock), nil
+			}},
+		{Name: ContextStockNews, Title: stock + "相关新闻资讯", Timeout: crawlTimeOut + 10*time.Second, Weight: 16, Compress: summarizeNews,
+			Fetch: func(ctx context.Context) (string, error) {
+				var newsText strings.Builder
+				if messages := SearchStockInfo(ctx, stock, "telegram", o.CrawlTimeOut); messages != nil {
+					for _, message := range *messages {
+						newsText.WriteString(message + "\n")
+					}
+				}
+				if !isIndex() {
+					if messages := SearchGuShiTongStockInfo(ctx, stockCode, o.CrawlTimeOut); messages != nil {
+						for _, message := range *messages {
+							newsText.WriteString(message + "\n")
+						}
+					}
+				}
+				if newsText.Len() == 0 {
+					return "", errors.New("未获取到数据")
+				}
+				return newsText.String(), nil
+			}},
+		{Name: ContextMarketNews, Title: "市场资讯", Timeout: 2*crawlTimeOut + 10*time.Second, Weight: 16, Compress: summarizeNews,
+			Fetch: func(ctx context.Context) (string, error) {
+				var messageText strings.Builder
+				if messages := GetTelegraphList(ctx, o.CrawlTimeOut); messages != nil {
+					for _, message := range *messages {
+						messageText.WriteString(message + "\n")
+					}
+				}
+				if messages := GetTopNewsList(ctx, o.CrawlTimeOut); messages != nil && len(*messages) > 0 {
+					messageText.WriteString("\n## 新闻资讯\n")
+					for _, message := range *messages {
+						messageText.WriteString(message + "\n")
+					}
+				}
+				if messageText.Len() == 0 {
+					return "", errors.New("未获取到数据")
+				}
+				return messageText.String(), nil
+			}},
+	}
+	return providers
+}
+
+// ContextTrim 超出token预算被压缩的数据
+type ContextTrim struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Tokens int    `json:"tokens"` //压缩前
+	Budget int    `json:"budget"`
+	Kept   int    `json:"kept"` //压缩后,0表示已丢弃
+}
+
+func (t ContextTrim) String() string {
+	if t.Kept == 0 {
+		return fmt.Sprintf("%s约%d tokens,超出预算已省略", t.Title, t.Tokens)
+	}
+	return fmt.Sprintf("%s约%d tokens,已压缩为约%d tokens", t.Title, t.Tokens, t.Kept)
+}
+
+// minSectionTokens 分到的预算低于该值时不再发送该数据
+const minSectionTokens = 64
+
+func (r ContextResult) tokens() int {
+	return llm.EstimateMessagesTokens([]llm.Message{{Content: r.Title}, {Content: r.Content}})
+}
+
+func (r ContextResult) share() int {
+	if r.weight <= 0 {
+		return 10
+	}
+	return r.weight
+}
+
+// FitContext 按权重为各段数据分配token预算:未超出的按实际用量计算,剩余的预算按权重分给超出的数据并压缩
+func FitContext(results []ContextResult, budget int) ([]ContextResult, []ContextTrim) {
+	tokens := make([]int, len(results))
+	settled := make([]bool, len(results))
+	for i, result := range results {
+		tokens[i] = result.tokens()
+	}
+	remain := budget
+	for changed := true; changed; {
+		changed = false
+		weight := 0
+		for i, result := range results {
+			if !settled[i] {
+				weight += result.share()
+			}
+		}
+		for i, result := range results {
+			if !settled[i] && tokens[i]*weight <= remain*result.share() {
+				settled[i] = true
+				remain -= tokens[i]
+				changed = true
+			}
+		}
+	}
+	weight := 0
+	for i, result := range results {
+		if !settled[i] {
+			weight += result.share()
+		}
+	}
+	fitted := make([]ContextResult, 0, len(results))
+	trims := make([]ContextTrim, 0)
+	for i, result := range results {
+		if settled[i] {
+			fitted = append(fitted, result)
+			continue
+		}
+		trim := ContextTrim{Name: result.Name, Title: result.Title, Tokens: tokens[i], Budget: max(remain, 0) * result.share() / weight}
+		//标题和消息格式占用的token
+		contentBudget := trim.Budget - (tokens[i] - llm.EstimateTokens(result.Content))
+		if contentBudget >= minSectionTokens {
+			if result.compress != nil {
+				result.Content = result.compress(result.Content, contentBudget)
+			}
+			if llm.EstimateTokens(result.Content) > contentBudget {
+				result.Content = truncateLines(result.Content, contentBudget)
+			}
+			trim.Kept = result.tokens()
+			fitted = append(fitted, result)
+		}
+		trims = append(trims, trim)
+	}
+	return fitted, trims
+}
+
+// truncateLines 按行保留开头的内容,不超过 budget 个token
+func truncateLines(content string, budget int) string {
+	const mark = "\n...(超出长度已省略)"
+	budget -= llm.EstimateTokens(mark)
+	var b strings.Builder
+	used := 0
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		tokens := llm.EstimateTokens(line + "\n")
+		if used+tokens > budget {
+			if used == 0 {
+				//第一行就超出时按字截断
+				runes := []rune(line)
+				b.WriteString(string(runes[:len(runes)*max(budget, 0)/tokens]))
+			}
+			return b.String() + mark
+		}
+		used += tokens
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// summarizeNews 资讯摘要:每条只保留第一句话,去掉重复的,再按预算截断
+func summarizeNews(content string, budget int) string {
+	seen := make(map[string]bool)
+	var b strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			line = firstSentence(line, 80)
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		b.WriteString(line + "\n")
+	}
+	return truncateLines(b.String(), budget)
+}
+
+// firstSentence 第一句话,不超过 limit 个字
+func firstSentence(text string, limit int) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if i >= 10 && strings.ContainsRune("。！？!?", r) {
+			runes = runes[:i+1]
+			break
+		}
+	}
+	if len(runes) > limit {
+		return string(runes[:limit]) + "..."
+	}
+	return string(runes)
+}
+
+// compressKLine K线压缩:较早的数据合并为周K,只保留最近的日K,仍超出时减少日K数量并省略最早的周K
+func compressKLine(K []KLineData, title string, budget int) string {
+	render := func(weekly, daily []KLineData) string {
+		var b strings.Builder
+		if len(weekly) > 0 {
+			b.WriteString(fmt.Sprintf("## %s(%s至%s周K)如下：\n", title, weekly[0].Day, weekly[len(weekly)-1].Day))
+			b.WriteString(kLineMarkdown(weekly))
+		}
+		b.WriteString(fmt.Sprintf("## %s(最近%d个交易日)如下：\n", title, len(daily)))
+		b.WriteString(kLineMarkdown(daily))
+		return b.String()
+	}
+	content := ""
+	for _, recent := range []int{30, 20, 10, 5} {
+		recent = min(recent, len(K))
+		weekly, daily := weeklyBars(K[:len(K)-recent]), K[len(K)-recent:]
+		for {
+			content = render(weekly, daily)
+			if llm.EstimateTokens(content) <= budget || len(weekly) == 0 {
+				break
+			}
+			weekly = weekly[1:]
+		}
+		if llm.EstimateTokens(content) <= budget {
+			break
+		}
+	}
+	return content
+}
+
+// weeklyBars 日K合并为周K,日期取每周最后一个交易日
+func weeklyBars(K []KLineData) []KLineData {
+	weekly := make([]KLineData, 0, len(K)/5+1)
+	lastWeek := ""
+	var high, low, volume float64
+	for _, bar := range K {
+		day, err := time.ParseInLocation(time.DateOnly, strutil.Before(bar.Day, " "), time.Local)
+		if err != nil {
+			continue
+		}
+		year, week := day.ISOWeek()
+		barHigh, _ := convertor.ToFloat(bar.High)
+		barLow, _ := convertor.ToFloat(bar.Low)
+		barVolume, _ := convertor.ToFloat(bar.Volume)
+		if key := fmt.Sprintf("%d-%d", year, week); key != lastWeek {
+			lastWeek = key
+			weekly = append(weekly, KLineData{Open: bar.Open})
+			high, low, volume = barHigh, barLow, 0
+		}
+		high, low, volume = max(high, barHigh), min(low, barLow), volume+barVolume
+		current := &weekly[len(weekly)-1]
+		current.Day = bar.Day
+		current.Close = bar.Close
+		current.High = strconv.FormatFloat(high, 'f', -1, 64)
+		current.Low = strconv.FormatFloat(low, 'f', -1, 64)
+		current.Volume = strconv.FormatFloat(volume, 'f', -1, 64)
+	}
+	return weekly
+}
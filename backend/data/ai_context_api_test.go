@@ -0,0 +1,156 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/models"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fetchAfter(delay time.Duration, content string, err error) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		time.Sleep(delay)
+		return content, err
+	}
+}
+
+func TestBuildContext(t *testing.T) {
+	providers := []ContextProvider{
+		{Name: "a", Title: "A", Fetch: fetchAfter(30*time.Millisecond, "a", nil)},
+		{Name: "b", Title: "B", Critical: true, Fetch: fetchAfter(0, "", errors.New("未获取到数据"))},
+		{Name: "c", Title: "C", Fetch: fetchAfter(0, "c", nil)},
+		{Name: "d", Title: "D", Timeout: 10 * time.Millisecond, Fetch: fetchAfter(time.Second, "d", nil)},
+		{Name: "e", Title: "E", Fetch: fetchAfter(10*time.Millisecond, " ", nil)},
+		{Name: "f", Title: "F", Fetch: func(ctx context.Context) (string, error) { panic("boom") }},
+		{Name: "g", Title: "G", Fetch: fetchAfter(20*time.Millisecond, "g", nil)},
+	}
+	start := time.Now()
+	results, warnings := BuildContext(context.Background(), providers)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	//按固定顺序组装,空内容跳过
+	assert.Equal(t, []ContextResult{{Name: "a", Title: "A", Content: "a"}, {Name: "c", Title: "C", Content: "c"}, {Name: "g", Title: "G", Content: "g"}}, results)
+	assert.Len(t, warnings, 3)
+	assert.Equal(t, ContextWarning{Name: "b", Title: "B", Message: "未获取到数据", Critical: true}, warnings[0])
+	assert.Equal(t, "超时(10ms)", warnings[1].Message)
+	assert.Equal(t, "boom", warnings[2].Message)
+	assert.EqualError(t, warnings[0], "获取B失败:未获取到数据")
+}
+
+func TestSelectContextProviders(t *testing.T) {
+	providers := OpenAi{}.StockContextProviders("浦发银行", "sh600000")
+	names := make([]string, 0)
+	for _, provider := range providers {
+		names = append(names, provider.Name)
+	}
+	sections := make([]string, 0)
+	for _, section := range ContextSections {
+		sections = append(sections, section.Name)
+	}
+	assert.Equal(t, sections, names)
+
+	selected := SelectContextProviders(providers, []string{ContextMarketNews, ContextPrice, "unknown"})
+	assert.Len(t, selected, 2)
+	assert.Equal(t, ContextPrice, selected[0].Name)
+	assert.Equal(t, ContextMarketNews, selected[1].Name)
+	assert.Len(t, SelectContextProviders(providers, nil), len(providers))
+	assert.Len(t, providers, len(ContextSections))
+}
+
+func TestPromptContextSections(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&models.PromptTemplate{})
+	api := NewPromptTemplateApi()
+	assert.Equal(t, "添加成功", api.AddPrompt(models.PromptTemplate{Name: "技术面", Content: "只看技术面", Type: "模型系统Prompt", ContextSections: "price, kline"}))
+	templates := api.GetPromptTemplates("技术面", "")
+	assert.Len(t, *templates, 1)
+	id := (*templates)[0].ID
+	assert.Equal(t, []string{ContextPrice, ContextKLine}, api.GetContextSections(int(id)))
+
+	//清空后发送全部数据
+	assert.Equal(t, "更新成功", api.AddPrompt(models.PromptTemplate{ID: id, Name: "技术面", Content: "只看技术面", Type: "模型系统Prompt"}))
+	assert.Nil(t, api.GetContextSections(int(id)))
+}
+
+func TestFitContext(t *testing.T) {
+	results := []ContextResult{
+		{Name: "price", Title: "股价", Content: "10.5", weight: 5},
+		{Name: "kline", Title: "K线", Content: strings.Repeat("K线数据\n", 200), weight: 30},
+		{Name: "news", Title: "资讯", Content: strings.Repeat("公司发布业绩预告净利润大增。详细内容很长很长\n", 100), weight: 10, compress: summarizeNews},
+	}
+	//预算足够时不压缩
+	fitted, trims := FitContext(results, 100000)
+	assert.Len(t, fitted, 3)
+	for i := range results {
+		assert.Equal(t, results[i].Content, fitted[i].Content)
+	}
+	assert.Empty(t, trims)
+
+	fitted, trims = FitContext(results, 600)
+	assert.Len(t, fitted, 3)
+	assert.Equal(t, "10.5", fitted[0].Content)
+	assert.Len(t, trims, 2)
+	total := 0
+	for _, result := range fitted {
+		total += result.tokens()
+	}
+	assert.LessOrEqual(t, total, 600)
+	//资讯去重后只剩一条
+	assert.Equal(t, "公司发布业绩预告净利润大增。\n", fitted[2].Content)
+	assert.Equal(t, "kline", trims[0].Name)
+	assert.Greater(t, trims[0].Tokens, trims[0].Budget)
+	assert.LessOrEqual(t, trims[0].Kept, trims[0].Budget)
+	assert.Contains(t, fitted[1].Content, "超出长度已省略")
+
+	//预算过少时省略
+	fitted, trims = FitContext(results, 100)
+	assert.Len(t, fitted, 1)
+	assert.Equal(t, 0, trims[0].Kept)
+	assert.Contains(t, trims[0].String(), "已省略")
+}
+
+func TestSummarizeNews(t *testing.T) {
+	content := "## 新闻资讯\n\n" +
+		"央行宣布降准0.5个百分点。预计释放长期资金约1万亿元。\n" +
+		"央行宣布降准0.5个百分点。预计释放长期资金约1万亿元。\n" +
+		strings.Repeat("很长的消息", 30) + "\n"
+	summary := summarizeNews(content, 1000)
+	assert.Equal(t, "## 新闻资讯\n央行宣布降准0.5个百分点。\n"+strings.Repeat("很长的消息", 16)+"...\n", summary)
+	assert.LessOrEqual(t, llm.EstimateTokens(summarizeNews(content, 30)), 30)
+}
+
+func TestCompressKLine(t *testing.T) {
+	K := make([]KLineData, 0)
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.Local)
+	for len(K) < 100 {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			price := strconv.Itoa(10 + len(K)%7)
+			K = append(K, KLineData{Day: day.Format(time.DateOnly), Open: price, High: price, Low: price, Close: price, Volume: "1000000"})
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	weekly := weeklyBars(K[:10])
+	assert.Len(t, weekly, 2)
+	assert.Equal(t, KLineData{Day: "2025-01-10", Open: "10", High: "14", Low: "10", Close: "14", Volume: "5000000"}, weekly[0])
+	assert.Equal(t, "2025-01-17", weekly[1].Day)
+
+	full := kLineMarkdown(K)
+	content := compressKLine(K, "浦发银行日K数据", llm.EstimateTokens(full)/2)
+	assert.LessOrEqual(t, llm.EstimateTokens(content), llm.EstimateTokens(full)/2)
+	assert.Contains(t, content, "周K")
+	assert.Contains(t, content, "最近30个交易日")
+	assert.Contains(t, content, K[len(K)-1].Day)
+
+	//预算很少时减少日K数量
+	content = compressKLine(K, "浦发银行日K数据", 300)
+	assert.LessOrEqual(t, llm.EstimateTokens(content), 300)
+	assert.NotContains(t, content, "最近30个交易日")
+}
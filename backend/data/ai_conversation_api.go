@@ -0,0 +1,222 @@
+package data
+
+import (
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/6/10 20:05
+// @Desc AI多轮对话:保存每次分析的对话记录,继续追问时带上之前的问答
+// -----------------------------------------------------------------------------------
+
+// AiConversation 一次AI对话
+type AiConversation struct {
+	gorm.Model
+	StockCode string `json:"stockCode" gorm:"index"`
+	StockName string `json:"stockName"`
+	Title     string `json:"title"`
+	ModelName string `json:"modelName"`
+}
+
+func (AiConversation) TableName() string {
+	return "ai_conversation"
+}
+
+// AiMessage 对话中的一条消息
+type AiMessage struct {
+	gorm.Model
+	ConversationId uint   `json:"conversationId" gorm:"index"`
+	Role           string `json:"role"`
+	Content        string `json:"content"`
+	ChatId         string `json:"chatId"`
+	ModelName      string `json:"modelName"`
+}
+
+func (AiMessage) TableName() string {
+	return "ai_message"
+}
+
+type AiConversationApi struct {
+	dao *gorm.DB
+}
+
+func NewAiConversationApi() *AiConversationApi {
+	return &AiConversationApi{dao: db.Dao}
+}
+
+// conversationTitle 默认使用问题作为标题
+func conversationTitle(stockName, question string) string {
+	title := []rune(strings.TrimSpace(RemoveAllBlankChar(question)))
+	if len(title) == 0 {
+		return stockName + "AI分析"
+	}
+	if len(title) > 30 {
+		return string(title[:30]) + "..."
+	}
+	return string(title)
+}
+
+// StartConversation 新建对话并保存第一轮问答
+func (c AiConversationApi) StartConversation(stockCode, stockName, modelName, question, answer, chatId string) (*AiConversation, error) {
+	conversation := &AiConversation{
+		StockCode: stockCode,
+		StockName: stockName,
+		Title:     conversationTitle(stockName, question),
+		ModelName: modelName,
+	}
+	err := c.dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+		return tx.Create([]AiMessage{
+			{ConversationId: conversation.ID, Role: llm.RoleUser, Content: question},
+			{ConversationId: conversation.ID, Role: llm.RoleAssistant, Content: answer, ChatId: chatId, ModelName: modelName},
+		}).Error
+	})
+	return conversation, err
+}
+
+// AppendTurn 保存一轮追问和回答
+func (c AiConversationApi) AppendTurn(conversationId uint, modelName, question, answer, chatId string) error {
+	return c.dao.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create([]AiMessage{
+			{ConversationId: conversationId, Role: llm.RoleUser, Content: question},
+			{ConversationId: conversationId, Role: llm.RoleAssistant, Content: answer, ChatId: chatId, ModelName: modelName},
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&AiConversation{}).Where("id = ?", conversationId).Update("model_name", modelName).Error
+	})
+}
+
+// GetConversations 股票的对话列表,最近更新的在前
+func (c AiConversationApi) GetConversations(stockCode string) []AiConversation {
+	var conversations []AiConversation
+	c.dao.Where("stock_code = ?", stockCode).Order("updated_at desc").Find(&conversations)
+	return conversations
+}
+
+func (c AiConversationApi) GetConversation(id uint) (*AiConversation, error) {
+	conversation := &AiConversation{}
+	if err := c.dao.First(conversation, id).Error; err != nil {
+		return nil, errors.New("对话不存在")
+	}
+	return conversation, nil
+}
+
+func (c AiConversationApi) GetMessages(conversationId uint) []AiMessage {
+	var messages []AiMessage
+	c.dao.Where("conversation_id = ?", conversationId).Order("id asc").Find(&messages)
+	return messages
+}
+
+func (c AiConversationApi) RenameConversation(id uint, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("标题不能为空")
+	}
+	result := c.dao.Model(&AiConversation{}).Where("id = ?", id).Update("title", title)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return errors.New("对话不存在")
+	}
+	return result.Error
+}
+
+// DeleteConversation 删除对话及其消息
+func (c AiConversationApi) DeleteConversation(id uint) error {
+	return c.dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", id).Delete(&AiMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&AiConversation{}, id).Error
+	})
+}
+
+// LatestResult 股票最近一次对话,按原分析结果的格式返回,追问内容依次附在回答后面。
+// 没有对话记录时返回旧版保存的分析结果
+func (c AiConversationApi) LatestResult(stockCode string) *models.AIResponseResult {
+	conversation := AiConversation{}
+	if c.dao.Where("stock_code = ?", stockCode).Order("updated_at desc").First(&conversation).Error != nil {
+		var result models.AIResponseResult
+		c.dao.Where("stock_code = ?", stockCode).Order("id desc").Limit(1).Find(&result)
+		return &result
+	}
+	result := &models.AIResponseResult{
+		Model:     conversation.Model,
+		StockCode: conversation.StockCode,
+		StockName: conversation.StockName,
+		ModelName: conversation.ModelName,
+	}
+	var content strings.Builder
+	for _, message := range c.GetMessages(conversation.ID) {
+		if message.Role == llm.RoleUser {
+			if result.Question == "" {
+				result.Question = message.Content
+			} else {
+				content.WriteString("\n\n---\n\n### " + message.Content + "\n\n")
+			}
+			continue
+		}
+		content.WriteString(message.Content)
+		if message.ChatId != "" {
+			result.ChatId = message.ChatId
+		}
+	}
+	result.Content = content.String()
+	return result
+}
+
+// HistoryMessages 从最近的问答往前回放,直到超出token预算,按完整的问答轮次取舍。
+// 最近一轮就超出预算时截断其回答,保证追问总有上下文
+func HistoryMessages(messages []AiMessage, budget int) []llm.Message {
+	var turns [][]llm.Message
+	for _, message := range messages {
+		if message.Role == llm.RoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], llm.Message{Role: message.Role, Content: message.Content})
+	}
+	history := make([]llm.Message, 0, len(messages))
+	used := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		tokens := llm.EstimateMessagesTokens(turns[i])
+		if used+tokens > budget {
+			if i == len(turns)-1 {
+				history = truncateTurn(turns[i], budget)
+			}
+			break
+		}
+		used += tokens
+		history = append(turns[i], history...)
+	}
+	return history
+}
+
+// truncateTurn 按预算截断一轮问答中的回答
+func truncateTurn(turn []llm.Message, budget int) []llm.Message {
+	truncated := make([]llm.Message, 0, len(turn))
+	remain := budget
+	for _, message := range turn {
+		tokens := llm.EstimateMessagesTokens([]llm.Message{message})
+		if tokens > remain {
+			runes := []rune(message.Content)
+			keep := len(runes) * max(remain, 0) / tokens
+			message.Content = string(runes[:keep]) + "..."
+		}
+		remain -= tokens
+		truncated = append(truncated, message)
+	}
+	return truncated
+}
+
+// ConversationHistory 继续对话时回放的历史问答,不超过 budget 个token
+func (c AiConversationApi) ConversationHistory(conversationId uint, budget int) []llm.Message {
+	return HistoryMessages(c.GetMessages(conversationId), budget)
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAiConversations(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiConversation{}, &AiMessage{}, &models.AIResponseResult{})
+	api := AiConversationApi{dao: db.Dao}
+
+	//没有对话时返回旧版分析结果
+	db.Dao.Create(&models.AIResponseResult{StockCode: "sh600000", Content: "旧版结果"})
+	assert.Equal(t, "旧版结果", api.LatestResult("sh600000").Content)
+
+	conversation, err := api.StartConversation("sh600000", "浦发银行", "deepseek-chat", "分析一下浦发银行", "短期震荡", "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, "分析一下浦发银行", conversation.Title)
+	assert.NoError(t, api.AppendTurn(conversation.ID, "deepseek-reasoner", "下跌风险呢?", "支撑位10元", "c2"))
+
+	result := api.LatestResult("sh600000")
+	assert.Equal(t, conversation.ID, result.ID)
+	assert.Equal(t, "分析一下浦发银行", result.Question)
+	assert.Equal(t, "短期震荡\n\n---\n\n### 下跌风险呢?\n\n支撑位10元", result.Content)
+	assert.Equal(t, "c2", result.ChatId)
+	assert.Equal(t, "deepseek-reasoner", result.ModelName)
+
+	other, _ := api.StartConversation("sh600000", "浦发银行", "deepseek-chat", "", "结果", "c3")
+	assert.Equal(t, "浦发银行AI分析", other.Title)
+	conversations := api.GetConversations("sh600000")
+	assert.Len(t, conversations, 2)
+	assert.Equal(t, other.ID, conversations[0].ID)
+	assert.Empty(t, api.GetConversations("sz000001"))
+
+	assert.Error(t, api.RenameConversation(conversation.ID, " "))
+	assert.Error(t, api.RenameConversation(99, "标题"))
+	assert.NoError(t, api.RenameConversation(conversation.ID, "浦发银行风险"))
+	conversation, _ = api.GetConversation(conversation.ID)
+	assert.Equal(t, "浦发银行风险", conversation.Title)
+
+	assert.NoError(t, api.DeleteConversation(other.ID))
+	assert.Empty(t, api.GetMessages(other.ID))
+	assert.Len(t, api.GetConversations("sh600000"), 1)
+	_, err = api.GetConversation(other.ID)
+	assert.Error(t, err)
+}
+
+func TestHistoryMessages(t *testing.T) {
+	messages := []AiMessage{
+		{Role: llm.RoleUser, Content: "分析"},
+		{Role: llm.RoleAssistant, Content: strings.Repeat("长", 100)},
+		{Role: llm.RoleUser, Content: "风险"},
+		{Role: llm.RoleAssistant, Content: "支撑位"},
+	}
+	assert.Len(t, HistoryMessages(messages, 1000), 4)
+	//预算不足时丢弃较早的轮次
+	history := HistoryMessages(messages, 50)
+	assert.Equal(t, []llm.Message{{Role: llm.RoleUser, Content: "风险"}, {Role: llm.RoleAssistant, Content: "支撑位"}}, history)
+	//最近一轮超出预算时截断回答
+	history = HistoryMessages(messages[:2], 58)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "分析", history[0].Content)
+	assert.Equal(t, strings.Repeat("长", 50)+"...", history[1].Content)
+	assert.Empty(t, HistoryMessages(nil, 100))
+}
+
+func TestContinueChat(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiConversation{}, &AiMessage{}, &AiUsage{})
+	var request struct {
+		Messages []map[string]string `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		for _, content := range []string{"跌破", "10元止损"} {
+			fmt.Fprintf(w, "data: {\"id\":\"c2\",\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", content)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	api := NewAiConversationApi()
+	conversation, _ := api.StartConversation("sh600000", "浦发银行", "m", "分析一下浦发银行", "短期震荡", "c1")
+
+	ai := OpenAi{BaseUrl: server.URL, Model: "m", Prompt: "你是分析师"}
+	var answer strings.Builder
+	for msg := range ai.ContinueChat(conversation.ID, "下跌风险呢?") {
+		assert.Equal(t, conversation.ID, msg["conversationId"])
+		if content, ok := msg["content"].(string); ok {
+			answer.WriteString(content)
+		}
+	}
+	assert.Equal(t, "跌破10元止损", answer.String())
+
+	//用量记录到对话的股票上
+	usages := NewAiUsageApi().GetUsages(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.Len(t, usages, 1)
+	assert.Equal(t, "sh600000", usages[0].StockCode)
+	assert.Equal(t, AiTriggerManual, usages[0].Trigger)
+
+	//请求中带上之前的问答
+	roles := make([]string, 0)
+	for _, message := range request.Messages {
+		roles = append(roles, message["role"])
+	}
+	assert.Equal(t, []string{"system", "user", "assistant", "user", "assistant", "user"}, roles)
+	assert.Equal(t, "短期震荡", request.Messages[4]["content"])
+	assert.Equal(t, "下跌风险呢?", request.Messages[5]["content"])
+
+	messages := api.GetMessages(conversation.ID)
+	assert.Len(t, messages, 4)
+	assert.Equal(t, "跌破10元止损", messages[3].Content)
+	assert.Equal(t, "c2", messages[3].ChatId)
+
+	//对话不存在
+	for msg := range ai.ContinueChat(99, "问题") {
+		assert.Equal(t, 0, msg["code"])
+	}
+}
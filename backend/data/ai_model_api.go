@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/logger"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/6/2 10:40
+// @Desc AI模型配置,可配置多个模型并在对话/定时分析时选择
+// -----------------------------------------------------------------------------------
+
+// AiModelProfile 模型配置
+type AiModelProfile struct {
+	gorm.Model
+	llm.Profile `gorm:"embedded"`
+	IsDefault   bool    `json:"isDefault"`   //未指定模型时使用
+	InputPrice  float64 `json:"inputPrice"`  //每百万输入token价格(元),用于统计费用
+	OutputPrice float64 `json:"outputPrice"` //每百万输出token价格(元)
+	RateLimit   int     `json:"rateLimit"`   //批量分析时每分钟最多请求次数,0表示不限制
+}
+
+func (AiModelProfile) TableName() string {
+	return "ai_model_profile"
+}
+
+type AiModelApi struct {
+	dao *gorm.DB
+}
+
+func NewAiModelApi() *AiModelApi {
+	return &AiModelApi{dao: db.Dao}
+}
+
+func (m AiModelApi) GetProfiles() []AiModelProfile {
+	var profiles []AiModelProfile
+	m.dao.Model(&AiModelProfile{}).Order("id asc").Find(&profiles)
+	return profiles
+}
+
+// SaveProfile 新增或修改模型配置,设为默认时取消其他配置的默认
+func (m AiModelApi) SaveProfile(profile AiModelProfile) error {
+	if profile.Name == "" {
+		return errors.New("配置名称不能为空")
+	}
+	if _, err := llm.New(profile.Profile); err != nil {
+		return err
+	}
+	return m.dao.Transaction(func(tx *gorm.DB) error {
+		if profile.IsDefault {
+			if err := tx.Model(&AiModelProfile{}).Where("id <> ?", profile.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if profile.ID == 0 {
+			return tx.Create(&profile).Error
+		}
+		return tx.Model(&AiModelProfile{}).Where("id = ?", profile.ID).Select("*").Omit("id", "created_at").Updates(&profile).Error
+	})
+}
+
+func (m AiModelApi) DeleteProfile(id uint) error {
+	return m.dao.Delete(&AiModelProfile{}, id).Error
+}
+
+// GetProfile 获取模型配置,id 为0或配置不存在时依次使用默认配置、第一个配置、设置中的旧版 OpenAI 配置
+func (m AiModelApi) GetProfile(id uint) llm.Profile {
+	return m.GetModelProfile(id).Profile
+}
+
+// GetModelProfile 同 GetProfile,包含价格等配置
+func (m AiModelApi) GetModelProfile(id uint) AiModelProfile {
+	profile := AiModelProfile{}
+	if id > 0 && m.dao.First(&profile, id).Error == nil {
+		return profile
+	}
+	if m.dao.Where("is_default = ?", true).First(&profile).Error == nil {
+		return profile
+	}
+	if m.dao.Order("id asc").First(&profile).Error == nil {
+		return profile
+	}
+	return AiModelProfile{Profile: legacyProfile(GetConfig())}
+}
+
+// legacyProfile 旧版设置中的 OpenAI 兼容接口配置
+func legacyProfile(config *Settings) llm.Profile {
+	return llm.Profile{
+		Name:        "默认",
+		Provider:    llm.TypeOpenAI,
+		BaseUrl:     config.OpenAiBaseUrl,
+		ApiKey:      config.OpenAiApiKey,
+		Model:       config.OpenAiModelName,
+		Temperature: config.OpenAiTemperature,
+		MaxTokens:   config.OpenAiMaxTokens,
+		TimeOut:     config.OpenAiApiTimeOut,
+	}
+}
+
+// MigrateLegacyProfile 没有模型配置时,将设置中的旧版 OpenAI 配置迁移为默认模型配置
+func (m AiModelApi) MigrateLegacyProfile() {
+	count := int64(0)
+	m.dao.Model(&AiModelProfile{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	config := &Settings{}
+	if m.dao.First(config).Error != nil || config.OpenAiBaseUrl == "" || config.OpenAiModelName == "" {
+		return
+	}
+	profile := AiModelProfile{Profile: legacyProfile(config), IsDefault: true}
+	if err := m.dao.Create(&profile).Error; err != nil {
+		logger.SugaredLogger.Errorf("迁移AI模型配置失败:%s", err.Error())
+	}
+}
+
+// TestProfile 发送测试消息,返回模型的回复
+func (m AiModelApi) TestProfile(id uint) (string, error) {
+	profile := AiModelProfile{}
+	if err := m.dao.First(&profile, id).Error; err != nil {
+		return "", err
+	}
+	provider, err := llm.New(profile.Profile)
+	if err != nil {
+		return "", err
+	}
+	events, err := provider.ChatStream(context.Background(), llm.Request{Messages: []llm.Message{
+		{Role: llm.RoleUser, Content: "你好,请用一句话介绍你自己"},
+	}})
+	if err != nil {
+		return "", err
+	}
+	var reply string
+	for event := range events {
+		if event.Err != nil {
+			err = event.Err
+		}
+		reply += event.Content
+	}
+	return reply, err
+}
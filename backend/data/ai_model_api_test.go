@@ -0,0 +1,49 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAiModelProfiles(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&Settings{}, &AiModelProfile{})
+	db.Dao.Create(&Settings{OpenAiBaseUrl: "https://api.deepseek.com", OpenAiApiKey: "sk-old", OpenAiModelName: "deepseek-chat", OpenAiMaxTokens: 2048})
+	api := AiModelApi{dao: db.Dao}
+
+	//没有模型配置时使用旧版设置
+	assert.Equal(t, "deepseek-chat", api.GetProfile(0).Model)
+	api.MigrateLegacyProfile()
+	profiles := api.GetProfiles()
+	assert.Len(t, profiles, 1)
+	assert.True(t, profiles[0].IsDefault)
+	assert.Equal(t, llm.TypeOpenAI, profiles[0].Provider)
+	assert.Equal(t, "sk-old", profiles[0].ApiKey)
+	assert.Equal(t, 2048, profiles[0].MaxTokens)
+	//已有配置时不重复迁移
+	api.MigrateLegacyProfile()
+	assert.Len(t, api.GetProfiles(), 1)
+
+	assert.Error(t, api.SaveProfile(AiModelProfile{Profile: llm.Profile{Provider: llm.TypeOllama, Model: "qwen3"}}))
+	assert.Error(t, api.SaveProfile(AiModelProfile{Profile: llm.Profile{Name: "Claude", Provider: llm.TypeAnthropic, Model: "claude-sonnet"}}))
+	assert.NoError(t, api.SaveProfile(AiModelProfile{Profile: llm.Profile{Name: "本地", Provider: llm.TypeOllama, Model: "qwen3"}, IsDefault: true}))
+	profiles = api.GetProfiles()
+	assert.Len(t, profiles, 2)
+	assert.False(t, profiles[0].IsDefault)
+	assert.Equal(t, "qwen3", api.GetProfile(0).Model)
+	assert.Equal(t, "deepseek-chat", api.GetProfile(profiles[0].ID).Model)
+	//指定的配置不存在时使用默认配置
+	assert.Equal(t, "qwen3", api.GetProfile(99).Model)
+
+	profiles[0].Profile.Model = "deepseek-reasoner"
+	assert.NoError(t, api.SaveProfile(profiles[0]))
+	assert.Equal(t, "deepseek-reasoner", api.GetProfile(profiles[0].ID).Model)
+	assert.Equal(t, "qwen3", api.GetProfile(0).Model)
+
+	assert.NoError(t, api.DeleteProfile(profiles[1].ID))
+	assert.Equal(t, "deepseek-reasoner", api.GetProfile(0).Model)
+}
@@ -0,0 +1,323 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/6/18 10:30
+// @Desc AI分析结构化结论:评级、置信度、支撑/压力位、目标价、持有周期、主要风险
+// -----------------------------------------------------------------------------------
+
+// 评级
+const (
+	RatingStrongBuy  = "strong_buy"
+	RatingBuy        = "buy"
+	RatingHold       = "hold"
+	RatingSell       = "sell"
+	RatingStrongSell = "strong_sell"
+)
+
+// 持有周期
+const (
+	HorizonShort  = "short"  //1个月以内
+	HorizonMedium = "medium" //1-6个月
+	HorizonLong   = "long"   //6个月以上
+)
+
+// AiRating 一次AI分析的结构化结论
+type AiRating struct {
+	gorm.Model
+	ConversationId   uint    `json:"conversationId" gorm:"index"`
+	ChatId           string  `json:"chatId"`
+	StockCode        string  `json:"stockCode" gorm:"index"`
+	StockName        string  `json:"stockName"`
+	ModelName        string  `json:"modelName"`
+	PromptTemplateId int     `json:"promptTemplateId"`
+	Trigger          string  `json:"trigger"`
+	Price            float64 `json:"price"` //分析时的价格
+	Rating           string  `json:"rating" gorm:"index"`
+	Confidence       int     `json:"confidence"` //0-100
+	Support          float64 `json:"support"`
+	Resistance       float64 `json:"resistance"`
+	TargetPrice      float64 `json:"targetPrice"`
+	Upside           float64 `json:"upside"` //目标价相对分析时价格的涨跌幅(%)
+	Horizon          string  `json:"horizon"`
+	KeyRisks         string  `json:"keyRisks"` //每行一条
+	Repaired         bool    `json:"repaired"` //由模型修正后解析成功
+}
+
+func (AiRating) TableName() string {
+	return "ai_rating"
+}
+
+var aiRatingSchema = func() *llm.Schema {
+	zero := 0.0
+	confidenceMin, confidenceMax := rangeOf(0, 100)
+	return &llm.Schema{
+		Type: "object",
+		Properties: map[string]*llm.Schema{
+			"rating":      {Type: "string", Description: "评级", Enum: []string{RatingStrongBuy, RatingBuy, RatingHold, RatingSell, RatingStrongSell}},
+			"confidence":  {Type: "integer", Description: "置信度0-100", Minimum: confidenceMin, Maximum: confidenceMax},
+			"support":     {Type: "number", Description: "支撑位", Minimum: &zero},
+			"resistance":  {Type: "number", Description: "压力位", Minimum: &zero},
+			"targetPrice": {Type: "number", Description: "目标价", Minimum: &zero},
+			"horizon":     {Type: "string", Description: "持有周期", Enum: []string{HorizonShort, HorizonMedium, HorizonLong}},
+			"keyRisks":    {Type: "array", Description: "主要风险"},
+		},
+		Required: []string{"rating", "confidence"},
+	}
+}()
+
+// StructuredOutputPrompt 结构化输出模式下附加在问题后面的要求
+const StructuredOutputPrompt = `
+
+请在回答的最后输出一个json代码块总结结论,格式如下:
+` + "```json" + `
+{"rating":"buy","confidence":70,"support":10.5,"resistance":12.8,"targetPrice":13.2,"horizon":"medium","keyRisks":["风险1","风险2"]}
+` + "```" + `
+字段说明:rating 评级,取值 strong_buy/buy/hold/sell/strong_sell;confidence 置信度,0-100的整数;support 支撑位;resistance 压力位;targetPrice 目标价;horizon 持有周期,取值 short(1个月以内)/medium(1-6个月)/long(6个月以上);keyRisks 主要风险,字符串数组。价格不确定时填0`
+
+var (
+	jsonBlockPattern     = regexp.MustCompile("(?s)```(?:json|JSON)?\\s*(\\{.*?\\})\\s*```")
+	trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
+	numberPattern        = regexp.MustCompile(`-?\d+(\.\d+)?`)
+)
+
+var ratingAliases = map[string]string{
+	"strongbuy": RatingStrongBuy, "强烈买入": RatingStrongBuy, "强烈推荐": RatingStrongBuy, "强烈看多": RatingStrongBuy,
+	"buy": RatingBuy, "买入": RatingBuy, "看多": RatingBuy, "增持": RatingBuy, "推荐": RatingBuy, "bullish": RatingBuy, "overweight": RatingBuy,
+	"hold": RatingHold, "持有": RatingHold, "中性": RatingHold, "观望": RatingHold, "neutral": RatingHold,
+	"sell": RatingSell, "卖出": RatingSell, "看空": RatingSell, "减持": RatingSell, "bearish": RatingSell, "underweight": RatingSell,
+	"strongsell": RatingStrongSell, "强烈卖出": RatingStrongSell, "强烈看空": RatingStrongSell,
+}
+
+var horizonAliases = map[string]string{
+	"short": HorizonShort, "短线": HorizonShort, "短期": HorizonShort,
+	"medium": HorizonMedium, "mid": HorizonMedium, "中线": HorizonMedium, "中期": HorizonMedium,
+	"long": HorizonLong, "长线": HorizonLong, "长期": HorizonLong,
+}
+
+// extractRatingJSON 回答中最后一个包含 rating 的JSON对象
+func extractRatingJSON(answer string) (string, error) {
+	blocks := jsonBlockPattern.FindAllStringSubmatch(answer, -1)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if strings.Contains(blocks[i][1], "rating") {
+			return blocks[i][1], nil
+		}
+	}
+	//没有代码块时取最后一个花括号对象
+	end := strings.LastIndex(answer, "}")
+	for end >= 0 {
+		depth := 0
+		for start := end; start >= 0; start-- {
+			switch answer[start] {
+			case '}':
+				depth++
+			case '{':
+				depth--
+			}
+			if depth == 0 {
+				if object := answer[start : end+1]; strings.Contains(object, "rating") {
+					return object, nil
+				}
+				break
+			}
+		}
+		end = strings.LastIndex(answer[:end], "}")
+	}
+	return "", errors.New("回答中没有找到JSON结论")
+}
+
+// unmarshalLenient 先按标准JSON解析,失败时替换全角标点、去掉多余逗号后再解析
+func unmarshalLenient(object string) (map[string]any, error) {
+	fields := make(map[string]any)
+	if json.Unmarshal([]byte(object), &fields) == nil {
+		return fields, nil
+	}
+	object = strings.NewReplacer("“", `"`, "”", `"`, "：", ":", "，", ",", "｛", "{", "｝", "}").Replace(object)
+	object = trailingCommaPattern.ReplaceAllString(object, "$1")
+	if err := json.Unmarshal([]byte(object), &fields); err != nil {
+		return nil, fmt.Errorf("JSON结论格式错误:%s", err.Error())
+	}
+	return fields, nil
+}
+
+// repairRatingFields 修正常见的取值问题:中文评级、带单位的价格、0-1的置信度、字符串形式的风险
+func repairRatingFields(fields map[string]any) {
+	alias := func(name string, aliases map[string]string) {
+		if v, ok := fields[name].(string); ok {
+			key := strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(v))
+			if mapped, ok := aliases[key]; ok {
+				fields[name] = mapped
+			} else if mapped, ok := aliases[strings.TrimSpace(v)]; ok {
+				fields[name] = mapped
+			}
+		}
+	}
+	alias("rating", ratingAliases)
+	alias("horizon", horizonAliases)
+	for _, name := range []string{"confidence", "support", "resistance", "targetPrice"} {
+		if v, ok := fields[name].(string); ok {
+			if number := numberPattern.FindString(v); number != "" {
+				f, _ := strconv.ParseFloat(number, 64)
+				fields[name] = f
+			} else {
+				delete(fields, name)
+			}
+		}
+	}
+	if confidence, ok := fields["confidence"].(float64); ok {
+		if confidence > 0 && confidence <= 1 {
+			confidence *= 100
+		}
+		fields["confidence"] = float64(int(confidence + 0.5))
+	}
+	if risks, ok := fields["keyRisks"].(string); ok {
+		fields["keyRisks"] = strings.FieldsFunc(risks, func(r rune) bool { return strings.ContainsRune("\n;；", r) })
+	}
+}
+
+// ParseAiRating 从回答中解析结构化结论,按定义校验各字段
+func ParseAiRating(answer string) (*AiRating, error) {
+	object, err := extractRatingJSON(answer)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := unmarshalLenient(object)
+	if err != nil {
+		return nil, err
+	}
+	repairRatingFields(fields)
+	repaired, _ := json.Marshal(fields)
+	args, err := llm.ValidateArguments(aiRatingSchema, string(repaired))
+	if err != nil {
+		return nil, err
+	}
+	rating := &AiRating{
+		Rating:     args["rating"].(string),
+		Confidence: int(args["confidence"].(int64)),
+	}
+	rating.Support, _ = args["support"].(float64)
+	rating.Resistance, _ = args["resistance"].(float64)
+	rating.TargetPrice, _ = args["targetPrice"].(float64)
+	rating.Horizon, _ = args["horizon"].(string)
+	if rating.Support > 0 && rating.Resistance > 0 && rating.Support > rating.Resistance {
+		rating.Support, rating.Resistance = rating.Resistance, rating.Support
+	}
+	if risks, ok := args["keyRisks"].([]any); ok {
+		lines := make([]string, 0, len(risks))
+		for _, risk := range risks {
+			if line := strings.TrimSpace(convertor.ToString(risk)); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		rating.KeyRisks = strings.Join(lines, "\n")
+	}
+	return rating, nil
+}
+
+// ratingRepairPrompt 解析失败时让模型修正结论
+func ratingRepairPrompt(err error) string {
+	return "你回答末尾的JSON结论不符合要求:" + err.Error() + "。请只输出修正后的json代码块,不要输出其他内容。" + StructuredOutputPrompt
+}
+
+// AiRatingQuery 评级查询条件
+type AiRatingQuery struct {
+	GroupId    int      `json:"groupId"` //自选分组,0表示全部自选股
+	StockCode  string   `json:"stockCode"`
+	Ratings    []string `json:"ratings"`
+	ModelName  string   `json:"modelName"`
+	StartDate  string   `json:"startDate"` //开始日期 2006-01-02
+	EndDate    string   `json:"endDate"`   //结束日期(含)
+	LatestOnly bool     `json:"latestOnly"`
+	SortBy     string   `json:"sortBy"` //time/confidence/upside/targetPrice
+	Desc       bool     `json:"desc"`
+}
+
+var ratingSortColumns = map[string]string{
+	"time":        "created_at",
+	"confidence":  "confidence",
+	"upside":      "upside",
+	"targetPrice": "target_price",
+}
+
+type AiRatingApi struct {
+	dao *gorm.DB
+}
+
+func NewAiRatingApi() *AiRatingApi {
+	return &AiRatingApi{dao: db.Dao}
+}
+
+// Save 保存评级,计算目标价对应的涨跌幅
+func (r AiRatingApi) Save(rating *AiRating) error {
+	if rating.Price > 0 && rating.TargetPrice > 0 {
+		rating.Upside = (rating.TargetPrice/rating.Price - 1) * 100
+	}
+	return r.dao.Create(rating).Error
+}
+
+// LinkConversation 分析结果保存为对话后关联评级
+func (r AiRatingApi) LinkConversation(stockCode, chatId string, conversationId uint) {
+	if chatId == "" {
+		return
+	}
+	r.dao.Model(&AiRating{}).Where("stock_code = ? and chat_id = ? and conversation_id = 0", stockCode, chatId).
+		Update("conversation_id", conversationId)
+}
+
+// LinkRating 按评级ID关联对话,模型未返回 chatId 时使用
+func (r AiRatingApi) LinkRating(ratingId, conversationId uint) {
+	r.dao.Model(&AiRating{}).Where("id = ?", ratingId).Update("conversation_id", conversationId)
+}
+
+// Query 按条件筛选和排序评级,默认按时间倒序
+func (r AiRatingApi) Query(query AiRatingQuery) []AiRating {
+	tx := r.dao.Model(&AiRating{})
+	if query.StockCode != "" {
+		tx = tx.Where("stock_code = ?", strings.ToLower(query.StockCode))
+	} else {
+		codes := make([]string, 0)
+		for _, follow := range *NewStockDataApi().GetFollowList(query.GroupId) {
+			codes = append(codes, follow.StockCode)
+		}
+		tx = tx.Where("stock_code in ?", codes)
+	}
+	if len(query.Ratings) > 0 {
+		tx = tx.Where("rating in ?", query.Ratings)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name = ?", query.ModelName)
+	}
+	if from, err := time.ParseInLocation(time.DateOnly, query.StartDate, time.Local); err == nil {
+		tx = tx.Where("created_at >= ?", from)
+	}
+	if to, err := time.ParseInLocation(time.DateOnly, query.EndDate, time.Local); err == nil {
+		tx = tx.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	if query.LatestOnly {
+		tx = tx.Where("id in (?)", r.dao.Model(&AiRating{}).Select("max(id)").Group("stock_code"))
+	}
+	column, ok := ratingSortColumns[query.SortBy]
+	if !ok {
+		column, query.Desc = "created_at", true
+	}
+	if query.Desc {
+		column += " desc"
+	}
+	var ratings []AiRating
+	tx.Order(column).Order("id desc").Find(&ratings)
+	return ratings
+}
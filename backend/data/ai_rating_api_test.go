@@ -0,0 +1,135 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-stock/backend/db"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAiRating(t *testing.T) {
+	rating, err := ParseAiRating("## 结论\n短期震荡。\n```json\n{\"rating\":\"buy\",\"confidence\":70,\"support\":10.5,\"resistance\":12.8,\"targetPrice\":13.2,\"horizon\":\"medium\",\"keyRisks\":[\"业绩不及预期\",\"大盘回调\"]}\n```")
+	assert.NoError(t, err)
+	assert.Equal(t, &AiRating{Rating: RatingBuy, Confidence: 70, Support: 10.5, Resistance: 12.8, TargetPrice: 13.2,
+		Horizon: HorizonMedium, KeyRisks: "业绩不及预期\n大盘回调"}, rating)
+
+	//中文评级、带单位的价格、0-1的置信度、全角标点、多余逗号、支撑压力位颠倒
+	rating, err = ParseAiRating("结论:{“rating”：“看多”，\"confidence\":0.8,\"support\":\"12.8元\",\"resistance\":10.5,\"targetPrice\":\"约13元\",\"horizon\":\"短线\",\"keyRisks\":\"估值偏高;解禁\",}")
+	assert.NoError(t, err)
+	assert.Equal(t, RatingBuy, rating.Rating)
+	assert.Equal(t, 80, rating.Confidence)
+	assert.Equal(t, 10.5, rating.Support)
+	assert.Equal(t, 12.8, rating.Resistance)
+	assert.Equal(t, 13.0, rating.TargetPrice)
+	assert.Equal(t, HorizonShort, rating.Horizon)
+	assert.Equal(t, "估值偏高\n解禁", rating.KeyRisks)
+
+	//取最后一个结论
+	rating, err = ParseAiRating("```json\n{\"rating\":\"sell\",\"confidence\":50}\n```\n修正:\n```json\n{\"rating\":\"Strong Buy\",\"confidence\":90}\n```")
+	assert.NoError(t, err)
+	assert.Equal(t, RatingStrongBuy, rating.Rating)
+
+	for _, answer := range []string{
+		"没有结论",
+		"```json\n{\"rating\":\"maybe\",\"confidence\":50}\n```",
+		"```json\n{\"rating\":\"buy\"}\n```",
+		"```json\n{\"rating\":\"buy\",\"confidence\":150}\n```",
+		"```json\n{\"rating\":\"buy\",\"confidence\":50,\"targetPrice\":-1}\n```",
+	} {
+		_, err = ParseAiRating(answer)
+		assert.Error(t, err, answer)
+	}
+}
+
+func TestAiRatingQuery(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiRating{}, &FollowedStock{}, &GroupStock{})
+	db.Dao.Create(&[]FollowedStock{{StockCode: "sh600000", Name: "浦发银行"}, {StockCode: "sz000001", Name: "平安银行"}})
+	api := NewAiRatingApi()
+	for _, rating := range []*AiRating{
+		{StockCode: "sh600000", Rating: RatingHold, Confidence: 60, Price: 10, TargetPrice: 10.5, ModelName: "a"},
+		{StockCode: "sh600000", Rating: RatingBuy, Confidence: 80, Price: 10, TargetPrice: 12, ModelName: "b"},
+		{StockCode: "sz000001", Rating: RatingBuy, Confidence: 70, Price: 10, TargetPrice: 13, ModelName: "a"},
+		{StockCode: "sh601398", Rating: RatingBuy, Confidence: 90},
+	} {
+		assert.NoError(t, api.Save(rating))
+	}
+	codes := func(ratings []AiRating) []string {
+		res := make([]string, 0, len(ratings))
+		for _, rating := range ratings {
+			res = append(res, fmt.Sprintf("%s-%d", rating.StockCode, rating.Confidence))
+		}
+		return res
+	}
+
+	//只返回自选股,默认按时间倒序
+	assert.Equal(t, []string{"sz000001-70", "sh600000-80", "sh600000-60"}, codes(api.Query(AiRatingQuery{})))
+	assert.Equal(t, []string{"sz000001-70", "sh600000-80"}, codes(api.Query(AiRatingQuery{Ratings: []string{RatingBuy}, SortBy: "upside", Desc: true})))
+	assert.Equal(t, []string{"sh600000-80", "sz000001-70"}, codes(api.Query(AiRatingQuery{LatestOnly: true, SortBy: "confidence", Desc: true})))
+	assert.Equal(t, []string{"sh600000-60", "sz000001-70"}, codes(api.Query(AiRatingQuery{ModelName: "a", SortBy: "confidence"})))
+	assert.Equal(t, []string{"sh601398-90"}, codes(api.Query(AiRatingQuery{StockCode: "SH601398"})))
+	assert.Empty(t, api.Query(AiRatingQuery{StartDate: time.Now().AddDate(0, 0, 1).Format(time.DateOnly)}))
+	assert.InDelta(t, 30.0, api.Query(AiRatingQuery{StockCode: "sz000001"})[0].Upside, 1e-9)
+
+	api.LinkConversation("sz000001", "", 5)
+	assert.Equal(t, uint(0), api.Query(AiRatingQuery{StockCode: "sz000001"})[0].ConversationId)
+	//没有 chatId 时按评级ID关联
+	rating := api.Query(AiRatingQuery{StockCode: "sz000001"})[0]
+	api.LinkRating(rating.ID, 5)
+	assert.Equal(t, uint(5), api.Query(AiRatingQuery{StockCode: "sz000001"})[0].ConversationId)
+}
+
+func TestAskWithRating(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiRating{}, &AiUsage{})
+	var requests []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, len(request.Messages))
+		content := "建议关注。```json\n{\"rating\":\"买入吧\",\"confidence\":70}\n```"
+		if len(requests) > 1 {
+			content = "```json\n{\"rating\":\"buy\",\"confidence\":70,\"targetPrice\":12}\n```"
+		}
+		data, _ := json.Marshal(content)
+		fmt.Fprintf(w, "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":%s}}]}\n\n", data)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	ai := OpenAi{BaseUrl: server.URL, Model: "m", StructuredOutput: true, Trigger: AiTriggerCron, stockCode: "sh600000", stockName: "浦发银行"}
+	ch := make(chan map[string]any, 64)
+	ai.askWithRating(nil, []map[string]interface{}{{"role": "user", "content": "分析一下" + StructuredOutputPrompt}}, ch, "分析一下", 10)
+	close(ch)
+	var answer string
+	var rating *AiRating
+	for msg := range ch {
+		if content, ok := msg["content"].(string); ok {
+			answer += content
+		}
+		if r, ok := msg["rating"].(*AiRating); ok {
+			rating = r
+		}
+	}
+	//修正后的结论不追加到回答中
+	assert.Equal(t, "建议关注。```json\n{\"rating\":\"买入吧\",\"confidence\":70}\n```", answer)
+	assert.Equal(t, []int{1, 3}, requests)
+	if assert.NotNil(t, rating) {
+		assert.True(t, rating.Repaired)
+		assert.Equal(t, RatingBuy, rating.Rating)
+		assert.Equal(t, "c1", rating.ChatId)
+		assert.Equal(t, AiTriggerCron, rating.Trigger)
+		assert.InDelta(t, 20.0, rating.Upside, 1e-9)
+	}
+	assert.Len(t, NewAiRatingApi().Query(AiRatingQuery{StockCode: "sh600000"}), 1)
+	//两次请求都记录用量
+	assert.Len(t, NewAiUsageApi().GetUsages(time.Now().Add(-time.Hour), time.Now().Add(time.Hour)), 2)
+}
@@ -0,0 +1,258 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/models"
+	"strings"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+)
+
+// @Author spark
+// @Date 2025/6/8 15:20
+// @Desc AI对话时可由模型按需调用的行情数据工具
+// -----------------------------------------------------------------------------------
+
+// defaultToolCallLimit 单次对话默认最多调用工具次数
+const defaultToolCallLimit = 8
+
+func rangeOf(minimum, maximum float64) (*float64, *float64) {
+	return &minimum, &maximum
+}
+
+var stockCodeSchema = &llm.Schema{Type: "string", Description: "股票代码,带市场前缀,如 sh600000、sz000001、hk00700、gb_aapl"}
+
+// stockCodeArg 工具参数中的股票代码,统一为小写,须为本地股票列表中的代码
+func stockCodeArg(args map[string]any) (string, error) {
+	stockCode := strings.ToLower(RemoveAllBlankChar(convertor.ToString(args["stockCode"])))
+	if !strutil.HasPrefixAny(stockCode, []string{"sh", "sz", "bj", "hk", "us", "gb_"}) {
+		return "", fmt.Errorf("股票代码 %s 缺少市场前缀", stockCode)
+	}
+	if !knownStockCode(stockCode) {
+		return "", fmt.Errorf("未找到股票代码 %s", stockCode)
+	}
+	return stockCode, nil
+}
+
+// knownStockCode 股票代码是否在行情缓存或股票基础信息中,避免模型编造的代码请求行情接口
+func knownStockCode(stockCode string) bool {
+	count := int64(0)
+	db.Dao.Model(&StockInfo{}).Where("code = ?", stockCode).Count(&count)
+	if count > 0 {
+		return true
+	}
+	switch {
+	case strutil.HasPrefixAny(stockCode, []string{"sh", "sz", "bj"}):
+		tsCode := ConvertStockCodeToTushareCode(stockCode)
+		db.Dao.Model(&StockBasic{}).Where("ts_code = ?", tsCode).Count(&count)
+		if count == 0 {
+			db.Dao.Model(&IndexBasic{}).Where("ts_code = ?", tsCode).Count(&count)
+		}
+	case strings.HasPrefix(stockCode, "hk"):
+		db.Dao.Model(&models.StockInfoHK{}).Where("code = ?", strutil.PadStart(strings.TrimPrefix(stockCode, "hk"), 5, "0")+".HK").Count(&count)
+	default:
+		symbol := strings.TrimPrefix(strings.TrimPrefix(stockCode, "gb_"), "us")
+		db.Dao.Model(&models.StockInfoUS{}).Where("upper(code) = ?", strings.ToUpper(symbol)+".US").Count(&count)
+	}
+	return count > 0
+}
+
+// intArg 整数参数,未传时使用默认值
+func intArg(args map[string]any, name string, defaultValue int64) int64 {
+	if v, ok := args[name].(int64); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// kLineMarkdown K线数据转换为markdown表格
+func kLineMarkdown(K []KLineData) string {
+	Kmap := &[]map[string]any{}
+	for _, kline := range K {
+		mapk := make(map[string]any, 6)
+		mapk["日期"] = kline.Day
+		mapk["开盘价"] = kline.Open
+		mapk["最高价"] = kline.High
+		mapk["最低价"] = kline.Low
+		mapk["收盘价"] = kline.Close
+		Volume, _ := convertor.ToFloat(kline.Volume)
+		mapk["成交量(万手)"] = Volume / 10000.00 / 100.00
+		*Kmap = append(*Kmap, mapk)
+	}
+	jsonData, _ := json.Marshal(Kmap)
+	markdownTable, _ := JSONToMarkdownTable(jsonData)
+	return markdownTable
+}
+
+// moneyTrendMarkdown 最近几个交易日的资金流向,按日期升序
+func moneyTrendMarkdown(ctx context.Context, stockCode string, days int) (string, error) {
+	res := NewMarketNewsApi().stockMoneyTrendByDay(ctx, stockCode, days)
+	if len(res) == 0 {
+		return "", errors.New("未获取到资金流向数据")
+	}
+	slice.Reverse(res)
+	jsonData, _ := json.Marshal(res)
+	return JSONToMarkdownTable(jsonData)
+}
+
+func joinLines(messages *[]string) (string, error) {
+	if messages == nil || len(*messages) == 0 {
+		return "", errors.New("未获取到数据")
+	}
+	return strings.Join(*messages, "\n"), nil
+}
+
+// NewStockToolbox AI对话可调用的行情数据工具:K线、分时、财报、资金流向、行业排名、资讯搜索
+func NewStockToolbox(o OpenAi) *llm.Toolbox {
+	box := llm.NewToolbox()
+	daysMin, daysMax := rangeOf(1, 365)
+	box.Register(llm.Tool{
+		Name:        "GetKLineData",
+		Description: "获取股票K线数据(日期/开盘价/最高价/最低价/收盘价/成交量),A股为不复权数据,港美股为前复权数据",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"stockCode": stockCodeSchema,
+				"period":    {Type: "string", Description: "K线周期,day:日K week:周K,默认day", Enum: []string{KLinePeriodDay, KLinePeriodWeek}},
+				"days":      {Type: "integer", Description: "获取最近多少根K线,默认30", Minimum: daysMin, Maximum: daysMax},
+			},
+			Required: []string{"stockCode"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		stockCode, err := stockCodeArg(args)
+		if err != nil {
+			return "", err
+		}
+		period := KLinePeriodDay
+		if v, ok := args["period"].(string); ok {
+			period = v
+		}
+		K := NewKLineStoreApi().GetKLineContext(ctx, stockCode, period, defaultKLineAdjust(stockCode), intArg(args, "days", 30))
+		if len(*K) == 0 {
+			return "", errors.New("未获取到K线数据")
+		}
+		return kLineMarkdown(*K), nil
+	})
+
+	box.Register(llm.Tool{
+		Name:        "GetStockMinutePriceData",
+		Description: "获取股票最近一个交易日的分时数据,每5分钟一条",
+		Parameters: &llm.Schema{
+			Type:       "object",
+			Properties: map[string]*llm.Schema{"stockCode": stockCodeSchema},
+			Required:   []string{"stockCode"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		stockCode, err := stockCodeArg(args)
+		if err != nil {
+			return "", err
+		}
+		priceData, date := NewStockDataApi().GetStockMinutePriceData(stockCode)
+		if priceData == nil || len(*priceData) == 0 {
+			return "", errors.New("未获取到分时数据")
+		}
+		var markdown strings.Builder
+		markdown.WriteString(fmt.Sprintf("### %s 分时数据\n| 时间 | 价格 | 成交量 | 成交额 |\n| --- | --- | --- | --- |\n", date))
+		for i, minute := range *priceData {
+			if i%5 != 0 && i != len(*priceData)-1 {
+				continue
+			}
+			markdown.WriteString(fmt.Sprintf("| %s | %.3f | %.0f | %.0f |\n", minute.Time, minute.Price, minute.Volume, minute.Amount))
+		}
+		return markdown.String(), nil
+	})
+
+	box.Register(llm.Tool{
+		Name:        "GetFinancialReportsByXUEQIU",
+		Description: "获取股票最近的财务报告主要指标(来源:雪球)",
+		Parameters: &llm.Schema{
+			Type:       "object",
+			Properties: map[string]*llm.Schema{"stockCode": stockCodeSchema},
+			Required:   []string{"stockCode"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		stockCode, err := stockCodeArg(args)
+		if err != nil {
+			return "", err
+		}
+		return joinLines(GetFinancialReportsByXUEQIU(ctx, stockCode, o.CrawlTimeOut))
+	})
+
+	trendMin, trendMax := rangeOf(1, 60)
+	box.Register(llm.Tool{
+		Name:        "GetStockMoneyTrendByDay",
+		Description: "获取A股个股每日资金流向(主力净流入等),按日期升序",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"stockCode": stockCodeSchema,
+				"days":      {Type: "integer", Description: "获取最近多少个交易日,默认10", Minimum: trendMin, Maximum: trendMax},
+			},
+			Required: []string{"stockCode"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		stockCode, err := stockCodeArg(args)
+		if err != nil {
+			return "", err
+		}
+		return moneyTrendMarkdown(ctx, stockCode, int(intArg(args, "days", 10)))
+	})
+
+	rankMin, rankMax := rangeOf(1, 100)
+	box.Register(llm.Tool{
+		Name:        "GetIndustryRank",
+		Description: "获取A股行业板块涨跌幅排名及领涨股",
+		Parameters: &llm.Schema{
+			Type: "object",
+			Properties: map[string]*llm.Schema{
+				"sort": {Type: "string", Description: "排序方式,0:涨幅从高到低 1:涨幅从低到高,默认0", Enum: []string{"0", "1"}},
+				"cnt":  {Type: "integer", Description: "获取前多少个行业,默认20", Minimum: rankMin, Maximum: rankMax},
+			},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		sort := "0"
+		if v, ok := args["sort"].(string); ok {
+			sort = v
+		}
+		res := NewMarketNewsApi().GetIndustryRank(sort, int(intArg(args, "cnt", 20)))
+		items, ok := res["data"].([]any)
+		if !ok || len(items) == 0 {
+			return "", errors.New("未获取到行业排名数据")
+		}
+		var markdown strings.Builder
+		markdown.WriteString("| 行业 | 涨跌幅(%) | 5日涨跌幅(%) | 20日涨跌幅(%) | 领涨股 | 领涨股涨跌幅(%) |\n| --- | --- | --- | --- | --- | --- |\n")
+		for _, item := range items {
+			row, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			markdown.WriteString(fmt.Sprintf("| %v | %v | %v | %v | %v(%v) | %v |\n",
+				row["bd_name"], row["bd_zdf"], row["bd_zdf5"], row["bd_zdf20"], row["nzg_name"], row["nzg_code"], row["nzg_zdf"]))
+		}
+		return markdown.String(), nil
+	})
+
+	box.Register(llm.Tool{
+		Name:        "SearchStockNews",
+		Description: "按关键词搜索财联社电报资讯,关键词可以是股票名称、行业或事件",
+		Parameters: &llm.Schema{
+			Type:       "object",
+			Properties: map[string]*llm.Schema{"keyword": {Type: "string", Description: "搜索关键词"}},
+			Required:   []string{"keyword"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		keyword := RemoveAllBlankChar(convertor.ToString(args["keyword"]))
+		if keyword == "" {
+			return "", errors.New("关键词不能为空")
+		}
+		return joinLines(SearchStockInfo(ctx, keyword, "telegram", o.CrawlTimeOut))
+	})
+	return box
+}
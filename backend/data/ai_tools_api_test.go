@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"go-stock/backend/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStockToolbox(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&StockInfo{}, &StockBasic{}, &IndexBasic{}, &models.StockInfoHK{}, &models.StockInfoUS{})
+	box := NewStockToolbox(OpenAi{CrawlTimeOut: 5})
+	names := make([]string, 0)
+	for _, tool := range box.Tools() {
+		names = append(names, tool.Name)
+	}
+	assert.Equal(t, []string{"GetKLineData", "GetStockMinutePriceData", "GetFinancialReportsByXUEQIU",
+		"GetStockMoneyTrendByDay", "GetIndustryRank", "SearchStockNews"}, names)
+
+	//参数校验失败时不调用接口
+	_, err := box.Call(context.Background(), llm.ToolCall{Name: "GetKLineData", Arguments: `{"stockCode":"sh600000","days":1000}`})
+	assert.EqualError(t, err, "参数 days 不能大于 365")
+	_, err = box.Call(context.Background(), llm.ToolCall{Name: "GetKLineData", Arguments: `{"stockCode":"600000"}`})
+	assert.EqualError(t, err, "股票代码 600000 缺少市场前缀")
+	_, err = box.Call(context.Background(), llm.ToolCall{Name: "GetKLineData", Arguments: `{"stockCode":"hk99999"}`})
+	assert.EqualError(t, err, "未找到股票代码 hk99999")
+	_, err = box.Call(context.Background(), llm.ToolCall{Name: "SearchStockNews", Arguments: `{"keyword":" "}`})
+	assert.EqualError(t, err, "关键词不能为空")
+}
+
+func TestKnownStockCode(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&StockInfo{}, &StockBasic{}, &IndexBasic{}, &models.StockInfoHK{}, &models.StockInfoUS{})
+	db.Dao.Create(&StockBasic{TsCode: "600000.SH", Name: "浦发银行"})
+	db.Dao.Create(&IndexBasic{TsCode: "000001.SH", Name: "上证指数"})
+	db.Dao.Create(&models.StockInfoHK{Code: "00700.HK", Name: "腾讯控股"})
+	db.Dao.Create(&models.StockInfoUS{Code: "AAPL.US", Name: "苹果"})
+	db.Dao.Create(&StockInfo{Code: "sz000001", Name: "平安银行"})
+
+	for _, code := range []string{"sh600000", "sh000001", "sz000001", "hk00700", "gb_aapl", "usaapl"} {
+		assert.True(t, knownStockCode(code), code)
+	}
+	for _, code := range []string{"sh600001", "hk99999", "gb_xxxx"} {
+		assert.False(t, knownStockCode(code), code)
+	}
+}
+
+func TestKLineMarkdown(t *testing.T) {
+	markdown := kLineMarkdown([]KLineData{{Day: "2025-06-06", Open: "10", High: "11", Low: "9.5", Close: "10.5", Volume: "2000000"}})
+	assert.Contains(t, markdown, "2025-06-06")
+	assert.Contains(t, markdown, "成交量(万手)")
+	assert.Contains(t, markdown, "| 10.5 |")
+}
@@ -0,0 +1,137 @@
+package data
+
+import (
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/6/15 16:10
+// @Desc AI调用用量和费用统计,超出预算时暂停定时分析
+// -----------------------------------------------------------------------------------
+
+// AI调用的触发方式
+const (
+	AiTriggerManual  = "manual"  //手动分析/追问
+	AiTriggerCron    = "cron"    //定时分析
+	AiTriggerSummary = "summary" //市场资讯总结
+)
+
+// AiUsage 一次AI调用的用量
+type AiUsage struct {
+	gorm.Model
+	ProfileName      string  `json:"profileName"`
+	Provider         string  `json:"provider"`
+	ModelName        string  `json:"modelName"`
+	StockCode        string  `json:"stockCode" gorm:"index"`
+	StockName        string  `json:"stockName"`
+	PromptTemplateId int     `json:"promptTemplateId"`
+	Trigger          string  `json:"trigger"`
+	ChatId           string  `json:"chatId"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Estimated        bool    `json:"estimated"` //接口未返回用量,按内容估算
+	Cost             float64 `json:"cost"`      //费用(元),按模型配置的价格计算
+}
+
+func (AiUsage) TableName() string {
+	return "ai_usage"
+}
+
+// AiUsageStat 一段时间的用量合计
+type AiUsageStat struct {
+	Day              string  `json:"day"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AiBudgetStatus 今日和本月费用及预算
+type AiBudgetStatus struct {
+	Today         AiUsageStat `json:"today"`
+	Month         AiUsageStat `json:"month"`
+	DailyBudget   float64     `json:"dailyBudget"`
+	MonthlyBudget float64     `json:"monthlyBudget"`
+	Exceeded      bool        `json:"exceeded"`
+	Message       string      `json:"message"`
+}
+
+type AiUsageApi struct {
+	dao *gorm.DB
+}
+
+func NewAiUsageApi() *AiUsageApi {
+	return &AiUsageApi{dao: db.Dao}
+}
+
+// UsageCost 按每百万token价格计算费用
+func UsageCost(usage llm.Usage, inputPrice, outputPrice float64) float64 {
+	return (float64(usage.PromptTokens)*inputPrice + float64(usage.CompletionTokens)*outputPrice) / 1000000
+}
+
+func (u AiUsageApi) Record(usage *AiUsage) error {
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if usage.Trigger == "" {
+		usage.Trigger = AiTriggerManual
+	}
+	return u.dao.Create(usage).Error
+}
+
+func (u AiUsageApi) GetUsages(from, to time.Time) []AiUsage {
+	var usages []AiUsage
+	u.dao.Where("created_at >= ? and created_at < ?", from, to).Order("id desc").Find(&usages)
+	return usages
+}
+
+// Stat [from, to) 的用量合计
+func (u AiUsageApi) Stat(from, to time.Time) AiUsageStat {
+	stat := AiUsageStat{}
+	u.dao.Model(&AiUsage{}).Where("created_at >= ? and created_at < ?", from, to).
+		Select("count(*) as requests, coalesce(sum(prompt_tokens),0) as prompt_tokens, coalesce(sum(completion_tokens),0) as completion_tokens, coalesce(sum(total_tokens),0) as total_tokens, coalesce(sum(cost),0) as cost").
+		Scan(&stat)
+	return stat
+}
+
+// DailyStats 最近 days 天每天的用量,按日期升序
+func (u AiUsageApi) DailyStats(days int, now time.Time) []AiUsageStat {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	stats := make([]AiUsageStat, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		stat := u.Stat(day, day.AddDate(0, 0, 1))
+		stat.Day = day.Format(time.DateOnly)
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// BudgetStatus 今日和本月的费用,超出设置的预算时 Exceeded 为 true
+func (u AiUsageApi) BudgetStatus(config *Settings, now time.Time) AiBudgetStatus {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	status := AiBudgetStatus{
+		Today:         u.Stat(today, today.AddDate(0, 0, 1)),
+		Month:         u.Stat(month, month.AddDate(0, 1, 0)),
+		DailyBudget:   config.AiDailyBudget,
+		MonthlyBudget: config.AiMonthlyBudget,
+	}
+	status.Today.Day = today.Format(time.DateOnly)
+	status.Month.Day = month.Format("2006-01")
+	if status.DailyBudget > 0 && status.Today.Cost >= status.DailyBudget {
+		status.Exceeded = true
+		status.Message = fmt.Sprintf("今日AI费用%.2f元已达到每日预算%.2f元", status.Today.Cost, status.DailyBudget)
+	} else if status.MonthlyBudget > 0 && status.Month.Cost >= status.MonthlyBudget {
+		status.Exceeded = true
+		status.Message = fmt.Sprintf("本月AI费用%.2f元已达到每月预算%.2f元", status.Month.Cost, status.MonthlyBudget)
+	}
+	return status
+}
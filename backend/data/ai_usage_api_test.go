@@ -0,0 +1,106 @@
+package data
+
+import (
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/llm"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAiUsageBudget(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiUsage{})
+	assert.InDelta(t, 0.012, UsageCost(llm.Usage{PromptTokens: 2000, CompletionTokens: 1000}, 2, 8), 1e-9)
+
+	api := NewAiUsageApi()
+	usage := &AiUsage{ModelName: "m", PromptTokens: 2000, CompletionTokens: 1000, Cost: 3}
+	assert.NoError(t, api.Record(usage))
+	assert.Equal(t, 3000, usage.TotalTokens)
+	assert.Equal(t, AiTriggerManual, usage.Trigger)
+
+	//上个月的记录不计入本月
+	now := time.Now()
+	old := &AiUsage{ModelName: "m", PromptTokens: 10, Cost: 100}
+	api.Record(old)
+	db.Dao.Model(old).Update("created_at", now.AddDate(0, -1, -1))
+
+	stats := api.DailyStats(3, now)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, now.Format(time.DateOnly), stats[2].Day)
+	assert.Equal(t, 1, stats[2].Requests)
+	assert.Equal(t, 3000, stats[2].TotalTokens)
+	assert.Equal(t, 0, stats[0].Requests)
+
+	//0表示不限制
+	status := api.BudgetStatus(&Settings{}, now)
+	assert.False(t, status.Exceeded)
+	assert.Equal(t, 3.0, status.Today.Cost)
+	assert.Equal(t, 3.0, status.Month.Cost)
+
+	status = api.BudgetStatus(&Settings{AiDailyBudget: 5, AiMonthlyBudget: 3}, now)
+	assert.True(t, status.Exceeded)
+	assert.Contains(t, status.Message, "每月预算")
+
+	status = api.BudgetStatus(&Settings{AiDailyBudget: 3}, now)
+	assert.True(t, status.Exceeded)
+	assert.Contains(t, status.Message, "每日预算")
+}
+
+func TestAskAiUsage(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AiUsage{})
+	withUsage := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":\"短期震荡\"}}]}\n\n")
+		if withUsage {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[],\"usage\":{\"prompt_tokens\":1000,\"completion_tokens\":500,\"total_tokens\":1500}}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	ai := OpenAi{BaseUrl: server.URL, Model: "m", ProfileName: "测试", InputPrice: 4, OutputPrice: 16, Trigger: AiTriggerCron,
+		stockCode: "sh600000", stockName: "浦发银行", promptTemplateId: 2}
+	messages := []map[string]interface{}{{"role": "user", "content": "分析一下浦发银行"}}
+	ask := func() *AiUsage {
+		ch := make(chan map[string]any, 16)
+		AskAi(ai, nil, messages, ch, "分析一下浦发银行")
+		close(ch)
+		var usage *AiUsage
+		for msg := range ch {
+			if u, ok := msg["usage"].(*AiUsage); ok {
+				usage = u
+			}
+		}
+		return usage
+	}
+
+	usage := ask()
+	if assert.NotNil(t, usage) {
+		assert.False(t, usage.Estimated)
+		assert.Equal(t, 1500, usage.TotalTokens)
+		assert.InDelta(t, 0.012, usage.Cost, 1e-9)
+		assert.Equal(t, "c1", usage.ChatId)
+		assert.Equal(t, AiTriggerCron, usage.Trigger)
+		assert.Equal(t, 2, usage.PromptTemplateId)
+	}
+
+	//接口未返回用量时按内容估算
+	withUsage = false
+	usage = ask()
+	if assert.NotNil(t, usage) {
+		assert.True(t, usage.Estimated)
+		assert.Equal(t, llm.EstimateMessagesTokens(toLLMMessages(messages)), usage.PromptTokens)
+		assert.Equal(t, llm.EstimateTokens("短期震荡"), usage.CompletionTokens)
+	}
+
+	usages := NewAiUsageApi().GetUsages(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.Len(t, usages, 2)
+	assert.Equal(t, "sh600000", usages[0].StockCode)
+}
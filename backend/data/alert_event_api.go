@@ -0,0 +1,332 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"go-stock/backend/notify"
+	"go-stock/internal/domain/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/29 10:20
+// @Desc 报警记录:推送结果、确认和暂停提醒
+// -----------------------------------------------------------------------------------
+
+const (
+	AlertTypeRule = "rule" //组合报警规则,其他类型为前端价格报警的类型名称
+
+	AlertStatusPending   = "pending"
+	AlertStatusSent      = "sent"
+	AlertStatusPartial   = "partial"
+	AlertStatusRetrying  = "retrying" //钉钉被限流,等待重试
+	AlertStatusFailed    = "failed"
+	AlertStatusNoChannel = "none"
+	AlertStatusMuted     = "muted" //免打扰时段内的报警,只记录不推送
+)
+
+// AlertEvent 报警记录
+type AlertEvent struct {
+	gorm.Model
+	RuleID         uint       `json:"ruleId" gorm:"index"`
+	RuleName       string     `json:"ruleName"`
+	AlertType      string     `json:"alertType"`
+	StockCode      string     `json:"stockCode" gorm:"index"`
+	StockName      string     `json:"stockName"`
+	Title          string     `json:"title"`
+	Content        string     `json:"content"`
+	Price          float64    `json:"price"`
+	ChangePercent  float64    `json:"changePercent"`
+	Quote          string     `json:"quote"`    //触发时的行情快照(JSON)
+	Channels       string     `json:"channels"` //发送成功的渠道,逗号分隔
+	Status         string     `json:"status" gorm:"index"`
+	DeliveryError  string     `json:"deliveryError"`
+	TriggeredAt    time.Time  `json:"triggeredAt" gorm:"index"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt"`
+	SnoozedUntil   *time.Time `json:"snoozedUntil"` //暂停提醒截止时间,期间同一股票同一规则的报警不再推送
+}
+
+func (AlertEvent) TableName() string {
+	return "alert_events"
+}
+
+// NewRuleAlertEvent 组合报警规则触发的报警
+func NewRuleAlertEvent(rule *models.AlertRule, values models.RuleValues, title, content string) *AlertEvent {
+	quote, _ := json.Marshal(values)
+	return &AlertEvent{
+		RuleID:        parseRuleID(rule.ID),
+		RuleName:      rule.Name,
+		AlertType:     AlertTypeRule,
+		StockCode:     normalizeTradeStockCode(rule.StockCode),
+		Title:         title,
+		Content:       content,
+		Price:         values["price"],
+		ChangePercent: values["change_pct"],
+		Quote:         string(quote),
+	}
+}
+
+// NewStockAlertEvent 前端价格报警(涨跌/股价/成本价)
+func NewStockAlertEvent(alertType string, stockInfo *StockInfo, content string) *AlertEvent {
+	quote, _ := json.Marshal(stockInfo)
+	price, _ := convertor.ToFloat(stockInfo.Price)
+	preClose, _ := convertor.ToFloat(stockInfo.PreClose)
+	event := &AlertEvent{
+		AlertType: alertType,
+		StockCode: normalizeTradeStockCode(stockInfo.Code),
+		StockName: stockInfo.Name,
+		Title:     alertType,
+		Content:   content,
+		Price:     price,
+		Quote:     string(quote),
+	}
+	if preClose > 0 {
+		event.ChangePercent = mathutil.RoundToFloat((price-preClose)/preClose*100, 2)
+	}
+	return event
+}
+
+type AlertEventApi struct {
+	dao *gorm.DB
+}
+
+func NewAlertEventApi() *AlertEventApi {
+	return &AlertEventApi{dao: db.Dao}
+}
+
+// IsSnoozed 同一股票同一规则(类型)的报警是否处于暂停提醒期间
+func (a AlertEventApi) IsSnoozed(event *AlertEvent, now time.Time) bool {
+	count := int64(0)
+	a.dao.Model(&AlertEvent{}).
+		Where("stock_code = ? and rule_id = ? and alert_type = ? and snoozed_until > ?", event.StockCode, event.RuleID, event.AlertType, now).
+		Count(&count)
+	return count > 0
+}
+
+// DingDingSender 推送钉钉并返回发送结果,被限流的消息重试结束时调用 retried
+type DingDingSender func(retried func(err error)) string
+
+// Deliver 记录报警并推送到通知渠道,dingding 不为空时同时推送钉钉,最后保存推送结果
+func (a AlertEventApi) Deliver(ctx context.Context, event *AlertEvent, channelIDs []string, dingding DingDingSender) []notify.DeliveryResult {
+	if event.TriggeredAt.IsZero() {
+		event.TriggeredAt = time.Now()
+	}
+	event.Status = AlertStatusPending
+	if err := a.dao.Create(event).Error; err != nil {
+		logger.SugaredLogger.Errorf("保存报警记录失败:%s", err.Error())
+	}
+	notifications := NewNotificationApi()
+	channels := NewAlertGuardApi().FilterChannels(event, notifications.enabledChannels(channelIDs), event.TriggeredAt)
+	results := notifications.send(ctx, channels, notify.Message{
+		Title:     event.Title,
+		Content:   event.Content,
+		StockCode: event.StockCode,
+		Time:      event.TriggeredAt,
+	})
+	if dingding == nil {
+		a.setDelivery(event, results)
+		return results
+	}
+	//钉钉被限流时,重试结束后再更新推送结果
+	var mu sync.Mutex
+	mu.Lock()
+	defer mu.Unlock()
+	index := len(results)
+	results = append(results, dingDingDelivery(func() string {
+		return dingding(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[index] = dingDingRetried(results[index], err)
+			a.setDelivery(event, results)
+		})
+	}))
+	a.setDelivery(event, results)
+	return results
+}
+
+// Mute 记录免打扰时段内的报警
+func (a AlertEventApi) Mute(event *AlertEvent) error {
+	if event.TriggeredAt.IsZero() {
+		event.TriggeredAt = time.Now()
+	}
+	event.Status = AlertStatusMuted
+	return a.dao.Create(event).Error
+}
+
+// dingDingDelivery 将钉钉发送结果转换为渠道发送结果
+func dingDingDelivery(send func() string) notify.DeliveryResult {
+	start := time.Now()
+	res := send()
+	result := notify.DeliveryResult{Channel: "钉钉", Type: notify.TypeDingDing, Duration: time.Since(start)}
+	switch res {
+	case DingDingSendSuccess:
+	case DingDingSendRetrying:
+		result.Retrying = true
+		result.Error = res
+	default:
+		result.Err = errors.New(res)
+		result.Error = res
+	}
+	return result
+}
+
+// dingDingRetried 钉钉重试结束后的发送结果
+func dingDingRetried(result notify.DeliveryResult, err error) notify.DeliveryResult {
+	result.Retrying = false
+	result.Err = err
+	result.Error = ""
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (a AlertEventApi) setDelivery(event *AlertEvent, results []notify.DeliveryResult) {
+	delivered := make([]string, 0)
+	failed := make([]string, 0)
+	retrying := make([]string, 0)
+	for _, result := range results {
+		switch {
+		case result.Retrying:
+			retrying = append(retrying, result.Channel+":"+result.Error)
+		case result.Err == nil:
+			delivered = append(delivered, result.Channel)
+		default:
+			failed = append(failed, result.Channel+":"+result.Error)
+		}
+	}
+	switch {
+	case len(results) == 0:
+		event.Status = AlertStatusNoChannel
+	case len(failed) == 0 && len(retrying) > 0:
+		event.Status = AlertStatusRetrying
+	case len(failed) == 0:
+		event.Status = AlertStatusSent
+	case len(delivered) == 0 && len(retrying) == 0:
+		event.Status = AlertStatusFailed
+	default:
+		event.Status = AlertStatusPartial
+	}
+	event.Channels = strings.Join(delivered, ",")
+	event.DeliveryError = strings.Join(append(failed, retrying...), "; ")
+	if event.ID == 0 {
+		return
+	}
+	a.dao.Model(&AlertEvent{}).Where("id = ?", event.ID).Updates(map[string]any{
+		"status":         event.Status,
+		"channels":       event.Channels,
+		"delivery_error": event.DeliveryError,
+	})
+}
+
+// AlertEventQuery 报警记录查询条件,零值表示不限
+type AlertEventQuery struct {
+	StockCode      string `json:"stockCode"`
+	RuleID         uint   `json:"ruleId"`
+	Status         string `json:"status"`
+	Unacknowledged bool   `json:"unacknowledged"`
+	StartDate      string `json:"startDate"` //2006-01-02
+	EndDate        string `json:"endDate"`
+	Limit          int    `json:"limit"`
+}
+
+func (a AlertEventApi) List(query AlertEventQuery) []AlertEvent {
+	tx := a.dao.Model(&AlertEvent{})
+	if query.StockCode != "" {
+		tx = tx.Where("stock_code = ?", normalizeTradeStockCode(query.StockCode))
+	}
+	if query.RuleID > 0 {
+		tx = tx.Where("rule_id = ?", query.RuleID)
+	}
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if query.Unacknowledged {
+		tx = tx.Where("acknowledged_at is null")
+	}
+	if start, err := time.ParseInLocation(time.DateOnly, query.StartDate, time.Local); err == nil {
+		tx = tx.Where("triggered_at >= ?", start)
+	}
+	if end, err := time.ParseInLocation(time.DateOnly, query.EndDate, time.Local); err == nil {
+		tx = tx.Where("triggered_at < ?", end.AddDate(0, 0, 1))
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 200
+	}
+	var res []AlertEvent
+	tx.Order("triggered_at desc, id desc").Limit(limit).Find(&res)
+	return res
+}
+
+// Acknowledge 确认报警,已确认的保留原确认时间
+func (a AlertEventApi) Acknowledge(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return a.dao.Model(&AlertEvent{}).Where("id in ? and acknowledged_at is null", ids).Update("acknowledged_at", time.Now()).Error
+}
+
+// Snooze 暂停提醒 minutes 分钟,minutes<=0 时取消暂停
+func (a AlertEventApi) Snooze(id uint, minutes int) error {
+	var until *time.Time
+	if minutes > 0 {
+		t := time.Now().Add(time.Duration(minutes) * time.Minute)
+		until = &t
+	}
+	res := a.dao.Model(&AlertEvent{}).Where("id = ?", id).Update("snoozed_until", until)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("报警记录不存在")
+	}
+	return nil
+}
+
+// Digest 当日未确认报警汇总,按时间倒序,没有未确认报警时 count 为0
+func (a AlertEventApi) Digest(date string) (title, content string, count int) {
+	alerts := a.List(AlertEventQuery{Unacknowledged: true, StartDate: date, EndDate: date, Limit: 1000})
+	title = "未确认报警汇总 " + date
+	if len(alerts) == 0 {
+		return title, "", 0
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s 共有 %d 条报警未确认\n", date, len(alerts)))
+	//最新的报警在前,最多50条
+	for i := 0; i < len(alerts) && i < 50; i++ {
+		alert := alerts[i]
+		name := alert.StockCode
+		if alert.StockName != "" {
+			name = alert.StockName + "(" + alert.StockCode + ")"
+		}
+		sb.WriteString(fmt.Sprintf("- %s %s %s 价格:%.2f 涨跌幅:%.2f%%\n", alert.TriggeredAt.Format("15:04:05"), name, alert.Title, alert.Price, alert.ChangePercent))
+	}
+	if len(alerts) > 50 {
+		sb.WriteString(fmt.Sprintf("- ... 其余 %d 条请在报警记录中查看\n", len(alerts)-50))
+	}
+	return title, sb.String(), len(alerts)
+}
+
+// SendDigest 推送当日未确认报警汇总
+func (a AlertEventApi) SendDigest(ctx context.Context, date string) int {
+	title, content, count := a.Digest(date)
+	if count == 0 {
+		return 0
+	}
+	NewNotificationApi().Send(ctx, nil, notify.Message{Title: title, Content: content})
+	if GetConfig().DingPushEnable {
+		NewDingDingAPI().SendToDingDing(title, content)
+	}
+	return count
+}
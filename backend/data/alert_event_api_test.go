@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/notify"
+	"go-stock/internal/domain/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertEventDeliver(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AlertEvent{}, &AlertState{}, &NotificationChannelSetting{}, &NotificationLog{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer server.Close()
+	api := NewAlertEventApi()
+	ctx := context.Background()
+
+	rule := &models.AlertRule{ID: "7", StockCode: "SH600000", Name: "突破"}
+	values := models.RuleValues{"price": 10.6, "change_pct": 6}
+	alert := NewRuleAlertEvent(rule, values, "报警规则:突破", "sh600000 price > prev_high")
+	api.Deliver(ctx, alert, nil, nil)
+	assert.Equal(t, AlertStatusNoChannel, alert.Status)
+
+	assert.NoError(t, NewNotificationApi().SaveChannel(NotificationChannelSetting{Config: notify.Config{Type: notify.TypeWeCom, Name: "企业微信", URL: server.URL}, Enabled: true}))
+	alert = NewRuleAlertEvent(rule, values, "报警规则:突破", "sh600000 price > prev_high")
+	api.Deliver(ctx, alert, nil, func(func(error)) string { return "发送钉钉消息失败" })
+	assert.Equal(t, AlertStatusPartial, alert.Status)
+
+	stock := NewStockAlertEvent("股价报警", &StockInfo{Code: "sz000001", Name: "平安银行", Price: "11", PreClose: "10"}, "[平安银行] 11 10%")
+	api.Deliver(ctx, stock, nil, func(func(error)) string { return DingDingSendSuccess })
+	assert.Equal(t, 10.0, stock.ChangePercent)
+
+	saved := api.List(AlertEventQuery{StockCode: "SH600000"})
+	assert.Len(t, saved, 2)
+	assert.Equal(t, uint(7), saved[0].RuleID)
+	assert.Equal(t, "企业微信", saved[0].Channels)
+	assert.Contains(t, saved[0].DeliveryError, "钉钉:发送钉钉消息失败")
+	assert.JSONEq(t, `{"price":10.6,"change_pct":6}`, saved[0].Quote)
+	sent := api.List(AlertEventQuery{Status: AlertStatusSent})
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "企业微信,钉钉", sent[0].Channels)
+
+	today := time.Now().Format(time.DateOnly)
+	_, content, count := api.Digest(today)
+	assert.Equal(t, 3, count)
+	assert.Contains(t, content, "平安银行(sz000001) 股价报警 价格:11.00 涨跌幅:10.00%")
+	//最新的报警在前
+	assert.Less(t, strings.Index(content, "平安银行"), strings.Index(content, "sh600000"))
+
+	assert.NoError(t, api.Acknowledge([]uint{saved[0].ID, saved[1].ID}))
+	assert.Len(t, api.List(AlertEventQuery{Unacknowledged: true, StartDate: today, EndDate: today}), 1)
+	assert.Empty(t, api.List(AlertEventQuery{EndDate: time.Now().AddDate(0, 0, -1).Format(time.DateOnly)}))
+	_, _, count = api.Digest(today)
+	assert.Equal(t, 1, count)
+
+	assert.False(t, api.IsSnoozed(alert, time.Now()))
+	assert.NoError(t, api.Snooze(saved[1].ID, 30))
+	assert.True(t, api.IsSnoozed(alert, time.Now()))
+	assert.False(t, api.IsSnoozed(stock, time.Now()))
+	assert.False(t, api.IsSnoozed(alert, time.Now().Add(time.Hour)))
+	assert.NoError(t, api.Snooze(saved[1].ID, 0))
+	assert.False(t, api.IsSnoozed(alert, time.Now()))
+	assert.Error(t, api.Snooze(999, 30))
+
+	//钉钉被限流等待重试,不计为发送失败
+	retrying := NewStockAlertEvent("股价报警", &StockInfo{Code: "sz000002", Name: "万科A", Price: "8", PreClose: "8"}, "[万科A] 8")
+	var retried func(error)
+	api.Deliver(ctx, retrying, nil, func(done func(error)) string {
+		retried = done
+		return DingDingSendRetrying
+	})
+	assert.Equal(t, AlertStatusRetrying, retrying.Status)
+	assert.Equal(t, "企业微信", retrying.Channels)
+	assert.Equal(t, "钉钉:"+DingDingSendRetrying, retrying.DeliveryError)
+
+	//重试结束后更新报警记录
+	retried(nil)
+	event := AlertEvent{}
+	db.Dao.First(&event, retrying.ID)
+	assert.Equal(t, AlertStatusSent, event.Status)
+	assert.Equal(t, "企业微信,钉钉", event.Channels)
+	assert.Empty(t, event.DeliveryError)
+	retried(errors.New("钉钉发送失败 130101: send too fast"))
+	db.Dao.First(&event, retrying.ID)
+	assert.Equal(t, AlertStatusPartial, event.Status)
+	assert.Equal(t, "企业微信", event.Channels)
+	assert.Equal(t, "钉钉:钉钉发送失败 130101: send too fast", event.DeliveryError)
+}
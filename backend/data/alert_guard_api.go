@@ -0,0 +1,182 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/5/30 9:40
+// @Desc 报警去重:按(规则,股票,方向)持久化冷却状态,免打扰时段和价格回滞
+// -----------------------------------------------------------------------------------
+
+var (
+	ErrAlertDuplicated = errors.New("重复报警")
+	ErrAlertMuted      = errors.New("免打扰")
+)
+
+const (
+	AlertDirectionUp   = "up"
+	AlertDirectionDown = "down"
+)
+
+// AlertState 报警去重状态,Channel 为空时表示报警本身,否则为通知渠道ID
+type AlertState struct {
+	gorm.Model
+	AlertKey    string    `json:"alertKey" gorm:"uniqueIndex:idx_alert_state"` //rule:规则ID 或 type:报警类型
+	StockCode   string    `json:"stockCode" gorm:"uniqueIndex:idx_alert_state"`
+	Direction   string    `json:"direction" gorm:"uniqueIndex:idx_alert_state"`
+	Channel     string    `json:"channel" gorm:"uniqueIndex:idx_alert_state"`
+	LastAlertAt time.Time `json:"lastAlertAt"`
+	LastPrice   float64   `json:"lastPrice"`
+}
+
+func (AlertState) TableName() string {
+	return "alert_state"
+}
+
+// AlertPolicy 报警推送策略
+type AlertPolicy struct {
+	QuietHours  string  //免打扰时段,如 22:00-08:00,支持跨零点
+	WeekendMute bool    //周末不推送
+	Hysteresis  float64 //同方向再次报警需要继续变动的百分比
+}
+
+func alertPolicy(config *Settings) AlertPolicy {
+	return AlertPolicy{
+		QuietHours:  config.AlertQuietHours,
+		WeekendMute: config.AlertWeekendMute,
+		Hysteresis:  config.AlertHysteresis,
+	}
+}
+
+// parseClock 解析 15:04 为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsMuted 是否处于免打扰时段或周末
+func (p AlertPolicy) IsMuted(now time.Time) bool {
+	if p.WeekendMute && (now.Weekday() == time.Saturday || now.Weekday() == time.Sunday) {
+		return true
+	}
+	start, end, ok := strings.Cut(p.QuietHours, "-")
+	if !ok {
+		return false
+	}
+	from, err1 := parseClock(start)
+	to, err2 := parseClock(end)
+	if err1 != nil || err2 != nil || from == to {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+func alertKey(event *AlertEvent) string {
+	if event.RuleID > 0 {
+		return "rule:" + strconv.FormatUint(uint64(event.RuleID), 10)
+	}
+	return "type:" + event.AlertType
+}
+
+func alertDirection(event *AlertEvent) string {
+	if event.ChangePercent < 0 {
+		return AlertDirectionDown
+	}
+	return AlertDirectionUp
+}
+
+// alertGuardMutex 检查和更新去重状态需要原子进行
+var alertGuardMutex sync.Mutex
+
+type AlertGuardApi struct {
+	dao    *gorm.DB
+	policy AlertPolicy
+}
+
+func NewAlertGuardApi() *AlertGuardApi {
+	return &AlertGuardApi{dao: db.Dao, policy: alertPolicy(GetConfig())}
+}
+
+func (g AlertGuardApi) state(event *AlertEvent, channel string) (*AlertState, bool) {
+	state := &AlertState{}
+	err := g.dao.Where("alert_key = ? and stock_code = ? and direction = ? and channel = ?",
+		alertKey(event), event.StockCode, alertDirection(event), channel).First(state).Error
+	return state, err == nil
+}
+
+func (g AlertGuardApi) save(event *AlertEvent, channel string, now time.Time) {
+	g.dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "alert_key"}, {Name: "stock_code"}, {Name: "direction"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_alert_at", "last_price", "updated_at"}),
+	}).Create(&AlertState{
+		AlertKey:    alertKey(event),
+		StockCode:   event.StockCode,
+		Direction:   alertDirection(event),
+		Channel:     channel,
+		LastAlertAt: now,
+		LastPrice:   event.Price,
+	})
+}
+
+// Allow 判断报警是否推送,未被去重时记录本次报警。
+// 冷却时间内或当天同方向价格变动不足返回 ErrAlertDuplicated;免打扰时段返回 ErrAlertMuted(同样记录,避免重复记录静默报警)
+func (g AlertGuardApi) Allow(event *AlertEvent, cooldown time.Duration, now time.Time) error {
+	alertGuardMutex.Lock()
+	defer alertGuardMutex.Unlock()
+	if state, ok := g.state(event, ""); ok {
+		if elapsed := now.Sub(state.LastAlertAt); elapsed < cooldown {
+			return fmt.Errorf("%w: 冷却中,剩余%s", ErrAlertDuplicated, (cooldown - elapsed).Round(time.Second))
+		}
+		sameDay := state.LastAlertAt.In(now.Location()).Format(time.DateOnly) == now.Format(time.DateOnly)
+		if g.policy.Hysteresis > 0 && sameDay && state.LastPrice > 0 {
+			//须沿报警方向继续变动,上涨报警要求价格继续上涨,下跌报警要求继续下跌
+			move := (event.Price - state.LastPrice) / state.LastPrice * 100
+			if alertDirection(event) == AlertDirectionDown {
+				move = -move
+			}
+			if move < g.policy.Hysteresis {
+				return fmt.Errorf("%w: 较上次报警价格%.2f仅变动%.2f%%", ErrAlertDuplicated, state.LastPrice, move)
+			}
+		}
+	}
+	g.save(event, "", now)
+	if g.policy.IsMuted(now) {
+		return ErrAlertMuted
+	}
+	return nil
+}
+
+// FilterChannels 过滤掉仍在渠道冷却时间内的渠道,并记录保留渠道的发送时间
+func (g AlertGuardApi) FilterChannels(event *AlertEvent, channels []NotificationChannelSetting, now time.Time) []NotificationChannelSetting {
+	alertGuardMutex.Lock()
+	defer alertGuardMutex.Unlock()
+	res := make([]NotificationChannelSetting, 0, len(channels))
+	for _, channel := range channels {
+		id := strconv.FormatUint(uint64(channel.ID), 10)
+		if channel.CooldownSeconds > 0 {
+			if state, ok := g.state(event, id); ok && now.Sub(state.LastAlertAt) < time.Duration(channel.CooldownSeconds)*time.Second {
+				continue
+			}
+		}
+		g.save(event, id, now)
+		res = append(res, channel)
+	}
+	return res
+}
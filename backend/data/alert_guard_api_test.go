@@ -0,0 +1,86 @@
+package data
+
+import (
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/notify"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertPolicyIsMuted(t *testing.T) {
+	at := func(day, hour, minute int) time.Time { return time.Date(2025, 5, day, hour, minute, 0, 0, time.Local) }
+	overnight := AlertPolicy{QuietHours: "22:00-08:00"}
+	assert.True(t, overnight.IsMuted(at(20, 23, 0)))
+	assert.True(t, overnight.IsMuted(at(20, 7, 59)))
+	assert.False(t, overnight.IsMuted(at(20, 8, 0)))
+	assert.False(t, overnight.IsMuted(at(24, 10, 0)))
+
+	lunch := AlertPolicy{QuietHours: "11:30-13:00", WeekendMute: true}
+	assert.True(t, lunch.IsMuted(at(20, 12, 0)))
+	assert.False(t, lunch.IsMuted(at(20, 13, 0)))
+	assert.True(t, lunch.IsMuted(at(24, 10, 0)))
+	assert.False(t, AlertPolicy{QuietHours: "bad"}.IsMuted(at(20, 12, 0)))
+}
+
+func TestAlertGuardAllow(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AlertState{})
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	guard := AlertGuardApi{dao: db.Dao, policy: AlertPolicy{Hysteresis: 2}}
+	price := func(alertType string, price, change float64) *AlertEvent {
+		return &AlertEvent{AlertType: alertType, StockCode: "sh600000", Price: price, ChangePercent: change}
+	}
+
+	assert.NoError(t, guard.Allow(price("股价报警", 10, 5), 30*time.Minute, now))
+	err := guard.Allow(price("股价报警", 10.5, 5), 30*time.Minute, now.Add(10*time.Minute))
+	assert.ErrorIs(t, err, ErrAlertDuplicated)
+	//不同类型和不同方向互不影响
+	assert.NoError(t, guard.Allow(price("涨跌报警", 10, 5), 5*time.Minute, now))
+	assert.NoError(t, guard.Allow(price("股价报警", 9.5, -5), 30*time.Minute, now))
+
+	//冷却结束后仍需继续变动2%
+	assert.ErrorIs(t, guard.Allow(price("股价报警", 10.1, 5), 30*time.Minute, now.Add(time.Hour)), ErrAlertDuplicated)
+	//回落超过2%不算同方向继续变动
+	assert.ErrorIs(t, guard.Allow(price("股价报警", 9.7, 5), 30*time.Minute, now.Add(time.Hour)), ErrAlertDuplicated)
+	assert.NoError(t, guard.Allow(price("股价报警", 10.3, 5), 30*time.Minute, now.Add(time.Hour)))
+	//下跌报警要求继续下跌
+	assert.ErrorIs(t, guard.Allow(price("股价报警", 9.8, -5), 30*time.Minute, now.Add(time.Hour)), ErrAlertDuplicated)
+	assert.NoError(t, guard.Allow(price("股价报警", 9.3, -5), 30*time.Minute, now.Add(time.Hour)))
+	//第二天不受回滞限制
+	assert.NoError(t, guard.Allow(price("股价报警", 10.3, 5), 30*time.Minute, now.AddDate(0, 0, 1)))
+
+	//规则报警按规则ID去重,状态持久化
+	rule := &AlertEvent{RuleID: 7, AlertType: AlertTypeRule, StockCode: "sh600000", Price: 10, ChangePercent: 1}
+	assert.NoError(t, guard.Allow(rule, time.Minute, now))
+	restarted := AlertGuardApi{dao: db.Dao}
+	assert.ErrorIs(t, restarted.Allow(rule, time.Minute, now.Add(30*time.Second)), ErrAlertDuplicated)
+
+	muted := AlertGuardApi{dao: db.Dao, policy: AlertPolicy{QuietHours: "09:00-11:00"}}
+	alert := price("成本价报警", 10, 1)
+	assert.ErrorIs(t, muted.Allow(alert, time.Minute, now), ErrAlertMuted)
+	err = muted.Allow(alert, time.Minute, now)
+	assert.True(t, errors.Is(err, ErrAlertDuplicated))
+}
+
+func TestAlertGuardFilterChannels(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AlertState{})
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	guard := AlertGuardApi{dao: db.Dao}
+	channels := []NotificationChannelSetting{
+		{Config: notify.Config{Name: "企业微信"}, CooldownSeconds: 600},
+		{Config: notify.Config{Name: "webhook"}},
+	}
+	channels[0].ID, channels[1].ID = 1, 2
+	alert := &AlertEvent{AlertType: "股价报警", StockCode: "sh600000", Price: 10}
+
+	assert.Len(t, guard.FilterChannels(alert, channels, now), 2)
+	filtered := guard.FilterChannels(alert, channels, now.Add(5*time.Minute))
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "webhook", filtered[0].Name)
+	assert.Len(t, guard.FilterChannels(alert, channels, now.Add(11*time.Minute)), 2)
+}
@@ -0,0 +1,291 @@
+package data
+
+import (
+	"context"
+	"go-stock/backend/calendar"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"go-stock/internal/domain/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/26 10:40
+// @Desc 组合报警规则存储,以及规则引擎所需的行情字段和衍生指标
+// -----------------------------------------------------------------------------------
+
+// AlertRule 组合报警规则
+type AlertRule struct {
+	gorm.Model
+	StockCode       string     `json:"stockCode" gorm:"index"`
+	Name            string     `json:"name"`
+	Expression      string     `json:"expression"`      //规则表达式,如 price > prev_high AND volume_ratio >= 2
+	TriggerMode     string     `json:"triggerMode"`     //EDGE:条件由假变真时触发 LEVEL:条件成立期间持续触发
+	CooldownSeconds int64      `json:"cooldownSeconds"` //冷却时间(秒)
+	IsActive        bool       `json:"isActive"`
+	Triggered       bool       `json:"triggered"` //最近一次计算结果
+	LastTriggered   *time.Time `json:"lastTriggered"`
+	Channels        string     `json:"channels"` //通知渠道ID,逗号分隔,为空时发送到全部启用的渠道
+}
+
+func (AlertRule) TableName() string {
+	return "alert_rule"
+}
+
+// ToModel 转换为领域模型
+func (r AlertRule) ToModel() *models.AlertRule {
+	rule := &models.AlertRule{
+		StockCode:   r.StockCode,
+		Name:        r.Name,
+		Expression:  r.Expression,
+		TriggerMode: models.TriggerMode(r.TriggerMode),
+		Cooldown:    time.Duration(r.CooldownSeconds) * time.Second,
+		IsActive:    r.IsActive,
+		Triggered:   r.Triggered,
+		CreatedAt:   r.CreatedAt,
+	}
+	if r.Channels != "" {
+		rule.Channels = strings.Split(r.Channels, ",")
+	}
+	if r.ID > 0 {
+		rule.ID = strconv.FormatUint(uint64(r.ID), 10)
+	}
+	if r.LastTriggered != nil {
+		rule.LastTriggered = *r.LastTriggered
+	}
+	return rule
+}
+
+type AlertRuleApi struct {
+	dao *gorm.DB
+}
+
+func NewAlertRuleApi() *AlertRuleApi {
+	return &AlertRuleApi{dao: db.Dao}
+}
+
+func parseRuleID(ruleID string) uint {
+	id, _ := strconv.ParseUint(ruleID, 10, 64)
+	return uint(id)
+}
+
+// List 查询规则,stockCode 为空时查询全部
+func (a AlertRuleApi) List(stockCode string) []AlertRule {
+	var rules []AlertRule
+	query := a.dao.Model(&AlertRule{})
+	if stockCode != "" {
+		query = query.Where("stock_code = ?", normalizeTradeStockCode(stockCode))
+	}
+	query.Order("id asc").Find(&rules)
+	return rules
+}
+
+func toRuleModels(rules []AlertRule) []*models.AlertRule {
+	res := make([]*models.AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		res = append(res, rule.ToModel())
+	}
+	return res
+}
+
+// GetRules 实现 repositories.AlertRuleRepository
+func (a AlertRuleApi) GetRules(ctx context.Context, stockCode string) ([]*models.AlertRule, error) {
+	return toRuleModels(a.List(stockCode)), nil
+}
+
+func (a AlertRuleApi) GetActiveRules(ctx context.Context) ([]*models.AlertRule, error) {
+	var rules []AlertRule
+	err := a.dao.WithContext(ctx).Model(&AlertRule{}).Where("is_active = ?", true).Order("id asc").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return toRuleModels(rules), nil
+}
+
+func (a AlertRuleApi) SaveRule(ctx context.Context, rule *models.AlertRule) error {
+	record := AlertRule{
+		StockCode:       normalizeTradeStockCode(rule.StockCode),
+		Name:            rule.Name,
+		Expression:      rule.Expression,
+		TriggerMode:     string(rule.TriggerMode),
+		CooldownSeconds: int64(rule.Cooldown / time.Second),
+		IsActive:        rule.IsActive,
+		Triggered:       rule.Triggered,
+		Channels:        strings.Join(rule.Channels, ","),
+	}
+	if !rule.LastTriggered.IsZero() {
+		record.LastTriggered = &rule.LastTriggered
+	}
+	if rule.ID == "" {
+		if err := a.dao.WithContext(ctx).Create(&record).Error; err != nil {
+			return err
+		}
+		rule.ID = strconv.FormatUint(uint64(record.ID), 10)
+		return nil
+	}
+	return a.dao.WithContext(ctx).Model(&AlertRule{}).Where("id = ?", parseRuleID(rule.ID)).Updates(map[string]any{
+		"stock_code":       record.StockCode,
+		"name":             record.Name,
+		"expression":       record.Expression,
+		"trigger_mode":     record.TriggerMode,
+		"cooldown_seconds": record.CooldownSeconds,
+		"is_active":        record.IsActive,
+		"triggered":        record.Triggered,
+		"channels":         record.Channels,
+	}).Error
+}
+
+func (a AlertRuleApi) DeleteRule(ctx context.Context, ruleID string) error {
+	return a.dao.WithContext(ctx).Delete(&AlertRule{}, parseRuleID(ruleID)).Error
+}
+
+func (a AlertRuleApi) UpdateRuleStatus(ctx context.Context, ruleID string, isActive, triggered bool, lastTriggered time.Time) error {
+	updates := map[string]any{
+		"is_active": isActive,
+		"triggered": triggered,
+	}
+	if !lastTriggered.IsZero() {
+		updates["last_triggered"] = lastTriggered
+	}
+	return a.dao.WithContext(ctx).Model(&AlertRule{}).Where("id = ?", parseRuleID(ruleID)).Updates(updates).Error
+}
+
+// ruleDailyStats 由历史K线计算的当日不变的统计值
+type ruleDailyStats struct {
+	closes    []float64 //最近19个交易日收盘价,旧的在前
+	prevHigh  float64
+	prevLow   float64
+	avgVolume float64 //最近5个交易日平均成交量(股)
+	fetchedAt time.Time
+}
+
+// 每只股票每天只计算一次
+var ruleStatsCache sync.Map
+
+func (a AlertRuleApi) dailyStats(code, market, date string) *ruleDailyStats {
+	key := code + "|" + date
+	if cached, ok := ruleStatsCache.Load(key); ok {
+		stats := cached.(*ruleDailyStats)
+		//获取K线失败时10分钟后重试
+		if len(stats.closes) > 0 || time.Since(stats.fetchedAt) < 10*time.Minute {
+			return stats
+		}
+	}
+	stats := &ruleDailyStats{fetchedAt: time.Now()}
+	bars := make([]KLineData, 0)
+	for _, k := range *NewKLineStoreApi().GetKLine(code, KLinePeriodDay, KLineAdjustQfq, 30) {
+		if k.Day < date {
+			bars = append(bars, k)
+		}
+	}
+	if len(bars) == 0 {
+		ruleStatsCache.Store(key, stats)
+		return stats
+	}
+	last := bars[len(bars)-1]
+	stats.prevHigh, _ = convertor.ToFloat(last.High)
+	stats.prevLow, _ = convertor.ToFloat(last.Low)
+	for _, k := range bars[max(0, len(bars)-19):] {
+		c, _ := convertor.ToFloat(k.Close)
+		stats.closes = append(stats.closes, c)
+	}
+	volumes := bars[max(0, len(bars)-5):]
+	for _, k := range volumes {
+		v, _ := convertor.ToFloat(k.Volume)
+		stats.avgVolume += v
+	}
+	stats.avgVolume /= float64(len(volumes))
+	if market == MarketCN {
+		//腾讯K线A股成交量单位为手
+		stats.avgVolume *= 100
+	}
+	ruleStatsCache.Store(key, stats)
+	return stats
+}
+
+// movingAverage 最近 n-1 个收盘价加上当前价格的均线,历史数据不足时返回 false
+func (s *ruleDailyStats) movingAverage(n int, price float64) (float64, bool) {
+	if len(s.closes) < n-1 {
+		return 0, false
+	}
+	sum := price
+	for _, c := range s.closes[len(s.closes)-(n-1):] {
+		sum += c
+	}
+	return sum / float64(n), true
+}
+
+// Values 计算规则引擎所需的行情字段和衍生指标,键为小写股票代码
+// 历史K线获取失败时不包含均线、前一交易日高低点和量比,引用这些字段的规则不会被计算
+func (a AlertRuleApi) Values(stockInfos []StockInfo, now time.Time) map[string]models.RuleValues {
+	res := make(map[string]models.RuleValues)
+	for i := range stockInfos {
+		quote, err := stockInfos[i].GetQuote()
+		if err != nil {
+			logger.SugaredLogger.Warnf("报警规则 %s", err.Error())
+			continue
+		}
+		price := quote.LastPrice().Float64()
+		preClose := quote.PreClose.Float64()
+		high, low := quote.DayHigh().Float64(), quote.DayLow().Float64()
+		values := models.RuleValues{
+			"price":     price,
+			"open":      quote.Open.Float64(),
+			"high":      high,
+			"low":       low,
+			"pre_close": preClose,
+			"volume":    float64(quote.Volume),
+			"amount":    quote.Amount.Float64(),
+			"new_high":  0,
+			"new_low":   0,
+		}
+		if preClose > 0 {
+			values["change"] = price - preClose
+			values["change_pct"] = (price - preClose) / preClose * 100
+		}
+		if high > 0 && price >= high {
+			values["new_high"] = 1
+		}
+		if low > 0 && price <= low {
+			values["new_low"] = 1
+		}
+
+		date := now.In(calendar.GetExchange(quote.Market).Location).Format(time.DateOnly)
+		if !quote.Time.IsZero() {
+			date = quote.Time.Format(time.DateOnly)
+		}
+		stats := a.dailyStats(quote.Code, quote.Market, date)
+		if stats.prevHigh > 0 {
+			values["prev_high"] = stats.prevHigh
+			values["prev_low"] = stats.prevLow
+		}
+		for _, n := range []int{5, 10, 20} {
+			if ma, ok := stats.movingAverage(n, price); ok {
+				values["ma"+strconv.Itoa(n)] = ma
+			}
+		}
+		if ma20, ok := values["ma20"]; ok && ma20 > 0 {
+			values["ma20_distance"] = (price - ma20) / ma20 * 100
+		}
+		if progress := calendar.SessionProgress(quote.Market, now); stats.avgVolume > 0 && progress > 0 {
+			values["volume_ratio"] = float64(quote.Volume) / (stats.avgVolume * progress)
+		}
+		res[normalizeTradeStockCode(quote.Code)] = values
+	}
+	return res
+}
+
+// RuleValues 实现 services.RuleValueSource,获取实时行情后计算
+func (a AlertRuleApi) RuleValues(ctx context.Context, stockCodes []string) (map[string]models.RuleValues, error) {
+	stockInfos, err := NewStockDataApi().GetStockCodeRealTimeData(stockCodes...)
+	if err != nil {
+		return nil, err
+	}
+	return a.Values(*stockInfos, time.Now()), nil
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"go-stock/backend/calendar"
+	"go-stock/backend/db"
+	"go-stock/internal/domain/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertRuleRepository(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&AlertRule{})
+	ctx := context.Background()
+	api := NewAlertRuleApi()
+
+	rule := &models.AlertRule{StockCode: "SH600000", Name: "突破", Expression: "price > prev_high", TriggerMode: models.TriggerEdge, Cooldown: 5 * time.Minute, IsActive: true}
+	assert.NoError(t, api.SaveRule(ctx, rule))
+	assert.NotEmpty(t, rule.ID)
+	assert.NoError(t, api.SaveRule(ctx, &models.AlertRule{StockCode: "sz000001", Expression: "change_pct < -5"}))
+
+	rules, err := api.GetActiveRules(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "sh600000", rules[0].StockCode)
+	assert.Equal(t, 5*time.Minute, rules[0].Cooldown)
+	assert.True(t, rules[0].LastTriggered.IsZero())
+
+	now := time.Now().Truncate(time.Second)
+	assert.NoError(t, api.UpdateRuleStatus(ctx, rule.ID, true, true, now))
+	rules, _ = api.GetRules(ctx, "sh600000")
+	assert.True(t, rules[0].Triggered)
+	assert.True(t, now.Equal(rules[0].LastTriggered))
+
+	rule.Expression = "price > ma20"
+	rule.Channels = []string{"1", "3"}
+	assert.NoError(t, api.SaveRule(ctx, rule))
+	assert.Equal(t, "price > ma20", api.List("sh600000")[0].Expression)
+	rules, _ = api.GetRules(ctx, "sh600000")
+	assert.Equal(t, []string{"1", "3"}, rules[0].Channels)
+
+	assert.NoError(t, api.DeleteRule(ctx, rule.ID))
+	assert.Len(t, api.List(""), 1)
+}
+
+func TestAlertRuleValues(t *testing.T) {
+	now := time.Date(2025, 5, 20, 10, 30, 0, 0, calendar.GetExchange(MarketCN).Location)
+	closes := make([]float64, 19)
+	for i := range closes {
+		closes[i] = 10
+	}
+	ruleStatsCache.Store("sh600000|2025-05-20", &ruleDailyStats{closes: closes, prevHigh: 10.4, prevLow: 9.8, avgVolume: 2370000, fetchedAt: now})
+
+	values := NewAlertRuleApi().Values([]StockInfo{
+		{Code: "sh600000", Date: "2025-05-20", Time: "10:30:00", Price: "10.6", PreClose: "10", Open: "10.1", High: "10.6", Low: "10", Volume: "1200000"},
+	}, now)["sh600000"]
+
+	assert.Equal(t, 10.6, values["price"])
+	assert.InDelta(t, 6, values["change_pct"], 1e-9)
+	assert.Equal(t, 1.0, values["new_high"])
+	assert.Equal(t, 0.0, values["new_low"])
+	assert.Equal(t, 10.4, values["prev_high"])
+	assert.InDelta(t, 10.03, values["ma20"], 1e-9)
+	assert.InDelta(t, (10.6-10.03)/10.03*100, values["ma20_distance"], 1e-9)
+	// 10:30 已过60/237的连续竞价时间
+	assert.InDelta(t, 1200000/(2370000*60.0/237), values["volume_ratio"], 1e-9)
+}
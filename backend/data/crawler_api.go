@@ -27,7 +27,7 @@ func (c *CrawlerApi) NewCrawler(ctx context.Context, crawlerBaseInfo CrawlerBase
 	return CrawlerApi{
 		crawlerCtx:      ctx,
 		crawlerBaseInfo: crawlerBaseInfo,
-		pool:            NewBrowserPool(GetConfig().BrowserPoolSize),
+		pool:            NewBrowserPool(ctx, GetConfig().BrowserPoolSize),
 	}
 }
 func (c *CrawlerApi) GetHtml(url, waitVisible string, headless bool) (string, bool) {
@@ -1,8 +1,17 @@
 package data
 
 import (
-	"github.com/go-resty/resty/v2"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-stock/backend/logger"
+	"go-stock/backend/notify"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
 )
 
 // @Author spark
@@ -11,66 +20,230 @@ import (
 //-----------------------------------------------------------------------------------
 
 type DingDingAPI struct {
-	client *resty.Client
+	client  *resty.Client
+	retried func(err error)
 }
 
 func NewDingDingAPI() *DingDingAPI {
 	return &DingDingAPI{
-		client: resty.New(),
+		client: resty.New().SetTimeout(10 * time.Second),
+	}
+}
+
+// OnRetried 被限流的消息重试结束时回调,err 为空表示重试发送成功
+func (d *DingDingAPI) OnRetried(retried func(err error)) *DingDingAPI {
+	d.retried = retried
+	return d
+}
+
+// 钉钉机器人限流错误码:每分钟最多发送20条
+var dingRateLimitCodes = map[int]bool{
+	130101: true,
+	410100: true,
+}
+
+const dingMaxRetries = 3
+
+const DingDingSendSuccess = "发送钉钉消息成功"
+
+const DingDingSendRetrying = "钉钉消息被限流,稍后重试"
+
+var errDingRateLimited = errors.New("钉钉机器人发送太快被限流")
+
+var (
+	dingRetryQueue = make(chan dingRetryItem, 100)
+	dingRetryOnce  sync.Once
+	dingRetryDelay = time.Minute
+)
+
+// dingRetryItem 被限流的消息,等待下一个限流周期后重试
+type dingRetryItem struct {
+	robot    dingRobot
+	body     []byte
+	attempts int
+	retryAt  time.Time
+	retried  func(err error)
+}
+
+func (item dingRetryItem) done(err error) {
+	if item.retried != nil {
+		item.retried(err)
 	}
 }
 
-func (DingDingAPI) SendDingDingMessage(message string) string {
-	if GetConfig().DingPushEnable == false {
+// dingRobot 钉钉机器人配置
+type dingRobot struct {
+	url    string
+	secret string
+	at     At
+}
+
+func newDingRobot(config *Settings) dingRobot {
+	robot := dingRobot{url: config.DingRobot, secret: config.DingSecret}
+	robot.at.AtMobiles = splitList(config.DingAtMobiles)
+	robot.at.AtUserIds = splitList(config.DingAtUserIds)
+	//未配置@对象时保持原来@所有人的行为
+	robot.at.IsAtAll = len(robot.at.AtMobiles) == 0 && len(robot.at.AtUserIds) == 0
+	return robot
+}
+
+func splitList(s string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func (d DingDingAPI) SendDingDingMessage(message string) string {
+	config := GetConfig()
+	if config.DingPushEnable == false {
 		//logger.SugaredLogger.Info("钉钉推送未开启")
 		return "钉钉推送未开启"
 	}
-	// 发送钉钉消息
-	resp, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(message).
-		Post(getApiURL())
-	if err != nil {
-		logger.SugaredLogger.Error(err.Error())
-		return "发送钉钉消息失败"
+	robot := newDingRobot(config)
+	body := []byte(message)
+	//前端生成的消息没有指定@对象时使用配置的@对象
+	msg := make(map[string]any)
+	if err := json.Unmarshal(body, &msg); err == nil {
+		if _, ok := msg["at"]; !ok && (msg["msgtype"] == "markdown" || msg["msgtype"] == "text") {
+			msg["at"] = robot.at
+			body, _ = json.Marshal(msg)
+		}
 	}
-	logger.SugaredLogger.Infof("send dingding message: %s", resp.String())
-	return "发送钉钉消息成功"
+	return d.result(robot, body)
 }
 func GetConfig() *Settings {
 	return NewSettingsApi(&Settings{}).GetConfig()
 }
-func getApiURL() string {
-	return GetConfig().DingRobot
+
+func (d DingDingAPI) SendToDingDing(title, message string) string {
+	robot := newDingRobot(GetConfig())
+	return d.Send(&Message{
+		Msgtype: "markdown",
+		Markdown: &Markdown{
+			Title: "go-stock " + title,
+			Text:  message,
+		},
+		At: &robot.at,
+	})
 }
 
-func (DingDingAPI) SendToDingDing(title, message string) string {
-	// 发送钉钉消息
-	resp, err := resty.New().R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(&Message{
-			Msgtype: "markdown",
-			Markdown: Markdown{
-				Title: "go-stock " + title,
-				Text:  message,
-			},
-			At: At{
-				IsAtAll: true,
-			},
-		}).
-		Post(getApiURL())
+// SendAlert 以 ActionCard 发送报警,附带查看行情按钮
+func (d DingDingAPI) SendAlert(title, message, stockCode string) string {
+	return d.Send(AlertActionCard(title, message, stockCode))
+}
+
+// SendAIReport 以 ActionCard 发送 AI 分析报告
+func (d DingDingAPI) SendAIReport(stockName, stockCode, report string) string {
+	return d.Send(AIReportActionCard(stockName, stockCode, report))
+}
+
+// Send 发送消息,被限流时加入重试队列
+func (d DingDingAPI) Send(msg *Message) string {
+	body, err := json.Marshal(msg)
 	if err != nil {
 		logger.SugaredLogger.Error(err.Error())
 		return "发送钉钉消息失败"
 	}
+	return d.result(newDingRobot(GetConfig()), body)
+}
+
+func (d DingDingAPI) result(robot dingRobot, body []byte) string {
+	err := d.send(robot, body)
+	if errors.Is(err, errDingRateLimited) {
+		return DingDingSendRetrying
+	}
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "发送钉钉消息失败"
+	}
+	return DingDingSendSuccess
+}
+
+func (d DingDingAPI) send(robot dingRobot, body []byte) error {
+	err := d.post(robot, body)
+	if errors.Is(err, errDingRateLimited) && !d.retry(dingRetryItem{robot: robot, body: body, attempts: 1, retryAt: time.Now().Add(dingRetryDelay), retried: d.retried}) {
+		return errors.New("钉钉机器人被限流且重试队列已满")
+	}
+	return err
+}
+
+// post 发送一次,被限流时返回 errDingRateLimited
+func (d DingDingAPI) post(robot dingRobot, body []byte) error {
+	if robot.url == "" {
+		return errors.New("未配置钉钉机器人接口地址")
+	}
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	resp, err := d.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(notify.DingTalkSignedURL(robot.url, robot.secret, time.Now()))
+	if err != nil {
+		return err
+	}
 	logger.SugaredLogger.Infof("send dingding message: %s", resp.String())
-	return "发送钉钉消息成功"
+	if err := json.Unmarshal(resp.Body(), &res); err != nil {
+		return fmt.Errorf("钉钉响应格式错误:%s", resp.String())
+	}
+	if dingRateLimitCodes[res.ErrCode] {
+		return fmt.Errorf("%w: %s", errDingRateLimited, res.ErrMsg)
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("钉钉发送失败 %d: %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// retry 加入重试队列,队列满时丢弃并返回 false
+func (d DingDingAPI) retry(item dingRetryItem) bool {
+	dingRetryOnce.Do(func() {
+		go d.retryLoop()
+	})
+	select {
+	case dingRetryQueue <- item:
+		logger.SugaredLogger.Infof("钉钉消息被限流,第%d次重试将在%s后进行", item.attempts, time.Until(item.retryAt).Round(time.Second))
+		return true
+	default:
+		logger.SugaredLogger.Error("钉钉重试队列已满,丢弃消息")
+		return false
+	}
+}
+
+// retryLoop 按入队顺序依次重试
+func (d DingDingAPI) retryLoop() {
+	for item := range dingRetryQueue {
+		if wait := time.Until(item.retryAt); wait > 0 {
+			time.Sleep(wait)
+		}
+		err := d.post(item.robot, item.body)
+		switch {
+		case errors.Is(err, errDingRateLimited) && item.attempts < dingMaxRetries:
+			item.attempts++
+			item.retryAt = time.Now().Add(dingRetryDelay)
+			if !d.retry(item) {
+				item.done(errors.New("钉钉重试队列已满"))
+			}
+		case err != nil:
+			logger.SugaredLogger.Errorf("钉钉消息重试失败:%s", err.Error())
+			item.done(err)
+		default:
+			item.done(nil)
+		}
+	}
 }
 
 type Message struct {
-	Msgtype  string   `json:"msgtype"`
-	Markdown Markdown `json:"markdown"`
-	At       At       `json:"at"`
+	Msgtype    string      `json:"msgtype"`
+	Markdown   *Markdown   `json:"markdown,omitempty"`
+	ActionCard *ActionCard `json:"actionCard,omitempty"`
+	FeedCard   *FeedCard   `json:"feedCard,omitempty"`
+	At         *At         `json:"at,omitempty"` //仅 markdown 和 text 消息支持@
 }
 
 type Markdown struct {
@@ -78,8 +251,83 @@ type Markdown struct {
 	Text  string `json:"text"`
 }
 
+type ActionCard struct {
+	Title          string          `json:"title"`
+	Text           string          `json:"text"`
+	BtnOrientation string          `json:"btnOrientation,omitempty"` //0:按钮竖直排列 1:横向排列
+	SingleTitle    string          `json:"singleTitle,omitempty"`
+	SingleURL      string          `json:"singleURL,omitempty"`
+	Btns           []ActionCardBtn `json:"btns,omitempty"`
+}
+
+type ActionCardBtn struct {
+	Title     string `json:"title"`
+	ActionURL string `json:"actionURL"`
+}
+
+type FeedCard struct {
+	Links []FeedCardLink `json:"links"`
+}
+
+type FeedCardLink struct {
+	Title      string `json:"title"`
+	MessageURL string `json:"messageURL"`
+	PicURL     string `json:"picURL"`
+}
+
 type At struct {
 	AtMobiles []string `json:"atMobiles"`
 	AtUserIds []string `json:"atUserIds"`
 	IsAtAll   bool     `json:"isAtAll"`
 }
+
+// StockQuoteURL 雪球行情页地址
+func StockQuoteURL(stockCode string) string {
+	code := strings.ToLower(stockCode)
+	switch {
+	case strings.HasPrefix(code, "hk"):
+		code = strings.TrimPrefix(code, "hk")
+	case strings.HasPrefix(code, "gb_"):
+		code = strings.TrimPrefix(code, "gb_")
+	case strings.HasPrefix(code, "us"):
+		code = strings.TrimPrefix(code, "us")
+	}
+	return "https://xueqiu.com/S/" + strings.ToUpper(code)
+}
+
+// AlertActionCard 报警消息模板
+func AlertActionCard(title, message, stockCode string) *Message {
+	return &Message{
+		Msgtype: "actionCard",
+		ActionCard: &ActionCard{
+			Title:       "go-stock " + title,
+			Text:        "### " + title + "\n\n" + strings.ReplaceAll(message, "\n", "\n\n"),
+			SingleTitle: "查看行情",
+			SingleURL:   StockQuoteURL(stockCode),
+		},
+	}
+}
+
+// AIReportActionCard AI分析报告模板
+func AIReportActionCard(stockName, stockCode, report string) *Message {
+	return &Message{
+		Msgtype: "actionCard",
+		ActionCard: &ActionCard{
+			Title:          "go-stock AI分析 " + stockName,
+			Text:           fmt.Sprintf("### %s(%s) AI分析\n\n%s", stockName, stockCode, report),
+			BtnOrientation: "1",
+			Btns: []ActionCardBtn{
+				{Title: "查看行情", ActionURL: StockQuoteURL(stockCode)},
+				{Title: "问财", ActionURL: "https://www.iwencai.com/unifiedwap/result?w=" + url.QueryEscape(stockName)},
+			},
+		},
+	}
+}
+
+// NewFeedCard FeedCard 消息,用于汇总多条资讯/报告
+func NewFeedCard(links ...FeedCardLink) *Message {
+	return &Message{
+		Msgtype:  "feedCard",
+		FeedCard: &FeedCard{Links: links},
+	}
+}
@@ -1,8 +1,18 @@
 package data
 
 import (
-	"github.com/go-resty/resty/v2"
+	"encoding/json"
+	"go-stock/backend/notify"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
 )
 
 // @Author spark
@@ -30,3 +40,56 @@ func TestRobot(t *testing.T) {
 	}
 	t.Log(resp.String())
 }
+
+func TestDingRobotRetry(t *testing.T) {
+	delay := dingRetryDelay
+	t.Cleanup(func() { dingRetryDelay = delay })
+	dingRetryDelay = 10 * time.Millisecond
+	var hits atomic.Int32
+	var query url.Values
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		json.NewDecoder(r.Body).Decode(&body)
+		if hits.Add(1) == 1 {
+			w.Write([]byte(`{"errcode":130101,"errmsg":"send too fast, exceed 20 times per minute"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	robot := newDingRobot(&Settings{DingRobot: server.URL + "/robot/send?access_token=abc", DingSecret: "SEC", DingAtMobiles: "138, 139"})
+	assert.Equal(t, []string{"138", "139"}, robot.at.AtMobiles)
+	assert.False(t, robot.at.IsAtAll)
+	assert.True(t, newDingRobot(&Settings{}).at.IsAtAll)
+
+	msg, _ := json.Marshal(AlertActionCard("报警规则:突破", "sh600000 price > prev_high", "sh600000"))
+	retried := make(chan error, 1)
+	api := NewDingDingAPI().OnRetried(func(err error) { retried <- err })
+	assert.Equal(t, "钉钉消息被限流,稍后重试", api.result(robot, msg))
+	select {
+	case err := <-retried:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("钉钉重试未回调")
+	}
+	assert.Equal(t, int32(2), hits.Load())
+	assert.Equal(t, "abc", query.Get("access_token"))
+	timestamp, _ := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	assert.Equal(t, notify.DingTalkSign(timestamp, "SEC"), query.Get("sign"))
+	assert.Equal(t, "actionCard", body["msgtype"])
+	assert.Equal(t, "https://xueqiu.com/S/SH600000", body["actionCard"].(map[string]any)["singleURL"])
+
+	assert.Equal(t, "发送钉钉消息成功", NewDingDingAPI().result(robot, msg))
+}
+
+func TestDingTemplates(t *testing.T) {
+	assert.Equal(t, "https://xueqiu.com/S/00700", StockQuoteURL("hk00700"))
+	assert.Equal(t, "https://xueqiu.com/S/AAPL", StockQuoteURL("gb_aapl"))
+	report := AIReportActionCard("浦发银行", "sh600000", "观望")
+	assert.Len(t, report.ActionCard.Btns, 2)
+	assert.Contains(t, report.ActionCard.Text, "浦发银行(sh600000)")
+	feed, _ := json.Marshal(NewFeedCard(FeedCardLink{Title: "报告", MessageURL: "https://example.com"}))
+	assert.JSONEq(t, `{"msgtype":"feedCard","feedCard":{"links":[{"title":"报告","messageURL":"https://example.com","picURL":""}]}}`, string(feed))
+}
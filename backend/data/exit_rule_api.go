@@ -0,0 +1,444 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/indicator"
+	"go-stock/backend/logger"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/31 10:20
+// @Desc 持仓止盈止损:移动止损(回撤百分比/ATR)、分档止盈和时间止损
+// -----------------------------------------------------------------------------------
+
+// 持仓离场报警类型
+const (
+	ExitAlertTrailingStop = "移动止损"
+	ExitAlertTakeProfit   = "止盈"
+	ExitAlertTimeStop     = "时间止损"
+)
+
+const defaultATRPeriod = 14
+
+// ExitRule 持仓离场规则,每只股票一条。建仓以来的最高价和已提醒状态随行情持久化,重新建仓后重置
+type ExitRule struct {
+	gorm.Model
+	StockCode         string  `json:"stockCode" gorm:"uniqueIndex"`
+	StockName         string  `json:"stockName"`
+	Enabled           bool    `json:"enabled"`
+	TrailingPercent   float64 `json:"trailingPercent"`   //从最高价回撤百分比止损,0不启用
+	TrailingATR       float64 `json:"trailingATR"`       //从最高价回撤N倍ATR止损,0不启用
+	ATRPeriod         int     `json:"atrPeriod"`         //ATR周期(日),默认14
+	TakeProfitLevels  string  `json:"takeProfitLevels"`  //止盈档位,相对成本的收益率(%),逗号分隔,如 10,20,30
+	TimeStopDays      int     `json:"timeStopDays"`      //持仓超过N个自然日,0不启用
+	TimeStopMinProfit float64 `json:"timeStopMinProfit"` //且收益率(%)低于该值时提醒
+
+	EntryDate     string  `json:"entryDate"`     //建仓日期
+	HighWaterMark float64 `json:"highWaterMark"` //建仓以来最高价
+	HighWaterDate string  `json:"highWaterDate"` //最高价日期
+	TakeProfitHit string  `json:"takeProfitHit"` //已提醒的止盈档位
+	TrailingFired bool    `json:"trailingFired"` //已提醒移动止损,创新高后重置
+	TimeStopFired bool    `json:"timeStopFired"` //已提醒时间止损
+	LastStopPrice float64 `json:"lastStopPrice"` //最近计算的止损价
+}
+
+func (ExitRule) TableName() string {
+	return "exit_rule"
+}
+
+// parseLevels 解析逗号分隔的百分比档位,升序去重
+func parseLevels(s string) ([]float64, error) {
+	levels := make([]float64, 0)
+	for _, item := range splitList(strings.ReplaceAll(s, "%", "")) {
+		v, err := strconv.ParseFloat(item, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("止盈档位错误:%s", item)
+		}
+		levels = append(levels, v)
+	}
+	sort.Float64s(levels)
+	res := make([]float64, 0, len(levels))
+	for i, v := range levels {
+		if i == 0 || v != levels[i-1] {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func formatLevels(levels []float64) string {
+	items := make([]string, 0, len(levels))
+	for _, v := range levels {
+		items = append(items, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return strings.Join(items, ",")
+}
+
+// Validate 校验规则配置
+func (r *ExitRule) Validate() error {
+	if r.StockCode == "" {
+		return errors.New("股票代码不能为空")
+	}
+	if r.TrailingPercent < 0 || r.TrailingPercent >= 100 {
+		return errors.New("回撤百分比应在0-100之间")
+	}
+	if r.TrailingATR < 0 || r.ATRPeriod < 0 || r.TimeStopDays < 0 {
+		return errors.New("参数不能为负数")
+	}
+	levels, err := parseLevels(r.TakeProfitLevels)
+	if err != nil {
+		return err
+	}
+	if r.TrailingPercent == 0 && r.TrailingATR == 0 && len(levels) == 0 && r.TimeStopDays == 0 {
+		return errors.New("至少设置一种止盈止损条件")
+	}
+	r.TakeProfitLevels = formatLevels(levels)
+	return nil
+}
+
+// ExitContext 评估离场规则所需的持仓和行情
+type ExitContext struct {
+	Price       float64   //最新价
+	DayHigh     float64   //当日最高价
+	CostPrice   float64   //每股成本
+	EntryDate   string    //建仓日期
+	HistoryHigh float64   //建仓日至昨日的最高价(前复权),用于初始化最高价,并在除权除息后重新计算最高价
+	ATR         float64   //日线ATR,仅 TrailingATR>0 时需要
+	Now         time.Time //当前时间
+}
+
+// ExitSignal 离场报警
+type ExitSignal struct {
+	Type          string  `json:"type"`
+	StockCode     string  `json:"stockCode"`
+	StockName     string  `json:"stockName"`
+	Price         float64 `json:"price"`
+	CostPrice     float64 `json:"costPrice"`
+	Profit        float64 `json:"profit"` //收益率(%)
+	HighWaterMark float64 `json:"highWaterMark"`
+	StopPrice     float64 `json:"stopPrice"` //移动止损价
+	Level         float64 `json:"level"`     //触发的止盈档位(%)
+	HoldingDays   int     `json:"holdingDays"`
+}
+
+// Message 报警内容
+func (s ExitSignal) Message() string {
+	var detail string
+	switch s.Type {
+	case ExitAlertTrailingStop:
+		detail = fmt.Sprintf("最高价:%.2f 止损价:%.2f", s.HighWaterMark, s.StopPrice)
+	case ExitAlertTakeProfit:
+		detail = fmt.Sprintf("达到止盈档位:%.2f%%", s.Level)
+	case ExitAlertTimeStop:
+		detail = fmt.Sprintf("已持有%d天", s.HoldingDays)
+	}
+	return fmt.Sprintf("[%s] %s 现价:%.2f 成本:%.2f 收益率:%.2f%% %s", s.StockName, s.Type, s.Price, s.CostPrice, s.Profit, detail)
+}
+
+// reset 新建仓时重置跟踪状态
+func (r *ExitRule) reset(entryDate string, historyHigh float64) {
+	r.EntryDate = entryDate
+	r.HighWaterMark = historyHigh
+	r.HighWaterDate = ""
+	if historyHigh > 0 {
+		r.HighWaterDate = entryDate
+	}
+	r.TakeProfitHit = ""
+	r.TrailingFired = false
+	r.TimeStopFired = false
+	r.LastStopPrice = 0
+}
+
+// StopPrice 移动止损价,同时设置百分比和ATR时取较高(较紧)的止损价
+func (r *ExitRule) StopPrice(atr float64) float64 {
+	if r.HighWaterMark <= 0 {
+		return 0
+	}
+	stop := 0.0
+	if r.TrailingPercent > 0 {
+		stop = r.HighWaterMark * (1 - r.TrailingPercent/100)
+	}
+	if r.TrailingATR > 0 && atr > 0 {
+		stop = math.Max(stop, r.HighWaterMark-r.TrailingATR*atr)
+	}
+	return mathutil.RoundToFloat(stop, 3)
+}
+
+// Evaluate 更新最高价并返回新触发的报警,每个条件只提醒一次:
+// 移动止损在创新高后可再次提醒,止盈每个档位提醒一次,时间止损每次建仓提醒一次
+func (r *ExitRule) Evaluate(ctx ExitContext) []ExitSignal {
+	if ctx.Price <= 0 || ctx.CostPrice <= 0 {
+		return nil
+	}
+	today := ctx.Now.Format(time.DateOnly)
+	if ctx.EntryDate != r.EntryDate {
+		r.reset(ctx.EntryDate, ctx.HistoryHigh)
+	} else if ctx.HistoryHigh > 0 && r.HighWaterDate != today {
+		//以前交易日的最高价按前复权K线重新计算,除权除息后最高价随之下调,避免误触发移动止损
+		r.HighWaterMark = ctx.HistoryHigh
+	}
+	if high := math.Max(ctx.DayHigh, ctx.Price); high > r.HighWaterMark {
+		r.HighWaterMark = high
+		r.HighWaterDate = today
+		r.TrailingFired = false
+	}
+	profit := mathutil.RoundToFloat((ctx.Price-ctx.CostPrice)/ctx.CostPrice*100, 2)
+	signal := func(alertType string) ExitSignal {
+		return ExitSignal{
+			Type:          alertType,
+			StockCode:     r.StockCode,
+			StockName:     r.StockName,
+			Price:         ctx.Price,
+			CostPrice:     ctx.CostPrice,
+			Profit:        profit,
+			HighWaterMark: r.HighWaterMark,
+		}
+	}
+	res := make([]ExitSignal, 0)
+
+	r.LastStopPrice = r.StopPrice(ctx.ATR)
+	if r.LastStopPrice > 0 && ctx.Price <= r.LastStopPrice && !r.TrailingFired {
+		r.TrailingFired = true
+		s := signal(ExitAlertTrailingStop)
+		s.StopPrice = r.LastStopPrice
+		res = append(res, s)
+	}
+
+	//同时越过多个档位时只提醒最高的一档
+	levels, _ := parseLevels(r.TakeProfitLevels)
+	hit, _ := parseLevels(r.TakeProfitHit)
+	crossed := 0.0
+	for _, level := range levels {
+		if profit >= level && !containsLevel(hit, level) {
+			hit = append(hit, level)
+			crossed = level
+		}
+	}
+	if crossed > 0 {
+		sort.Float64s(hit)
+		r.TakeProfitHit = formatLevels(hit)
+		s := signal(ExitAlertTakeProfit)
+		s.Level = crossed
+		res = append(res, s)
+	}
+
+	if r.TimeStopDays > 0 && !r.TimeStopFired && r.EntryDate != "" {
+		entry, err := time.ParseInLocation(time.DateOnly, r.EntryDate, ctx.Now.Location())
+		if err == nil {
+			days := int(ctx.Now.Sub(entry).Hours() / 24)
+			if days >= r.TimeStopDays && profit < r.TimeStopMinProfit {
+				r.TimeStopFired = true
+				s := signal(ExitAlertTimeStop)
+				s.HoldingDays = days
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+func containsLevel(levels []float64, level float64) bool {
+	for _, v := range levels {
+		if v == level {
+			return true
+		}
+	}
+	return false
+}
+
+// PositionEntryDate 当前持仓的建仓日期,即合计持仓最近一次由0变为正数的日期。没有持仓时返回零值
+func PositionEntryDate(trades []Trade) time.Time {
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].TradeDate.Equal(sorted[j].TradeDate) {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].TradeDate.Before(sorted[j].TradeDate)
+	})
+	var quantity int64
+	var entry time.Time
+	for _, trade := range sorted {
+		switch trade.Type {
+		case TradeBuy:
+			if quantity <= 0 {
+				entry = trade.TradeDate
+			}
+			quantity += trade.Quantity
+		case TradeSell:
+			quantity -= trade.Quantity
+		case TradeSplit:
+			if trade.Ratio > 0 {
+				quantity = int64(math.Round(float64(quantity) * trade.Ratio))
+			}
+		}
+	}
+	if quantity <= 0 {
+		return time.Time{}
+	}
+	return entry
+}
+
+// exitDailyStats 由历史K线计算的当日不变的统计值
+type exitDailyStats struct {
+	bars      []indicator.Bar //截至昨日的日K线(前复权)
+	fetchedAt time.Time
+}
+
+// highSince 某日(含)至昨日的最高价
+func (s *exitDailyStats) highSince(date string) float64 {
+	high := 0.0
+	for _, bar := range s.bars {
+		if bar.Day >= date {
+			high = math.Max(high, bar.High)
+		}
+	}
+	return high
+}
+
+// atr 截至昨日的ATR,K线不足时返回0
+func (s *exitDailyStats) atr(n int) float64 {
+	v := indicator.ATR(s.bars, n).Last()
+	if math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// 每只股票每天只计算一次
+var exitStatsCache sync.Map
+
+type ExitRuleApi struct {
+	dao *gorm.DB
+}
+
+func NewExitRuleApi() *ExitRuleApi {
+	return &ExitRuleApi{dao: db.Dao}
+}
+
+func (e ExitRuleApi) GetRules() []ExitRule {
+	var rules []ExitRule
+	e.dao.Model(&ExitRule{}).Order("id asc").Find(&rules)
+	return rules
+}
+
+// SaveRule 新增或修改规则,修改时保留已跟踪的最高价和提醒状态
+func (e ExitRuleApi) SaveRule(rule ExitRule) error {
+	rule.StockCode = normalizeTradeStockCode(rule.StockCode)
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	existing := &ExitRule{}
+	if err := e.dao.Where("stock_code = ?", rule.StockCode).First(existing).Error; err == nil {
+		if rule.ID != 0 && rule.ID != existing.ID {
+			return fmt.Errorf("%s 已存在止盈止损规则", rule.StockCode)
+		}
+		return e.dao.Model(existing).Select("stock_name", "enabled", "trailing_percent", "trailing_atr", "atr_period",
+			"take_profit_levels", "time_stop_days", "time_stop_min_profit").Updates(&rule).Error
+	}
+	rule.ID = 0
+	return e.dao.Create(&rule).Error
+}
+
+func (e ExitRuleApi) DeleteRule(id uint) error {
+	return e.dao.Unscoped().Delete(&ExitRule{}, id).Error
+}
+
+func (e ExitRuleApi) dailyStats(code, date string) *exitDailyStats {
+	key := code + "|" + date
+	if cached, ok := exitStatsCache.Load(key); ok {
+		stats := cached.(*exitDailyStats)
+		//获取K线失败时10分钟后重试
+		if len(stats.bars) > 0 || time.Since(stats.fetchedAt) < 10*time.Minute {
+			return stats
+		}
+	}
+	K := make([]KLineData, 0)
+	for _, k := range *NewKLineStoreApi().GetKLine(code, KLinePeriodDay, KLineAdjustQfq, 250) {
+		if k.Day < date {
+			K = append(K, k)
+		}
+	}
+	stats := &exitDailyStats{bars: KLineBars(K), fetchedAt: time.Now()}
+	exitStatsCache.Store(key, stats)
+	return stats
+}
+
+// entryDate 建仓日期:有交易流水时取流水,否则为首次检测到持仓的日期
+func (e ExitRuleApi) entryDate(rule *ExitRule, today string) string {
+	if entry := PositionEntryDate(NewTradeApi().GetTrades(rule.StockCode, "")); !entry.IsZero() {
+		return entry.Format(time.DateOnly)
+	}
+	if rule.EntryDate != "" {
+		return rule.EntryDate
+	}
+	return today
+}
+
+// Check 按最新行情评估已启用的规则,持久化最高价和提醒状态,返回新触发的报警。
+// 成本和持仓数量取 StockInfo 中由交易流水或手工设置计算的值,没有持仓的股票跳过
+func (e ExitRuleApi) Check(stockInfos []StockInfo, now time.Time) []ExitSignal {
+	var rules []ExitRule
+	e.dao.Model(&ExitRule{}).Where("enabled = ?", true).Find(&rules)
+	if len(rules) == 0 {
+		return nil
+	}
+	stockInfoMap := make(map[string]*StockInfo, len(stockInfos))
+	for i := range stockInfos {
+		stockInfoMap[normalizeTradeStockCode(stockInfos[i].Code)] = &stockInfos[i]
+	}
+	today := now.Format(time.DateOnly)
+	res := make([]ExitSignal, 0)
+	for i := range rules {
+		rule := &rules[i]
+		stockInfo, ok := stockInfoMap[rule.StockCode]
+		if !ok || stockInfo.CostPrice <= 0 || stockInfo.CostVolume <= 0 {
+			continue
+		}
+		price, _ := convertor.ToFloat(stockInfo.Price)
+		high, _ := convertor.ToFloat(stockInfo.High)
+		if price <= 0 {
+			continue
+		}
+		ctx := ExitContext{
+			Price:     price,
+			DayHigh:   high,
+			CostPrice: stockInfo.CostPrice,
+			EntryDate: e.entryDate(rule, today),
+			Now:       now,
+		}
+		needStats := ctx.EntryDate != rule.EntryDate || rule.TrailingPercent > 0 || rule.TrailingATR > 0
+		if needStats {
+			stats := e.dailyStats(stockInfo.Code, today)
+			ctx.HistoryHigh = stats.highSince(ctx.EntryDate)
+			period := rule.ATRPeriod
+			if period <= 0 {
+				period = defaultATRPeriod
+			}
+			ctx.ATR = stats.atr(period)
+		}
+		if rule.StockName == "" {
+			rule.StockName = stockInfo.Name
+		}
+		signals := rule.Evaluate(ctx)
+		err := e.dao.Model(rule).Select("stock_name", "entry_date", "high_water_mark", "high_water_date", "take_profit_hit",
+			"trailing_fired", "time_stop_fired", "last_stop_price").Updates(rule).Error
+		if err != nil {
+			logger.SugaredLogger.Errorf("保存止盈止损状态失败 %s:%s", rule.StockCode, err.Error())
+		}
+		res = append(res, signals...)
+	}
+	return res
+}
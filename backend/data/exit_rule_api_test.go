@@ -0,0 +1,162 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitRuleTrailingStop(t *testing.T) {
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	rule := &ExitRule{StockCode: "sh600000", StockName: "浦发银行", TrailingPercent: 10}
+	ctx := ExitContext{Price: 11, DayHigh: 11.5, CostPrice: 10, EntryDate: "2025-05-01", HistoryHigh: 12, Now: now}
+
+	//建仓以来最高价取历史K线和当日最高价的较大值
+	assert.Empty(t, rule.Evaluate(ctx))
+	assert.Equal(t, 12.0, rule.HighWaterMark)
+	assert.Equal(t, "2025-05-01", rule.HighWaterDate)
+
+	ctx.Price, ctx.DayHigh = 10.8, 11.5
+	signals := rule.Evaluate(ctx)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, ExitAlertTrailingStop, signals[0].Type)
+	assert.Equal(t, 10.8, signals[0].StopPrice)
+	assert.Contains(t, signals[0].Message(), "止损价:10.80")
+
+	//已提醒后不再重复,创新高后重新生效
+	ctx.Price = 10.5
+	assert.Empty(t, rule.Evaluate(ctx))
+	ctx.Price, ctx.DayHigh = 13, 13
+	assert.Empty(t, rule.Evaluate(ctx))
+	assert.Equal(t, "2025-05-20", rule.HighWaterDate)
+	ctx.Price = 11.7
+	assert.Len(t, rule.Evaluate(ctx), 1)
+
+	//重新建仓时重置最高价
+	ctx = ExitContext{Price: 9, DayHigh: 9.2, CostPrice: 9, EntryDate: "2025-05-20", Now: now}
+	assert.Empty(t, rule.Evaluate(ctx))
+	assert.Equal(t, 9.2, rule.HighWaterMark)
+	assert.False(t, rule.TrailingFired)
+}
+
+func TestExitRuleExDividend(t *testing.T) {
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	rule := &ExitRule{StockCode: "sh600000", TrailingPercent: 10, EntryDate: "2025-05-01", HighWaterMark: 12, HighWaterDate: "2025-05-15"}
+	//除息1.2元后前复权最高价为10.8,现价10.5未回撤10%
+	ctx := ExitContext{Price: 10.5, DayHigh: 10.6, CostPrice: 9, EntryDate: "2025-05-01", HistoryHigh: 10.8, Now: now}
+	assert.Empty(t, rule.Evaluate(ctx))
+	assert.Equal(t, 10.8, rule.HighWaterMark)
+	assert.Equal(t, 9.72, rule.LastStopPrice)
+
+	//当日创出的最高价不被历史最高价覆盖
+	ctx.Price, ctx.DayHigh = 11, 11.2
+	rule.Evaluate(ctx)
+	ctx.Price = 10.9
+	rule.Evaluate(ctx)
+	assert.Equal(t, 11.2, rule.HighWaterMark)
+}
+
+func TestExitRuleATRStop(t *testing.T) {
+	rule := &ExitRule{TrailingPercent: 20, TrailingATR: 2, HighWaterMark: 20}
+	//ATR止损价 20-2*0.5=19 高于百分比止损价 16,取较紧的
+	assert.Equal(t, 19.0, rule.StopPrice(0.5))
+	assert.Equal(t, 16.0, rule.StopPrice(0))
+	rule.TrailingPercent = 0
+	assert.Equal(t, 0.0, rule.StopPrice(0))
+}
+
+func TestExitRuleTakeProfitAndTimeStop(t *testing.T) {
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	rule := &ExitRule{StockCode: "sz000001", TakeProfitLevels: "20, 10,30%", TimeStopDays: 30, TimeStopMinProfit: 5}
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, "10,20,30", rule.TakeProfitLevels)
+
+	ctx := ExitContext{Price: 11.5, CostPrice: 10, EntryDate: "2025-05-01", Now: now}
+	signals := rule.Evaluate(ctx)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, ExitAlertTakeProfit, signals[0].Type)
+	assert.Equal(t, 10.0, signals[0].Level)
+	assert.Empty(t, rule.Evaluate(ctx))
+
+	//同时越过多个档位只提醒最高档
+	ctx.Price = 13.2
+	signals = rule.Evaluate(ctx)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, 30.0, signals[0].Level)
+	assert.Equal(t, "10,20,30", rule.TakeProfitHit)
+
+	//持有满30天且收益率低于5%
+	ctx.Price = 10.2
+	ctx.Now = time.Date(2025, 5, 30, 10, 0, 0, 0, time.Local)
+	assert.Empty(t, rule.Evaluate(ctx))
+	ctx.Now = time.Date(2025, 5, 31, 10, 0, 0, 0, time.Local)
+	signals = rule.Evaluate(ctx)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, ExitAlertTimeStop, signals[0].Type)
+	assert.Equal(t, 30, signals[0].HoldingDays)
+	assert.Empty(t, rule.Evaluate(ctx))
+
+	assert.Error(t, (&ExitRule{StockCode: "sz000001"}).Validate())
+	assert.Error(t, (&ExitRule{StockCode: "sz000001", TakeProfitLevels: "10,abc"}).Validate())
+}
+
+func TestPositionEntryDate(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 0, 0, 0, 0, time.Local) }
+	trades := []Trade{
+		{Type: TradeBuy, Quantity: 100, TradeDate: day(1)},
+		{Type: TradeBuy, Quantity: 100, TradeDate: day(5)},
+		{Type: TradeSell, Quantity: 200, TradeDate: day(8)},
+		{Type: TradeBuy, Quantity: 100, TradeDate: day(12)},
+		{Type: TradeSplit, Ratio: 2, TradeDate: day(13)},
+		{Type: TradeBuy, Quantity: 100, TradeDate: day(15)},
+	}
+	assert.Equal(t, day(12), PositionEntryDate(trades))
+	assert.True(t, PositionEntryDate(trades[:3]).IsZero())
+	assert.Equal(t, day(1), PositionEntryDate(trades[:2]))
+}
+
+func TestExitRuleApiCheck(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&ExitRule{}, &Trade{})
+	api := ExitRuleApi{dao: db.Dao}
+
+	assert.Error(t, api.SaveRule(ExitRule{StockCode: "sh600000", Enabled: true}))
+	assert.NoError(t, api.SaveRule(ExitRule{StockCode: "SH600000", Enabled: true, TrailingPercent: 5}))
+	rules := api.GetRules()
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "sh600000", rules[0].StockCode)
+	//手工设置成本的持仓,建仓日期为首次检测到持仓的日期
+	db.Dao.Model(&rules[0]).Updates(map[string]any{"entry_date": "2025-05-20", "high_water_mark": 11})
+
+	now := time.Date(2025, 5, 20, 10, 0, 0, 0, time.Local)
+	//没有历史K线时沿用已记录的最高价
+	exitStatsCache.Store("sh600000|2025-05-20", &exitDailyStats{fetchedAt: time.Now()})
+	defer exitStatsCache.Delete("sh600000|2025-05-20")
+	stockInfos := []StockInfo{
+		{Code: "sh600000", Name: "浦发银行", Price: "10.4", High: "10.6", PreClose: "10.5", CostPrice: 10, CostVolume: 100},
+		{Code: "sz000001", Name: "平安银行", Price: "9", CostPrice: 10, CostVolume: 100},
+	}
+	signals := api.Check(stockInfos, now)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, ExitAlertTrailingStop, signals[0].Type)
+	assert.Equal(t, "浦发银行", signals[0].StockName)
+	assert.Empty(t, api.Check(stockInfos, now))
+
+	//修改配置时保留跟踪状态
+	assert.NoError(t, api.SaveRule(ExitRule{StockCode: "sh600000", Enabled: true, TrailingPercent: 8, TakeProfitLevels: "20"}))
+	rules = api.GetRules()
+	assert.Len(t, rules, 1)
+	assert.Equal(t, 8.0, rules[0].TrailingPercent)
+	assert.Equal(t, 11.0, rules[0].HighWaterMark)
+	assert.True(t, rules[0].TrailingFired)
+
+	//没有持仓时跳过
+	stockInfos[0].CostVolume = 0
+	assert.Empty(t, api.Check(stockInfos, now))
+
+	assert.NoError(t, api.DeleteRule(rules[0].ID))
+	assert.Empty(t, api.GetRules())
+}
@@ -0,0 +1,308 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/indicator"
+	"go-stock/backend/logger"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/5/14 14:05
+// @Desc 本地K线存储,增量同步
+// -----------------------------------------------------------------------------------
+
+const (
+	KLinePeriodDay  = "day"
+	KLinePeriodWeek = "week"
+
+	KLineAdjustNone = ""
+	KLineAdjustQfq  = "qfq"
+)
+
+// 同步后多长时间内直接使用本地数据
+const kLineFreshDuration = time.Minute * 5
+
+// StockKLine 本地K线,按 股票代码/周期/复权方式/日期 唯一
+type StockKLine struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	Code      string    `json:"code" gorm:"uniqueIndex:idx_stock_kline_key,priority:1"`
+	Period    string    `json:"period" gorm:"uniqueIndex:idx_stock_kline_key,priority:2"`
+	Adjust    string    `json:"adjust" gorm:"uniqueIndex:idx_stock_kline_key,priority:3"`
+	Day       string    `json:"day" gorm:"uniqueIndex:idx_stock_kline_key,priority:4"`
+	Open      string    `json:"open"`
+	High      string    `json:"high"`
+	Low       string    `json:"low"`
+	Close     string    `json:"close"`
+	Volume    string    `json:"volume"`
+}
+
+func (StockKLine) TableName() string {
+	return "stock_kline"
+}
+
+// StockKLineSync K线同步状态
+type StockKLineSync struct {
+	ID       uint      `gorm:"primarykey"`
+	Code     string    `gorm:"uniqueIndex:idx_stock_kline_sync_key,priority:1"`
+	Period   string    `gorm:"uniqueIndex:idx_stock_kline_sync_key,priority:2"`
+	Adjust   string    `gorm:"uniqueIndex:idx_stock_kline_sync_key,priority:3"`
+	Depth    int64     //最近一次全量同步请求的K线数量
+	SyncedAt time.Time //最近一次同步时间
+}
+
+func (StockKLineSync) TableName() string {
+	return "stock_kline_sync"
+}
+
+// KLineFetcher 从远程获取最近 n 根K线
+type KLineFetcher func(code string, n int64) *[]KLineData
+
+type KLineStoreApi struct {
+	dao   *gorm.DB
+	fetch func(ctx context.Context, code, period, adjust string) KLineFetcher
+}
+
+func NewKLineStoreApi() *KLineStoreApi {
+	return &KLineStoreApi{
+		dao:   db.Dao,
+		fetch: remoteKLineFetcher,
+	}
+}
+
+var kLineLocks sync.Map
+
+func kLineLock(key string) *sync.Mutex {
+	l, _ := kLineLocks.LoadOrStore(key, &sync.Mutex{})
+	return l.(*sync.Mutex)
+}
+
+// defaultKLineAdjust A股默认不复权,港美股只有前复权数据
+func defaultKLineAdjust(code string) string {
+	if strutil.HasPrefixAny(code, []string{"hk", "us", "gb_"}) {
+		return KLineAdjustQfq
+	}
+	return KLineAdjustNone
+}
+
+// remoteKLineFetcher 按复权方式选择数据源:不复权使用新浪(仅A股),前复权使用腾讯
+func remoteKLineFetcher(ctx context.Context, code, period, adjust string) KLineFetcher {
+	api := NewStockDataApi().WithContext(ctx)
+	if adjust == KLineAdjustNone && strutil.HasPrefixAny(code, []string{"sh", "sz", "bj"}) {
+		scale := "240"
+		if period == KLinePeriodWeek {
+			scale = "1200"
+		}
+		return func(code string, n int64) *[]KLineData {
+			return api.GetKLineData(code, scale, n)
+		}
+	}
+	if strutil.HasPrefixAny(code, []string{"hk", "us", "gb_"}) {
+		return func(code string, n int64) *[]KLineData {
+			return api.GetHK_KLineData(code, period, n)
+		}
+	}
+	return func(code string, n int64) *[]KLineData {
+		return api.GetCommonKLineData(code, period, n)
+	}
+}
+
+// GetKLine 获取最近 days 根K线,本地数据新鲜时直接返回,否则只拉取最后一根已存K线之后的数据
+func (k KLineStoreApi) GetKLine(code, period, adjust string, days int64) *[]KLineData {
+	return k.GetKLineContext(context.Background(), code, period, adjust, days)
+}
+
+// GetKLineContext 同 GetKLine,远程拉取随 ctx 取消
+func (k KLineStoreApi) GetKLineContext(ctx context.Context, code, period, adjust string, days int64) *[]KLineData {
+	code = strings.ToLower(strutil.Trim(code))
+	if period == "" {
+		period = KLinePeriodDay
+	}
+	if days <= 0 {
+		days = 120
+	}
+	lock := kLineLock(fmt.Sprintf("%s|%s|%s", code, period, adjust))
+	lock.Lock()
+	defer lock.Unlock()
+
+	state := StockKLineSync{}
+	k.dao.Where("code = ? and period = ? and adjust = ?", code, period, adjust).First(&state)
+	var count int64
+	k.dao.Model(&StockKLine{}).Where("code = ? and period = ? and adjust = ?", code, period, adjust).Count(&count)
+
+	if count > 0 && state.Depth >= days && time.Since(state.SyncedAt) < kLineFreshDuration {
+		return k.load(code, period, adjust, days)
+	}
+
+	n := days
+	depth := days
+	last := StockKLine{}
+	incremental := count > 0 && state.Depth >= days
+	if incremental {
+		// 增量同步:最后一根K线可能是盘中未完成的,一并覆盖
+		k.dao.Where("code = ? and period = ? and adjust = ?", code, period, adjust).Order("day desc").First(&last)
+		n = kLineIncrementSize(last.Day, period, days)
+		depth = state.Depth
+	}
+
+	bars := k.fetch(ctx, code, period, adjust)(code, n)
+	if bars == nil || len(*bars) == 0 {
+		logger.SugaredLogger.Warnf("GetKLine 远程获取K线失败,使用本地数据 code:%s period:%s adjust:%s", code, period, adjust)
+		return k.load(code, period, adjust, days)
+	}
+	if incremental && k.readjusted(code, period, adjust, last.Day, *bars) {
+		// 除权除息后前复权价格整体变化,本地K线全部作废,重新全量同步
+		logger.SugaredLogger.Infof("GetKLine K线与本地不一致,重新全量同步 code:%s period:%s adjust:%s", code, period, adjust)
+		full := k.fetch(ctx, code, period, adjust)(code, depth)
+		if full == nil || len(*full) == 0 {
+			logger.SugaredLogger.Warnf("GetKLine 远程获取K线失败,使用本地数据 code:%s period:%s adjust:%s", code, period, adjust)
+			return k.load(code, period, adjust, days)
+		}
+		k.dao.Where("code = ? and period = ? and adjust = ?", code, period, adjust).Delete(&StockKLine{})
+		bars = full
+	}
+	k.save(code, period, adjust, *bars)
+	state.Code = code
+	state.Period = period
+	state.Adjust = adjust
+	state.Depth = depth
+	state.SyncedAt = time.Now()
+	k.dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}, {Name: "period"}, {Name: "adjust"}},
+		DoUpdates: clause.AssignmentColumns([]string{"depth", "synced_at"}),
+	}).Create(&state)
+	return k.load(code, period, adjust, days)
+}
+
+// kLineIncrementSize 根据最后一根K线日期估算需要补齐的K线数量(含最后一根)
+func kLineIncrementSize(lastDay, period string, max int64) int64 {
+	last, err := time.ParseInLocation(time.DateOnly, strutil.Before(lastDay, " "), time.Local)
+	if err != nil {
+		return max
+	}
+	n := int64(time.Since(last).Hours()/24) + 2
+	if period == KLinePeriodWeek {
+		n = n/7 + 2
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// readjusted 增量拉取的K线中最后一根已存K线之前的已完成K线与本地不一致,说明复权价格已重新计算。
+// 增量数量按自然日估算,总会包含最后一根已存K线之前的至少一根
+func (k KLineStoreApi) readjusted(code, period, adjust, lastDay string, bars []KLineData) bool {
+	for _, bar := range bars {
+		if bar.Day == "" || bar.Day >= lastDay {
+			continue
+		}
+		row := StockKLine{}
+		k.dao.Where("code = ? and period = ? and adjust = ? and day = ?", code, period, adjust, bar.Day).Limit(1).Find(&row)
+		if row.ID == 0 {
+			continue
+		}
+		for _, pair := range [][2]string{{row.Open, bar.Open}, {row.High, bar.High}, {row.Low, bar.Low}, {row.Close, bar.Close}} {
+			stored, _ := convertor.ToFloat(pair[0])
+			fetched, _ := convertor.ToFloat(pair[1])
+			if math.Abs(stored-fetched) > 1e-6 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (k KLineStoreApi) save(code, period, adjust string, bars []KLineData) {
+	rows := make([]StockKLine, 0, len(bars))
+	for _, bar := range bars {
+		if bar.Day == "" {
+			continue
+		}
+		rows = append(rows, StockKLine{
+			Code:   code,
+			Period: period,
+			Adjust: adjust,
+			Day:    bar.Day,
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+		})
+	}
+	if len(rows) == 0 {
+		return
+	}
+	err := k.dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}, {Name: "period"}, {Name: "adjust"}, {Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "updated_at"}),
+	}).CreateInBatches(&rows, 200).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存K线失败 code:%s %s", code, err.Error())
+	}
+}
+
+func (k KLineStoreApi) load(code, period, adjust string, days int64) *[]KLineData {
+	var rows []StockKLine
+	k.dao.Where("code = ? and period = ? and adjust = ?", code, period, adjust).Order("day desc").Limit(int(days)).Find(&rows)
+	K := make([]KLineData, len(rows))
+	for i, row := range rows {
+		K[len(rows)-1-i] = KLineData{
+			Day:    row.Day,
+			Open:   row.Open,
+			High:   row.High,
+			Low:    row.Low,
+			Close:  row.Close,
+			Volume: row.Volume,
+		}
+	}
+	return &K
+}
+
+// 计算指标时额外加载的K线数量,保证 MA60/EMA26 等长周期指标在展示区间内已经稳定
+const indicatorWarmup = 60
+
+// KLineBars 转换为指标计算使用的K线
+func KLineBars(K []KLineData) []indicator.Bar {
+	bars := make([]indicator.Bar, 0, len(K))
+	for _, kline := range K {
+		bar := indicator.Bar{Day: kline.Day}
+		bar.Open, _ = convertor.ToFloat(kline.Open)
+		bar.High, _ = convertor.ToFloat(kline.High)
+		bar.Low, _ = convertor.ToFloat(kline.Low)
+		bar.Close, _ = convertor.ToFloat(kline.Close)
+		bar.Volume, _ = convertor.ToFloat(kline.Volume)
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// GetIndicators 计算最近 days 根K线的技术指标,names 为空时计算全部指标
+func (k KLineStoreApi) GetIndicators(code, period, adjust string, days int64, names []string) *indicator.Result {
+	if days <= 0 {
+		days = 120
+	}
+	K := k.GetKLine(code, period, adjust, days+indicatorWarmup)
+	res := indicator.Compute(KLineBars(*K), names...)
+	if skip := len(res.Days) - int(days); skip > 0 {
+		res.Days = res.Days[skip:]
+		res.Close = res.Close[skip:]
+		for name, series := range res.Values {
+			res.Values[name] = series[skip:]
+		}
+	}
+	return res
+}
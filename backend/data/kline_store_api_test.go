@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"go-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKLineStoreIncrementalSync(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&StockKLine{}, &StockKLineSync{})
+
+	today := time.Now()
+	bars := make([]KLineData, 0)
+	for i := 9; i >= 0; i-- {
+		bars = append(bars, KLineData{Day: today.AddDate(0, 0, -i).Format(time.DateOnly), Open: "10", High: "11", Low: "9", Close: "10.5", Volume: "100"})
+	}
+	var requested []int64
+	api := KLineStoreApi{
+		dao: db.Dao,
+		fetch: func(ctx context.Context, code, period, adjust string) KLineFetcher {
+			return func(code string, n int64) *[]KLineData {
+				requested = append(requested, n)
+				res := bars
+				if int64(len(res)) > n {
+					res = res[int64(len(res))-n:]
+				}
+				return &res
+			}
+		},
+	}
+
+	K := api.GetKLine("SH600000", KLinePeriodDay, KLineAdjustNone, 10)
+	assert.Len(t, *K, 10)
+	assert.Equal(t, bars[0].Day, (*K)[0].Day)
+	assert.Equal(t, []int64{10}, requested)
+
+	// 新鲜数据直接读取本地
+	api.GetKLine("sh600000", KLinePeriodDay, KLineAdjustNone, 5)
+	assert.Equal(t, []int64{10}, requested)
+
+	// 过期后只增量拉取最后一根之后的K线,并覆盖盘中未完成的最后一根
+	db.Dao.Model(&StockKLineSync{}).Where("code = ?", "sh600000").Update("synced_at", time.Now().Add(-time.Hour))
+	bars[len(bars)-1].Close = "10.8"
+	K = api.GetKLine("sh600000", KLinePeriodDay, KLineAdjustNone, 10)
+	assert.Equal(t, []int64{10, 2}, requested)
+	assert.Len(t, *K, 10)
+	assert.Equal(t, "10.8", (*K)[9].Close)
+
+	// 请求更多K线时全量同步
+	api.GetKLine("sh600000", KLinePeriodDay, KLineAdjustNone, 30)
+	assert.Equal(t, []int64{10, 2, 30}, requested)
+}
+
+func TestKLineStoreReadjust(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&StockKLine{}, &StockKLineSync{})
+
+	today := time.Now()
+	bars := make([]KLineData, 0)
+	for i := 9; i >= 0; i-- {
+		bars = append(bars, KLineData{Day: today.AddDate(0, 0, -i).Format(time.DateOnly), Open: "10", High: "11", Low: "9", Close: "10.5", Volume: "100"})
+	}
+	var requested []int64
+	api := KLineStoreApi{
+		dao: db.Dao,
+		fetch: func(ctx context.Context, code, period, adjust string) KLineFetcher {
+			return func(code string, n int64) *[]KLineData {
+				requested = append(requested, n)
+				res := bars[max(int64(len(bars))-n, 0):]
+				return &res
+			}
+		},
+	}
+	api.GetKLine("hk00700", KLinePeriodDay, KLineAdjustQfq, 10)
+
+	// 除息后前复权价格整体下移,增量拉取发现已存K线变化后全量替换
+	db.Dao.Model(&StockKLineSync{}).Where("code = ?", "hk00700").Update("synced_at", time.Now().Add(-time.Hour))
+	for i := range bars {
+		bars[i].Close = "10.2"
+	}
+	K := api.GetKLine("hk00700", KLinePeriodDay, KLineAdjustQfq, 10)
+	assert.Equal(t, []int64{10, 2, 10}, requested)
+	assert.Len(t, *K, 10)
+	assert.Equal(t, "10.2", (*K)[0].Close)
+}
+
+func TestKLineStoreGetIndicators(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&StockKLine{}, &StockKLineSync{})
+
+	today := time.Now()
+	api := KLineStoreApi{
+		dao: db.Dao,
+		fetch: func(ctx context.Context, code, period, adjust string) KLineFetcher {
+			return func(code string, n int64) *[]KLineData {
+				bars := make([]KLineData, 0, n)
+				for i := n - 1; i >= 0; i-- {
+					bars = append(bars, KLineData{Day: today.AddDate(0, 0, -int(i)).Format(time.DateOnly), Open: "10", High: "11", Low: "9", Close: "10", Volume: "100"})
+				}
+				return &bars
+			}
+		},
+	}
+	res := api.GetIndicators("sh600000", KLinePeriodDay, KLineAdjustQfq, 10, []string{"MA"})
+	assert.Len(t, res.Days, 10)
+	assert.Len(t, res.Values["MA60"], 10)
+	// 预热数据保证展示区间内长周期均线已有值
+	assert.Equal(t, 10.0, res.Values["MA60"][0])
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
@@ -277,9 +278,15 @@ func (m MarketNewsApi) GetMoneyRankSina(sort string) []map[string]any {
 }
 
 func (m MarketNewsApi) GetStockMoneyTrendByDay(stockCode string, days int) []map[string]any {
+	return m.stockMoneyTrendByDay(context.Background(), stockCode, days)
+}
+
+// stockMoneyTrendByDay 请求随 ctx 取消
+func (m MarketNewsApi) stockMoneyTrendByDay(ctx context.Context, stockCode string, days int) []map[string]any {
 	url := fmt.Sprintf("http://vip.stock.finance.sina.com.cn/quotes_service/api/json_v2.php/MoneyFlow.ssl_qsfx_zjlrqs?page=1&num=%d&sort=opendate&asc=0&daima=%s", days, stockCode)
 
 	response, _ := resty.New().SetTimeout(time.Duration(5)*time.Second).R().
+		SetContext(ctx).
 		SetHeader("Host", "vip.stock.finance.sina.com.cn").
 		SetHeader("Referer", "https://finance.sina.com.cn").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36 Edg/117.0.2045.60").Get(url)
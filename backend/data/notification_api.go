@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"go-stock/backend/notify"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/28 14:30
+// @Desc 通知渠道配置和发送记录
+// -----------------------------------------------------------------------------------
+
+// NotificationChannelSetting 通知渠道配置
+type NotificationChannelSetting struct {
+	gorm.Model
+	notify.Config   `gorm:"embedded"`
+	Enabled         bool  `json:"enabled"`
+	CooldownSeconds int64 `json:"cooldownSeconds"` //同一报警在该渠道的冷却时间(秒),0表示不限制
+}
+
+func (NotificationChannelSetting) TableName() string {
+	return "notification_channel"
+}
+
+// NotificationLog 通知发送记录
+type NotificationLog struct {
+	gorm.Model
+	ChannelID  uint   `json:"channelId" gorm:"index"`
+	Channel    string `json:"channel"`
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	StockCode  string `json:"stockCode"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+func (NotificationLog) TableName() string {
+	return "notification_log"
+}
+
+type NotificationApi struct {
+	dao *gorm.DB
+}
+
+func NewNotificationApi() *NotificationApi {
+	return &NotificationApi{dao: db.Dao}
+}
+
+func (n NotificationApi) GetChannels() []NotificationChannelSetting {
+	var channels []NotificationChannelSetting
+	n.dao.Model(&NotificationChannelSetting{}).Order("id asc").Find(&channels)
+	return channels
+}
+
+// SaveChannel 新增或修改渠道,保存前校验配置
+func (n NotificationApi) SaveChannel(channel NotificationChannelSetting) error {
+	if channel.Name == "" {
+		return errors.New("渠道名称不能为空")
+	}
+	if _, err := notify.New(channel.Config); err != nil {
+		return err
+	}
+	if channel.ID == 0 {
+		return n.dao.Create(&channel).Error
+	}
+	return n.dao.Model(&NotificationChannelSetting{}).Where("id = ?", channel.ID).Select("*").Omit("id", "created_at").Updates(&channel).Error
+}
+
+func (n NotificationApi) DeleteChannel(id uint) error {
+	return n.dao.Delete(&NotificationChannelSetting{}, id).Error
+}
+
+// TestChannel 向指定渠道发送测试消息(不要求渠道已启用)
+func (n NotificationApi) TestChannel(id uint) error {
+	channel := NotificationChannelSetting{}
+	if err := n.dao.First(&channel, id).Error; err != nil {
+		return err
+	}
+	results := n.send(context.Background(), []NotificationChannelSetting{channel}, notify.Message{
+		Title:   "测试消息",
+		Content: "这是一条来自 go-stock 的测试消息",
+	})
+	if len(results) == 0 {
+		return errors.New("渠道配置错误")
+	}
+	return results[0].Err
+}
+
+// Send 发送到指定渠道,channelIDs 为空时发送到全部启用的渠道,返回各渠道的发送结果
+func (n NotificationApi) Send(ctx context.Context, channelIDs []string, msg notify.Message) []notify.DeliveryResult {
+	return n.send(ctx, n.enabledChannels(channelIDs), msg)
+}
+
+// enabledChannels 指定的渠道中已启用的渠道,channelIDs 为空时返回全部启用的渠道
+func (n NotificationApi) enabledChannels(channelIDs []string) []NotificationChannelSetting {
+	query := n.dao.Model(&NotificationChannelSetting{}).Where("enabled = ?", true)
+	if len(channelIDs) > 0 {
+		ids := make([]uint, 0, len(channelIDs))
+		for _, id := range channelIDs {
+			if v, err := strconv.ParseUint(id, 10, 64); err == nil {
+				ids = append(ids, uint(v))
+			}
+		}
+		query = query.Where("id in ?", ids)
+	}
+	var channels []NotificationChannelSetting
+	query.Order("id asc").Find(&channels)
+	return channels
+}
+
+func (n NotificationApi) send(ctx context.Context, settings []NotificationChannelSetting, msg notify.Message) []notify.DeliveryResult {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	channels := make([]notify.NotificationChannel, 0, len(settings))
+	ids := make([]uint, 0, len(settings))
+	for _, setting := range settings {
+		channel, err := notify.New(setting.Config)
+		if err != nil {
+			logger.SugaredLogger.Errorf("通知渠道 %s 配置错误:%s", setting.Name, err.Error())
+			continue
+		}
+		channels = append(channels, channel)
+		ids = append(ids, setting.ID)
+	}
+	results := notify.SendAll(ctx, channels, msg)
+	logs := make([]NotificationLog, 0, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			logger.SugaredLogger.Errorf("通知发送失败 %s:%s", result.Channel, result.Error)
+		}
+		logs = append(logs, NotificationLog{
+			ChannelID:  ids[i],
+			Channel:    result.Channel,
+			Type:       result.Type,
+			Title:      msg.Title,
+			Content:    msg.Content,
+			StockCode:  msg.StockCode,
+			Success:    result.Err == nil,
+			Error:      result.Error,
+			DurationMs: result.Duration.Milliseconds(),
+		})
+	}
+	if len(logs) > 0 {
+		if err := n.dao.Create(&logs).Error; err != nil {
+			logger.SugaredLogger.Errorf("保存通知记录失败:%s", err.Error())
+		}
+	}
+	return results
+}
+
+// GetLogs 最近的发送记录
+func (n NotificationApi) GetLogs(limit int) []NotificationLog {
+	if limit <= 0 {
+		limit = 100
+	}
+	var logs []NotificationLog
+	n.dao.Model(&NotificationLog{}).Order("id desc").Limit(limit).Find(&logs)
+	return logs
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"go-stock/backend/db"
+	"go-stock/backend/notify"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationApi(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&NotificationChannelSetting{}, &NotificationLog{})
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer server.Close()
+	api := NewNotificationApi()
+
+	assert.Error(t, api.SaveChannel(NotificationChannelSetting{Config: notify.Config{Type: notify.TypeWeCom, Name: "企业微信"}}))
+	assert.NoError(t, api.SaveChannel(NotificationChannelSetting{Config: notify.Config{Type: notify.TypeWeCom, Name: "企业微信", URL: server.URL}, Enabled: true}))
+	assert.NoError(t, api.SaveChannel(NotificationChannelSetting{Config: notify.Config{Type: notify.TypeWebhook, Name: "webhook", URL: server.URL + "/fail"}, Enabled: true}))
+	assert.NoError(t, api.SaveChannel(NotificationChannelSetting{Config: notify.Config{Type: notify.TypeWeCom, Name: "停用", URL: server.URL}}))
+	channels := api.GetChannels()
+	assert.Len(t, channels, 3)
+
+	msg := notify.Message{Title: "报警", Content: "浦发银行 突破前高", StockCode: "sh600000"}
+	results := api.Send(context.Background(), nil, msg)
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+
+	results = api.Send(context.Background(), []string{strconv.Itoa(int(channels[0].ID))}, msg)
+	assert.Len(t, results, 1)
+	assert.Equal(t, int32(3), hits.Load())
+
+	assert.NoError(t, api.TestChannel(channels[2].ID))
+	logs := api.GetLogs(10)
+	assert.Len(t, logs, 4)
+	assert.Equal(t, "停用", logs[0].Channel)
+	assert.False(t, logs[2].Success)
+	assert.Contains(t, logs[2].Error, "502")
+	assert.Equal(t, "sh600000", logs[3].StockCode)
+
+	channels[1].URL = server.URL
+	assert.NoError(t, api.SaveChannel(channels[1]))
+	assert.NoError(t, api.Send(context.Background(), []string{strconv.Itoa(int(channels[1].ID))}, msg)[0].Err)
+	assert.NoError(t, api.DeleteChannel(channels[2].ID))
+	assert.Len(t, api.GetChannels(), 2)
+}
@@ -1,9 +1,7 @@
 package data
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
@@ -13,8 +11,10 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"go-stock/backend/db"
+	"go-stock/backend/llm"
 	"go-stock/backend/logger"
 	"go-stock/backend/models"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +26,8 @@ import (
 // -----------------------------------------------------------------------------------
 type OpenAi struct {
 	ctx              context.Context
+	ProfileName      string  `json:"profile_name"`
+	Provider         string  `json:"provider"`
 	BaseUrl          string  `json:"base_url"`
 	ApiKey           string  `json:"api_key"`
 	Model            string  `json:"model"`
@@ -33,18 +35,32 @@ type OpenAi struct {
 	Temperature      float64 `json:"temperature"`
 	Prompt           string  `json:"prompt"`
 	TimeOut          int     `json:"time_out"`
+	ContextSize      int     `json:"context_size"`
 	QuestionTemplate string  `json:"question_template"`
 	CrawlTimeOut     int64   `json:"crawl_time_out"`
 	KDays            int64   `json:"kDays"`
 	BrowserPath      string  `json:"browser_path"`
+	IndicatorSummary bool    `json:"indicator_summary"`
+	ToolsEnable      bool    `json:"tools_enable"`
+	ToolCallLimit    int     `json:"tool_call_limit"`
+	InputPrice       float64 `json:"input_price"`
+	OutputPrice      float64 `json:"output_price"`
+	Trigger          string  `json:"trigger"`
+	StructuredOutput bool    `json:"structured_output"`
+
+	stockCode        string
+	stockName        string
+	promptTemplateId int
 }
 
 func NewDeepSeekOpenAi(ctx context.Context) *OpenAi {
+	return NewOpenAiWithProfile(ctx, 0)
+}
+
+// NewOpenAiWithProfile 使用指定的模型配置,profileId 为0时使用默认模型配置
+func NewOpenAiWithProfile(ctx context.Context, profileId uint) *OpenAi {
 	config := GetConfig()
 	if config.OpenAiEnable {
-		if config.OpenAiApiTimeOut <= 0 {
-			config.OpenAiApiTimeOut = 60 * 5
-		}
 		if config.CrawlTimeOut <= 0 {
 			config.CrawlTimeOut = 60
 		}
@@ -52,19 +68,49 @@ func NewDeepSeekOpenAi(ctx context.Context) *OpenAi {
 			config.KDays = 120
 		}
 	}
+	modelProfile := NewAiModelApi().GetModelProfile(profileId)
+	profile := modelProfile.Profile
+	if profile.TimeOut <= 0 {
+		profile.TimeOut = 60 * 5
+	}
 	return &OpenAi{
 		ctx:              ctx,
-		BaseUrl:          config.OpenAiBaseUrl,
-		ApiKey:           config.OpenAiApiKey,
-		Model:            config.OpenAiModelName,
-		MaxTokens:        config.OpenAiMaxTokens,
-		Temperature:      config.OpenAiTemperature,
+		ProfileName:      profile.Name,
+		Provider:         profile.Provider,
+		BaseUrl:          profile.BaseUrl,
+		ApiKey:           profile.ApiKey,
+		Model:            profile.Model,
+		MaxTokens:        profile.MaxTokens,
+		Temperature:      profile.Temperature,
 		Prompt:           config.Prompt,
-		TimeOut:          config.OpenAiApiTimeOut,
+		TimeOut:          profile.TimeOut,
+		ContextSize:      profile.ContextSize,
 		QuestionTemplate: config.QuestionTemplate,
 		CrawlTimeOut:     config.CrawlTimeOut,
 		KDays:            config.KDays,
 		BrowserPath:      config.BrowserPath,
+		IndicatorSummary: config.KLineIndicatorSummary,
+		ToolsEnable:      config.AiToolsEnable,
+		ToolCallLimit:    config.AiToolCallLimit,
+		InputPrice:       modelProfile.InputPrice,
+		OutputPrice:      modelProfile.OutputPrice,
+		Trigger:          AiTriggerManual,
+		StructuredOutput: config.AiStructuredOutput,
+	}
+}
+
+// profile 当前使用的模型配置
+func (o OpenAi) profile() llm.Profile {
+	return llm.Profile{
+		Name:        o.ProfileName,
+		Provider:    o.Provider,
+		BaseUrl:     o.BaseUrl,
+		ApiKey:      o.ApiKey,
+		Model:       o.Model,
+		Temperature: o.Temperature,
+		MaxTokens:   o.MaxTokens,
+		TimeOut:     o.TimeOut,
+		ContextSize: o.ContextSize,
 	}
 }
 
@@ -115,10 +161,12 @@ func (o OpenAi) NewSummaryStockNewsStream(userQuestion string, sysPromptId *int)
 		}()
 		defer close(ch)
 
+		o.Trigger = AiTriggerSummary
 		sysPrompt := ""
 		if sysPromptId == nil || *sysPromptId == 0 {
 			sysPrompt = o.Prompt
 		} else {
+			o.promptTemplateId = *sysPromptId
 			sysPrompt = NewPromptTemplateApi().GetPromptTemplateByID(*sysPromptId)
 		}
 		if sysPrompt == "" {
@@ -146,9 +194,9 @@ func (o OpenAi) NewSummaryStockNewsStream(userQuestion string, sysPromptId *int)
 		go func() {
 			defer wg.Done()
 			var market strings.Builder
-			market.WriteString(getZSInfo("创业板指数", "sz399006", 30) + "\n")
-			market.WriteString(getZSInfo("上证综合指数", "sh000001", 30) + "\n")
-			market.WriteString(getZSInfo("沪深300指数", "sh000300", 30) + "\n")
+			market.WriteString(getZSInfo(o.requestContext(), "创业板指数", "sz399006", 30) + "\n")
+			market.WriteString(getZSInfo(o.requestContext(), "上证综合指数", "sh000001", 30) + "\n")
+			market.WriteString(getZSInfo(o.requestContext(), "沪深300指数", "sh000300", 30) + "\n")
 			//logger.SugaredLogger.Infof("NewChatStream getZSInfo=\n%s", market.String())
 			msg = append(msg, map[string]interface{}{
 				"role":    "user",
@@ -207,10 +255,13 @@ func (o OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptId
 		}()
 		defer close(ch)
 
+		o.stockCode = stockCode
+		o.stockName = stock
 		sysPrompt := ""
 		if sysPromptId == nil || *sysPromptId == 0 {
 			sysPrompt = o.Prompt
 		} else {
+			o.promptTemplateId = *sysPromptId
 			sysPrompt = NewPromptTemplateApi().GetPromptTemplateByID(*sysPromptId)
 		}
 		if sysPrompt == "" {
@@ -245,7 +296,9 @@ func (o OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptId
 		}
 		followedStock := NewStockDataApi().GetFollowedStockByStockCode(stockCode)
 		stockData, err := NewStockDataApi().GetStockCodeRealTimeData(stockCode)
+		price := 0.0
 		if err == nil && len(*stockData) > 0 {
+			price, _ = convertor.ToFloat((*stockData)[0].Price)
 			msg = append(msg, map[string]interface{}{
 				"role":    "user",
 				"content": fmt.Sprintf("当前%s[%s]价格是多少？", stock, stockCode),
@@ -272,389 +325,283 @@ func (o OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptId
 		logger.SugaredLogger.Infof("NewChatStream stock:%s stockCode:%s", stock, stockCode)
 		logger.SugaredLogger.Infof("Prompt：%s", sysPrompt)
 		logger.SugaredLogger.Infof("final question:%s", question)
+		content := question
+		if o.StructuredOutput {
+			content += StructuredOutputPrompt
+		}
 
-		wg := &sync.WaitGroup{}
-		wg.Add(7)
-
-		go func() {
-			defer wg.Done()
-			var market strings.Builder
-			market.WriteString(getZSInfo("创业板指数", "sz399006", 30) + "\n")
-			market.WriteString(getZSInfo("上证综合指数", "sh000001", 30) + "\n")
-			market.WriteString(getZSInfo("沪深300指数", "sh000300", 30) + "\n")
-			//logger.SugaredLogger.Infof("NewChatStream getZSInfo=\n%s", market.String())
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "市场指数",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": "市场指数情况如下：\n" + market.String(),
-			})
-		}()
-
-		go func() {
-			defer wg.Done()
-			//endDate := time.Now().Format("20060102")
-			//startDate := time.Now().Add(-time.Hour * time.Duration(24*o.KDays)).Format("20060102")
-			//code := stockCode
-			//if strutil.HasPrefixAny(stockCode, []string{"hk"}) {
-			//	code = ConvertStockCodeToTushareCode(stockCode)
-			//	K := NewTushareApi(GetConfig()).GetDaily(code, startDate, endDate, o.CrawlTimeOut)
-			//	msg = append(msg, map[string]interface{}{
-			//		"role":    "user",
-			//		"content": stock + "日K数据",
-			//	})
-			//	msg = append(msg, map[string]interface{}{
-			//		"role":    "assistant",
-			//		"content": stock + "日K数据如下：\n" + K,
-			//	})
-			//}
-
-			logger.SugaredLogger.Infof("NewChatStream getKLineData stock:%s stockCode:%s", stock, stockCode)
-			if strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
-				K := &[]KLineData{}
-				logger.SugaredLogger.Infof("NewChatStream getKLineData stock:%s stockCode:%s", stock, stockCode)
-				if strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
-					K = NewStockDataApi().GetKLineData(stockCode, "240", o.KDays)
-				}
-				if strutil.HasPrefixAny(stockCode, []string{"hk", "us", "gb_"}) {
-					K = NewStockDataApi().GetHK_KLineData(stockCode, "day", o.KDays)
-				}
-				Kmap := &[]map[string]any{}
-				for _, kline := range *K {
-					mapk := make(map[string]any, 6)
-					mapk["日期"] = kline.Day
-					mapk["开盘价"] = kline.Open
-					mapk["最高价"] = kline.High
-					mapk["最低价"] = kline.Low
-					mapk["收盘价"] = kline.Close
-					Volume, _ := convertor.ToFloat(kline.Volume)
-					mapk["成交量(万手)"] = Volume / 10000.00 / 100.00
-					*Kmap = append(*Kmap, mapk)
-				}
-				jsonData, _ := json.Marshal(Kmap)
-				markdownTable, _ := JSONToMarkdownTable(jsonData)
-				msg = append(msg, map[string]interface{}{
-					"role":    "user",
-					"content": stock + "日K数据",
-				})
-				msg = append(msg, map[string]interface{}{
-					"role":    "assistant",
-					"content": "## " + stock + "日K数据如下：\n" + markdownTable,
-				})
-				logger.SugaredLogger.Infof("getKLineData=\n%s", markdownTable)
-			}
-
-		}()
-
-		go func() {
-			defer wg.Done()
-			messages := SearchStockPriceInfo(stock, stockCode, o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票价格失败")
-				//ch <- "***❗获取股票价格失败,分析结果可能不准确***<hr>"
-				ch <- map[string]any{
-					"code":         1,
-					"question":     question,
-					"extraContent": "***❗获取股票价格失败,分析结果可能不准确***<hr>",
-				}
-				go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票价格失败,分析结果可能不准确")
-				return
-			}
-			price := ""
-			for _, message := range *messages {
-				price += message + ";"
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "股价数据",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": "\n## " + stock + "股价数据：\n" + price,
-			})
-			logger.SugaredLogger.Infof("SearchStockPriceInfo stock:%s stockCode:%s", stock, stockCode)
-			logger.SugaredLogger.Infof("SearchStockPriceInfo assistant:%s", "\n## "+stock+"股价数据：\n"+price)
-		}()
-
-		go func() {
-			defer wg.Done()
-
-			if checkIsIndexBasic(stock) {
-				return
-			}
-			messages := GetFinancialReportsByXUEQIU(stockCode, o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票财报失败")
-				// "***❗获取股票财报失败,分析结果可能不准确***<hr>"
-				ch <- map[string]any{
-					"code":         1,
-					"question":     question,
-					"extraContent": "***❗获取股票财报失败,分析结果可能不准确***<hr>",
-				}
-				go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票财报失败,分析结果可能不准确")
-				return
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "财报数据",
-			})
-			for _, message := range *messages {
-				msg = append(msg, map[string]interface{}{
-					"role":    "assistant",
-					"content": stock + message,
-				})
-			}
-		}()
-
-		go func() {
-			defer wg.Done()
-			messages := GetTelegraphList(o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取市场资讯失败")
-				//ch <- "***❗获取市场资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取市场资讯失败,分析结果可能不准确")
-				return
-			}
-			var messageText strings.Builder
-			for _, message := range *messages {
-				messageText.WriteString(message + "\n")
-			}
+		if o.ToolsEnable {
+			//由模型按需调用工具获取数据
 			msg = append(msg, map[string]interface{}{
 				"role":    "user",
-				"content": "市场资讯",
+				"content": content,
 			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": messageText.String(),
-			})
-
-			messages = GetTopNewsList(o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取新闻资讯失败")
-				//ch <- "***❗获取新闻资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取新闻资讯失败,分析结果可能不准确")
-				return
-			}
-			var newsText strings.Builder
-			for _, message := range *messages {
-				newsText.WriteString(message + "\n")
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "新闻资讯",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": newsText.String(),
-			})
-		}()
-
-		//go func() {
-		//	defer wg.Done()
-		//	messages := SearchStockInfo(stock, "depth", o.CrawlTimeOut)
-		//	if messages == nil || len(*messages) == 0 {
-		//		logger.SugaredLogger.Error("获取股票资讯失败")
-		//		//ch <- "***❗获取股票资讯失败,分析结果可能不准确***<hr>"
-		//		//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票资讯失败,分析结果可能不准确")
-		//		return
-		//	}
-		//	for _, message := range *messages {
-		//		msg = append(msg, map[string]interface{}{
-		//			"role":    "user",
-		//			"content": message,
-		//		})
-		//	}
-		//}()
-		go func() {
-			defer wg.Done()
-			messages := SearchStockInfo(stock, "telegram", o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票电报资讯失败")
-				//ch <- "***❗获取股票电报资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票电报资讯失败,分析结果可能不准确")
-				return
-			}
-			var newsText strings.Builder
-			for _, message := range *messages {
-				newsText.WriteString(message + "\n")
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "相关新闻资讯",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": newsText.String(),
-			})
-		}()
-
-		go func() {
-			defer wg.Done()
+			o.askWithRating(err, msg, ch, question, price)
+			return
+		}
 
-			if checkIsIndexBasic(stock) {
-				return
+		providers := o.StockContextProviders(stock, stockCode)
+		if sysPromptId != nil && *sysPromptId > 0 {
+			providers = SelectContextProviders(providers, NewPromptTemplateApi().GetContextSections(*sysPromptId))
+		}
+		results, warnings := BuildContext(o.requestContext(), providers)
+		for _, warning := range warnings {
+			warnMsg := map[string]any{
+				"code":     1,
+				"question": question,
+				"warning":  warning,
 			}
-
-			messages := SearchGuShiTongStockInfo(stockCode, o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股势通资讯失败")
-				//ch <- "***❗获取股势通资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股势通资讯失败,分析结果可能不准确")
-				return
+			if warning.Critical {
+				warnMsg["extraContent"] = "***❗获取" + warning.Title + "失败,分析结果可能不准确***<hr>"
+				go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取"+warning.Title+"失败,分析结果可能不准确")
 			}
-			var newsText strings.Builder
-			for _, message := range *messages {
-				newsText.WriteString(message + "\n")
+			ch <- warnMsg
+		}
+		//按模型上下文长度压缩数据,扣除已有消息和问题占用的token
+		budget := o.profile().PromptBudget() - llm.EstimateMessagesTokens(toLLMMessages(msg)) - llm.EstimateTokens(content)
+		results, trims := FitContext(results, budget)
+		for _, trim := range trims {
+			logger.SugaredLogger.Infof("NewChatStream %s", trim.String())
+			ch <- map[string]any{
+				"code":        1,
+				"question":    question,
+				"contextTrim": trim,
 			}
+		}
+		for _, result := range results {
 			msg = append(msg, map[string]interface{}{
 				"role":    "user",
-				"content": stock + "相关新闻资讯",
+				"content": result.Title,
 			})
 			msg = append(msg, map[string]interface{}{
 				"role":    "assistant",
-				"content": newsText.String(),
+				"content": result.Content,
 			})
-		}()
-
-		wg.Wait()
+		}
 		msg = append(msg, map[string]interface{}{
 			"role":    "user",
-			"content": question,
+			"content": content,
 		})
 
 		//reqJson, _ := json.Marshal(msg)
 		//logger.SugaredLogger.Errorf("Stream request: \n%s\n", reqJson)
-		AskAi(o, err, msg, ch, question)
+		o.askWithRating(err, msg, ch, question, price)
 	}()
 	return ch
 }
 
+// askWithRating 结构化输出模式下,回答完成后解析末尾的JSON结论并保存评级,
+// 解析失败时让模型修正一次
+func (o OpenAi) askWithRating(err error, msg []map[string]interface{}, ch chan map[string]any, question string, price float64) {
+	if !o.StructuredOutput {
+		AskAi(o, err, msg, ch, question)
+		return
+	}
+	answer, chatId, model, ok := o.collectAnswer(msg, ch, question, true)
+	if !ok {
+		return
+	}
+	rating, parseErr := ParseAiRating(answer)
+	repaired := false
+	if parseErr != nil {
+		logger.SugaredLogger.Warnf("解析AI结论失败,请求模型修正:%s", parseErr.Error())
+		repair := append(slices.Clone(msg),
+			map[string]interface{}{"role": "assistant", "content": answer},
+			map[string]interface{}{"role": "user", "content": ratingRepairPrompt(parseErr)},
+		)
+		o.ToolsEnable = false
+		if fixed, _, _, ok := o.collectAnswer(repair, ch, question, false); ok {
+			rating, parseErr = ParseAiRating(fixed)
+			repaired = true
+		}
+	}
+	if parseErr != nil {
+		logger.SugaredLogger.Errorf("解析AI结论失败:%s", parseErr.Error())
+		ch <- map[string]any{
+			"code":        1,
+			"question":    question,
+			"ratingError": parseErr.Error(),
+		}
+		return
+	}
+	if model == "" {
+		model = o.Model
+	}
+	rating.ChatId = chatId
+	rating.StockCode = o.stockCode
+	rating.StockName = o.stockName
+	rating.ModelName = model
+	rating.PromptTemplateId = o.promptTemplateId
+	rating.Trigger = o.Trigger
+	rating.Price = price
+	rating.Repaired = repaired
+	if err := NewAiRatingApi().Save(rating); err != nil {
+		logger.SugaredLogger.Errorf("保存AI评级失败:%s", err.Error())
+	}
+	ch <- map[string]any{
+		"code":     1,
+		"question": question,
+		"rating":   rating,
+	}
+}
+
+// collectAnswer 请求模型并汇总回答,forward 为 false 时只转发用量和错误
+func (o OpenAi) collectAnswer(msg []map[string]interface{}, ch chan map[string]any, question string, forward bool) (answer, chatId, model string, ok bool) {
+	answers := make(chan map[string]any, 512)
+	go func() {
+		defer close(answers)
+		AskAi(o, nil, msg, answers, question)
+	}()
+	var content strings.Builder
+	failed := false
+	for answerMsg := range answers {
+		if forward || answerMsg["code"] == 0 || answerMsg["usage"] != nil {
+			ch <- answerMsg
+		}
+		if answerMsg["code"] == 0 {
+			failed = true
+			continue
+		}
+		if text, ok := answerMsg["content"].(string); ok {
+			content.WriteString(text)
+		}
+		if id, ok := answerMsg["chatId"].(string); ok && id != "" {
+			chatId = id
+		}
+		if name, ok := answerMsg["model"].(string); ok && name != "" {
+			model = name
+		}
+	}
+	return content.String(), chatId, model, !failed && content.Len() > 0
+}
+
+// requestContext 请求模型和获取数据使用的 context,批量分析取消时一并停止
+func (o OpenAi) requestContext() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+func toLLMMessages(messages []map[string]interface{}) []llm.Message {
+	msgs := make([]llm.Message, 0, len(messages))
+	for _, message := range messages {
+		msgs = append(msgs, llm.Message{Role: convertor.ToString(message["role"]), Content: convertor.ToString(message["content"])})
+	}
+	return msgs
+}
+
 func AskAi(o OpenAi, err error, messages []map[string]interface{}, ch chan map[string]any, question string) {
-	client := resty.New()
-	client.SetBaseURL(strutil.Trim(o.BaseUrl))
-	client.SetHeader("Authorization", "Bearer "+o.ApiKey)
-	client.SetHeader("Content-Type", "application/json")
-	//client.SetRetryCount(3)
-	if o.TimeOut <= 0 {
-		o.TimeOut = 300
-	}
-	client.SetTimeout(time.Duration(o.TimeOut) * time.Second)
-	resp, err := client.R().
-		SetDoNotParseResponse(true).
-		SetBody(map[string]interface{}{
-			"model":       o.Model,
-			"max_tokens":  o.MaxTokens,
-			"temperature": o.Temperature,
-			"stream":      true,
-			"messages":    messages,
-		}).
-		Post("/chat/completions")
-
-	body := resp.RawBody()
-	defer body.Close()
-	if err != nil {
+	fail := func(err error) {
 		logger.SugaredLogger.Infof("Stream error : %s", err.Error())
-		//ch <- err.Error()
 		ch <- map[string]any{
 			"code":     0,
 			"question": question,
 			"content":  err.Error(),
 		}
+	}
+	provider, err := llm.New(o.profile())
+	if err != nil {
+		fail(err)
 		return
 	}
-	//location, _ := time.LoadLocation("Asia/Shanghai")
-
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		//logger.SugaredLogger.Infof("Received data: %s", line)
-		if strings.HasPrefix(line, "data:") {
-			data := strutil.Trim(strings.TrimPrefix(line, "data:"))
-			if data == "[DONE]" {
-				return
+	ctx := o.requestContext()
+	msgs := toLLMMessages(messages)
+	var events <-chan llm.Event
+	if o.ToolsEnable {
+		limit := o.ToolCallLimit
+		if limit <= 0 {
+			limit = defaultToolCallLimit
+		}
+		events, err = llm.ChatWithTools(ctx, provider, llm.Request{Messages: msgs}, NewStockToolbox(o), limit)
+	} else {
+		events, err = provider.ChatStream(ctx, llm.Request{Messages: msgs})
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	reported := false
+	estimator := llm.NewUsageEstimator(msgs)
+	var output strings.Builder
+	chatId, model := "", ""
+	for event := range events {
+		if event.Err != nil {
+			fail(event.Err)
+			continue
+		}
+		estimator.Add(event)
+		reported = reported || event.Usage != nil
+		if event.ID != "" {
+			chatId = event.ID
+		}
+		if event.Model != "" {
+			model = event.Model
+		}
+		output.WriteString(event.Content)
+		output.WriteString(event.Reasoning)
+		if event.ToolResult != nil {
+			logger.SugaredLogger.Infof("AI调用工具 %s(%s) error:%s", event.ToolResult.Name, event.ToolResult.Arguments, event.ToolResult.Error)
+			ch <- map[string]any{
+				"code":       1,
+				"question":   question,
+				"toolResult": event.ToolResult,
+				"time":       time.Now().Format(time.DateTime),
 			}
-
-			var streamResponse struct {
-				Id      string `json:"id"`
-				Model   string `json:"model"`
-				Choices []struct {
-					Delta struct {
-						Content          string `json:"content"`
-						ReasoningContent string `json:"reasoning_content"`
-					} `json:"delta"`
-					FinishReason string `json:"finish_reason"`
-				} `json:"choices"`
+		}
+		for _, call := range event.ToolCalls {
+			ch <- map[string]any{
+				"code":     1,
+				"question": question,
+				"toolCall": call,
+				"time":     time.Now().Format(time.DateTime),
 			}
-
-			if err := json.Unmarshal([]byte(data), &streamResponse); err == nil {
-				for _, choice := range streamResponse.Choices {
-					if content := choice.Delta.Content; content != "" {
-						//ch <- content
-						ch <- map[string]any{
-							"code":     1,
-							"question": question,
-							"chatId":   streamResponse.Id,
-							"model":    streamResponse.Model,
-							"content":  content,
-							"time":     time.Now().Format(time.DateTime),
-						}
-
-						//logger.SugaredLogger.Infof("Content data: %s", content)
-					}
-					if reasoningContent := choice.Delta.ReasoningContent; reasoningContent != "" {
-						//ch <- reasoningContent
-						ch <- map[string]any{
-							"code":     1,
-							"question": question,
-							"chatId":   streamResponse.Id,
-							"model":    streamResponse.Model,
-							"content":  reasoningContent,
-							"time":     time.Now().Format(time.DateTime),
-						}
-
-						//logger.SugaredLogger.Infof("ReasoningContent data: %s", reasoningContent)
-					}
-					if choice.FinishReason == "stop" {
-						return
-					}
-				}
-			} else {
-				if err != nil {
-					logger.SugaredLogger.Infof("Stream data error : %s", err.Error())
-					//ch <- err.Error()
-					ch <- map[string]any{
-						"code":     0,
-						"question": question,
-						"content":  err.Error(),
-					}
-				} else {
-					logger.SugaredLogger.Infof("Stream data error : %s", data)
-					//ch <- data
-					ch <- map[string]any{
-						"code":     0,
-						"question": question,
-						"content":  data,
-					}
-				}
+		}
+		for _, content := range []string{event.Content, event.Reasoning} {
+			if content == "" {
+				continue
 			}
-		} else {
-			if strutil.RemoveNonPrintable(line) != "" {
-				logger.SugaredLogger.Infof("Stream data error : %s", line)
-				res := &models.Resp{}
-				if err := json.Unmarshal([]byte(line), res); err == nil {
-					//ch <- line
-					ch <- map[string]any{
-						"code":     0,
-						"question": question,
-						"content":  res.Message,
-					}
-				}
+			ch <- map[string]any{
+				"code":     1,
+				"question": question,
+				"chatId":   event.ID,
+				"model":    event.Model,
+				"content":  content,
+				"time":     time.Now().Format(time.DateTime),
 			}
-
 		}
-
+	}
+	if !reported && output.Len() == 0 {
+		return
+	}
+	//工具调用的每一轮分别统计,接口未返回用量的轮次按估算计入
+	usage := estimator.Usage()
+	if model == "" {
+		model = o.Model
+	}
+	record := &AiUsage{
+		ProfileName:      o.ProfileName,
+		Provider:         o.Provider,
+		ModelName:        model,
+		StockCode:        o.stockCode,
+		StockName:        o.stockName,
+		PromptTemplateId: o.promptTemplateId,
+		Trigger:          o.Trigger,
+		ChatId:           chatId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Estimated:        estimator.Estimated(),
+		Cost:             UsageCost(usage, o.InputPrice, o.OutputPrice),
+	}
+	if err := NewAiUsageApi().Record(record); err != nil {
+		logger.SugaredLogger.Errorf("保存AI用量失败:%s", err.Error())
+	}
+	ch <- map[string]any{
+		"code":     1,
+		"question": question,
+		"usage":    record,
+		"time":     time.Now().Format(time.DateTime),
 	}
 }
 
@@ -664,9 +611,9 @@ func checkIsIndexBasic(stock string) bool {
 	return count > 0
 }
 
-func SearchGuShiTongStockInfo(stock string, crawlTimeOut int64) *[]string {
+func SearchGuShiTongStockInfo(parent context.Context, stock string, crawlTimeOut int64) *[]string {
 	crawlerAPI := CrawlerApi{}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, CrawlerBaseInfo{
@@ -713,7 +660,7 @@ func SearchGuShiTongStockInfo(stock string, crawlTimeOut int64) *[]string {
 	}
 	return &messages
 }
-func GetFinancialReportsByXUEQIU(stockCode string, crawlTimeOut int64) *[]string {
+func GetFinancialReportsByXUEQIU(parent context.Context, stockCode string, crawlTimeOut int64) *[]string {
 	if strutil.HasPrefixAny(stockCode, []string{"HK", "hk"}) {
 		stockCode = strings.ReplaceAll(stockCode, "hk", "")
 		stockCode = strings.ReplaceAll(stockCode, "HK", "")
@@ -731,7 +678,7 @@ func GetFinancialReportsByXUEQIU(stockCode string, crawlTimeOut int64) *[]string
 		BaseUrl:     "https://xueqiu.com",
 		Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, crawlerBaseInfo)
 
@@ -792,9 +739,10 @@ func GetFinancialReports(stockCode string, crawlTimeOut int64) *[]string {
 	return &[]string{markdown.String()}
 }
 
-func GetTelegraphList(crawlTimeOut int64) *[]string {
+func GetTelegraphList(ctx context.Context, crawlTimeOut int64) *[]string {
 	url := "https://www.cls.cn/telegraph"
 	response, err := resty.New().SetTimeout(time.Duration(crawlTimeOut)*time.Second).R().
+		SetContext(ctx).
 		SetHeader("Referer", "https://www.cls.cn/").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36 Edg/117.0.2045.60").
 		Get(fmt.Sprintf(url))
@@ -814,9 +762,10 @@ func GetTelegraphList(crawlTimeOut int64) *[]string {
 	return &telegraph
 }
 
-func GetTopNewsList(crawlTimeOut int64) *[]string {
+func GetTopNewsList(ctx context.Context, crawlTimeOut int64) *[]string {
 	url := "https://www.cls.cn"
 	response, err := resty.New().SetTimeout(time.Duration(crawlTimeOut)*time.Second).R().
+		SetContext(ctx).
 		SetHeader("Referer", "https://www.cls.cn/").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36 Edg/117.0.2045.60").
 		Get(fmt.Sprintf(url))
@@ -836,19 +785,97 @@ func GetTopNewsList(crawlTimeOut int64) *[]string {
 	return &telegraph
 }
 
+// SaveAIResponseResult 保存分析结果,每次分析保存为一个新的对话
 func (o OpenAi) SaveAIResponseResult(stockCode, stockName, result, chatId, question string) {
-	db.Dao.Create(&models.AIResponseResult{
-		StockCode: stockCode,
-		StockName: stockName,
-		ModelName: o.Model,
-		Content:   result,
-		ChatId:    chatId,
-		Question:  question,
-	})
+	conversation, err := NewAiConversationApi().StartConversation(stockCode, stockName, o.Model, question, result, chatId)
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存AI对话失败:%s", err.Error())
+		return
+	}
+	NewAiRatingApi().LinkConversation(stockCode, chatId, conversation.ID)
 }
 
+// GetAIResponseResult 股票最近一次的AI对话
 func (o OpenAi) GetAIResponseResult(stock string) *models.AIResponseResult {
-	var result models.AIResponseResult
-	db.Dao.Where("stock_code = ?", stock).Order("id desc").Limit(1).Find(&result)
-	return &result
+	return NewAiConversationApi().LatestResult(stock)
+}
+
+// ContinueChat 在已有对话上继续追问:回放之前的问答后提问,回答完成后保存到对话中
+func (o OpenAi) ContinueChat(conversationId uint, question string) <-chan map[string]any {
+	ch := make(chan map[string]any, 512)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.SugaredLogger.Errorf("ContinueChat goroutine  panic :%s", err)
+			}
+		}()
+		defer close(ch)
+
+		api := NewAiConversationApi()
+		conversation, err := api.GetConversation(conversationId)
+		if err != nil {
+			ch <- map[string]any{
+				"code":           0,
+				"question":       question,
+				"conversationId": conversationId,
+				"content":        err.Error(),
+			}
+			return
+		}
+		o.stockCode = conversation.StockCode
+		o.stockName = conversation.StockName
+		msg := []map[string]interface{}{
+			{
+				"role":    "system",
+				"content": o.Prompt,
+			},
+			{
+				"role":    "user",
+				"content": "当前时间",
+			},
+			{
+				"role":    "assistant",
+				"content": "当前本地时间是:" + time.Now().Format("2006-01-02 15:04:05"),
+			},
+		}
+		budget := o.profile().PromptBudget() - llm.EstimateMessagesTokens(toLLMMessages(msg)) - llm.EstimateTokens(question)
+		for _, message := range api.ConversationHistory(conversationId, budget) {
+			msg = append(msg, map[string]interface{}{
+				"role":    message.Role,
+				"content": message.Content,
+			})
+		}
+		msg = append(msg, map[string]interface{}{
+			"role":    "user",
+			"content": question,
+		})
+
+		answers := make(chan map[string]any, 512)
+		go func() {
+			defer close(answers)
+			AskAi(o, nil, msg, answers, question)
+		}()
+		var answer strings.Builder
+		chatId := ""
+		failed := false
+		for answerMsg := range answers {
+			answerMsg["conversationId"] = conversationId
+			if answerMsg["code"] == 0 {
+				failed = true
+			} else if content, ok := answerMsg["content"].(string); ok {
+				answer.WriteString(content)
+			}
+			if id, ok := answerMsg["chatId"].(string); ok && id != "" {
+				chatId = id
+			}
+			ch <- answerMsg
+		}
+		if failed || answer.Len() == 0 {
+			return
+		}
+		if err := api.AppendTurn(conversationId, o.Model, question, answer.String(), chatId); err != nil {
+			logger.SugaredLogger.Errorf("保存AI对话失败:%s", err.Error())
+		}
+	}()
+	return ch
 }
@@ -19,13 +19,13 @@ func TestNewDeepSeekOpenAiConfig(t *testing.T) {
 }
 
 func TestGetTopNewsList(t *testing.T) {
-	GetTopNewsList(30)
+	GetTopNewsList(context.Background(), 30)
 }
 
 func TestSearchGuShiTongStockInfo(t *testing.T) {
 	//db.Init("../../data/stock.db")
-	SearchGuShiTongStockInfo("hk01810", 60)
-	SearchGuShiTongStockInfo("sh600745", 60)
-	SearchGuShiTongStockInfo("gb_goog", 60)
+	SearchGuShiTongStockInfo(context.Background(), "hk01810", 60)
+	SearchGuShiTongStockInfo(context.Background(), "sh600745", 60)
+	SearchGuShiTongStockInfo(context.Background(), "gb_goog", 60)
 
 }
@@ -11,13 +11,14 @@ import (
 
 // BrowserPool 浏览器池结构
 type BrowserPool struct {
+	ctx  context.Context
 	pool chan *context.Context
 	mu   sync.Mutex
 	size int
 }
 
-// NewBrowserPool 创建新的浏览器池
-func NewBrowserPool(size int) *BrowserPool {
+// NewBrowserPool 创建新的浏览器池,parent 取消时浏览器实例随之关闭
+func NewBrowserPool(parent context.Context, size int) *BrowserPool {
 	pool := make(chan *context.Context, size)
 	for i := 0; i < size; i++ {
 		path := GetConfig().BrowserPath
@@ -26,7 +27,7 @@ func NewBrowserPool(size int) *BrowserPool {
 			crawlTimeOut = 30
 		}
 		if path != "" {
-			ctx, _ := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+			ctx, _ := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 			ctx, _ = chromedp.NewExecAllocator(
 				ctx,
 				chromedp.ExecPath(path),
@@ -63,6 +64,7 @@ func NewBrowserPool(size int) *BrowserPool {
 		}
 	}
 	return &BrowserPool{
+		ctx:  parent,
 		pool: pool,
 		size: size,
 	}
@@ -97,8 +99,13 @@ func (pool *BrowserPool) Close() {
 
 // FetchPage 使用浏览器池获取页面内容
 func (pool *BrowserPool) FetchPage(url, waitVisible string) (string, error) {
-	// 从池中获取浏览器实例
-	ctx := pool.Get()
+	// 从池中获取浏览器实例,取消或超时时不再等待
+	var ctx *context.Context
+	select {
+	case ctx = <-pool.pool:
+	case <-pool.ctx.Done():
+		return "", pool.ctx.Err()
+	}
 	defer pool.Put(ctx) // 使用完毕后放回池中
 	var htmlContent string
 	err := chromedp.Run(*ctx,
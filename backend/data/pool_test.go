@@ -1,6 +1,8 @@
 package data
 
 import (
+	"context"
+	"github.com/stretchr/testify/assert"
 	"go-stock/backend/db"
 	"testing"
 )
@@ -8,7 +10,7 @@ import (
 func TestPool(t *testing.T) {
 	db.Init("../../data/stock.db")
 
-	pool := NewBrowserPool(1)
+	pool := NewBrowserPool(context.Background(), 1)
 	go pool.FetchPage("https://fund.eastmoney.com/016533.html", "body")
 	go pool.FetchPage("https://fund.eastmoney.com/217021.html", "body")
 	go pool.FetchPage("https://fund.eastmoney.com/001125.html", "body")
@@ -16,3 +18,11 @@ func TestPool(t *testing.T) {
 	select {}
 
 }
+
+func TestPoolFetchPageCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &BrowserPool{ctx: ctx, pool: make(chan *context.Context, 1)}
+	cancel()
+	_, err := pool.FetchPage("https://fund.eastmoney.com/016533.html", "body")
+	assert.ErrorIs(t, err, context.Canceled)
+}
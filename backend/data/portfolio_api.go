@@ -0,0 +1,457 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"go-stock/backend/calendar"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/5/22 15:20
+// @Desc 每日持仓快照(市值/资金流入流出/当日盈亏)及收益分析
+// -----------------------------------------------------------------------------------
+
+const (
+	AllAccounts      = ""         //快照中表示全部账户
+	AllGroups        = 0          //快照中表示全部分组
+	DefaultBenchmark = "sh000300" //默认业绩基准 沪深300
+
+	tradingDaysPerYear  = 252
+	defaultRiskFreeRate = 0.02 //无风险年化收益率
+)
+
+// PortfolioSnapshot 每日持仓快照,按 日期/账户/分组 唯一
+// 不同市场的持仓按原币种直接相加,与实时盈亏的汇总方式保持一致
+type PortfolioSnapshot struct {
+	ID          uint      `json:"-" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+	Date        string    `json:"date" gorm:"uniqueIndex:idx_portfolio_snapshot_key,priority:1"`
+	Account     string    `json:"account" gorm:"uniqueIndex:idx_portfolio_snapshot_key,priority:2"`
+	GroupId     int       `json:"groupId" gorm:"uniqueIndex:idx_portfolio_snapshot_key,priority:3"`
+	MarketValue float64   `json:"marketValue"`                            //持仓市值
+	CostBasis   float64   `json:"costBasis"`                              //持仓成本
+	CashFlow    float64   `json:"cashFlow"`                               //当日净投入(买入和费用为正,卖出和分红为负)
+	DailyPnL    float64   `json:"dailyPnL" gorm:"column:daily_pnl"`       //当日盈亏
+	RealizedPnL float64   `json:"realizedPnL" gorm:"column:realized_pnl"` //累计已实现盈亏
+	Positions   int       `json:"positions"`                              //持仓股票数
+}
+
+func (PortfolioSnapshot) TableName() string {
+	return "portfolio_snapshot"
+}
+
+// Holding 某只股票在某个账户的收盘持仓
+type Holding struct {
+	StockCode   string
+	Account     string
+	Groups      []int
+	Quantity    int64
+	CostBasis   float64
+	RealizedPnL float64
+	Price       float64
+	PreClose    float64
+	CashFlow    float64
+}
+
+type snapshotKey struct {
+	Account string
+	GroupId int
+}
+
+// BuildSnapshots 按全部/账户/分组汇总持仓,previous 为各维度上一次的快照,用于计算当日盈亏
+func BuildSnapshots(date string, holdings []Holding, previous map[snapshotKey]PortfolioSnapshot) []PortfolioSnapshot {
+	snapshots := make(map[snapshotKey]*PortfolioSnapshot)
+	estimated := make(map[snapshotKey]float64)
+	keys := make([]snapshotKey, 0)
+	add := func(key snapshotKey, h Holding) {
+		s, ok := snapshots[key]
+		if !ok {
+			s = &PortfolioSnapshot{Date: date, Account: key.Account, GroupId: key.GroupId}
+			snapshots[key] = s
+			keys = append(keys, key)
+		}
+		s.MarketValue += h.Price * float64(h.Quantity)
+		s.CostBasis += h.CostBasis
+		s.CashFlow += h.CashFlow
+		s.RealizedPnL += h.RealizedPnL
+		if h.Quantity > 0 {
+			s.Positions++
+			estimated[key] += (h.Price - h.PreClose) * float64(h.Quantity)
+		}
+	}
+	for _, h := range holdings {
+		add(snapshotKey{AllAccounts, AllGroups}, h)
+		add(snapshotKey{h.Account, AllGroups}, h)
+		for _, groupId := range h.Groups {
+			add(snapshotKey{AllAccounts, groupId}, h)
+		}
+	}
+
+	res := make([]PortfolioSnapshot, 0, len(keys))
+	for _, key := range keys {
+		s := snapshots[key]
+		if prev, ok := previous[key]; ok {
+			s.DailyPnL = s.MarketValue - prev.MarketValue - s.CashFlow
+		} else {
+			//没有历史快照时按昨日收盘价估算
+			s.DailyPnL = estimated[key]
+		}
+		s.MarketValue = round2(s.MarketValue)
+		s.CostBasis = round2(s.CostBasis)
+		s.CashFlow = round2(s.CashFlow)
+		s.DailyPnL = round2(s.DailyPnL)
+		s.RealizedPnL = round2(s.RealizedPnL)
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Account != res[j].Account {
+			return res[i].Account < res[j].Account
+		}
+		return res[i].GroupId < res[j].GroupId
+	})
+	return res
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// tradeCashFlow 交易对持仓的净投入
+func tradeCashFlow(trade Trade) float64 {
+	switch trade.Type {
+	case TradeBuy:
+		return trade.Price*float64(trade.Quantity) + trade.Fee
+	case TradeSell:
+		return -(trade.Price*float64(trade.Quantity) - trade.Fee)
+	case TradeDividend:
+		amount := trade.Amount
+		if amount == 0 {
+			amount = trade.Price * float64(trade.Quantity)
+		}
+		return -(amount - trade.Fee)
+	case TradeFee:
+		return trade.Amount + trade.Fee
+	default:
+		return trade.Fee
+	}
+}
+
+type PortfolioApi struct {
+	dao *gorm.DB
+}
+
+func NewPortfolioApi() *PortfolioApi {
+	return &PortfolioApi{dao: db.Dao}
+}
+
+// fallbackPrice 没有行情时依次使用关注列表中最近一次刷新的价格、本地不复权K线 date 当日及之前的最后收盘价
+func (p PortfolioApi) fallbackPrice(date string, follow FollowedStock) (price, preClose float64, ok bool) {
+	if follow.Price > 0 {
+		return follow.Price, follow.Price - follow.PriceChange, true
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+	if err != nil {
+		return 0, 0, false
+	}
+	//K线日期可能带有时间
+	code := strings.ToLower(follow.StockCode)
+	var rows []StockKLine
+	p.dao.Where("code = ? and period = ? and adjust = ? and day < ?", code, KLinePeriodDay, defaultKLineAdjust(code), day.AddDate(0, 0, 1).Format(time.DateOnly)).
+		Order("day desc").Limit(2).Find(&rows)
+	if len(rows) == 0 {
+		return 0, 0, false
+	}
+	price, _ = convertor.ToFloat(rows[0].Close)
+	preClose = price
+	if len(rows) > 1 {
+		preClose, _ = convertor.ToFloat(rows[1].Close)
+	}
+	return price, preClose, price > 0
+}
+
+// Holdings 根据关注列表和收盘行情生成 date 当日的持仓,有交易流水的股票按账户拆分,否则使用手工填写的成本价和数量。
+// 有持仓的股票既没有行情也没有可用的历史价格时返回错误,避免按0市值保存快照
+func (p PortfolioApi) Holdings(date string, follows []FollowedStock, quotes []StockInfo) ([]Holding, error) {
+	prices := make(map[string]*Quote)
+	for i := range quotes {
+		quote, err := quotes[i].GetQuote()
+		if err != nil {
+			logger.SugaredLogger.Warnf("持仓快照 %s", err.Error())
+			continue
+		}
+		prices[normalizeTradeStockCode(quote.Code)] = quote
+	}
+
+	method := GetConfig().CostMethod
+	tradeApi := TradeApi{dao: p.dao}
+	holdings := make([]Holding, 0)
+	for _, follow := range follows {
+		code := normalizeTradeStockCode(follow.StockCode)
+		groups := make([]int, 0, len(follow.Groups))
+		for _, group := range follow.Groups {
+			groups = append(groups, group.GroupId)
+		}
+		holding := Holding{StockCode: code, Groups: groups}
+		priced := true
+		if quote, ok := prices[code]; ok {
+			holding.Price = quote.LastPrice().Float64()
+			holding.PreClose = quote.PreClose.Float64()
+		} else {
+			holding.Price, holding.PreClose, priced = p.fallbackPrice(date, follow)
+			logger.SugaredLogger.Warnf("持仓快照 %s 没有行情,使用历史价格:%v", code, holding.Price)
+		}
+
+		trades := tradeApi.GetTrades(code, "")
+		if len(trades) == 0 {
+			if follow.Volume > 0 {
+				if !priced {
+					return nil, fmt.Errorf("%s 没有行情和历史价格,持仓快照不完整", code)
+				}
+				holding.Account = DefaultAccount
+				holding.Quantity = follow.Volume
+				holding.CostBasis = follow.CostPrice * float64(follow.Volume)
+				holdings = append(holdings, holding)
+			}
+			continue
+		}
+		accounts := make(map[string][]Trade)
+		names := make([]string, 0)
+		for _, trade := range trades {
+			day := trade.TradeDate.Format(time.DateOnly)
+			if day > date {
+				continue
+			}
+			if _, ok := accounts[trade.Account]; !ok {
+				names = append(names, trade.Account)
+			}
+			accounts[trade.Account] = append(accounts[trade.Account], trade)
+		}
+		for _, name := range names {
+			position, err := ComputePosition(accounts[name], method)
+			if err != nil {
+				logger.SugaredLogger.Errorf("持仓快照 %s %s:%s", code, name, err.Error())
+				continue
+			}
+			if !priced && position.Quantity > 0 {
+				return nil, fmt.Errorf("%s 没有行情和历史价格,持仓快照不完整", code)
+			}
+			h := holding
+			h.Account = name
+			h.Quantity = position.Quantity
+			h.CostBasis = position.CostBasis
+			h.RealizedPnL = position.RealizedPnL
+			for _, trade := range accounts[name] {
+				if trade.TradeDate.Format(time.DateOnly) == date {
+					h.CashFlow += tradeCashFlow(trade)
+				}
+			}
+			holdings = append(holdings, h)
+		}
+	}
+	return holdings, nil
+}
+
+// SaveSnapshots 保存快照,同一天重复保存时覆盖
+func (p PortfolioApi) SaveSnapshots(date string, holdings []Holding) ([]PortfolioSnapshot, error) {
+	var history []PortfolioSnapshot
+	p.dao.Model(&PortfolioSnapshot{}).Where("date < ?", date).Order("date asc").Find(&history)
+	previous := make(map[snapshotKey]PortfolioSnapshot)
+	for _, s := range history {
+		previous[snapshotKey{s.Account, s.GroupId}] = s
+	}
+	snapshots := BuildSnapshots(date, holdings, previous)
+	if len(snapshots) == 0 {
+		return snapshots, nil
+	}
+	err := p.dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}, {Name: "account"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "market_value", "cost_basis", "cash_flow", "daily_pnl", "realized_pnl", "positions"}),
+	}).Create(&snapshots).Error
+	return snapshots, err
+}
+
+// TakeSnapshot 获取关注股票的最新行情并保存 date 当日的快照
+func (p PortfolioApi) TakeSnapshot(date string) ([]PortfolioSnapshot, error) {
+	var follows []FollowedStock
+	p.dao.Model(&FollowedStock{}).Preload("Groups").Find(&follows)
+	if len(follows) == 0 {
+		return []PortfolioSnapshot{}, nil
+	}
+	codes := make([]string, 0, len(follows))
+	for _, follow := range follows {
+		codes = append(codes, follow.StockCode)
+	}
+	quotes, err := NewStockDataApi().GetStockCodeRealTimeData(codes...)
+	if err != nil {
+		return nil, err
+	}
+	holdings, err := p.Holdings(date, follows, *quotes)
+	if err != nil {
+		return nil, err
+	}
+	return p.SaveSnapshots(date, holdings)
+}
+
+// GetSnapshots 查询快照,日期为空时不限制
+func (p PortfolioApi) GetSnapshots(account string, groupId int, startDate, endDate string) []PortfolioSnapshot {
+	var snapshots []PortfolioSnapshot
+	query := p.dao.Model(&PortfolioSnapshot{}).Where("account = ? and group_id = ?", account, groupId)
+	if startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+	query.Order("date asc").Find(&snapshots)
+	return snapshots
+}
+
+// PerformancePoint 收益曲线上的一个交易日
+type PerformancePoint struct {
+	Date         string  `json:"date"`
+	MarketValue  float64 `json:"marketValue"`
+	DailyReturn  float64 `json:"dailyReturn"`
+	NAV          float64 `json:"nav"`          //单位净值,起始日为1
+	Drawdown     float64 `json:"drawdown"`     //距前高回撤
+	BenchmarkNAV float64 `json:"benchmarkNav"` //基准净值,起始日为1,没有基准数据时为0
+}
+
+// PortfolioPerformance 收益分析,收益率/回撤/波动率均为小数
+type PortfolioPerformance struct {
+	Account          string             `json:"account"`
+	GroupId          int                `json:"groupId"`
+	Benchmark        string             `json:"benchmark"`
+	StartDate        string             `json:"startDate"`
+	EndDate          string             `json:"endDate"`
+	Days             int                `json:"days"`
+	TotalPnL         float64            `json:"totalPnL"`         //区间累计盈亏
+	CumulativeReturn float64            `json:"cumulativeReturn"` //时间加权累计收益率
+	AnnualizedReturn float64            `json:"annualizedReturn"`
+	MaxDrawdown      float64            `json:"maxDrawdown"`
+	Volatility       float64            `json:"volatility"` //年化波动率
+	Sharpe           float64            `json:"sharpe"`
+	BenchmarkReturn  float64            `json:"benchmarkReturn"`
+	ExcessReturn     float64            `json:"excessReturn"` //超额收益
+	Points           []PerformancePoint `json:"points"`
+}
+
+// AnalyzePortfolio 按时间加权计算收益,当日资金流入视为开盘时投入:r = 当日盈亏/(上日市值+当日净投入)
+// benchmark 为基准指数 日期->收盘价,快照日没有基准数据时沿用前一交易日
+func AnalyzePortfolio(snapshots []PortfolioSnapshot, benchmark map[string]float64, riskFreeRate float64) *PortfolioPerformance {
+	res := &PortfolioPerformance{Points: []PerformancePoint{}}
+	if len(snapshots) == 0 {
+		return res
+	}
+	res.Account = snapshots[0].Account
+	res.GroupId = snapshots[0].GroupId
+	res.StartDate = snapshots[0].Date
+	res.EndDate = snapshots[len(snapshots)-1].Date
+	res.Days = len(snapshots)
+
+	benchDays := make([]string, 0, len(benchmark))
+	for day := range benchmark {
+		benchDays = append(benchDays, day)
+	}
+	sort.Strings(benchDays)
+	benchClose := func(date string) float64 {
+		i := sort.SearchStrings(benchDays, date)
+		if i < len(benchDays) && benchDays[i] == date {
+			return benchmark[date]
+		}
+		if i == 0 {
+			return 0
+		}
+		return benchmark[benchDays[i-1]]
+	}
+	benchBase := benchClose(res.StartDate)
+
+	nav, peak := 1.0, 1.0
+	returns := make([]float64, 0, len(snapshots))
+	for i, s := range snapshots {
+		r := 0.0
+		if i > 0 {
+			res.TotalPnL += s.DailyPnL
+			if base := snapshots[i-1].MarketValue + s.CashFlow; base > 0 {
+				r = s.DailyPnL / base
+			}
+			returns = append(returns, r)
+			nav *= 1 + r
+		}
+		peak = max(peak, nav)
+		point := PerformancePoint{Date: s.Date, MarketValue: s.MarketValue, DailyReturn: r, NAV: nav, Drawdown: nav/peak - 1}
+		if benchBase > 0 {
+			point.BenchmarkNAV = benchClose(s.Date) / benchBase
+		}
+		res.MaxDrawdown = min(res.MaxDrawdown, point.Drawdown)
+		res.Points = append(res.Points, point)
+	}
+
+	res.CumulativeReturn = nav - 1
+	if len(returns) > 0 {
+		res.AnnualizedReturn = math.Pow(nav, float64(tradingDaysPerYear)/float64(len(returns))) - 1
+	}
+	if len(returns) > 1 {
+		mean := 0.0
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+		variance := 0.0
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		res.Volatility = std * math.Sqrt(tradingDaysPerYear)
+		if std > 0 {
+			res.Sharpe = (mean - riskFreeRate/tradingDaysPerYear) / std * math.Sqrt(tradingDaysPerYear)
+		}
+	}
+	if benchBase > 0 {
+		res.BenchmarkReturn = res.Points[len(res.Points)-1].BenchmarkNAV - 1
+		res.ExcessReturn = res.CumulativeReturn - res.BenchmarkReturn
+	}
+	return res
+}
+
+// GetPerformance 分析 startDate~endDate 的收益并与基准指数比较,benchmark 为空时使用沪深300
+func (p PortfolioApi) GetPerformance(account string, groupId int, startDate, endDate, benchmark string) (*PortfolioPerformance, error) {
+	snapshots := p.GetSnapshots(account, groupId, startDate, endDate)
+	if len(snapshots) == 0 {
+		return nil, errors.New("没有持仓快照数据")
+	}
+	if benchmark == "" {
+		benchmark = DefaultBenchmark
+	}
+	closes := make(map[string]float64)
+	if start, err := time.Parse(time.DateOnly, snapshots[0].Date); err == nil {
+		//自然日数量覆盖交易日数量,多取几天用于起始日对齐
+		days := int64(time.Since(start).Hours()/24) + 10
+		for _, k := range *NewKLineStoreApi().GetKLine(benchmark, KLinePeriodDay, KLineAdjustNone, days) {
+			if c, err := convertor.ToFloat(k.Close); err == nil {
+				closes[k.Day] = c
+			}
+		}
+	}
+	if len(closes) == 0 {
+		logger.SugaredLogger.Warnf("获取基准指数%s行情失败", benchmark)
+	}
+	res := AnalyzePortfolio(snapshots, closes, defaultRiskFreeRate)
+	res.Benchmark = strings.ToLower(benchmark)
+	return res, nil
+}
+
+// SnapshotDate 市场收盘时快照对应的交易日(交易所当地日期)
+func SnapshotDate(market string, t time.Time) string {
+	return t.In(calendar.GetExchange(market).Location).Format(time.DateOnly)
+}
@@ -0,0 +1,141 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSnapshots(t *testing.T) {
+	holdings := []Holding{
+		{StockCode: "sh600000", Account: DefaultAccount, Groups: []int{1}, Quantity: 100, CostBasis: 1000, Price: 11, PreClose: 10.5},
+		{StockCode: "sz000001", Account: "融资账户", Groups: []int{1, 2}, Quantity: 200, CostBasis: 2400, Price: 12, PreClose: 12.5, CashFlow: 1200, RealizedPnL: 30},
+	}
+	snapshots := BuildSnapshots("2025-05-20", holdings, nil)
+	assert.Len(t, snapshots, 5)
+
+	byKey := make(map[snapshotKey]PortfolioSnapshot)
+	for _, s := range snapshots {
+		byKey[snapshotKey{s.Account, s.GroupId}] = s
+	}
+	total := byKey[snapshotKey{AllAccounts, AllGroups}]
+	assert.Equal(t, 3500.0, total.MarketValue)
+	assert.Equal(t, 3400.0, total.CostBasis)
+	assert.Equal(t, 2, total.Positions)
+	//没有历史快照时按昨收估算 50-100
+	assert.Equal(t, -50.0, total.DailyPnL)
+	assert.Equal(t, 2400.0, byKey[snapshotKey{"融资账户", AllGroups}].MarketValue)
+	assert.Equal(t, 3500.0, byKey[snapshotKey{AllAccounts, 1}].MarketValue)
+	assert.Equal(t, 2400.0, byKey[snapshotKey{AllAccounts, 2}].MarketValue)
+
+	previous := map[snapshotKey]PortfolioSnapshot{{AllAccounts, AllGroups}: {MarketValue: 2000}}
+	snapshots = BuildSnapshots("2025-05-20", holdings, previous)
+	//3500-2000-1200
+	assert.Equal(t, 300.0, snapshots[0].DailyPnL)
+}
+
+func TestAnalyzePortfolio(t *testing.T) {
+	snapshots := []PortfolioSnapshot{
+		{Date: "2025-05-19", MarketValue: 1000},
+		{Date: "2025-05-20", MarketValue: 1100, DailyPnL: 100},
+		{Date: "2025-05-21", MarketValue: 1980, DailyPnL: -220, CashFlow: 1100},
+		{Date: "2025-05-22", MarketValue: 2178, DailyPnL: 198},
+	}
+	benchmark := map[string]float64{"2025-05-16": 3900, "2025-05-19": 4000, "2025-05-20": 4100, "2025-05-22": 4200}
+	p := AnalyzePortfolio(snapshots, benchmark, 0)
+
+	assert.Equal(t, "2025-05-19", p.StartDate)
+	assert.Equal(t, "2025-05-22", p.EndDate)
+	assert.Len(t, p.Points, 4)
+	assert.InDelta(t, 0.1, p.Points[1].DailyReturn, 1e-9)
+	assert.InDelta(t, -0.1, p.Points[2].DailyReturn, 1e-9)
+	assert.InDelta(t, 0.1, p.Points[3].DailyReturn, 1e-9)
+	//1.1*0.9*1.1
+	assert.InDelta(t, 0.089, p.CumulativeReturn, 1e-9)
+	assert.InDelta(t, 78, p.TotalPnL, 1e-9)
+	assert.InDelta(t, -0.1, p.MaxDrawdown, 1e-9)
+	assert.InDelta(t, math.Sqrt(0.04/3)*math.Sqrt(252), p.Volatility, 1e-9)
+	assert.Greater(t, p.Sharpe, 0.0)
+
+	//缺少基准数据的交易日沿用前一交易日
+	assert.InDelta(t, 1.025, p.Points[2].BenchmarkNAV, 1e-9)
+	assert.InDelta(t, 0.05, p.BenchmarkReturn, 1e-9)
+	assert.InDelta(t, 0.039, p.ExcessReturn, 1e-9)
+
+	p = AnalyzePortfolio(snapshots, nil, 0)
+	assert.Equal(t, 0.0, p.BenchmarkReturn)
+	assert.Equal(t, 0.0, p.Points[3].BenchmarkNAV)
+}
+
+func TestPortfolioSnapshotSave(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&Settings{}, &Trade{}, &PortfolioSnapshot{}, &StockKLine{})
+	clearPositionCache()
+	api := NewPortfolioApi()
+
+	assert.NoError(t, NewTradeApi().AddTrade(Trade{StockCode: "sh600000", Type: TradeBuy, Price: 10, Quantity: 100, TradeDate: tradeDay("2025-05-19")}))
+	assert.NoError(t, NewTradeApi().AddTrade(Trade{StockCode: "sh600000", Type: TradeBuy, Price: 11, Quantity: 100, TradeDate: tradeDay("2025-05-20")}))
+	follows := []FollowedStock{
+		{StockCode: "sh600000", Groups: []GroupStock{{GroupId: 3}}},
+		{StockCode: "gb_aapl", Volume: 10, CostPrice: 150},
+		{StockCode: "sz000001"},
+	}
+	quotes := []StockInfo{
+		{Code: "sh600000", Price: "10.5", PreClose: "10"},
+		{Code: "gb_aapl", Price: "200", PreClose: "190"},
+		{Code: "sz000001", Price: "12", PreClose: "12"},
+	}
+
+	holdings, err := api.Holdings("2025-05-19", follows, quotes)
+	assert.NoError(t, err)
+	assert.Len(t, holdings, 2)
+	assert.Equal(t, int64(100), holdings[0].Quantity)
+	assert.Equal(t, 1000.0, holdings[0].CashFlow)
+	assert.Equal(t, "usaapl", holdings[1].StockCode)
+	assert.Equal(t, DefaultAccount, holdings[1].Account)
+
+	_, err = api.SaveSnapshots("2025-05-19", holdings)
+	assert.NoError(t, err)
+	quotes[0].Quote = nil
+	quotes[0].Price = "11.5"
+	holdings, err = api.Holdings("2025-05-20", follows, quotes)
+	assert.NoError(t, err)
+	_, err = api.SaveSnapshots("2025-05-20", holdings)
+	assert.NoError(t, err)
+	//重复保存覆盖同一天的快照
+	_, err = api.SaveSnapshots("2025-05-20", holdings)
+	assert.NoError(t, err)
+
+	snapshots := api.GetSnapshots(AllAccounts, 3, "", "")
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, 2300.0, snapshots[1].MarketValue)
+	assert.Equal(t, 1100.0, snapshots[1].CashFlow)
+	//2300-1050-1100
+	assert.Equal(t, 150.0, snapshots[1].DailyPnL)
+	assert.Len(t, api.GetSnapshots(AllAccounts, AllGroups, "2025-05-20", ""), 1)
+
+	//没有行情时使用最近一次刷新的价格,再没有则使用本地K线收盘价
+	follows[1].Price = 195
+	follows[1].PriceChange = 5
+	holdings, err = api.Holdings("2025-05-21", follows, quotes[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, 195.0, holdings[1].Price)
+	assert.Equal(t, 190.0, holdings[1].PreClose)
+
+	follows[1].Price = 0
+	_, err = api.Holdings("2025-05-21", follows, quotes[:1])
+	assert.Error(t, err)
+
+	db.Dao.Create(&[]StockKLine{
+		{Code: "gb_aapl", Period: KLinePeriodDay, Adjust: KLineAdjustQfq, Day: "2025-05-20", Close: "198"},
+		{Code: "gb_aapl", Period: KLinePeriodDay, Adjust: KLineAdjustQfq, Day: "2025-05-21", Close: "201"},
+		{Code: "gb_aapl", Period: KLinePeriodDay, Adjust: KLineAdjustQfq, Day: "2025-05-22", Close: "203"},
+	})
+	holdings, err = api.Holdings("2025-05-21", follows, quotes[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, 201.0, holdings[1].Price)
+	assert.Equal(t, 198.0, holdings[1].PreClose)
+}
@@ -0,0 +1,313 @@
+package data
+
+import (
+	"fmt"
+	"go-stock/backend/db"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/30 15:10
+// @Desc A股涨跌停价格和涨停/跌停/炸板/接近涨跌停报警
+// -----------------------------------------------------------------------------------
+
+// A股板块,名称与 StockBasic.Market 一致
+const (
+	BoardMain    = "主板"
+	BoardChiNext = "创业板"
+	BoardSTAR    = "科创板"
+	BoardBSE     = "北交所"
+)
+
+// A股最小报价单位 0.01
+const priceTick = Decimal(decimalScale / 100)
+
+// 涨跌停报警类型
+const (
+	LimitAlertHitUp      = "涨停"
+	LimitAlertHitDown    = "跌停"
+	LimitAlertOpenedUp   = "涨停炸板"
+	LimitAlertOpenedDown = "跌停打开"
+	LimitAlertNearUp     = "接近涨停"
+	LimitAlertNearDown   = "接近跌停"
+)
+
+// DetectBoard 根据代码前缀判断板块,basic 不为空时优先使用 tushare 的板块信息
+func DetectBoard(code string, basic *StockBasic) string {
+	if basic != nil {
+		switch basic.Market {
+		case BoardChiNext, BoardSTAR, BoardBSE:
+			return basic.Market
+		case BoardMain:
+			return BoardMain
+		}
+	}
+	code = strings.ToLower(code)
+	symbol := strings.TrimLeft(code, "shzbj")
+	switch {
+	case strings.HasPrefix(code, "bj"), strings.HasPrefix(symbol, "8"), strings.HasPrefix(symbol, "4"), strings.HasPrefix(symbol, "92"):
+		return BoardBSE
+	case strings.HasPrefix(symbol, "688"), strings.HasPrefix(symbol, "689"):
+		return BoardSTAR
+	case strings.HasPrefix(symbol, "300"), strings.HasPrefix(symbol, "301"), strings.HasPrefix(symbol, "302"):
+		return BoardChiNext
+	default:
+		return BoardMain
+	}
+}
+
+// IsSTName 是否 ST/*ST 股票
+func IsSTName(name string) bool {
+	return strings.Contains(strings.ToUpper(name), "ST")
+}
+
+// LimitRatio 涨跌幅限制(百分比):主板10%,ST主板5%,创业板/科创板20%,北交所30%。
+// 新股上市初期不设涨跌幅限制的情况不处理
+func LimitRatio(board string, st bool) int64 {
+	switch board {
+	case BoardChiNext, BoardSTAR:
+		return 20
+	case BoardBSE:
+		return 30
+	}
+	if st {
+		return 5
+	}
+	return 10
+}
+
+// PriceLimit 某日涨跌停价格
+type PriceLimit struct {
+	Code      string  `json:"code"`
+	Board     string  `json:"board"`
+	ST        bool    `json:"st"`
+	Ratio     int64   `json:"ratio"`
+	PreClose  Decimal `json:"preClose"`
+	LimitUp   Decimal `json:"limitUp"`
+	LimitDown Decimal `json:"limitDown"`
+}
+
+// roundToTick 四舍五入到分
+func roundToTick(preClose Decimal, percent int64) Decimal {
+	cents := (int64(preClose)*100/decimalScale*percent + 50) / 100
+	return Decimal(cents) * priceTick
+}
+
+// ComputePriceLimit 以昨日收盘价计算涨跌停价,四舍五入到0.01元
+func ComputePriceLimit(code, name string, preClose Decimal, basic *StockBasic) PriceLimit {
+	board := DetectBoard(code, basic)
+	st := IsSTName(name)
+	if basic != nil && basic.Name != "" {
+		st = st || IsSTName(basic.Name)
+	}
+	ratio := LimitRatio(board, st)
+	return PriceLimit{
+		Code:      code,
+		Board:     board,
+		ST:        st,
+		Ratio:     ratio,
+		PreClose:  preClose,
+		LimitUp:   roundToTick(preClose, 100+ratio),
+		LimitDown: roundToTick(preClose, 100-ratio),
+	}
+}
+
+// limitPhase 相对涨跌停的位置
+type limitPhase int
+
+const (
+	limitPhaseNone limitPhase = iota
+	limitPhaseNearUp
+	limitPhaseSealedUp
+	limitPhaseNearDown
+	limitPhaseSealedDown
+)
+
+// phase 根据最新价和盘口判断:涨停价上卖一没有挂单为封涨停,跌停价上买一没有挂单为封跌停,
+// 距涨跌停 ticks 档以内为接近涨跌停
+func (l PriceLimit) phase(q *Quote, ticks int64) limitPhase {
+	price := q.LastPrice()
+	if price.IsZero() || l.LimitUp.IsZero() {
+		return limitPhaseNone
+	}
+	switch {
+	case price >= l.LimitUp && q.Asks[0].Volume == 0:
+		return limitPhaseSealedUp
+	case price <= l.LimitDown && q.Bids[0].Volume == 0:
+		return limitPhaseSealedDown
+	case ticks > 0 && price < l.LimitUp && l.LimitUp-price <= Decimal(ticks)*priceTick:
+		return limitPhaseNearUp
+	case ticks > 0 && price > l.LimitDown && price-l.LimitDown <= Decimal(ticks)*priceTick:
+		return limitPhaseNearDown
+	}
+	return limitPhaseNone
+}
+
+// LimitSignal 涨跌停报警
+type LimitSignal struct {
+	Type       string     `json:"type"`
+	Code       string     `json:"code"`
+	Name       string     `json:"name"`
+	Price      Decimal    `json:"price"`
+	Limit      PriceLimit `json:"limit"`
+	SealVolume int64      `json:"sealVolume"` //封单量(股)
+}
+
+// Message 报警内容
+func (s LimitSignal) Message() string {
+	msg := fmt.Sprintf("[%s] %s %.2f 涨停价:%.2f 跌停价:%.2f", s.Name, s.Type, s.Price.Float64(), s.Limit.LimitUp.Float64(), s.Limit.LimitDown.Float64())
+	if s.SealVolume > 0 {
+		msg += fmt.Sprintf(" 封单:%d手", s.SealVolume/100)
+	}
+	return msg
+}
+
+type limitTrackerState struct {
+	date  string
+	phase limitPhase
+}
+
+// LimitTracker 跟踪每只股票当天相对涨跌停的位置,位置变化时产生报警
+type LimitTracker struct {
+	mu     sync.Mutex
+	states map[string]limitTrackerState
+}
+
+func NewLimitTracker() *LimitTracker {
+	return &LimitTracker{states: make(map[string]limitTrackerState)}
+}
+
+// Update 更新行情,返回触发的报警。跨日时重新开始跟踪
+func (t *LimitTracker) Update(limit PriceLimit, q *Quote, ticks int64) []LimitSignal {
+	phase := limit.phase(q, ticks)
+	date := q.Time.Format(time.DateOnly)
+	t.mu.Lock()
+	last, ok := t.states[limit.Code]
+	t.states[limit.Code] = limitTrackerState{date: date, phase: phase}
+	t.mu.Unlock()
+	if !ok || last.date != date {
+		last = limitTrackerState{date: date, phase: limitPhaseNone}
+	}
+	if phase == last.phase {
+		return nil
+	}
+	signal := func(alertType string) LimitSignal {
+		s := LimitSignal{Type: alertType, Code: limit.Code, Name: q.Name, Price: q.LastPrice(), Limit: limit}
+		switch phase {
+		case limitPhaseSealedUp:
+			s.SealVolume = q.Bids[0].Volume
+		case limitPhaseSealedDown:
+			s.SealVolume = q.Asks[0].Volume
+		}
+		return s
+	}
+	res := make([]LimitSignal, 0, 2)
+	switch last.phase {
+	case limitPhaseSealedUp:
+		res = append(res, signal(LimitAlertOpenedUp))
+	case limitPhaseSealedDown:
+		res = append(res, signal(LimitAlertOpenedDown))
+	}
+	switch phase {
+	case limitPhaseSealedUp:
+		res = append(res, signal(LimitAlertHitUp))
+	case limitPhaseSealedDown:
+		res = append(res, signal(LimitAlertHitDown))
+	case limitPhaseNearUp:
+		//炸板后回落到接近涨停不再重复提醒
+		if last.phase == limitPhaseNone || last.phase == limitPhaseNearDown {
+			res = append(res, signal(LimitAlertNearUp))
+		}
+	case limitPhaseNearDown:
+		if last.phase == limitPhaseNone || last.phase == limitPhaseNearUp {
+			res = append(res, signal(LimitAlertNearDown))
+		}
+	}
+	return res
+}
+
+// 涨跌停价格按 代码|日期 缓存
+var priceLimitCache sync.Map
+
+var defaultLimitTracker = NewLimitTracker()
+
+type PriceLimitApi struct {
+	dao *gorm.DB
+}
+
+func NewPriceLimitApi() *PriceLimitApi {
+	return &PriceLimitApi{dao: db.Dao}
+}
+
+// tsCode sh600000 -> 600000.SH
+func tsCode(code string) string {
+	code = strings.ToLower(code)
+	if len(code) < 3 {
+		return code
+	}
+	return code[2:] + "." + strings.ToUpper(code[:2])
+}
+
+// isIndexCode 指数没有涨跌停限制
+func isIndexCode(code string) bool {
+	code = strings.ToLower(code)
+	return strings.HasPrefix(code, "sh000") || strings.HasPrefix(code, "sz399") || strings.HasPrefix(code, "bj899")
+}
+
+// Get 某日涨跌停价格,非A股个股返回 nil
+func (p PriceLimitApi) Get(q *Quote) *PriceLimit {
+	if q.Market != MarketCN || q.PreClose.IsZero() || isIndexCode(q.Code) {
+		return nil
+	}
+	key := q.Code + "|" + q.Time.Format(time.DateOnly)
+	if limit, ok := priceLimitCache.Load(key); ok {
+		return limit.(*PriceLimit)
+	}
+	var basic *StockBasic
+	record := &StockBasic{}
+	if p.dao != nil && p.dao.Where("ts_code = ?", tsCode(q.Code)).First(record).Error == nil {
+		basic = record
+	}
+	limit := ComputePriceLimit(q.Code, q.Name, q.PreClose, basic)
+	priceLimitCache.Store(key, &limit)
+	return &limit
+}
+
+// Fill 填充 StockInfo 的涨跌停价格和状态
+func (p PriceLimitApi) Fill(stockInfo *StockInfo, q *Quote) {
+	limit := p.Get(q)
+	if limit == nil {
+		return
+	}
+	stockInfo.LimitUpPrice = limit.LimitUp.Float64()
+	stockInfo.LimitDownPrice = limit.LimitDown.Float64()
+	switch limit.phase(q, 0) {
+	case limitPhaseSealedUp:
+		stockInfo.LimitStatus = LimitAlertHitUp
+	case limitPhaseSealedDown:
+		stockInfo.LimitStatus = LimitAlertHitDown
+	default:
+		stockInfo.LimitStatus = ""
+	}
+}
+
+// Signals 计算涨跌停报警,ticks 为接近涨跌停的档数,0表示不提醒接近涨跌停
+func (p PriceLimitApi) Signals(stockInfos []StockInfo, ticks int64) []LimitSignal {
+	res := make([]LimitSignal, 0)
+	for i := range stockInfos {
+		q, err := stockInfos[i].GetQuote()
+		if err != nil {
+			continue
+		}
+		limit := p.Get(q)
+		if limit == nil {
+			continue
+		}
+		res = append(res, defaultLimitTracker.Update(*limit, q, ticks)...)
+	}
+	return res
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectBoardAndRatio(t *testing.T) {
+	assert.Equal(t, BoardMain, DetectBoard("sh600000", nil))
+	assert.Equal(t, BoardMain, DetectBoard("sz000001", nil))
+	assert.Equal(t, BoardChiNext, DetectBoard("sz300750", nil))
+	assert.Equal(t, BoardSTAR, DetectBoard("sh688981", nil))
+	assert.Equal(t, BoardBSE, DetectBoard("bj830799", nil))
+	assert.Equal(t, BoardBSE, DetectBoard("bj920001", nil))
+	assert.Equal(t, BoardChiNext, DetectBoard("sz000001", &StockBasic{Market: "创业板"}))
+	assert.Equal(t, BoardSTAR, DetectBoard("sh688981", &StockBasic{Market: "CDR"}))
+
+	assert.Equal(t, int64(10), LimitRatio(BoardMain, false))
+	assert.Equal(t, int64(5), LimitRatio(BoardMain, true))
+	assert.Equal(t, int64(20), LimitRatio(BoardChiNext, true))
+	assert.Equal(t, int64(30), LimitRatio(BoardBSE, false))
+	assert.True(t, IsSTName("*ST中润"))
+}
+
+func TestComputePriceLimit(t *testing.T) {
+	limit := ComputePriceLimit("sh600000", "浦发银行", NewDecimal(10), nil)
+	assert.Equal(t, NewDecimal(11), limit.LimitUp)
+	assert.Equal(t, NewDecimal(9), limit.LimitDown)
+
+	//四舍五入到分
+	limit = ComputePriceLimit("sz000002", "万科A", NewDecimal(3.33), nil)
+	assert.Equal(t, NewDecimal(3.66), limit.LimitUp)
+	assert.Equal(t, NewDecimal(3), limit.LimitDown)
+	limit = ComputePriceLimit("sz000004", "ST国华", NewDecimal(4.33), nil)
+	assert.Equal(t, NewDecimal(4.55), limit.LimitUp)
+	assert.Equal(t, NewDecimal(4.11), limit.LimitDown)
+	limit = ComputePriceLimit("sz300750", "宁德时代", NewDecimal(180.05), nil)
+	assert.Equal(t, NewDecimal(216.06), limit.LimitUp)
+	assert.Equal(t, NewDecimal(144.04), limit.LimitDown)
+	limit = ComputePriceLimit("bj830799", "艾融软件", NewDecimal(20.01), nil)
+	assert.Equal(t, NewDecimal(26.01), limit.LimitUp)
+	assert.Equal(t, NewDecimal(14.01), limit.LimitDown)
+}
+
+func limitQuote(price, a1v, b1v string) *StockInfo {
+	return &StockInfo{
+		Code: "sh600000", Name: "浦发银行", Date: "2025-05-20", Time: "10:30:00",
+		Price: price, PreClose: "10.00", A1P: "11.00", A1V: a1v, B1P: "10.99", B1V: b1v,
+	}
+}
+
+func TestLimitTracker(t *testing.T) {
+	tracker := NewLimitTracker()
+	limit := ComputePriceLimit("sh600000", "浦发银行", NewDecimal(10), nil)
+	update := func(info *StockInfo) []string {
+		q, err := info.GetQuote()
+		assert.NoError(t, err)
+		res := make([]string, 0)
+		for _, signal := range tracker.Update(limit, q, 3) {
+			res = append(res, signal.Type)
+		}
+		return res
+	}
+
+	assert.Empty(t, update(limitQuote("10.50", "100", "100")))
+	assert.Equal(t, []string{LimitAlertNearUp}, update(limitQuote("10.98", "100", "100")))
+	assert.Empty(t, update(limitQuote("10.99", "100", "100")))
+	q, _ := limitQuote("11.00", "0", "1200000").GetQuote()
+	signals := tracker.Update(limit, q, 3)
+	assert.Len(t, signals, 1)
+	assert.Equal(t, LimitAlertHitUp, signals[0].Type)
+	assert.Equal(t, int64(1200000), signals[0].SealVolume)
+	assert.Contains(t, signals[0].Message(), "封单:12000手")
+	assert.Empty(t, update(limitQuote("11.00", "0", "800000")))
+	//炸板后回落到接近涨停只提醒炸板
+	assert.Equal(t, []string{LimitAlertOpenedUp}, update(limitQuote("10.99", "500", "100")))
+	assert.Equal(t, []string{LimitAlertHitUp}, update(limitQuote("11.00", "0", "100")))
+
+	//跨日重新跟踪
+	next := limitQuote("11.00", "0", "100")
+	next.Date = "2025-05-21"
+	assert.Equal(t, []string{LimitAlertHitUp}, update(next))
+
+	down := limitQuote("9.00", "100", "0")
+	down.Date = "2025-05-21"
+	assert.Equal(t, []string{LimitAlertOpenedUp, LimitAlertHitDown}, update(down))
+}
+
+func TestPriceLimitFill(t *testing.T) {
+	info := limitQuote("11.00", "0", "100")
+	info.Code = "sh601000"
+	q, _ := info.GetQuote()
+	api := PriceLimitApi{}
+	api.Fill(info, q)
+	assert.Equal(t, 11.0, info.LimitUpPrice)
+	assert.Equal(t, 9.0, info.LimitDownPrice)
+	assert.Equal(t, LimitAlertHitUp, info.LimitStatus)
+
+	index := &StockInfo{Code: "sh000001", Name: "上证指数", Date: "2025-05-20", Time: "10:30:00", Price: "3300", PreClose: "3000"}
+	q, _ = index.GetQuote()
+	assert.Nil(t, api.Get(q))
+}
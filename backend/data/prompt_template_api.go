@@ -4,6 +4,7 @@ import (
 	"go-stock/backend/db"
 	"go-stock/backend/logger"
 	"go-stock/backend/models"
+	"strings"
 )
 
 type PromptTemplateApi struct {
@@ -31,9 +32,10 @@ func (t PromptTemplateApi) AddPrompt(template models.PromptTemplate) string {
 	db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).First(&tmp)
 	if tmp.ID == 0 {
 		err := db.Dao.Model(&models.PromptTemplate{}).Create(&models.PromptTemplate{
-			Content: template.Content,
-			Name:    template.Name,
-			Type:    template.Type,
+			Content:         template.Content,
+			Name:            template.Name,
+			Type:            template.Type,
+			ContextSections: template.ContextSections,
 		}).Error
 		if err != nil {
 			return "添加失败"
@@ -41,7 +43,7 @@ func (t PromptTemplateApi) AddPrompt(template models.PromptTemplate) string {
 			return "添加成功"
 		}
 	} else {
-		err := db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).Updates(template).Error
+		err := db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).Select("name", "content", "type", "context_sections").Updates(template).Error
 		if err != nil {
 			return "更新失败"
 		} else {
@@ -70,6 +72,16 @@ func (t PromptTemplateApi) GetPromptTemplateByID(id int) string {
 	logger.SugaredLogger.Infof("GetPromptTemplateByID:%d %s", id, prompt.Content)
 	return prompt.Content
 }
+
+// GetContextSections 模板配置的AI分析数据,未配置时返回空
+func (t PromptTemplateApi) GetContextSections(id int) []string {
+	prompt := &models.PromptTemplate{}
+	db.Dao.Model(&models.PromptTemplate{}).Where("id=?", id).First(prompt)
+	if strings.TrimSpace(prompt.ContextSections) == "" {
+		return nil
+	}
+	return strings.Split(RemoveAllBlankChar(prompt.ContextSections), ",")
+}
 func NewPromptTemplateApi() *PromptTemplateApi {
 	return &PromptTemplateApi{}
 }
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"go-stock/backend/calendar"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/5/15 10:12
+// @Desc 强类型行情(定点小数价格/整数成交量/带交易所时区的行情时间)
+// -----------------------------------------------------------------------------------
+
+// Decimal 定点小数,保留4位小数(覆盖A股/港股/美股报价精度)
+type Decimal int64
+
+const decimalScale = 10000
+
+// 超出该范围的数值转换为定点小数会溢出
+const maxDecimal = math.MaxInt64 / decimalScale
+
+// ParseDecimal 解析十进制字符串
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > maxDecimal {
+		return 0, fmt.Errorf("decimal out of range %q", s)
+	}
+	return Decimal(math.Round(f * decimalScale)), nil
+}
+
+func NewDecimal(f float64) Decimal {
+	return Decimal(math.Round(f * decimalScale))
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// Int64 取整(四舍五入)
+func (d Decimal) Int64() int64 {
+	return int64(math.Round(d.Float64()))
+}
+
+func (d Decimal) IsZero() bool {
+	return d == 0
+}
+
+func (d Decimal) String() string {
+	return strconv.FormatFloat(d.Float64(), 'f', -1, 64)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON 兼容数字和字符串两种写法
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, "\"")
+	if len(b) == 0 || string(b) == "null" {
+		*d = 0
+		return nil
+	}
+	v, err := ParseDecimal(string(b))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// QuoteLevel 盘口档位
+type QuoteLevel struct {
+	Price  Decimal `json:"price"`
+	Volume int64   `json:"volume"`
+}
+
+// Quote 强类型实时行情
+type Quote struct {
+	Code                  string        `json:"code"`
+	Name                  string        `json:"name"`
+	Market                string        `json:"market"`
+	Time                  time.Time     `json:"time"` //交易所当地时间
+	Price                 Decimal       `json:"price"`
+	PreClose              Decimal       `json:"preClose"`
+	Open                  Decimal       `json:"open"`
+	High                  Decimal       `json:"high"`
+	Low                   Decimal       `json:"low"`
+	Bid                   Decimal       `json:"bid"`
+	Ask                   Decimal       `json:"ask"`
+	Volume                int64         `json:"volume"`
+	Amount                Decimal       `json:"amount"`
+	Bids                  [5]QuoteLevel `json:"bids"`
+	Asks                  [5]QuoteLevel `json:"asks"`
+	ExtendedPrice         Decimal       `json:"extendedPrice"`         //盘前盘后价
+	ExtendedChangePercent Decimal       `json:"extendedChangePercent"` //盘前盘后涨跌幅
+}
+
+// QuoteParseError 行情字段解析失败
+type QuoteParseError struct {
+	Code  string
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *QuoteParseError) Error() string {
+	return fmt.Sprintf("解析行情失败 %s %s=%q: %v", e.Code, e.Field, e.Value, e.Err)
+}
+
+func (e *QuoteParseError) Unwrap() error {
+	return e.Err
+}
+
+// quoteParser 记录第一个解析错误,避免每个字段都判断一次
+type quoteParser struct {
+	code string
+	err  error
+}
+
+func (p *quoteParser) fail(field, value string, err error) {
+	if p.err == nil {
+		p.err = &QuoteParseError{Code: p.code, Field: field, Value: value, Err: err}
+	}
+}
+
+// required 必填价格字段,为空时报错
+func (p *quoteParser) required(field, value string) Decimal {
+	if strings.TrimSpace(value) == "" {
+		p.fail(field, value, fmt.Errorf("missing value"))
+		return 0
+	}
+	return p.decimal(field, value)
+}
+
+// decimal 可选价格字段,为空时为0
+func (p *quoteParser) decimal(field, value string) Decimal {
+	if strings.TrimSpace(value) == "" {
+		return 0
+	}
+	d, err := ParseDecimal(value)
+	if err != nil {
+		p.fail(field, value, err)
+	}
+	return d
+}
+
+// volume 可选成交量字段,部分数据源带有 .0 小数位
+func (p *quoteParser) volume(field, value string) int64 {
+	d := p.decimal(field, value)
+	if d%decimalScale != 0 {
+		p.fail(field, value, fmt.Errorf("volume is not an integer"))
+	}
+	return d.Int64()
+}
+
+// timestamp 新浪/腾讯/东方财富行情时间均为北京时间,转换为交易所当地时间
+func (p *quoteParser) timestamp(date, clock, market string) time.Time {
+	date = strings.TrimSpace(date)
+	clock = strings.TrimSpace(clock)
+	if date == "" && clock == "" {
+		return time.Time{}
+	}
+	value := date + " " + clock
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, quoteLocation()); err == nil {
+			return t.In(calendar.GetExchange(market).Location)
+		}
+	}
+	p.fail("日期时间", value, fmt.Errorf("invalid time"))
+	return time.Time{}
+}
+
+// ParseQuote 将行情字段解析为强类型行情,字段格式错误时返回 *QuoteParseError
+func ParseQuote(info *StockInfo) (*Quote, error) {
+	code := strings.TrimSpace(info.Code)
+	if code == "" {
+		return nil, &QuoteParseError{Field: "股票代码", Err: fmt.Errorf("missing value")}
+	}
+	p := &quoteParser{code: code}
+	market := GetStockMarket(code)
+	q := &Quote{
+		Code:                  code,
+		Name:                  info.Name,
+		Market:                market,
+		Time:                  p.timestamp(info.Date, info.Time, market),
+		Price:                 p.required("当前价格", info.Price),
+		PreClose:              p.required("昨日收盘价", info.PreClose),
+		Open:                  p.decimal("今日开盘价", info.Open),
+		High:                  p.decimal("今日最高价", info.High),
+		Low:                   p.decimal("今日最低价", info.Low),
+		Bid:                   p.decimal("竞买价", info.Bid),
+		Ask:                   p.decimal("竞卖价", info.Ask),
+		Volume:                p.volume("成交的股票数", info.Volume),
+		Amount:                p.decimal("成交金额", info.Amount),
+		ExtendedPrice:         p.decimal("盘前盘后", info.BA),
+		ExtendedChangePercent: p.decimal("盘前盘后涨跌幅", info.BAChange),
+	}
+	bids := [5][2]string{{info.B1P, info.B1V}, {info.B2P, info.B2V}, {info.B3P, info.B3V}, {info.B4P, info.B4V}, {info.B5P, info.B5V}}
+	asks := [5][2]string{{info.A1P, info.A1V}, {info.A2P, info.A2V}, {info.A3P, info.A3V}, {info.A4P, info.A4V}, {info.A5P, info.A5V}}
+	for i := range bids {
+		q.Bids[i] = QuoteLevel{Price: p.decimal(fmt.Sprintf("买%d报价", i+1), bids[i][0]), Volume: p.volume(fmt.Sprintf("买%d申报", i+1), bids[i][1])}
+		q.Asks[i] = QuoteLevel{Price: p.decimal(fmt.Sprintf("卖%d报价", i+1), asks[i][0]), Volume: p.volume(fmt.Sprintf("卖%d申报", i+1), asks[i][1])}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return q, nil
+}
+
+// LastPrice 当前价格,未成交时依次使用卖一、买一、昨日收盘价
+func (q *Quote) LastPrice() Decimal {
+	for _, price := range []Decimal{q.Price, q.Asks[0].Price, q.Bids[0].Price} {
+		if price > 0 {
+			return price
+		}
+	}
+	return q.PreClose
+}
+
+// DayHigh 今日最高价,没有时使用开盘价
+func (q *Quote) DayHigh() Decimal {
+	if q.High > 0 {
+		return q.High
+	}
+	return q.Open
+}
+
+// DayLow 今日最低价,没有时使用开盘价
+func (q *Quote) DayLow() Decimal {
+	if q.Low > 0 {
+		return q.Low
+	}
+	return q.Open
+}
+
+// GetQuote 返回强类型行情,从数据库读取的行情没有解析结果时按字段重新解析
+func (receiver *StockInfo) GetQuote() (*Quote, error) {
+	if receiver.Quote != nil {
+		return receiver.Quote, nil
+	}
+	q, err := ParseQuote(receiver)
+	if err != nil {
+		return nil, err
+	}
+	receiver.Quote = q
+	return q, nil
+}
@@ -0,0 +1,472 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-stock/backend/calendar"
+	"go-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/5/12 10:02
+// @Desc 实时行情数据源及自动故障切换
+// -----------------------------------------------------------------------------------
+
+const eastmoneyStockUrl = "https://push2.eastmoney.com/api/qt/ulist.np/get?fltt=2&invt=2&fields=%s&secids=%s&_=%d"
+
+const (
+	QuoteSourceSina      = "sina"
+	QuoteSourceTencent   = "tencent"
+	QuoteSourceEastmoney = "eastmoney"
+)
+
+const (
+	MarketCN = calendar.MarketCN
+	MarketHK = calendar.MarketHK
+	MarketUS = calendar.MarketUS
+)
+
+// 连续失败多少次后进入冷却期，冷却期内该数据源排到最后
+const quoteFailureThreshold = 3
+const quoteCoolDown = time.Minute * 2
+
+// QuoteProvider 实时行情数据源
+type QuoteProvider interface {
+	// Name 数据源名称
+	Name() string
+	// Supports 是否支持该股票代码
+	Supports(code string) bool
+	// Fetch 批量获取实时行情,返回的股票代码与新浪格式保持一致(sh600000/hk00700/gb_aapl)
+	Fetch(client *resty.Client, codes ...string) ([]StockInfo, error)
+}
+
+// QuoteSourceStatus 数据源健康状态
+type QuoteSourceStatus struct {
+	Name          string    `json:"name"`
+	Healthy       bool      `json:"healthy"`
+	Failures      int       `json:"failures"`
+	LastError     string    `json:"lastError"`
+	LastSuccess   time.Time `json:"lastSuccess"`
+	LastFailure   time.Time `json:"lastFailure"`
+	CoolDownUntil time.Time `json:"coolDownUntil"`
+}
+
+type quoteHealthTracker struct {
+	mu     sync.RWMutex
+	status map[string]*QuoteSourceStatus
+}
+
+var quoteHealth = &quoteHealthTracker{status: make(map[string]*QuoteSourceStatus)}
+
+func (h *quoteHealthTracker) get(name string) *QuoteSourceStatus {
+	s, ok := h.status[name]
+	if !ok {
+		s = &QuoteSourceStatus{Name: name, Healthy: true}
+		h.status[name] = s
+	}
+	return s
+}
+
+func (h *quoteHealthTracker) success(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(name)
+	s.Healthy = true
+	s.Failures = 0
+	s.LastSuccess = time.Now()
+	s.CoolDownUntil = time.Time{}
+}
+
+func (h *quoteHealthTracker) failure(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(name)
+	s.Failures++
+	s.LastFailure = time.Now()
+	if err != nil {
+		s.LastError = err.Error()
+	}
+	if s.Failures >= quoteFailureThreshold {
+		s.Healthy = false
+		s.CoolDownUntil = time.Now().Add(quoteCoolDown)
+	}
+}
+
+func (h *quoteHealthTracker) available(name string, now time.Time) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.status[name]
+	if !ok {
+		return true
+	}
+	return s.Healthy || now.After(s.CoolDownUntil)
+}
+
+func (h *quoteHealthTracker) snapshot() []QuoteSourceStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := make([]QuoteSourceStatus, 0, len(h.status))
+	for _, name := range []string{QuoteSourceTencent, QuoteSourceSina, QuoteSourceEastmoney} {
+		if s, ok := h.status[name]; ok {
+			res = append(res, *s)
+		} else {
+			res = append(res, QuoteSourceStatus{Name: name, Healthy: true})
+		}
+	}
+	return res
+}
+
+// GetQuoteSourceStatus 获取各行情数据源的健康状态
+func GetQuoteSourceStatus() []QuoteSourceStatus {
+	return quoteHealth.snapshot()
+}
+
+var quoteProviders = map[string]QuoteProvider{
+	QuoteSourceSina:      &SinaQuoteProvider{url: sinaStockUrl},
+	QuoteSourceTencent:   &TencentQuoteProvider{url: txStockUrl},
+	QuoteSourceEastmoney: &EastmoneyQuoteProvider{url: eastmoneyStockUrl},
+}
+
+var defaultQuoteSources = map[string][]string{
+	MarketCN: {QuoteSourceTencent, QuoteSourceSina, QuoteSourceEastmoney},
+	MarketHK: {QuoteSourceTencent, QuoteSourceSina, QuoteSourceEastmoney},
+	MarketUS: {QuoteSourceSina},
+}
+
+// GetStockMarket 根据股票代码判断所属市场
+func GetStockMarket(code string) string {
+	switch {
+	case strutil.HasPrefixAny(code, []string{"hk", "HK"}):
+		return MarketHK
+	case strutil.HasPrefixAny(code, []string{"us", "US", "gb_", "GB_"}):
+		return MarketUS
+	default:
+		return MarketCN
+	}
+}
+
+// quoteSources 按配置返回某市场的数据源优先级,配置中未列出的默认数据源追加在末尾兜底
+func quoteSources(config *Settings, market string) []string {
+	setting := ""
+	if config != nil {
+		switch market {
+		case MarketCN:
+			setting = config.QuoteSourcesCN
+		case MarketHK:
+			setting = config.QuoteSourcesHK
+		case MarketUS:
+			setting = config.QuoteSourcesUS
+		}
+	}
+	var sources []string
+	for _, name := range strutil.SplitAndTrim(strings.ToLower(setting), ",") {
+		if _, ok := quoteProviders[name]; ok && !slice.Contain(sources, name) {
+			sources = append(sources, name)
+		}
+	}
+	for _, name := range defaultQuoteSources[market] {
+		if !slice.Contain(sources, name) {
+			sources = append(sources, name)
+		}
+	}
+	return sources
+}
+
+// QuoteRouter 按市场优先级依次尝试各数据源,失败时自动切换到下一个
+type QuoteRouter struct {
+	client    *resty.Client
+	config    *Settings
+	providers map[string]QuoteProvider
+	health    *quoteHealthTracker
+}
+
+func NewQuoteRouter(client *resty.Client, config *Settings) *QuoteRouter {
+	return &QuoteRouter{
+		client:    client,
+		config:    config,
+		providers: quoteProviders,
+		health:    quoteHealth,
+	}
+}
+
+// Fetch 获取实时行情,部分数据源失败时返回其余可用数据,全部失败才返回错误
+func (r *QuoteRouter) Fetch(codes ...string) ([]StockInfo, error) {
+	groups := map[string][]string{}
+	for _, code := range codes {
+		market := GetStockMarket(code)
+		groups[market] = append(groups[market], code)
+	}
+	stockInfos := make([]StockInfo, 0, len(codes))
+	var lastErr error
+	for _, market := range []string{MarketCN, MarketHK, MarketUS} {
+		if len(groups[market]) == 0 {
+			continue
+		}
+		infos, err := r.fetchMarket(market, groups[market])
+		if err != nil {
+			lastErr = err
+		}
+		stockInfos = append(stockInfos, infos...)
+	}
+	if len(stockInfos) == 0 && lastErr != nil {
+		return stockInfos, lastErr
+	}
+	return stockInfos, nil
+}
+
+func (r *QuoteRouter) fetchMarket(market string, codes []string) ([]StockInfo, error) {
+	now := time.Now()
+	sources := quoteSources(r.config, market)
+	// 冷却中的数据源排到最后,全部不可用时仍然按顺序尝试
+	ordered := slice.Filter(sources, func(_ int, name string) bool { return r.health.available(name, now) })
+	ordered = append(ordered, slice.Filter(sources, func(_ int, name string) bool { return !r.health.available(name, now) })...)
+
+	result := make([]StockInfo, 0, len(codes))
+	pending := codes
+	var lastErr error
+	for _, name := range ordered {
+		provider, ok := r.providers[name]
+		if !ok || len(pending) == 0 {
+			continue
+		}
+		supported := slice.Filter(pending, func(_ int, code string) bool { return provider.Supports(code) })
+		if len(supported) == 0 {
+			continue
+		}
+		infos, err := provider.Fetch(r.client, supported...)
+		if err == nil && len(infos) == 0 {
+			err = fmt.Errorf("%s 未返回任何行情数据", name)
+		}
+		if err != nil {
+			logger.SugaredLogger.Warnf("行情数据源[%s]获取失败,切换下一个数据源:%s", name, err.Error())
+			r.health.failure(name, err)
+			lastErr = err
+			continue
+		}
+		r.health.success(name)
+		result = append(result, infos...)
+		pending = slice.Filter(pending, func(_ int, code string) bool {
+			return !slice.ContainBy(infos, func(info StockInfo) bool { return sameStockCode(code, info.Code) })
+		})
+	}
+	if len(pending) > 0 && lastErr != nil {
+		return result, fmt.Errorf("%v 获取行情失败: %w", pending, lastErr)
+	}
+	return result, nil
+}
+
+// sameStockCode 比较关注列表代码与行情返回代码(usAAPL 与 gb_aapl 视为同一只)
+func sameStockCode(code, quoteCode string) bool {
+	code = strings.ToLower(code)
+	if strings.HasPrefix(code, "us") {
+		code = strings.Replace(code, "us", "gb_", 1)
+	}
+	return code == strings.ToLower(quoteCode)
+}
+
+// SinaQuoteProvider 新浪行情
+type SinaQuoteProvider struct {
+	url string
+}
+
+func (p *SinaQuoteProvider) Name() string {
+	return QuoteSourceSina
+}
+
+func (p *SinaQuoteProvider) Supports(code string) bool {
+	return true
+}
+
+func (p *SinaQuoteProvider) Fetch(client *resty.Client, codes ...string) ([]StockInfo, error) {
+	list := slice.JoinFunc(codes, ",", func(s string) string {
+		if strutil.HasPrefixAny(s, []string{"us", "US"}) {
+			s = "gb_" + s[2:]
+		}
+		return strings.ToLower(s)
+	})
+	resp, err := client.R().
+		SetHeader("Host", "hq.sinajs.cn").
+		SetHeader("Referer", "https://finance.sina.com.cn/").
+		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
+		Get(fmt.Sprintf(p.url, time.Now().Unix(), list))
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("sina status %d", resp.StatusCode())
+	}
+	stockInfos := make([]StockInfo, 0, len(codes))
+	for _, data := range strutil.SplitEx(GB18030ToUTF8(resp.Body()), "\n", true) {
+		stockData, err := ParseFullSingleStockData(data)
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+			continue
+		}
+		stockInfos = append(stockInfos, *stockData)
+	}
+	return stockInfos, nil
+}
+
+// TencentQuoteProvider 腾讯行情
+type TencentQuoteProvider struct {
+	url string
+}
+
+func (p *TencentQuoteProvider) Name() string {
+	return QuoteSourceTencent
+}
+
+func (p *TencentQuoteProvider) Supports(code string) bool {
+	return strutil.HasPrefixAny(code, []string{"hk", "HK", "sh", "sz", "SH", "SZ"})
+}
+
+func (p *TencentQuoteProvider) Fetch(client *resty.Client, codes ...string) ([]StockInfo, error) {
+	list := slice.JoinFunc(codes, ",", func(s string) string {
+		if strutil.HasPrefixAny(s, []string{"hk", "HK"}) {
+			return "r_" + strings.ToLower(s)
+		}
+		return strings.ToLower(s)
+	})
+	resp, err := client.R().
+		SetHeader("Host", "qt.gtimg.cn").
+		SetHeader("Referer", "https://gu.qq.com/").
+		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
+		Get(fmt.Sprintf(p.url, time.Now().Unix(), list))
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("tencent status %d", resp.StatusCode())
+	}
+	stockInfos := make([]StockInfo, 0, len(codes))
+	for _, data := range strutil.SplitAndTrim(strings.Trim(GB18030ToUTF8(resp.Body()), "\n"), ";") {
+		stockData, err := ParseTxStockData(data)
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+			continue
+		}
+		stockInfos = append(stockInfos, *stockData)
+	}
+	return stockInfos, nil
+}
+
+// EastmoneyQuoteProvider 东方财富行情
+type EastmoneyQuoteProvider struct {
+	url string
+}
+
+func (p *EastmoneyQuoteProvider) Name() string {
+	return QuoteSourceEastmoney
+}
+
+func (p *EastmoneyQuoteProvider) Supports(code string) bool {
+	return eastmoneySecId(code) != ""
+}
+
+// eastmoneySecId 股票代码转换为东方财富 secid: 1.600000 / 0.000001 / 116.00700
+func eastmoneySecId(code string) string {
+	code = strings.ToLower(code)
+	switch {
+	case strings.HasPrefix(code, "sh"):
+		return "1." + code[2:]
+	case strutil.HasPrefixAny(code, []string{"sz", "bj"}):
+		return "0." + code[2:]
+	case strings.HasPrefix(code, "hk"):
+		return "116." + code[2:]
+	}
+	return ""
+}
+
+func (p *EastmoneyQuoteProvider) Fetch(client *resty.Client, codes ...string) ([]StockInfo, error) {
+	secIds := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if secId := eastmoneySecId(code); secId != "" {
+			secIds = append(secIds, secId)
+		}
+	}
+	if len(secIds) == 0 {
+		return nil, fmt.Errorf("eastmoney 不支持的股票代码:%v", codes)
+	}
+	fields := "f2,f5,f6,f12,f13,f14,f15,f16,f17,f18,f124"
+	resp, err := client.R().
+		SetHeader("Host", "push2.eastmoney.com").
+		SetHeader("Referer", "https://quote.eastmoney.com/").
+		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
+		Get(fmt.Sprintf(p.url, fields, strings.Join(secIds, ","), time.Now().UnixMilli()))
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("eastmoney status %d", resp.StatusCode())
+	}
+	return ParseEastmoneyStockData(resp.Body(), codes)
+}
+
+// ParseEastmoneyStockData 解析东方财富 ulist.np 接口返回的行情
+func ParseEastmoneyStockData(body []byte, codes []string) ([]StockInfo, error) {
+	res := struct {
+		Rc   int `json:"rc"`
+		Data *struct {
+			Diff []map[string]any `json:"diff"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	if res.Data == nil {
+		return nil, fmt.Errorf("eastmoney rc=%d 无数据", res.Rc)
+	}
+	stockInfos := make([]StockInfo, 0, len(res.Data.Diff))
+	for _, item := range res.Data.Diff {
+		// 按 市场.代码 匹配,避免 sh000001(上证指数) 与 sz000001(平安银行) 等同号代码混淆
+		secId := convertor.ToString(item["f13"]) + "." + convertor.ToString(item["f12"])
+		code, ok := slice.FindBy(codes, func(_ int, c string) bool {
+			return eastmoneySecId(c) == secId
+		})
+		if !ok {
+			continue
+		}
+		code = strings.ToLower(code)
+		// 停牌或无成交时东财返回 "-"
+		num := func(key string) string {
+			v := convertor.ToString(item[key])
+			if v == "-" {
+				return "0"
+			}
+			return v
+		}
+		volume := num("f5")
+		if market := GetStockMarket(code); market == MarketCN {
+			// A股成交量单位为手,统一转换为股
+			if v, err := convertor.ToFloat(volume); err == nil {
+				volume = convertor.ToString(int64(v * 100))
+			}
+		}
+		stockInfo := StockInfo{
+			Code:     code,
+			Name:     convertor.ToString(item["f14"]),
+			Price:    num("f2"),
+			Volume:   volume,
+			Amount:   num("f6"),
+			High:     num("f15"),
+			Low:      num("f16"),
+			Open:     num("f17"),
+			PreClose: num("f18"),
+		}
+		if ts, err := convertor.ToInt(item["f124"]); err == nil && ts > 0 {
+			t := time.Unix(ts, 0).In(time.FixedZone("CST", 8*3600))
+			stockInfo.Date = t.Format(time.DateOnly)
+			stockInfo.Time = t.Format(time.TimeOnly)
+		}
+		stockInfos = append(stockInfos, stockInfo)
+	}
+	return stockInfos, nil
+}
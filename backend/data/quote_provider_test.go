@@ -0,0 +1,128 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeQuoteProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (f *fakeQuoteProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeQuoteProvider) Supports(code string) bool {
+	return true
+}
+
+func (f *fakeQuoteProvider) Fetch(client *resty.Client, codes ...string) ([]StockInfo, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	infos := make([]StockInfo, 0, len(codes))
+	for _, code := range codes {
+		infos = append(infos, StockInfo{Code: code, Name: f.name, Price: "1.00"})
+	}
+	return infos, nil
+}
+
+func TestQuoteRouterFailover(t *testing.T) {
+	tencent := &fakeQuoteProvider{name: QuoteSourceTencent, err: errors.New("429 too many requests")}
+	sina := &fakeQuoteProvider{name: QuoteSourceSina}
+	router := &QuoteRouter{
+		client: resty.New(),
+		config: &Settings{QuoteSourcesCN: "tencent,sina"},
+		providers: map[string]QuoteProvider{
+			QuoteSourceTencent: tencent,
+			QuoteSourceSina:    sina,
+		},
+		health: &quoteHealthTracker{status: make(map[string]*QuoteSourceStatus)},
+	}
+
+	for i := 0; i < quoteFailureThreshold; i++ {
+		infos, err := router.Fetch("sh600000", "sz000001")
+		assert.NoError(t, err)
+		assert.Len(t, infos, 2)
+		assert.Equal(t, QuoteSourceSina, infos[0].Name)
+	}
+	assert.Equal(t, quoteFailureThreshold, tencent.calls)
+	assert.False(t, router.health.available(QuoteSourceTencent, time.Now()))
+
+	// 冷却期内优先使用健康的数据源
+	_, err := router.Fetch("sh600000")
+	assert.NoError(t, err)
+	assert.Equal(t, quoteFailureThreshold, tencent.calls)
+}
+
+func TestQuoteRouterAllFailed(t *testing.T) {
+	router := &QuoteRouter{
+		client: resty.New(),
+		config: &Settings{},
+		providers: map[string]QuoteProvider{
+			QuoteSourceSina: &fakeQuoteProvider{name: QuoteSourceSina, err: errors.New("timeout")},
+		},
+		health: &quoteHealthTracker{status: make(map[string]*QuoteSourceStatus)},
+	}
+	infos, err := router.Fetch("gb_aapl")
+	assert.Error(t, err)
+	assert.Empty(t, infos)
+}
+
+func TestQuoteSources(t *testing.T) {
+	assert.Equal(t, []string{"eastmoney", "tencent", "sina"}, quoteSources(&Settings{QuoteSourcesCN: "eastmoney, unknown"}, MarketCN))
+	assert.Equal(t, []string{"sina"}, quoteSources(&Settings{}, MarketUS))
+	assert.True(t, sameStockCode("usAAPL", "gb_aapl"))
+}
+
+func TestParseEastmoneyStockData(t *testing.T) {
+	body := `{"rc":0,"data":{"total":2,"diff":[
+		{"f2":10.5,"f5":12345,"f6":129623.0,"f12":"600000","f13":1,"f14":"浦发银行","f15":10.6,"f16":10.2,"f17":10.3,"f18":10.4,"f124":1747022400},
+		{"f2":"-","f5":"-","f6":"-","f12":"00700","f13":116,"f14":"腾讯控股","f15":"-","f16":"-","f17":"-","f18":480.2,"f124":1747022400}]}}`
+	infos, err := ParseEastmoneyStockData([]byte(body), []string{"sh600000", "hk00700"})
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "sh600000", infos[0].Code)
+	assert.Equal(t, "1234500", infos[0].Volume)
+	assert.Equal(t, "2025-05-12", infos[0].Date)
+	assert.Equal(t, "hk00700", infos[1].Code)
+	assert.Equal(t, "0", infos[1].Price)
+
+	//同号的沪深代码按市场区分
+	body = `{"rc":0,"data":{"total":2,"diff":[
+		{"f2":11.2,"f12":"000001","f13":0,"f14":"平安银行"},
+		{"f2":3350.5,"f12":"000001","f13":1,"f14":"上证指数"}]}}`
+	infos, err = ParseEastmoneyStockData([]byte(body), []string{"sh000001", "sz000001"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sz000001", "sh000001"}, []string{infos[0].Code, infos[1].Code})
+	assert.Equal(t, "上证指数", infos[1].Name)
+}
+
+func TestParseTxStockDataVolume(t *testing.T) {
+	hk := "v_r_hk09660=\"100~地平线机器人-W~09660~6.340~5.690~5.800~210980204.0~0~0~6.340~0~0~0~0~0~0~0~0~0~6.340~0~0~0~0~0~0~0~0~0~210980204.0~2025/04/29\n14:14:52~0.650~11.42~6.450~5.710~6.340~210980204.0~1295585259.040~0~33.03~~0~0~13.01~702.2123~836.8986~HORIZONROBOT-W~0.00~10.380~3.320~1.00~-53.74~0~0~0~0~0~33.03~6.50~1.90~600~76.11~19.85~GP~19.70~11.51~0.63~-17.23~46.76~13200293682.00~11075904412.00~33.03~0.000~6.141~58.90~HKD~1~30\";"
+	info, err := ParseTxStockData(hk)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(210980204), info.Quote.Volume)
+	assert.Equal(t, "1295585259.04", info.Quote.Amount.String())
+
+	sz := "v_sz002241=\"51~歌尔股份~002241~21.92~22.27~22.14~109872~40211~69642~21.91~25~21.90~961~21.89~257~21.88~748~21.87~665~21.92~86~21.93~168~21.94~556~21.95~171~21.96~85~~20250509094209~-0.35~-1.57~22.16~21.84~21.92/109872/241183171~109872~24118~0.36~27.78~~22.16~21.84~1.44~675.97~765.22~2.27~24.50~20.04~2.57~1590~21.95~40.80~28.71~~~1.24~24118.3171~0.0000~0~\n~GP-A~-15.07~5.13~1.11~8.18~3.39~30.63~15.70~5.23~15.67~-25.11~3083811231~3490989083~42.72~10.31~3083811231~~~37.23~0.18~~CNY~0~~21.85~1952\";"
+	info, err = ParseTxStockData(sz)
+	assert.NoError(t, err)
+	//成交量(手)换算为股
+	assert.Equal(t, int64(10987200), info.Quote.Volume)
+	assert.Equal(t, "241183171", info.Quote.Amount.String())
+
+	//截断的港股行情没有成交量字段
+	info, err = ParseTxStockData(strings.Join(strings.Split(hk, "~")[:36], "~") + "\";")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Quote.Volume)
+}
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/13 9:20
+// @Desc 盘中行情快照(追加写入的时间序列)
+// -----------------------------------------------------------------------------------
+
+// QuoteSnapshot 盘中行情快照,每个行情时间点一条记录,只追加不更新
+type QuoteSnapshot struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Code         string    `json:"code" gorm:"index:idx_quote_snapshot_code_time,priority:1"`
+	SnapshotTime time.Time `json:"snapshotTime" gorm:"index:idx_quote_snapshot_code_time,priority:2"`
+	Price        float64   `json:"price"`
+	Volume       float64   `json:"volume"`
+	Amount       float64   `json:"amount"`
+	B1P          float64   `json:"b1p"`
+	B1V          float64   `json:"b1v"`
+	B2P          float64   `json:"b2p"`
+	B2V          float64   `json:"b2v"`
+	B3P          float64   `json:"b3p"`
+	B3V          float64   `json:"b3v"`
+	B4P          float64   `json:"b4p"`
+	B4V          float64   `json:"b4v"`
+	B5P          float64   `json:"b5p"`
+	B5V          float64   `json:"b5v"`
+	A1P          float64   `json:"a1p"`
+	A1V          float64   `json:"a1v"`
+	A2P          float64   `json:"a2p"`
+	A2V          float64   `json:"a2v"`
+	A3P          float64   `json:"a3p"`
+	A3V          float64   `json:"a3v"`
+	A4P          float64   `json:"a4p"`
+	A4V          float64   `json:"a4v"`
+	A5P          float64   `json:"a5p"`
+	A5V          float64   `json:"a5v"`
+}
+
+func (QuoteSnapshot) TableName() string {
+	return "quote_snapshot"
+}
+
+// 默认保留天数
+const defaultSnapshotRetentionDays = 30
+
+type QuoteSnapshotApi struct {
+	dao *gorm.DB
+}
+
+func NewQuoteSnapshotApi() *QuoteSnapshotApi {
+	return &QuoteSnapshotApi{dao: db.Dao}
+}
+
+// 每只股票最近一次写入的行情时间,行情未更新(停牌/休市)时不重复写入
+var lastSnapshotTime = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+// NewQuoteSnapshot 由实时行情生成快照,行情无法解析或没有行情时间时返回 nil
+func NewQuoteSnapshot(info StockInfo) *QuoteSnapshot {
+	quote, err := info.GetQuote()
+	if err != nil || quote.Time.IsZero() {
+		return nil
+	}
+	return &QuoteSnapshot{
+		Code:         quote.Code,
+		SnapshotTime: quote.Time.In(quoteLocation()),
+		Price:        quote.Price.Float64(),
+		Volume:       float64(quote.Volume),
+		Amount:       quote.Amount.Float64(),
+		B1P:          quote.Bids[0].Price.Float64(),
+		B1V:          float64(quote.Bids[0].Volume),
+		B2P:          quote.Bids[1].Price.Float64(),
+		B2V:          float64(quote.Bids[1].Volume),
+		B3P:          quote.Bids[2].Price.Float64(),
+		B3V:          float64(quote.Bids[2].Volume),
+		B4P:          quote.Bids[3].Price.Float64(),
+		B4V:          float64(quote.Bids[3].Volume),
+		B5P:          quote.Bids[4].Price.Float64(),
+		B5V:          float64(quote.Bids[4].Volume),
+		A1P:          quote.Asks[0].Price.Float64(),
+		A1V:          float64(quote.Asks[0].Volume),
+		A2P:          quote.Asks[1].Price.Float64(),
+		A2V:          float64(quote.Asks[1].Volume),
+		A3P:          quote.Asks[2].Price.Float64(),
+		A3V:          float64(quote.Asks[2].Volume),
+		A4P:          quote.Asks[3].Price.Float64(),
+		A4V:          float64(quote.Asks[3].Volume),
+		A5P:          quote.Asks[4].Price.Float64(),
+		A5V:          float64(quote.Asks[4].Volume),
+	}
+}
+
+// quoteLocation 新浪/腾讯行情时间均为北京时间
+func quoteLocation() *time.Location {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*3600)
+	}
+	return location
+}
+
+// SaveSnapshots 追加写入行情快照,同一股票同一行情时间只写一次
+func (q QuoteSnapshotApi) SaveSnapshots(infos []StockInfo) int {
+	snapshots := make([]*QuoteSnapshot, 0, len(infos))
+	lastSnapshotTime.Lock()
+	defer lastSnapshotTime.Unlock()
+	//写入成功后才更新最近行情时间,写入失败时下次仍会重试
+	pending := make(map[string]time.Time)
+	for _, info := range infos {
+		snapshot := NewQuoteSnapshot(info)
+		if snapshot == nil || snapshot.Price <= 0 {
+			continue
+		}
+		last, ok := pending[snapshot.Code]
+		if !ok {
+			last, ok = lastSnapshotTime.m[snapshot.Code]
+		}
+		if ok && !snapshot.SnapshotTime.After(last) {
+			continue
+		}
+		pending[snapshot.Code] = snapshot.SnapshotTime
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return 0
+	}
+	err := q.dao.Model(&QuoteSnapshot{}).CreateInBatches(snapshots, 100).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("保存行情快照失败:%s", err.Error())
+		return 0
+	}
+	for code, snapshotTime := range pending {
+		lastSnapshotTime.m[code] = snapshotTime
+	}
+	return len(snapshots)
+}
+
+// GetIntradaySnapshots 查询某只股票在时间区间内的行情快照,按时间升序
+func (q QuoteSnapshotApi) GetIntradaySnapshots(code string, from, to time.Time) []QuoteSnapshot {
+	var result []QuoteSnapshot
+	q.dao.Model(&QuoteSnapshot{}).
+		Where("code = ? and snapshot_time >= ? and snapshot_time <= ?", strings.ToLower(code), from, to).
+		Order("snapshot_time asc").
+		Find(&result)
+	return result
+}
+
+// PurgeSnapshots 删除超过保留天数的快照
+func (q QuoteSnapshotApi) PurgeSnapshots(retentionDays int) int64 {
+	if retentionDays <= 0 {
+		retentionDays = defaultSnapshotRetentionDays
+	}
+	//快照时间按北京时间保存,截止时间使用相同时区才能正确比较
+	before := time.Now().In(quoteLocation()).AddDate(0, 0, -retentionDays)
+	res := q.dao.Where("snapshot_time < ?", before).Delete(&QuoteSnapshot{})
+	if res.Error != nil {
+		logger.SugaredLogger.Errorf("清理行情快照失败:%s", res.Error.Error())
+		return 0
+	}
+	logger.SugaredLogger.Infof("清理%d天前的行情快照%d条", retentionDays, res.RowsAffected)
+	return res.RowsAffected
+}
+
+// ParseSnapshotRange 解析查询时间区间(yyyy-MM-dd HH:mm:ss 或 yyyy-MM-dd),from 默认为当天零点,to 默认为当前时间
+func ParseSnapshotRange(from, to string) (time.Time, time.Time) {
+	now := time.Now().In(quoteLocation())
+	parse := func(s string, def time.Time) time.Time {
+		s = strings.TrimSpace(s)
+		if t, err := time.ParseInLocation(time.DateTime, s, quoteLocation()); err == nil {
+			return t
+		}
+		if t, err := time.ParseInLocation(time.DateOnly, s, quoteLocation()); err == nil {
+			return t
+		}
+		return def
+	}
+	start := parse(from, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	end := parse(to, now)
+	if len(strings.TrimSpace(to)) == len(time.DateOnly) {
+		end = end.Add(time.Hour*24 - time.Second)
+	}
+	return start, end
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteSnapshotApi(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&QuoteSnapshot{})
+	api := NewQuoteSnapshotApi()
+
+	info := StockInfo{Code: "sh600000", Date: "2025-05-12", Time: "10:00:03", Price: "10.50", PreClose: "10.40", Volume: "12345", B1P: "10.49", B1V: "300"}
+	assert.Equal(t, 1, api.SaveSnapshots([]StockInfo{info}))
+	// 行情时间未变化时不重复写入
+	assert.Equal(t, 0, api.SaveSnapshots([]StockInfo{info}))
+	info.Time = "10:00:06"
+	info.Price = "10.52"
+	assert.Equal(t, 1, api.SaveSnapshots([]StockInfo{info}))
+
+	from, to := ParseSnapshotRange("2025-05-12", "2025-05-12")
+	snapshots := api.GetIntradaySnapshots("SH600000", from, to)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, 10.5, snapshots[0].Price)
+	assert.Equal(t, 10.49, snapshots[0].B1P)
+	assert.Equal(t, 10.52, snapshots[1].Price)
+
+	assert.Equal(t, int64(2), api.PurgeSnapshots(1))
+	assert.Empty(t, api.GetIntradaySnapshots("sh600000", from, to))
+
+	//写入失败时不记录行情时间,下次仍会写入
+	info = StockInfo{Code: "sz000001", Date: "2025-05-12", Time: "10:00:03", Price: "12.00", PreClose: "11.90"}
+	db.Dao.Migrator().DropTable(&QuoteSnapshot{})
+	assert.Equal(t, 0, api.SaveSnapshots([]StockInfo{info}))
+	db.Dao.AutoMigrate(&QuoteSnapshot{})
+	assert.Equal(t, 1, api.SaveSnapshots([]StockInfo{info}))
+
+	//保留天数按北京时间计算
+	now := time.Now().In(quoteLocation())
+	for _, at := range []time.Time{now.Add(-25 * time.Hour), now.Add(-23 * time.Hour)} {
+		info := StockInfo{Code: "sz000002", Date: at.Format(time.DateOnly), Time: at.Format(time.TimeOnly), Price: "8.00", PreClose: "8.00"}
+		assert.Equal(t, 1, api.SaveSnapshots([]StockInfo{info}))
+	}
+	db.Dao.Where("code = ?", "sz000001").Delete(&QuoteSnapshot{})
+	assert.Equal(t, int64(1), api.PurgeSnapshots(1))
+	assert.Len(t, api.GetIntradaySnapshots("sz000002", now.Add(-48*time.Hour), now), 1)
+}
+
+func TestParseSnapshotRange(t *testing.T) {
+	from, to := ParseSnapshotRange("2025-05-12 09:30:00", "2025-05-12")
+	assert.Equal(t, "2025-05-12 09:30:00", from.Format(time.DateTime))
+	assert.Equal(t, "2025-05-12 23:59:59", to.Format(time.DateTime))
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	d, err := ParseDecimal("170.2100")
+	assert.NoError(t, err)
+	assert.Equal(t, Decimal(1702100), d)
+	assert.Equal(t, "170.21", d.String())
+
+	_, err = ParseDecimal("abc")
+	assert.Error(t, err)
+
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":"10.5","b":0.1}`), &v))
+	assert.Equal(t, NewDecimal(10.5), v.A)
+	b, _ := json.Marshal(v)
+	assert.Equal(t, `{"a":10.5,"b":0.1}`, string(b))
+}
+
+func TestParseFullSingleStockDataQuote(t *testing.T) {
+	info, err := ParseFullSingleStockData("var hq_str_gb_tsla = \"特斯拉,268.8472,-5.55,2025-03-04 22:52:56,-15.8028,270.9300,278.2800,268.1000,488.5400,138.8030,23618295,88214389,864751599149,2.23,120.550000,0.00,0.00,0.00,0.00,3216517037,61,0.0000,0.00,0.00,,Mar 04 09:52AM EST,284.6500,0,1,2025,6458502467.0000,0.0000,0.0000,0.0000,0.0000,284.6500\";")
+	assert.NoError(t, err)
+	// 字符串字段保持原样,兼容前端
+	assert.Equal(t, "268.8472", info.Price)
+	q := info.Quote
+	assert.Equal(t, MarketUS, q.Market)
+	assert.Equal(t, NewDecimal(268.8472), q.Price)
+	assert.Equal(t, NewDecimal(284.65), q.PreClose)
+	assert.Equal(t, int64(23618295), q.Volume)
+	// 北京时间转换为纽约时间
+	assert.Equal(t, "2025-03-04 09:52:56 EST", q.Time.Format("2006-01-02 15:04:05 MST"))
+
+	info, err = ParseFullSingleStockData("var hq_str_hk01810=\"XIAOMI-W,小米集团－Ｗ,50.050,49.150,51.950,49.700,51.700,2.550,5.188,51.65000,51.70000,15770408249,308362585,0.000,0.000,51.950,12.560,2025/02/21,16:08\";")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(308362585), info.Quote.Volume)
+	assert.Equal(t, "2025-02-21 16:08:00 HKT", info.Quote.Time.Format("2006-01-02 15:04:05 MST"))
+}
+
+func TestParseQuoteError(t *testing.T) {
+	_, err := ParseQuote(&StockInfo{Code: "sh600000", Price: "10.5", PreClose: "10.4", B1V: "1x00"})
+	var parseErr *QuoteParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, "买1申报", parseErr.Field)
+
+	_, err = ParseQuote(&StockInfo{Code: "sh600000", Price: "10.5"})
+	assert.Error(t, err)
+
+	q, err := ParseQuote(&StockInfo{Code: "sh600000", Price: "0.00", PreClose: "10.40", A1P: "10.45", Open: "10.42"})
+	assert.NoError(t, err)
+	assert.Equal(t, NewDecimal(10.45), q.LastPrice())
+	assert.Equal(t, NewDecimal(10.42), q.DayHigh())
+	assert.True(t, q.Time.IsZero())
+}
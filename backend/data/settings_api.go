@@ -14,6 +14,9 @@ type Settings struct {
 	LocalPushEnable        bool   `json:"localPushEnable"`
 	DingPushEnable         bool   `json:"dingPushEnable"`
 	DingRobot              string `json:"dingRobot"`
+	DingSecret             string `json:"dingSecret"`    //钉钉机器人加签密钥
+	DingAtMobiles          string `json:"dingAtMobiles"` //@的手机号,逗号分隔,和 DingAtUserIds 都为空时@所有人
+	DingAtUserIds          string `json:"dingAtUserIds"` //@的用户ID,逗号分隔
 	UpdateBasicInfoOnStart bool   `json:"updateBasicInfoOnStart"`
 	RefreshInterval        int64  `json:"refreshInterval"`
 
@@ -35,6 +38,34 @@ type Settings struct {
 	DarkTheme         bool    `json:"darkTheme"`
 	BrowserPoolSize   int     `json:"browserPoolSize"`
 	EnableFund        bool    `json:"enableFund"`
+
+	QuoteSourcesCN string `json:"quoteSourcesCN"` //A股行情数据源优先级,逗号分隔 如:tencent,sina,eastmoney
+	QuoteSourcesHK string `json:"quoteSourcesHK"` //港股行情数据源优先级
+	QuoteSourcesUS string `json:"quoteSourcesUS"` //美股行情数据源优先级
+
+	QuoteSnapshotEnable        bool `json:"quoteSnapshotEnable"`        //是否保存盘中行情快照
+	QuoteSnapshotRetentionDays int  `json:"quoteSnapshotRetentionDays"` //行情快照保留天数
+	KLineIndicatorSummary      bool `json:"kLineIndicatorSummary"`      //AI分析时发送技术指标摘要代替完整K线数据
+
+	CostMethod string `json:"costMethod"` //持仓成本计算方法 average:移动平均 fifo:先进先出
+
+	AlertChangeCooldown int     `json:"alertChangeCooldown"` //涨跌报警冷却时间(分钟),默认5
+	AlertPriceCooldown  int     `json:"alertPriceCooldown"`  //股价报警冷却时间(分钟),默认30
+	AlertCostCooldown   int     `json:"alertCostCooldown"`   //成本价报警冷却时间(分钟),默认30
+	AlertQuietHours     string  `json:"alertQuietHours"`     //免打扰时段,如 22:00-08:00
+	AlertWeekendMute    bool    `json:"alertWeekendMute"`    //周末不推送报警
+	AlertHysteresis     float64 `json:"alertHysteresis"`     //同方向再次报警需要继续变动的百分比,0表示不限制
+
+	LimitAlertEnable bool  `json:"limitAlertEnable"` //A股涨停/跌停/炸板报警
+	LimitAlertTicks  int64 `json:"limitAlertTicks"`  //距涨跌停几档以内提醒接近涨跌停,0表示不提醒
+
+	AiToolsEnable   bool `json:"aiToolsEnable"`   //AI分析时由模型按需调用工具获取行情数据
+	AiToolCallLimit int  `json:"aiToolCallLimit"` //单次对话最多调用工具次数,默认8
+
+	AiDailyBudget   float64 `json:"aiDailyBudget"`   //AI每日费用预算(元),超出后暂停定时分析,0表示不限制
+	AiMonthlyBudget float64 `json:"aiMonthlyBudget"` //AI每月费用预算(元)
+
+	AiStructuredOutput bool `json:"aiStructuredOutput"` //AI分析时要求输出评级、目标价等结构化结论
 }
 
 func (receiver Settings) TableName() string {
@@ -56,56 +87,102 @@ func (s SettingsApi) UpdateConfig() string {
 	db.Dao.Model(s.Config).Count(&count)
 	if count > 0 {
 		db.Dao.Model(s.Config).Where("id=?", s.Config.ID).Updates(map[string]any{
-			"local_push_enable":          s.Config.LocalPushEnable,
-			"ding_push_enable":           s.Config.DingPushEnable,
-			"ding_robot":                 s.Config.DingRobot,
-			"update_basic_info_on_start": s.Config.UpdateBasicInfoOnStart,
-			"refresh_interval":           s.Config.RefreshInterval,
-			"open_ai_enable":             s.Config.OpenAiEnable,
-			"open_ai_base_url":           s.Config.OpenAiBaseUrl,
-			"open_ai_api_key":            s.Config.OpenAiApiKey,
-			"open_ai_model_name":         s.Config.OpenAiModelName,
-			"open_ai_max_tokens":         s.Config.OpenAiMaxTokens,
-			"open_ai_temperature":        s.Config.OpenAiTemperature,
-			"tushare_token":              s.Config.TushareToken,
-			"prompt":                     s.Config.Prompt,
-			"check_update":               s.Config.CheckUpdate,
-			"open_ai_api_time_out":       s.Config.OpenAiApiTimeOut,
-			"question_template":          s.Config.QuestionTemplate,
-			"crawl_time_out":             s.Config.CrawlTimeOut,
-			"k_days":                     s.Config.KDays,
-			"enable_danmu":               s.Config.EnableDanmu,
-			"browser_path":               s.Config.BrowserPath,
-			"enable_news":                s.Config.EnableNews,
-			"dark_theme":                 s.Config.DarkTheme,
-			"enable_fund":                s.Config.EnableFund,
+			"local_push_enable":             s.Config.LocalPushEnable,
+			"ding_push_enable":              s.Config.DingPushEnable,
+			"ding_robot":                    s.Config.DingRobot,
+			"ding_secret":                   s.Config.DingSecret,
+			"ding_at_mobiles":               s.Config.DingAtMobiles,
+			"ding_at_user_ids":              s.Config.DingAtUserIds,
+			"update_basic_info_on_start":    s.Config.UpdateBasicInfoOnStart,
+			"refresh_interval":              s.Config.RefreshInterval,
+			"open_ai_enable":                s.Config.OpenAiEnable,
+			"open_ai_base_url":              s.Config.OpenAiBaseUrl,
+			"open_ai_api_key":               s.Config.OpenAiApiKey,
+			"open_ai_model_name":            s.Config.OpenAiModelName,
+			"open_ai_max_tokens":            s.Config.OpenAiMaxTokens,
+			"open_ai_temperature":           s.Config.OpenAiTemperature,
+			"tushare_token":                 s.Config.TushareToken,
+			"prompt":                        s.Config.Prompt,
+			"check_update":                  s.Config.CheckUpdate,
+			"open_ai_api_time_out":          s.Config.OpenAiApiTimeOut,
+			"question_template":             s.Config.QuestionTemplate,
+			"crawl_time_out":                s.Config.CrawlTimeOut,
+			"k_days":                        s.Config.KDays,
+			"enable_danmu":                  s.Config.EnableDanmu,
+			"browser_path":                  s.Config.BrowserPath,
+			"enable_news":                   s.Config.EnableNews,
+			"dark_theme":                    s.Config.DarkTheme,
+			"enable_fund":                   s.Config.EnableFund,
+			"quote_sources_cn":              s.Config.QuoteSourcesCN,
+			"quote_sources_hk":              s.Config.QuoteSourcesHK,
+			"quote_sources_us":              s.Config.QuoteSourcesUS,
+			"quote_snapshot_enable":         s.Config.QuoteSnapshotEnable,
+			"quote_snapshot_retention_days": s.Config.QuoteSnapshotRetentionDays,
+			"k_line_indicator_summary":      s.Config.KLineIndicatorSummary,
+			"cost_method":                   s.Config.CostMethod,
+			"alert_change_cooldown":         s.Config.AlertChangeCooldown,
+			"alert_price_cooldown":          s.Config.AlertPriceCooldown,
+			"alert_cost_cooldown":           s.Config.AlertCostCooldown,
+			"alert_quiet_hours":             s.Config.AlertQuietHours,
+			"alert_weekend_mute":            s.Config.AlertWeekendMute,
+			"alert_hysteresis":              s.Config.AlertHysteresis,
+			"limit_alert_enable":            s.Config.LimitAlertEnable,
+			"limit_alert_ticks":             s.Config.LimitAlertTicks,
+			"ai_tools_enable":               s.Config.AiToolsEnable,
+			"ai_tool_call_limit":            s.Config.AiToolCallLimit,
+			"ai_daily_budget":               s.Config.AiDailyBudget,
+			"ai_monthly_budget":             s.Config.AiMonthlyBudget,
+			"ai_structured_output":          s.Config.AiStructuredOutput,
 		})
 	} else {
 		logger.SugaredLogger.Infof("未找到配置，创建默认配置:%+v", s.Config)
 		db.Dao.Model(s.Config).Create(&Settings{
-			LocalPushEnable:        s.Config.LocalPushEnable,
-			DingPushEnable:         s.Config.DingPushEnable,
-			DingRobot:              s.Config.DingRobot,
-			UpdateBasicInfoOnStart: s.Config.UpdateBasicInfoOnStart,
-			RefreshInterval:        s.Config.RefreshInterval,
-			OpenAiEnable:           s.Config.OpenAiEnable,
-			OpenAiBaseUrl:          s.Config.OpenAiBaseUrl,
-			OpenAiApiKey:           s.Config.OpenAiApiKey,
-			OpenAiModelName:        s.Config.OpenAiModelName,
-			OpenAiMaxTokens:        s.Config.OpenAiMaxTokens,
-			OpenAiTemperature:      s.Config.OpenAiTemperature,
-			TushareToken:           s.Config.TushareToken,
-			Prompt:                 s.Config.Prompt,
-			CheckUpdate:            s.Config.CheckUpdate,
-			OpenAiApiTimeOut:       s.Config.OpenAiApiTimeOut,
-			QuestionTemplate:       s.Config.QuestionTemplate,
-			CrawlTimeOut:           s.Config.CrawlTimeOut,
-			KDays:                  s.Config.KDays,
-			EnableDanmu:            s.Config.EnableDanmu,
-			BrowserPath:            s.Config.BrowserPath,
-			EnableNews:             s.Config.EnableNews,
-			DarkTheme:              s.Config.DarkTheme,
-			EnableFund:             s.Config.EnableFund,
+			LocalPushEnable:            s.Config.LocalPushEnable,
+			DingPushEnable:             s.Config.DingPushEnable,
+			DingRobot:                  s.Config.DingRobot,
+			DingSecret:                 s.Config.DingSecret,
+			DingAtMobiles:              s.Config.DingAtMobiles,
+			DingAtUserIds:              s.Config.DingAtUserIds,
+			UpdateBasicInfoOnStart:     s.Config.UpdateBasicInfoOnStart,
+			RefreshInterval:            s.Config.RefreshInterval,
+			OpenAiEnable:               s.Config.OpenAiEnable,
+			OpenAiBaseUrl:              s.Config.OpenAiBaseUrl,
+			OpenAiApiKey:               s.Config.OpenAiApiKey,
+			OpenAiModelName:            s.Config.OpenAiModelName,
+			OpenAiMaxTokens:            s.Config.OpenAiMaxTokens,
+			OpenAiTemperature:          s.Config.OpenAiTemperature,
+			TushareToken:               s.Config.TushareToken,
+			Prompt:                     s.Config.Prompt,
+			CheckUpdate:                s.Config.CheckUpdate,
+			OpenAiApiTimeOut:           s.Config.OpenAiApiTimeOut,
+			QuestionTemplate:           s.Config.QuestionTemplate,
+			CrawlTimeOut:               s.Config.CrawlTimeOut,
+			KDays:                      s.Config.KDays,
+			EnableDanmu:                s.Config.EnableDanmu,
+			BrowserPath:                s.Config.BrowserPath,
+			EnableNews:                 s.Config.EnableNews,
+			DarkTheme:                  s.Config.DarkTheme,
+			EnableFund:                 s.Config.EnableFund,
+			QuoteSourcesCN:             s.Config.QuoteSourcesCN,
+			QuoteSourcesHK:             s.Config.QuoteSourcesHK,
+			QuoteSourcesUS:             s.Config.QuoteSourcesUS,
+			QuoteSnapshotEnable:        s.Config.QuoteSnapshotEnable,
+			QuoteSnapshotRetentionDays: s.Config.QuoteSnapshotRetentionDays,
+			KLineIndicatorSummary:      s.Config.KLineIndicatorSummary,
+			CostMethod:                 s.Config.CostMethod,
+			AlertChangeCooldown:        s.Config.AlertChangeCooldown,
+			AlertPriceCooldown:         s.Config.AlertPriceCooldown,
+			AlertCostCooldown:          s.Config.AlertCostCooldown,
+			AlertQuietHours:            s.Config.AlertQuietHours,
+			AlertWeekendMute:           s.Config.AlertWeekendMute,
+			AlertHysteresis:            s.Config.AlertHysteresis,
+			LimitAlertEnable:           s.Config.LimitAlertEnable,
+			LimitAlertTicks:            s.Config.LimitAlertTicks,
+			AiToolsEnable:              s.Config.AiToolsEnable,
+			AiToolCallLimit:            s.Config.AiToolCallLimit,
+			AiDailyBudget:              s.Config.AiDailyBudget,
+			AiMonthlyBudget:            s.Config.AiMonthlyBudget,
+			AiStructuredOutput:         s.Config.AiStructuredOutput,
 		})
 	}
 	return "保存成功！"
@@ -38,6 +38,7 @@ const tushareApiUrl = "http://api.tushare.pro"
 type StockDataApi struct {
 	client *resty.Client
 	config *Settings
+	ctx    context.Context
 }
 type StockInfo struct {
 	gorm.Model
@@ -89,12 +90,17 @@ type StockInfo struct {
 	Profit            float64 `json:"profit"`            //总盈亏率
 	ProfitAmount      float64 `json:"profitAmount"`      //总盈亏金额
 	ProfitAmountToday float64 `json:"profitAmountToday"` //今日盈亏金额
+	RealizedProfit    float64 `json:"realizedProfit"`    //已实现盈亏
+	LimitUpPrice      float64 `json:"limitUpPrice"`      //涨停价(A股)
+	LimitDownPrice    float64 `json:"limitDownPrice"`    //跌停价(A股)
+	LimitStatus       string  `json:"limitStatus"`       //涨停/跌停(封板),其他为空
 
 	Sort               int64   `json:"sort"` //排序
 	AlarmChangePercent float64 `json:"alarmChangePercent"`
 	AlarmPrice         float64 `json:"alarmPrice"`
 
 	Groups []GroupStock `gorm:"-:all"`
+	Quote  *Quote       `json:"quote,omitempty" gorm:"-:all"` //强类型行情
 }
 
 func (receiver StockInfo) TableName() string {
@@ -168,6 +174,7 @@ type FollowedStock struct {
 	Time               time.Time
 	Sort               int64
 	Cron               *string
+	AiModelId          uint                  //定时分析使用的模型配置,0表示默认模型
 	IsDel              soft_delete.DeletedAt `gorm:"softDelete:flag"`
 	Groups             []GroupStock          `gorm:"foreignKey:StockCode;references:StockCode"`
 }
@@ -198,6 +205,19 @@ func NewStockDataApi() *StockDataApi {
 	}
 }
 
+// WithContext K线请求随 ctx 取消
+func (receiver *StockDataApi) WithContext(ctx context.Context) *StockDataApi {
+	receiver.ctx = ctx
+	return receiver
+}
+
+func (receiver StockDataApi) requestContext() context.Context {
+	if receiver.ctx == nil {
+		return context.Background()
+	}
+	return receiver.ctx
+}
+
 // GetIndexBasic 获取指数信息
 func (receiver StockDataApi) GetIndexBasic() {
 	res := &TushareStockBasicResponse{}
@@ -292,105 +312,23 @@ func (receiver StockDataApi) GetStockBaseInfo() {
 }
 
 func (receiver StockDataApi) GetStockCodeRealTimeData(StockCodes ...string) (*[]StockInfo, error) {
-	stockInfos := make([]StockInfo, 0)
-
-	hkcodes := slice.Filter(StockCodes, func(i int, s string) bool {
-		return strutil.HasPrefixAny(s, []string{"hk", "HK", "sh", "sz"})
-	})
-
-	if hkcodes != nil && len(hkcodes) > 0 {
-		hkcodesStr := slice.JoinFunc(hkcodes, ",", func(s string) string {
-			if strutil.HasPrefixAny(s, []string{"hk", "HK"}) {
-				return "r_" + strings.ToLower(s)
-			} else {
-				return strings.ToLower(s)
-			}
-		})
-		url := fmt.Sprintf(txStockUrl, time.Now().Unix(), hkcodesStr)
-		resp, err := receiver.client.R().
-			SetHeader("Host", "qt.gtimg.cn").
-			SetHeader("Referer", "https://gu.qq.com/").
-			SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
-			Get(url)
-		logger.SugaredLogger.Infof("GetStockCodeRealTimeData %s", url)
-		if err != nil {
-			logger.SugaredLogger.Error(err.Error())
-			return &[]StockInfo{}, err
-		}
-		str := GB18030ToUTF8(resp.Body())
-		dataStr := strutil.SplitAndTrim(strings.Trim(str, "\n"), ";")
-
-		for _, data := range dataStr {
-			stockData, err := ParseTxStockData(data)
-			if err != nil {
-				logger.SugaredLogger.Error(err.Error())
-				continue
-			}
-			stockInfos = append(stockInfos, *stockData)
-			go func() {
-				var count int64
-				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Count(&count)
-				if count == 0 {
-					db.Dao.Model(&StockInfo{}).Create(stockData)
-				} else {
-					db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(stockData)
-				}
-			}()
-		}
-	}
-
-	szzsusCodes := slice.Filter(StockCodes, func(i int, s string) bool {
-		return !strutil.HasPrefixAny(s, []string{"hk", "HK", "sh", "sz"})
-	})
-
-	codes := slice.JoinFunc(szzsusCodes, ",", func(s string) string {
-		if strings.HasPrefix(s, "us") {
-			s = strings.Replace(s, "us", "gb_", 1)
-		}
-		if strings.HasPrefix(s, "US") {
-			s = strings.Replace(s, "US", "gb_", 1)
-		}
-		return strings.ToLower(s)
-	})
-
-	url := fmt.Sprintf(sinaStockUrl, time.Now().Unix(), codes)
-	//logger.SugaredLogger.Infof("GetStockCodeRealTimeData %s", url)
-	resp, err := receiver.client.R().
-		SetHeader("Host", "hq.sinajs.cn").
-		SetHeader("Referer", "https://finance.sina.com.cn/").
-		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
-		Get(url)
+	stockInfos, err := NewQuoteRouter(receiver.client, receiver.config).Fetch(StockCodes...)
 	if err != nil {
 		logger.SugaredLogger.Error(err.Error())
 		return &[]StockInfo{}, err
 	}
-
-	str := GB18030ToUTF8(resp.Body())
-	dataStr := strutil.SplitEx(str, "\n", true)
-
-	for _, data := range dataStr {
-		//logger.SugaredLogger.Info(data)
-		stockData, err := ParseFullSingleStockData(data)
-		//logger.SugaredLogger.Infof("GetStockCodeRealTimeData %v", stockData)
-		if err != nil {
-			logger.SugaredLogger.Error(err.Error())
-			continue
-		}
-		stockInfos = append(stockInfos, *stockData)
-
-		go func() {
+	for _, stockData := range stockInfos {
+		go func(stockData StockInfo) {
 			var count int64
 			db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Count(&count)
 			if count == 0 {
-				db.Dao.Model(&StockInfo{}).Create(stockData)
+				db.Dao.Model(&StockInfo{}).Create(&stockData)
 			} else {
-				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(stockData)
+				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(&stockData)
 			}
-		}()
-
+		}(stockData)
 	}
-
-	return &stockInfos, err
+	return &stockInfos, nil
 }
 
 func (receiver StockDataApi) Follow(stockCode string) string {
@@ -482,6 +420,12 @@ func (receiver StockDataApi) SetStockAICron(cron string, stockCode string) {
 	db.Dao.Model(&FollowedStock{}).Where("stock_code = ?", strings.ToLower(stockCode)).Update("cron", cron)
 
 }
+
+// SetStockAIModel 设置定时分析使用的模型配置
+func (receiver StockDataApi) SetStockAIModel(aiModelId uint, stockCode string) {
+	db.Dao.Model(&FollowedStock{}).Where("stock_code = ?", normalizeTradeStockCode(stockCode)).Update("ai_model_id", aiModelId)
+}
+
 func (receiver StockDataApi) GetFollowList(groupId int) *[]FollowedStock {
 	logger.SugaredLogger.Infof("GetFollowList %d", groupId)
 
@@ -572,6 +516,9 @@ func ParseTxStockData(data string) (*StockInfo, error) {
 	if strutil.ContainsAny(datas[0], []string{"v_r_hk", "v_hk", "v_sz", "v_sh"}) {
 		result, err = ParseTxHKStockData(datas)
 	}
+	if result == nil {
+		return nil, fmt.Errorf("unsupported data: %s %v", datas[0], err)
+	}
 
 	//logger.SugaredLogger.Infof("股票数据解析完成: %v", result)
 	marshal, err := json.Marshal(result)
@@ -587,6 +534,10 @@ func ParseTxStockData(data string) (*StockInfo, error) {
 		return nil, err
 	}
 	//logger.SugaredLogger.Infof("股票数据解析完成stockInfo: %+v", stockInfo)
+	stockInfo.Quote, err = ParseQuote(stockInfo)
+	if err != nil {
+		return nil, err
+	}
 
 	return stockInfo, nil
 
@@ -688,6 +639,19 @@ func ParseTxHKStockData(datas []string) (map[string]string, error) {
 		result["今日最高价"] = parts[32]
 		result["今日最低价"] = parts[33]
 	}
+
+	//港股成交量单位为股;沪深为 当前价/成交量(手)/成交额(元),成交量换算为股,与新浪行情一致
+	if strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
+		deal := strutil.SplitAndTrim(parts[34], "/")
+		if len(deal) == 3 {
+			hands, _ := convertor.ToFloat(deal[1])
+			result["成交的股票数"] = convertor.ToString(int64(hands * 100))
+			result["成交金额"] = deal[2]
+		}
+	} else if len(parts) > 37 {
+		result["成交的股票数"] = parts[36]
+		result["成交金额"] = parts[37]
+	}
 	//logger.SugaredLogger.Infof("股票数据解析完成 %s %s 时间: %s,%s", parts[1], parts[3], parts[29], parts[30])
 
 	//logger.SugaredLogger.Infof("股票数据解析完成 时间: %v", timestr)
@@ -713,6 +677,9 @@ func ParseFullSingleStockData(data string) (*StockInfo, error) {
 	if strutil.ContainsAny(datas[0], []string{"hq_str_gb"}) {
 		result, err = ParseUSStockData(datas)
 	}
+	if result == nil {
+		return nil, fmt.Errorf("unsupported data: %s %v", datas[0], err)
+	}
 
 	//logger.SugaredLogger.Infof("股票数据解析完成: %v", result)
 	marshal, err := json.Marshal(result)
@@ -728,6 +695,10 @@ func ParseFullSingleStockData(data string) (*StockInfo, error) {
 		return nil, err
 	}
 	//logger.SugaredLogger.Infof("股票数据解析完成stockInfo: %+v", stockInfo)
+	stockInfo.Quote, err = ParseQuote(stockInfo)
+	if err != nil {
+		return nil, err
+	}
 
 	return stockInfo, nil
 }
@@ -792,6 +763,7 @@ func ParseUSStockData(datas []string) (map[string]string, error) {
 	result["今日最高价"] = parts[6]
 	result["今日最低价"] = parts[7]
 	result["当前价格"] = parts[1]
+	result["成交的股票数"] = parts[10]
 	result["盘前盘后"] = parts[21]
 	result["盘前盘后涨跌幅"] = parts[22]
 	result["日期"] = strutil.SplitAndTrim(parts[3], " ", "")[0]
@@ -836,6 +808,8 @@ func ParseHKStockData(datas []string) (map[string]string, error) {
 	result["今日最高价"] = parts[4]
 	result["今日最低价"] = parts[5]
 	result["当前价格"] = parts[6]
+	result["成交金额"] = parts[11]
+	result["成交的股票数"] = parts[12]
 	result["日期"] = strings.ReplaceAll(parts[17], "/", "-")
 	result["时间"] = strings.ReplaceAll(parts[18], "\";", ":00")
 	//logger.SugaredLogger.Infof("股票数据解析完成: %v", result)
@@ -972,7 +946,7 @@ func GetRealTimeStockPriceInfo(ctx context.Context, stockCode string) (price, pr
 	return price, priceTime
 }
 
-func SearchStockPriceInfo(stockName, stockCode string, crawlTimeOut int64) *[]string {
+func SearchStockPriceInfo(ctx context.Context, stockName, stockCode string, crawlTimeOut int64) *[]string {
 
 	if strutil.HasPrefixAny(stockCode, []string{"SZ", "SH", "sh", "sz", "bj"}) {
 		//if strutil.HasPrefixAny(stockCode, []string{"bj", "BJ"}) {
@@ -982,18 +956,18 @@ func SearchStockPriceInfo(stockName, stockCode string, crawlTimeOut int64) *[]st
 		//	}) + ".BJ"
 		//}
 
-		return getSHSZStockPriceInfo(stockName, stockCode, crawlTimeOut)
+		return getSHSZStockPriceInfo(ctx, stockName, stockCode, crawlTimeOut)
 	}
 	if strutil.HasPrefixAny(stockCode, []string{"HK", "hk"}) {
-		return getHKStockPriceInfo(stockCode, crawlTimeOut)
+		return getHKStockPriceInfo(ctx, stockCode, crawlTimeOut)
 	}
 	if strutil.HasPrefixAny(stockCode, []string{"US", "us", "gb_"}) {
-		return getUSStockPriceInfo(stockCode, crawlTimeOut)
+		return getUSStockPriceInfo(ctx, stockCode, crawlTimeOut)
 	}
 	return &[]string{}
 }
 
-func getUSStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
+func getUSStockPriceInfo(parent context.Context, stockCode string, crawlTimeOut int64) *[]string {
 	var messages []string
 	crawlerAPI := CrawlerApi{}
 	crawlerBaseInfo := CrawlerBaseInfo{
@@ -1002,7 +976,7 @@ func getUSStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
 		BaseUrl:     "https://stock.finance.sina.com.cn",
 		Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, crawlerBaseInfo)
 
@@ -1046,7 +1020,7 @@ func getUSStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
 	return &messages
 }
 
-func getHKStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
+func getHKStockPriceInfo(parent context.Context, stockCode string, crawlTimeOut int64) *[]string {
 	var messages []string
 	crawlerAPI := CrawlerApi{}
 	crawlerBaseInfo := CrawlerBaseInfo{
@@ -1055,7 +1029,7 @@ func getHKStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
 		BaseUrl:     "https://stock.finance.sina.com.cn",
 		Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, crawlerBaseInfo)
 
@@ -1101,7 +1075,7 @@ func getHKStockPriceInfo(stockCode string, crawlTimeOut int64) *[]string {
 	return &messages
 }
 
-func getZSInfo(name, stockCode string, crawlTimeOut int64) string {
+func getZSInfo(parent context.Context, name, stockCode string, crawlTimeOut int64) string {
 	url := "https://finance.sina.com.cn/realstock/company/" + stockCode + "/nc.shtml"
 	crawlerAPI := CrawlerApi{}
 	crawlerBaseInfo := CrawlerBaseInfo{
@@ -1110,7 +1084,7 @@ func getZSInfo(name, stockCode string, crawlTimeOut int64) string {
 		BaseUrl:     "https://finance.sina.com.cn",
 		Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, crawlerBaseInfo)
 	html, ok := crawlerAPI.GetHtml(url, "div#hqDetails table", true)
@@ -1132,7 +1106,7 @@ func getZSInfo(name, stockCode string, crawlTimeOut int64) string {
 	return markdown.String()
 }
 
-func getSHSZStockPriceInfo(stockName, stockCode string, crawlTimeOut int64) *[]string {
+func getSHSZStockPriceInfo(parent context.Context, stockName, stockCode string, crawlTimeOut int64) *[]string {
 	url := "https://finance.sina.com.cn/realstock/company/" + stockCode + "/nc.shtml"
 	crawlerAPI := CrawlerApi{}
 	crawlerBaseInfo := CrawlerBaseInfo{
@@ -1141,7 +1115,7 @@ func getSHSZStockPriceInfo(stockName, stockCode string, crawlTimeOut int64) *[]s
 		BaseUrl:     "https://finance.sina.com.cn",
 		Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	ctx, cancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer cancel()
 	crawlerAPI = crawlerAPI.NewCrawler(ctx, crawlerBaseInfo)
 	html, ok := crawlerAPI.GetHtml(url, "div#hqDetails table", true)
@@ -1163,7 +1137,7 @@ func getSHSZStockPriceInfo(stockName, stockCode string, crawlTimeOut int64) *[]s
 	return &[]string{markdown.String()}
 }
 
-func SearchStockInfo(stock, msgType string, crawlTimeOut int64) *[]string {
+func SearchStockInfo(parent context.Context, stock, msgType string, crawlTimeOut int64) *[]string {
 	crawler := CrawlerApi{
 		crawlerBaseInfo: CrawlerBaseInfo{
 
@@ -1173,7 +1147,7 @@ func SearchStockInfo(stock, msgType string, crawlTimeOut int64) *[]string {
 			Headers:     map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0"},
 		},
 	}
-	timeoutCtx, timeoutCtxCancel := context.WithTimeout(context.Background(), time.Duration(crawlTimeOut)*time.Second)
+	timeoutCtx, timeoutCtxCancel := context.WithTimeout(parent, time.Duration(crawlTimeOut)*time.Second)
 	defer timeoutCtxCancel()
 	crawler = crawler.NewCrawler(timeoutCtx, crawler.crawlerBaseInfo)
 	url := fmt.Sprintf("https://www.cls.cn/searchPage?keyword=%s&type=%s", RemoveAllBlankChar(stock), msgType)
@@ -1300,6 +1274,7 @@ func (receiver StockDataApi) GetKLineData(stockCode string, kLineType string, da
 	url := fmt.Sprintf("http://quotes.sina.cn/cn/api/json_v2.php/CN_MarketDataService.getKLineData?symbol=%s&scale=%s&ma=yes&datalen=%d", stockCode, kLineType, days)
 	K := &[]KLineData{}
 	_, err := receiver.client.SetTimeout(time.Duration(receiver.config.CrawlTimeOut)*time.Second).R().
+		SetContext(receiver.requestContext()).
 		SetHeader("Host", "quotes.sina.cn").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
 		SetResult(K).
@@ -1322,6 +1297,7 @@ func (receiver StockDataApi) GetHK_KLineData(stockCode string, kLineType string,
 	K := &[]KLineData{}
 	res := make(map[string]interface{})
 	resp, err := receiver.client.SetTimeout(time.Duration(receiver.config.CrawlTimeOut)*time.Second).R().
+		SetContext(receiver.requestContext()).
 		SetHeader("Host", "web.ifzq.gtimg.cn").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
 		Get(url)
@@ -1336,18 +1312,16 @@ func (receiver StockDataApi) GetHK_KLineData(stockCode string, kLineType string,
 		return K
 	}
 	if res["data"] != nil && code == 0 {
-		data := res["data"].(map[string]interface{})[stockCode].(map[string]interface{})
+		//代码不存在或响应格式变化时返回空K线
+		datas, _ := res["data"].(map[string]interface{})
+		data, _ := datas[stockCode].(map[string]interface{})
 		if data != nil {
-			var day []any
-			if data["qfqday"] != nil {
-				day = data["qfqday"].([]any)
-			}
+			day, _ := data["qfqday"].([]any)
 			if data["day"] != nil {
-				day = data["day"].([]any)
+				day, _ = data["day"].([]any)
 			}
 			for _, v := range day {
-				if v != nil {
-					vv := v.([]any)
+				if vv, ok := v.([]any); ok && len(vv) >= 6 {
 					KLine := &KLineData{
 						Day:    convertor.ToString(vv[0]),
 						Open:   convertor.ToString(vv[1]),
@@ -1557,6 +1531,7 @@ func (receiver StockDataApi) GetCommonKLineData(stockCode string, kLineType stri
 	K := &[]KLineData{}
 	res := make(map[string]interface{})
 	resp, err := receiver.client.SetTimeout(time.Duration(receiver.config.CrawlTimeOut)*time.Second).R().
+		SetContext(receiver.requestContext()).
 		SetHeader("Host", "web.ifzq.gtimg.cn").
 		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
 		Get(url)
@@ -1571,18 +1546,16 @@ func (receiver StockDataApi) GetCommonKLineData(stockCode string, kLineType stri
 		return K
 	}
 	if res["data"] != nil && code == 0 {
-		data := res["data"].(map[string]interface{})[stockCode].(map[string]interface{})
+		//代码不存在或响应格式变化时返回空K线
+		datas, _ := res["data"].(map[string]interface{})
+		data, _ := datas[stockCode].(map[string]interface{})
 		if data != nil {
-			var day []any
-			if data["qfqday"] != nil {
-				day = data["qfqday"].([]any)
-			}
+			day, _ := data["qfqday"].([]any)
 			if data["day"] != nil {
-				day = data["day"].([]any)
+				day, _ = data["day"].([]any)
 			}
 			for _, v := range day {
-				if v != nil {
-					vv := v.([]any)
+				if vv, ok := v.([]any); ok && len(vv) >= 6 {
 					KLine := &KLineData{
 						Day:    convertor.ToString(vv[0]),
 						Open:   convertor.ToString(vv[1]),
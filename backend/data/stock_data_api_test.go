@@ -25,7 +25,7 @@ import (
 func TestGetTelegraph(t *testing.T) {
 	db.Init("../../data/stock.db")
 
-	//telegraphs := GetTelegraphList(30)
+	//telegraphs := GetTelegraphList(context.Background(), 30)
 	//for _, telegraph := range *telegraphs {
 	//	logger.SugaredLogger.Info(telegraph)
 	//}
@@ -40,15 +40,15 @@ func TestGetFinancialReports(t *testing.T) {
 	//GetFinancialReports("sz000802", 30)
 	//GetFinancialReports("hk00927", 30)
 	//GetFinancialReports("gb_aapl", 30)
-	GetFinancialReportsByXUEQIU("sz000802", 30)
-	GetFinancialReportsByXUEQIU("gb_aapl", 30)
-	GetFinancialReportsByXUEQIU("hk00927", 30)
+	GetFinancialReportsByXUEQIU(context.Background(), "sz000802", 30)
+	GetFinancialReportsByXUEQIU(context.Background(), "gb_aapl", 30)
+	GetFinancialReportsByXUEQIU(context.Background(), "hk00927", 30)
 
 }
 
 func TestGetTelegraphSearch(t *testing.T) {
 	//url := "https://www.cls.cn/searchPage?keyword=%E9%97%BB%E6%B3%B0%E7%A7%91%E6%8A%80&type=telegram"
-	messages := SearchStockInfo("谷歌", "telegram", 30)
+	messages := SearchStockInfo(context.Background(), "谷歌", "telegram", 30)
 	for _, message := range *messages {
 		logger.SugaredLogger.Info(message)
 	}
@@ -61,13 +61,13 @@ func TestSearchStockInfoByCode(t *testing.T) {
 
 func TestSearchStockPriceInfo(t *testing.T) {
 	db.Init("../../data/stock.db")
-	//SearchStockPriceInfo("中信证券", "hk06030", 30)
-	//SearchStockPriceInfo("上海贝岭", "sh600171", 30)
-	SearchStockPriceInfo("苹果公司", "gb_aapl", 30)
-	//SearchStockPriceInfo("微创光电", "bj430198", 30)
-	getZSInfo("创业板指数", "sz399006", 30)
-	//getZSInfo("上证综合指数", "sh000001", 30)
-	//getZSInfo("沪深300指数", "sh000300", 30)
+	//SearchStockPriceInfo(context.Background(), "中信证券", "hk06030", 30)
+	//SearchStockPriceInfo(context.Background(), "上海贝岭", "sh600171", 30)
+	SearchStockPriceInfo(context.Background(), "苹果公司", "gb_aapl", 30)
+	//SearchStockPriceInfo(context.Background(), "微创光电", "bj430198", 30)
+	getZSInfo(context.Background(), "创业板指数", "sz399006", 30)
+	//getZSInfo(context.Background(), "上证综合指数", "sh000001", 30)
+	//getZSInfo(context.Background(), "沪深300指数", "sh000300", 30)
 
 }
 func TestGetStockMinutePriceData(t *testing.T) {
@@ -0,0 +1,329 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"go-stock/backend/db"
+	"go-stock/backend/logger"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/5/20 14:10
+// @Desc 交易流水,按移动平均成本法/先进先出法计算持仓成本和已实现盈亏
+// -----------------------------------------------------------------------------------
+
+const (
+	TradeBuy      = "buy"      //买入
+	TradeSell     = "sell"     //卖出
+	TradeDividend = "dividend" //现金分红
+	TradeSplit    = "split"    //拆股/送转股
+	TradeFee      = "fee"      //其他费用
+
+	CostMethodAverage = "average" //移动平均成本法
+	CostMethodFIFO    = "fifo"    //先进先出法
+
+	DefaultAccount = "默认账户"
+)
+
+// Trade 交易流水
+type Trade struct {
+	gorm.Model
+	StockCode string    `json:"stockCode" gorm:"index"`
+	Account   string    `json:"account" gorm:"index"`
+	Type      string    `json:"type"`
+	Price     float64   `json:"price"`    //成交价(买入/卖出),每股派息(分红)
+	Quantity  int64     `json:"quantity"` //成交股数(买入/卖出),分红时为0表示按当时持仓计算
+	Ratio     float64   `json:"ratio"`    //拆股比例,每1股变为 Ratio 股,如10送5为1.5
+	Amount    float64   `json:"amount"`   //现金金额(分红/费用),为0时按 Price*Quantity 计算
+	Fee       float64   `json:"fee"`      //手续费、印花税等
+	TradeDate time.Time `json:"tradeDate" gorm:"index"`
+	Remark    string    `json:"remark"`
+}
+
+func (Trade) TableName() string {
+	return "stock_trade"
+}
+
+// Lot 先进先出法下的未平仓批次
+type Lot struct {
+	TradeDate time.Time `json:"tradeDate"`
+	Quantity  int64     `json:"quantity"`
+	CostPrice float64   `json:"costPrice"` //含买入费用的每股成本
+}
+
+// Position 由交易流水推导的持仓
+type Position struct {
+	StockCode   string  `json:"stockCode"`
+	Account     string  `json:"account"`
+	Method      string  `json:"method"`
+	Quantity    int64   `json:"quantity"`    //剩余持仓
+	CostBasis   float64 `json:"costBasis"`   //剩余持仓总成本
+	AvgCost     float64 `json:"avgCost"`     //每股成本
+	RealizedPnL float64 `json:"realizedPnL"` //已实现盈亏(含分红、扣除费用)
+	Dividends   float64 `json:"dividends"`   //累计分红
+	Fees        float64 `json:"fees"`        //累计费用
+	Lots        []Lot   `json:"lots"`        //仅先进先出法
+}
+
+// ComputePosition 按交易日期顺序计算持仓,卖出数量超过持仓时返回错误
+func ComputePosition(trades []Trade, method string) (*Position, error) {
+	if method != CostMethodFIFO {
+		method = CostMethodAverage
+	}
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].TradeDate.Equal(sorted[j].TradeDate) {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].TradeDate.Before(sorted[j].TradeDate)
+	})
+
+	p := &Position{Method: method, Lots: []Lot{}}
+	for _, trade := range sorted {
+		p.StockCode = trade.StockCode
+		p.Account = trade.Account
+		p.Fees += trade.Fee
+		switch trade.Type {
+		case TradeBuy:
+			if trade.Quantity <= 0 || trade.Price < 0 {
+				return nil, fmt.Errorf("%s 买入数量或价格错误", trade.TradeDate.Format(time.DateOnly))
+			}
+			cost := trade.Price*float64(trade.Quantity) + trade.Fee
+			p.Quantity += trade.Quantity
+			p.CostBasis += cost
+			if method == CostMethodFIFO {
+				p.Lots = append(p.Lots, Lot{TradeDate: trade.TradeDate, Quantity: trade.Quantity, CostPrice: cost / float64(trade.Quantity)})
+			}
+		case TradeSell:
+			if trade.Quantity <= 0 || trade.Quantity > p.Quantity {
+				return nil, fmt.Errorf("%s 卖出数量%d超过持仓%d", trade.TradeDate.Format(time.DateOnly), trade.Quantity, p.Quantity)
+			}
+			cost := p.sell(trade.Quantity)
+			p.RealizedPnL += trade.Price*float64(trade.Quantity) - trade.Fee - cost
+		case TradeDividend:
+			amount := trade.Amount
+			if amount == 0 {
+				quantity := trade.Quantity
+				if quantity == 0 {
+					quantity = p.Quantity
+				}
+				amount = trade.Price * float64(quantity)
+			}
+			p.Dividends += amount
+			p.RealizedPnL += amount - trade.Fee
+		case TradeSplit:
+			if trade.Ratio <= 0 {
+				return nil, fmt.Errorf("%s 拆股比例错误", trade.TradeDate.Format(time.DateOnly))
+			}
+			p.Quantity = int64(math.Round(float64(p.Quantity) * trade.Ratio))
+			if method == CostMethodFIFO {
+				p.Quantity = 0
+				for i := range p.Lots {
+					p.Lots[i].Quantity = int64(math.Round(float64(p.Lots[i].Quantity) * trade.Ratio))
+					p.Lots[i].CostPrice /= trade.Ratio
+					p.Quantity += p.Lots[i].Quantity
+				}
+			}
+			p.RealizedPnL -= trade.Fee
+		case TradeFee:
+			p.Fees += trade.Amount
+			p.RealizedPnL -= trade.Amount + trade.Fee
+		default:
+			return nil, fmt.Errorf("未知的交易类型:%s", trade.Type)
+		}
+	}
+	if p.Quantity > 0 {
+		p.AvgCost = p.CostBasis / float64(p.Quantity)
+	} else {
+		p.CostBasis = 0
+		p.Lots = []Lot{}
+	}
+	return p, nil
+}
+
+// sell 减少持仓,返回卖出部分的成本
+func (p *Position) sell(quantity int64) float64 {
+	var cost float64
+	if p.Method == CostMethodAverage {
+		cost = p.CostBasis / float64(p.Quantity) * float64(quantity)
+	} else {
+		remain := quantity
+		for remain > 0 && len(p.Lots) > 0 {
+			lot := &p.Lots[0]
+			n := min(remain, lot.Quantity)
+			cost += lot.CostPrice * float64(n)
+			lot.Quantity -= n
+			remain -= n
+			if lot.Quantity == 0 {
+				p.Lots = p.Lots[1:]
+			}
+		}
+	}
+	p.Quantity -= quantity
+	p.CostBasis -= cost
+	return cost
+}
+
+type TradeApi struct {
+	dao *gorm.DB
+}
+
+func NewTradeApi() *TradeApi {
+	return &TradeApi{dao: db.Dao}
+}
+
+// 持仓缓存,交易流水变化或切换成本计算方法时失效
+var positionCache sync.Map
+
+func positionCacheKey(stockCode, method string) string {
+	return stockCode + "|" + method
+}
+
+func clearPositionCache() {
+	positionCache.Range(func(key, value any) bool {
+		positionCache.Delete(key)
+		return true
+	})
+}
+
+// normalizeTradeStockCode 与关注列表的股票代码保持一致,美股 gb_ 前缀转换为 us
+func normalizeTradeStockCode(stockCode string) string {
+	stockCode = strings.ToLower(strutil.Trim(stockCode))
+	if strings.HasPrefix(stockCode, "gb_") {
+		stockCode = strings.Replace(stockCode, "gb_", "us", 1)
+	}
+	return stockCode
+}
+
+// AddTrade 新增交易流水,新增后持仓不合法(如卖出超过持仓)时拒绝
+func (t TradeApi) AddTrade(trade Trade) error {
+	trade.ID = 0
+	trade.StockCode = normalizeTradeStockCode(trade.StockCode)
+	if trade.StockCode == "" {
+		return errors.New("股票代码不能为空")
+	}
+	if trade.Account == "" {
+		trade.Account = DefaultAccount
+	}
+	if trade.TradeDate.IsZero() {
+		trade.TradeDate = time.Now()
+	}
+	trades := append(t.GetTrades(trade.StockCode, trade.Account), trade)
+	if _, err := ComputePosition(trades, CostMethodFIFO); err != nil {
+		return err
+	}
+	if err := t.dao.Create(&trade).Error; err != nil {
+		return err
+	}
+	clearPositionCache()
+	return nil
+}
+
+// DeleteTrade 删除交易流水
+func (t TradeApi) DeleteTrade(id uint) error {
+	trade := Trade{}
+	if err := t.dao.First(&trade, id).Error; err != nil {
+		return err
+	}
+	remain := make([]Trade, 0)
+	for _, item := range t.GetTrades(trade.StockCode, trade.Account) {
+		if item.ID != id {
+			remain = append(remain, item)
+		}
+	}
+	if _, err := ComputePosition(remain, CostMethodFIFO); err != nil {
+		return fmt.Errorf("删除后持仓不合法:%w", err)
+	}
+	if err := t.dao.Delete(&Trade{}, id).Error; err != nil {
+		return err
+	}
+	clearPositionCache()
+	return nil
+}
+
+// GetTrades 查询交易流水,account 为空时查询全部账户
+func (t TradeApi) GetTrades(stockCode, account string) []Trade {
+	var trades []Trade
+	query := t.dao.Model(&Trade{}).Where("stock_code = ?", normalizeTradeStockCode(stockCode))
+	if account != "" {
+		query = query.Where("account = ?", account)
+	}
+	query.Order("trade_date asc, id asc").Find(&trades)
+	return trades
+}
+
+// GetPositions 按账户计算某只股票的持仓
+func (t TradeApi) GetPositions(stockCode, method string) ([]*Position, error) {
+	accounts := make(map[string][]Trade)
+	names := make([]string, 0)
+	for _, trade := range t.GetTrades(stockCode, "") {
+		if _, ok := accounts[trade.Account]; !ok {
+			names = append(names, trade.Account)
+		}
+		accounts[trade.Account] = append(accounts[trade.Account], trade)
+	}
+	positions := make([]*Position, 0, len(names))
+	for _, name := range names {
+		position, err := ComputePosition(accounts[name], method)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", name, err)
+		}
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+// GetPosition 汇总全部账户的持仓,没有交易流水时返回 nil
+func (t TradeApi) GetPosition(stockCode, method string) (*Position, error) {
+	stockCode = normalizeTradeStockCode(stockCode)
+	if method != CostMethodFIFO {
+		method = CostMethodAverage
+	}
+	key := positionCacheKey(stockCode, method)
+	if cached, ok := positionCache.Load(key); ok {
+		return cached.(*Position), nil
+	}
+	positions, err := t.GetPositions(stockCode, method)
+	if err != nil {
+		return nil, err
+	}
+	var total *Position
+	if len(positions) > 0 {
+		total = &Position{StockCode: stockCode, Method: method, Lots: []Lot{}}
+		for _, p := range positions {
+			total.Quantity += p.Quantity
+			total.CostBasis += p.CostBasis
+			total.RealizedPnL += p.RealizedPnL
+			total.Dividends += p.Dividends
+			total.Fees += p.Fees
+			total.Lots = append(total.Lots, p.Lots...)
+		}
+		if total.Quantity > 0 {
+			total.AvgCost = total.CostBasis / float64(total.Quantity)
+		}
+		if len(positions) == 1 {
+			total.Account = positions[0].Account
+		}
+	}
+	positionCache.Store(key, total)
+	return total, nil
+}
+
+// GetHoldingPosition 按设置中的成本计算方法获取持仓,供行情刷新时计算盈亏
+func GetHoldingPosition(stockCode string) *Position {
+	position, err := NewTradeApi().GetPosition(stockCode, GetConfig().CostMethod)
+	if err != nil {
+		logger.SugaredLogger.Errorf("计算持仓失败 %s:%s", stockCode, err.Error())
+		return nil
+	}
+	return position
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"go-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tradeDay(day string) time.Time {
+	t, _ := time.Parse(time.DateOnly, day)
+	return t
+}
+
+func TestComputePositionAverageAndFIFO(t *testing.T) {
+	trades := []Trade{
+		{Type: TradeBuy, Price: 10, Quantity: 100, Fee: 5, TradeDate: tradeDay("2025-01-02")},
+		{Type: TradeBuy, Price: 12, Quantity: 100, Fee: 5, TradeDate: tradeDay("2025-01-03")},
+		{Type: TradeSell, Price: 13, Quantity: 150, Fee: 10, TradeDate: tradeDay("2025-01-06")},
+	}
+
+	avg, err := ComputePosition(trades, CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), avg.Quantity)
+	assert.InDelta(t, 11.05, avg.AvgCost, 1e-9)
+	//1950-10-150*11.05
+	assert.InDelta(t, 282.5, avg.RealizedPnL, 1e-9)
+	assert.InDelta(t, 20, avg.Fees, 1e-9)
+	assert.Empty(t, avg.Lots)
+
+	fifo, err := ComputePosition(trades, CostMethodFIFO)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), fifo.Quantity)
+	assert.InDelta(t, 12.05, fifo.AvgCost, 1e-9)
+	//1950-10-(100*10.05+50*12.05)
+	assert.InDelta(t, 332.5, fifo.RealizedPnL, 1e-9)
+	assert.Len(t, fifo.Lots, 1)
+	assert.Equal(t, int64(50), fifo.Lots[0].Quantity)
+}
+
+func TestComputePositionSplitAndDividend(t *testing.T) {
+	trades := []Trade{
+		{Type: TradeBuy, Price: 20, Quantity: 100, TradeDate: tradeDay("2025-01-02")},
+		{Type: TradeDividend, Price: 0.5, TradeDate: tradeDay("2025-03-03")},
+		{Type: TradeSplit, Ratio: 1.5, TradeDate: tradeDay("2025-03-04")},
+		{Type: TradeFee, Amount: 3, TradeDate: tradeDay("2025-03-05")},
+	}
+	for _, method := range []string{CostMethodAverage, CostMethodFIFO} {
+		p, err := ComputePosition(trades, method)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(150), p.Quantity)
+		assert.InDelta(t, 2000, p.CostBasis, 1e-9)
+		assert.InDelta(t, 2000.0/150, p.AvgCost, 1e-9)
+		assert.InDelta(t, 50, p.Dividends, 1e-9)
+		assert.InDelta(t, 47, p.RealizedPnL, 1e-9)
+	}
+}
+
+func TestComputePositionOversell(t *testing.T) {
+	_, err := ComputePosition([]Trade{
+		{Type: TradeBuy, Price: 10, Quantity: 100, TradeDate: tradeDay("2025-01-02")},
+		{Type: TradeSell, Price: 11, Quantity: 200, TradeDate: tradeDay("2025-01-03")},
+	}, CostMethodAverage)
+	assert.Error(t, err)
+
+	//按日期排序,先录入的卖出不影响
+	p, err := ComputePosition([]Trade{
+		{Type: TradeSell, Price: 11, Quantity: 100, TradeDate: tradeDay("2025-01-03")},
+		{Type: TradeBuy, Price: 10, Quantity: 100, TradeDate: tradeDay("2025-01-02")},
+	}, CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), p.Quantity)
+	assert.Equal(t, 0.0, p.AvgCost)
+	assert.InDelta(t, 100, p.RealizedPnL, 1e-9)
+}
+
+func TestTradeApiPosition(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "stock.db"))
+	db.Dao.AutoMigrate(&Trade{})
+	clearPositionCache()
+	api := NewTradeApi()
+
+	position, err := api.GetPosition("sh600000", CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Nil(t, position)
+
+	assert.NoError(t, api.AddTrade(Trade{StockCode: "SH600000", Type: TradeBuy, Price: 10, Quantity: 100, TradeDate: tradeDay("2025-01-02")}))
+	assert.NoError(t, api.AddTrade(Trade{StockCode: "sh600000", Account: "融资账户", Type: TradeBuy, Price: 12, Quantity: 100, TradeDate: tradeDay("2025-01-03")}))
+	assert.Error(t, api.AddTrade(Trade{StockCode: "sh600000", Type: TradeSell, Price: 12, Quantity: 200, TradeDate: tradeDay("2025-01-06")}))
+
+	position, err = api.GetPosition("sh600000", CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), position.Quantity)
+	assert.InDelta(t, 11, position.AvgCost, 1e-9)
+
+	positions, err := api.GetPositions("sh600000", CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Len(t, positions, 2)
+	assert.Equal(t, DefaultAccount, positions[0].Account)
+
+	trades := api.GetTrades("sh600000", DefaultAccount)
+	assert.Len(t, trades, 1)
+	assert.NoError(t, api.DeleteTrade(trades[0].ID))
+	position, err = api.GetPosition("sh600000", CostMethodAverage)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), position.Quantity)
+	assert.Equal(t, "融资账户", position.Account)
+}
@@ -0,0 +1,175 @@
+package indicator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// @Author spark
+// @Date 2025/5/16 10:25
+// @Desc 按名称批量计算指标,生成给AI的精简指标摘要
+// -----------------------------------------------------------------------------------
+
+const (
+	NameMA   = "MA"
+	NameEMA  = "EMA"
+	NameMACD = "MACD"
+	NameKDJ  = "KDJ"
+	NameRSI  = "RSI"
+	NameBOLL = "BOLL"
+	NameATR  = "ATR"
+	NameOBV  = "OBV"
+)
+
+// Names 支持的全部指标
+var Names = []string{NameMA, NameEMA, NameMACD, NameKDJ, NameRSI, NameBOLL, NameATR, NameOBV}
+
+// Result 指标计算结果,Values 中的序列与 Days 一一对应
+type Result struct {
+	Days   []string          `json:"days"`
+	Close  Series            `json:"close"`
+	Values map[string]Series `json:"values"`
+}
+
+// Compute 计算指定指标(不区分大小写),names 为空时计算全部
+func Compute(bars []Bar, names ...string) *Result {
+	if len(names) == 0 {
+		names = Names
+	}
+	values := closes(bars)
+	res := &Result{
+		Days:   make([]string, len(bars)),
+		Close:  values,
+		Values: make(map[string]Series),
+	}
+	for i, bar := range bars {
+		res.Days[i] = bar.Day
+	}
+	for _, name := range names {
+		switch strings.ToUpper(strings.TrimSpace(name)) {
+		case NameMA:
+			for _, n := range []int{5, 10, 20, 60} {
+				res.Values[fmt.Sprintf("MA%d", n)] = SMA(values, n)
+			}
+		case NameEMA:
+			res.Values["EMA12"] = EMA(values, 12)
+			res.Values["EMA26"] = EMA(values, 26)
+		case NameMACD:
+			res.Values["DIF"], res.Values["DEA"], res.Values["MACD"] = MACD(values, 12, 26, 9)
+		case NameKDJ:
+			res.Values["K"], res.Values["D"], res.Values["J"] = KDJ(bars, 9, 3, 3)
+		case NameRSI:
+			for _, n := range []int{6, 12, 24} {
+				res.Values[fmt.Sprintf("RSI%d", n)] = RSI(values, n)
+			}
+		case NameBOLL:
+			res.Values["BOLL_UP"], res.Values["BOLL_MID"], res.Values["BOLL_LOW"] = BOLL(values, 20, 2)
+		case NameATR:
+			res.Values["ATR14"] = ATR(bars, 14)
+		case NameOBV:
+			res.Values["OBV"] = OBV(bars)
+		}
+	}
+	return res
+}
+
+// Summary 最新一根K线的指标数值和常见信号,用于替代完整K线表发送给AI
+func (r *Result) Summary() string {
+	if len(r.Days) == 0 {
+		return ""
+	}
+	last := len(r.Days) - 1
+	price := r.Close[last]
+	lines := []string{fmt.Sprintf("- 日期:%s 收盘价:%s", r.Days[last], format(price))}
+	value := func(name string) float64 {
+		return r.Values[name].At(last)
+	}
+	join := func(names ...string) string {
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			if v := value(name); !math.IsNaN(v) {
+				parts = append(parts, name+":"+format(v))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	if s := join("MA5", "MA10", "MA20", "MA60"); s != "" {
+		line := "- 均线 " + s
+		if ma5, ma10, ma20 := value("MA5"), value("MA10"), value("MA20"); ma5 > ma10 && ma10 > ma20 {
+			line += " (多头排列)"
+		} else if ma5 < ma10 && ma10 < ma20 {
+			line += " (空头排列)"
+		}
+		lines = append(lines, line)
+	}
+	if s := join("EMA12", "EMA26"); s != "" {
+		lines = append(lines, "- EMA "+s)
+	}
+	if s := join("DIF", "DEA", "MACD"); s != "" {
+		lines = append(lines, "- MACD "+s+r.cross("DIF", "DEA", 3))
+	}
+	if s := join("K", "D", "J"); s != "" {
+		line := "- KDJ " + s + r.cross("K", "D", 3)
+		if j := value("J"); j > 100 {
+			line += " (超买)"
+		} else if j < 0 {
+			line += " (超卖)"
+		}
+		lines = append(lines, line)
+	}
+	if s := join("RSI6", "RSI12", "RSI24"); s != "" {
+		line := "- RSI " + s
+		if rsi := value("RSI6"); rsi > 80 {
+			line += " (超买)"
+		} else if rsi < 20 {
+			line += " (超卖)"
+		}
+		lines = append(lines, line)
+	}
+	if s := join("BOLL_UP", "BOLL_MID", "BOLL_LOW"); s != "" {
+		line := "- 布林带 " + s
+		if up, low := value("BOLL_UP"), value("BOLL_LOW"); price > up {
+			line += " (收盘价突破上轨)"
+		} else if price < low {
+			line += " (收盘价跌破下轨)"
+		}
+		lines = append(lines, line)
+	}
+	if atr := value("ATR14"); !math.IsNaN(atr) && price > 0 {
+		lines = append(lines, fmt.Sprintf("- ATR14:%s (占收盘价%.2f%%)", format(atr), atr/price*100))
+	}
+	if obv := r.Values["OBV"]; len(obv) > 5 {
+		trend := "走平"
+		if change := obv[last] - obv[last-5]; change > 0 {
+			trend = "上升"
+		} else if change < 0 {
+			trend = "下降"
+		}
+		lines = append(lines, fmt.Sprintf("- OBV:%s (近5日%s)", format(obv[last]), trend))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cross 最近 n 根K线内 fast 上穿/下穿 slow
+func (r *Result) cross(fast, slow string, n int) string {
+	f, s := r.Values[fast], r.Values[slow]
+	for i := len(f) - 1; i > 0 && i >= len(f)-n; i-- {
+		prev, cur := f[i-1]-s[i-1], f[i]-s[i]
+		if math.IsNaN(prev) || math.IsNaN(cur) {
+			return ""
+		}
+		if prev <= 0 && cur > 0 {
+			return fmt.Sprintf(" (%s金叉)", r.Days[i])
+		}
+		if prev >= 0 && cur < 0 {
+			return fmt.Sprintf(" (%s死叉)", r.Days[i])
+		}
+	}
+	return ""
+}
+
+func format(v float64) string {
+	return fmt.Sprintf("%.3f", v)
+}
@@ -0,0 +1,234 @@
+package indicator
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+)
+
+// @Author spark
+// @Date 2025/5/16 9:40
+// @Desc 技术指标计算(MA/EMA/MACD/KDJ/RSI/BOLL/ATR/OBV)
+// -----------------------------------------------------------------------------------
+
+// Bar K线
+type Bar struct {
+	Day    string
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Series 指标序列,与K线一一对应,数据不足的位置为 NaN(序列化为 null)
+type Series []float64
+
+func (s Series) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("[")
+	for i, v := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			buf.WriteString("null")
+			continue
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// Last 最后一个值
+func (s Series) Last() float64 {
+	return s.At(len(s) - 1)
+}
+
+// At 第 i 个值,越界时返回 NaN
+func (s Series) At(i int) float64 {
+	if i < 0 || i >= len(s) {
+		return math.NaN()
+	}
+	return s[i]
+}
+
+func newSeries(n int) Series {
+	s := make(Series, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+func closes(bars []Bar) []float64 {
+	values := make([]float64, len(bars))
+	for i, bar := range bars {
+		values[i] = bar.Close
+	}
+	return values
+}
+
+// SMA 简单移动平均
+func SMA(values []float64, n int) Series {
+	res := newSeries(len(values))
+	if n <= 0 {
+		return res
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= n {
+			sum -= values[i-n]
+		}
+		if i >= n-1 {
+			res[i] = sum / float64(n)
+		}
+	}
+	return res
+}
+
+// EMA 指数移动平均,以第一个值为初始值(与通达信/同花顺一致)
+func EMA(values []float64, n int) Series {
+	res := newSeries(len(values))
+	if n <= 0 || len(values) == 0 {
+		return res
+	}
+	alpha := 2 / float64(n+1)
+	res[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		res[i] = alpha*values[i] + (1-alpha)*res[i-1]
+	}
+	return res
+}
+
+// MACD 返回 DIF、DEA、MACD柱(2*(DIF-DEA))
+func MACD(values []float64, short, long, signal int) (dif, dea, hist Series) {
+	fast := EMA(values, short)
+	slow := EMA(values, long)
+	dif = newSeries(len(values))
+	for i := range values {
+		dif[i] = fast[i] - slow[i]
+	}
+	dea = EMA(dif, signal)
+	hist = newSeries(len(values))
+	for i := range values {
+		hist[i] = 2 * (dif[i] - dea[i])
+	}
+	return dif, dea, hist
+}
+
+// KDJ 随机指标,K/D 初始值为50
+func KDJ(bars []Bar, n, m1, m2 int) (k, d, j Series) {
+	k, d, j = newSeries(len(bars)), newSeries(len(bars)), newSeries(len(bars))
+	prevK, prevD := 50.0, 50.0
+	for i := range bars {
+		start := max(0, i-n+1)
+		low, high := bars[start].Low, bars[start].High
+		for _, bar := range bars[start : i+1] {
+			low = math.Min(low, bar.Low)
+			high = math.Max(high, bar.High)
+		}
+		rsv := 50.0
+		if high > low {
+			rsv = (bars[i].Close - low) / (high - low) * 100
+		}
+		prevK = (float64(m1-1)*prevK + rsv) / float64(m1)
+		prevD = (float64(m2-1)*prevD + prevK) / float64(m2)
+		k[i], d[i], j[i] = prevK, prevD, 3*prevK-2*prevD
+	}
+	return k, d, j
+}
+
+// RSI 相对强弱指标(Wilder 平滑)
+func RSI(values []float64, n int) Series {
+	res := newSeries(len(values))
+	if n <= 0 || len(values) <= n {
+		return res
+	}
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= n; i++ {
+		change := values[i] - values[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(n)
+	loss /= float64(n)
+	res[n] = rsi(gain, loss)
+	for i := n + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain = (gain*float64(n-1) + math.Max(change, 0)) / float64(n)
+		loss = (loss*float64(n-1) + math.Max(-change, 0)) / float64(n)
+		res[i] = rsi(gain, loss)
+	}
+	return res
+}
+
+func rsi(gain, loss float64) float64 {
+	if gain+loss == 0 {
+		return 50
+	}
+	return gain / (gain + loss) * 100
+}
+
+// BOLL 布林带,返回上轨、中轨、下轨
+func BOLL(values []float64, n int, k float64) (upper, mid, lower Series) {
+	mid = SMA(values, n)
+	upper, lower = newSeries(len(values)), newSeries(len(values))
+	for i := n - 1; i < len(values); i++ {
+		if i < 0 {
+			continue
+		}
+		variance := 0.0
+		for _, v := range values[i-n+1 : i+1] {
+			variance += (v - mid[i]) * (v - mid[i])
+		}
+		std := math.Sqrt(variance / float64(n))
+		upper[i] = mid[i] + k*std
+		lower[i] = mid[i] - k*std
+	}
+	return upper, mid, lower
+}
+
+// ATR 平均真实波幅(Wilder 平滑)
+func ATR(bars []Bar, n int) Series {
+	res := newSeries(len(bars))
+	if n <= 0 || len(bars) < n {
+		return res
+	}
+	tr := make([]float64, len(bars))
+	for i, bar := range bars {
+		tr[i] = bar.High - bar.Low
+		if i > 0 {
+			preClose := bars[i-1].Close
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(bar.High-preClose), math.Abs(bar.Low-preClose)))
+		}
+	}
+	sum := 0.0
+	for _, v := range tr[:n] {
+		sum += v
+	}
+	res[n-1] = sum / float64(n)
+	for i := n; i < len(bars); i++ {
+		res[i] = (res[i-1]*float64(n-1) + tr[i]) / float64(n)
+	}
+	return res
+}
+
+// OBV 能量潮
+func OBV(bars []Bar) Series {
+	res := newSeries(len(bars))
+	obv := 0.0
+	for i, bar := range bars {
+		if i > 0 {
+			switch {
+			case bar.Close > bars[i-1].Close:
+				obv += bar.Volume
+			case bar.Close < bars[i-1].Close:
+				obv -= bar.Volume
+			}
+		}
+		res[i] = obv
+	}
+	return res
+}
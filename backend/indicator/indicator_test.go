@@ -0,0 +1,85 @@
+package indicator
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testBars() []Bar {
+	values := []float64{10, 11, 12, 11, 13, 14, 13, 15, 16, 15, 17, 18, 17, 19, 20, 19, 21, 22, 21, 23, 22, 21, 20, 22, 24}
+	bars := make([]Bar, len(values))
+	for i, v := range values {
+		bars[i] = Bar{Day: string(rune('A' + i)), Open: v - 0.5, High: v + 1, Low: v - 1, Close: v, Volume: 100}
+	}
+	return bars
+}
+
+func TestSMAAndEMA(t *testing.T) {
+	ma := SMA([]float64{1, 2, 3, 4, 5}, 3)
+	assert.True(t, math.IsNaN(ma[1]))
+	assert.Equal(t, 2.0, ma[2])
+	assert.Equal(t, 4.0, ma[4])
+
+	ema := EMA([]float64{1, 2, 3}, 3)
+	assert.Equal(t, 1.0, ema[0])
+	assert.Equal(t, 1.5, ema[1])
+	assert.Equal(t, 2.25, ema[2])
+
+	b, _ := json.Marshal(ma)
+	assert.Equal(t, "[null,null,2,3,4]", string(b))
+}
+
+func TestMACD(t *testing.T) {
+	values := closes(testBars())
+	dif, dea, hist := MACD(values, 12, 26, 9)
+	fast, slow := EMA(values, 12), EMA(values, 26)
+	last := len(values) - 1
+	assert.InDelta(t, fast[last]-slow[last], dif[last], 1e-9)
+	assert.InDelta(t, 2*(dif[last]-dea[last]), hist[last], 1e-9)
+	assert.Greater(t, dif[last], 0.0)
+}
+
+func TestKDJAndRSI(t *testing.T) {
+	bars := testBars()
+	k, d, j := KDJ(bars, 9, 3, 3)
+	last := len(bars) - 1
+	assert.InDelta(t, 3*k[last]-2*d[last], j[last], 1e-9)
+	assert.True(t, k[last] >= 0 && k[last] <= 100)
+
+	// 持续上涨时 RSI 为 100
+	rsi := RSI([]float64{1, 2, 3, 4, 5, 6, 7}, 6)
+	assert.True(t, math.IsNaN(rsi[5]))
+	assert.Equal(t, 100.0, rsi[6])
+}
+
+func TestBOLLATROBV(t *testing.T) {
+	upper, mid, lower := BOLL([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	assert.Equal(t, 5.0, mid[7])
+	assert.Equal(t, 9.0, upper[7])
+	assert.Equal(t, 1.0, lower[7])
+
+	bars := []Bar{
+		{High: 11, Low: 9, Close: 10, Volume: 100},
+		{High: 12, Low: 10, Close: 11, Volume: 200},
+		{High: 14, Low: 11, Close: 10, Volume: 300},
+	}
+	atr := ATR(bars, 2)
+	assert.Equal(t, 2.0, atr[1])
+	assert.Equal(t, 2.5, atr[2])
+	assert.Equal(t, Series{0, 200, -100}, OBV(bars))
+}
+
+func TestComputeSummary(t *testing.T) {
+	res := Compute(testBars(), "ma", "MACD", "RSI", "BOLL", "ATR", "OBV", "KDJ")
+	assert.Len(t, res.Values["MA5"], 25)
+	assert.NotContains(t, res.Values, "EMA12")
+	summary := res.Summary()
+	assert.True(t, strings.HasPrefix(summary, "- 日期:Y 收盘价:24.000"))
+	assert.Contains(t, summary, "MA20:")
+	assert.NotContains(t, summary, "MA60")
+	assert.Contains(t, summary, "OBV:")
+}
@@ -0,0 +1,242 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/6/2 9:30
+// @Desc 大模型接入层:OpenAI 兼容接口/Anthropic Messages API/Ollama 原生接口
+// -----------------------------------------------------------------------------------
+
+const (
+	TypeOpenAI    = "openai"
+	TypeAnthropic = "anthropic"
+	TypeOllama    = "ollama"
+)
+
+// Types 支持的接口类型
+var Types = []string{TypeOpenAI, TypeAnthropic, TypeOllama}
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message 对话消息。模型请求调用工具时 ToolCalls 不为空,工具的返回结果以 RoleTool 消息回传
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`
+	ToolCallID string     `json:"toolCallId,omitempty"` //RoleTool 消息对应的调用ID
+	Name       string     `json:"name,omitempty"`       //RoleTool 消息对应的工具名称
+}
+
+// Request 对话请求,模型参数取自 Profile
+type Request struct {
+	Messages []Message `json:"messages"`
+	Tools    []Tool    `json:"tools,omitempty"`
+}
+
+// Usage token 用量,接口未返回时为空
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// Event 流式响应的一个片段,Err 不为空时流结束
+type Event struct {
+	ID           string      `json:"id"`
+	Model        string      `json:"model"`
+	Content      string      `json:"content"`
+	Reasoning    string      `json:"reasoning"` //思考过程
+	FinishReason string      `json:"finishReason"`
+	Usage        *Usage      `json:"usage,omitempty"`
+	ToolCalls    []ToolCall  `json:"toolCalls,omitempty"`  //模型请求调用的工具,一轮回复结束时给出完整参数
+	ToolResult   *ToolResult `json:"toolResult,omitempty"` //工具执行结果,仅 ChatWithTools 产生
+	Err          error       `json:"-"`
+}
+
+// LLMProvider 大模型接口
+type LLMProvider interface {
+	Name() string
+	Type() string
+	// ChatStream 发起流式对话,请求失败时返回错误,否则通过 channel 返回响应片段,结束后关闭 channel
+	ChatStream(ctx context.Context, req Request) (<-chan Event, error)
+}
+
+// Profile 模型配置,各接口类型的默认地址:
+//
+//	openai:    BaseUrl 必填,如 https://api.deepseek.com
+//	anthropic: https://api.anthropic.com
+//	ollama:    http://localhost:11434
+type Profile struct {
+	Name        string  `json:"name"`
+	Provider    string  `json:"provider"`
+	BaseUrl     string  `json:"baseUrl"`
+	ApiKey      string  `json:"apiKey"`
+	Model       string  `json:"model"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   int     `json:"maxTokens"`
+	TimeOut     int     `json:"timeOut"`     //请求超时时间(秒),默认300
+	ContextSize int     `json:"contextSize"` //模型上下文长度(token),默认64000
+}
+
+const defaultTimeOut = 300
+
+// New 根据配置创建模型接口,Provider 为空时按 OpenAI 兼容接口处理
+func New(profile Profile) (LLMProvider, error) {
+	if strings.TrimSpace(profile.Model) == "" {
+		return nil, errors.New("模型名称不能为空")
+	}
+	if profile.TimeOut <= 0 {
+		profile.TimeOut = defaultTimeOut
+	}
+	base := httpProvider{profile: profile, client: resty.New().SetTimeout(time.Duration(profile.TimeOut) * time.Second)}
+	switch profile.Provider {
+	case TypeOpenAI, "":
+		if strings.TrimSpace(profile.BaseUrl) == "" {
+			return nil, errors.New("接口地址不能为空")
+		}
+		base.profile.Provider = TypeOpenAI
+		return &openAIProvider{base}, nil
+	case TypeAnthropic:
+		if strings.TrimSpace(profile.ApiKey) == "" {
+			return nil, errors.New("API Key 不能为空")
+		}
+		return &anthropicProvider{base}, nil
+	case TypeOllama:
+		return &ollamaProvider{base}, nil
+	default:
+		return nil, errors.New("不支持的模型接口类型:" + profile.Provider)
+	}
+}
+
+// httpProvider 基于 HTTP 流式接口的模型
+type httpProvider struct {
+	profile Profile
+	client  *resty.Client
+}
+
+func (p *httpProvider) Name() string {
+	if p.profile.Name != "" {
+		return p.profile.Name
+	}
+	return p.profile.Model
+}
+
+func (p *httpProvider) Type() string {
+	return p.profile.Provider
+}
+
+// baseURL 配置的接口地址,未配置时使用默认地址
+func (p *httpProvider) baseURL(defaultURL string) string {
+	if url := strings.TrimSpace(p.profile.BaseUrl); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return defaultURL
+}
+
+// stream 发送流式请求,非2xx状态码时读取响应内容作为错误返回
+func (p *httpProvider) stream(ctx context.Context, url string, body any, headers map[string]string) (io.ReadCloser, error) {
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(headers).
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		return nil, err
+	}
+	raw := resp.RawBody()
+	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+		defer raw.Close()
+		content, _ := io.ReadAll(io.LimitReader(raw, 1024))
+		return nil, &statusError{provider: p.profile.Provider, code: resp.StatusCode(), body: truncate(string(content), 200)}
+	}
+	return raw, nil
+}
+
+// statusError 接口返回非 2xx 状态码
+type statusError struct {
+	provider string
+	code     int
+	body     string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s 响应状态码 %d: %s", e.provider, e.code, e.body)
+}
+
+// emit 发送事件,调用方已停止读取(ctx 取消)时返回 false。
+// 缓冲区有空位时优先发送,保证取消后的错误事件仍能送达
+func emit(ctx context.Context, ch chan<- Event, event Event) bool {
+	select {
+	case ch <- event:
+		return true
+	default:
+	}
+	select {
+	case ch <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readLines 逐行读取响应,handle 返回 false 时停止。读取完毕后调用 flush(可为空)输出未完成的工具调用,
+// 然后关闭 body 和 ch
+func readLines(ctx context.Context, body io.ReadCloser, ch chan<- Event, handle func(line string) bool, flush func()) {
+	defer close(ch)
+	defer body.Close()
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !handle(line) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		emit(ctx, ch, Event{Err: err})
+		return
+	}
+	if flush != nil {
+		flush()
+	}
+}
+
+// sseData 解析 SSE 的 data 行,非 data 行返回 false
+func sseData(line string) (string, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,292 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]any
+}
+
+// standIn 本地 HTTP 替身,记录请求并按行返回流式响应
+func standIn(t *testing.T, status int, lines ...string) (*httptest.Server, *[]capturedRequest) {
+	requests := make([]capturedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]any)
+		content, _ := io.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		requests = append(requests, capturedRequest{Path: r.URL.Path, Header: r.Header, Body: body})
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// collect 读取全部响应片段
+func collect(t *testing.T, provider LLMProvider, messages ...Message) (string, string, *Usage, error) {
+	events, err := provider.ChatStream(context.Background(), Request{Messages: messages})
+	if err != nil {
+		return "", "", nil, err
+	}
+	var content, reasoning strings.Builder
+	var usage *Usage
+	for event := range events {
+		if event.Err != nil {
+			err = event.Err
+		}
+		content.WriteString(event.Content)
+		reasoning.WriteString(event.Reasoning)
+		if event.Usage != nil {
+			usage = event.Usage
+		}
+	}
+	return content.String(), reasoning.String(), usage, err
+}
+
+func TestNewValidatesProfile(t *testing.T) {
+	_, err := New(Profile{Provider: TypeOpenAI, BaseUrl: "http://localhost"})
+	assert.Error(t, err)
+	_, err = New(Profile{Provider: TypeOpenAI, Model: "deepseek-chat"})
+	assert.Error(t, err)
+	_, err = New(Profile{Provider: TypeAnthropic, Model: "claude"})
+	assert.Error(t, err)
+	_, err = New(Profile{Provider: "unknown", Model: "m"})
+	assert.Error(t, err)
+
+	provider, err := New(Profile{BaseUrl: "http://localhost", Model: "deepseek-chat"})
+	assert.NoError(t, err)
+	assert.Equal(t, TypeOpenAI, provider.Type())
+	assert.Equal(t, "deepseek-chat", provider.Name())
+	provider, err = New(Profile{Name: "本地", Provider: TypeOllama, Model: "qwen3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "本地", provider.Name())
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`data: {"id":"c1","model":"deepseek-reasoner","choices":[{"delta":{"reasoning_content":"思考"}}]}`,
+		``,
+		`data: {"id":"c1","model":"deepseek-reasoner","choices":[{"delta":{"content":"你好"}}]}`,
+		`data: {"id":"c1","model":"deepseek-reasoner","choices":[{"delta":{"content":"!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`,
+		`data: [DONE]`,
+	)
+	provider, _ := New(Profile{Provider: TypeOpenAI, BaseUrl: server.URL + "/v1/", ApiKey: "sk-test", Model: "deepseek-reasoner", MaxTokens: 1024, Temperature: 0.5})
+	content, reasoning, usage, err := collect(t, provider, Message{Role: RoleSystem, Content: "系统"}, Message{Role: RoleUser, Content: "问题"})
+	assert.NoError(t, err)
+	assert.Equal(t, "你好!", content)
+	assert.Equal(t, "思考", reasoning)
+	assert.Equal(t, &Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}, usage)
+
+	assert.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, "/v1/chat/completions", req.Path)
+	assert.Equal(t, "Bearer sk-test", req.Header.Get("Authorization"))
+	assert.Equal(t, "deepseek-reasoner", req.Body["model"])
+	assert.Equal(t, float64(1024), req.Body["max_tokens"])
+	assert.Equal(t, true, req.Body["stream"])
+	assert.Equal(t, map[string]any{"include_usage": true}, req.Body["stream_options"])
+	assert.Len(t, req.Body["messages"], 2)
+}
+
+func TestOpenAIProviderErrors(t *testing.T) {
+	server, _ := standIn(t, http.StatusUnauthorized, `{"error":{"message":"invalid api key"}}`)
+	provider, _ := New(Profile{BaseUrl: server.URL, Model: "m"})
+	_, _, _, err := collect(t, provider)
+	assert.ErrorContains(t, err, "401")
+	assert.ErrorContains(t, err, "invalid api key")
+
+	//状态码200但返回错误 JSON
+	server, _ = standIn(t, http.StatusOK, `{"code":500,"message":"余额不足"}`)
+	provider, _ = New(Profile{BaseUrl: server.URL, Model: "m"})
+	_, _, _, err = collect(t, provider)
+	assert.EqualError(t, err, "余额不足")
+
+	//不支持 stream_options 的接口返回400时去掉后重试一次
+	requests := make([]map[string]any, 0)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]any)
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		if _, ok := body["stream_options"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"unknown field stream_options"}}`))
+			return
+		}
+		w.Write([]byte(`data: {"choices":[{"delta":{"content":"好"},"finish_reason":"stop"}]}` + "\n"))
+	}))
+	defer server.Close()
+	provider, _ = New(Profile{BaseUrl: server.URL, Model: "m"})
+	content, _, _, err := collect(t, provider)
+	assert.NoError(t, err)
+	assert.Equal(t, "好", content)
+	assert.Len(t, requests, 2)
+}
+
+func TestEmit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan Event, 1)
+	assert.True(t, emit(ctx, ch, Event{Content: "a"}))
+	cancel()
+	//调用方停止读取后不再阻塞
+	assert.False(t, emit(ctx, ch, Event{Content: "b"}))
+	<-ch
+	//缓冲区有空位时取消后的错误仍能送达
+	assert.True(t, emit(ctx, ch, Event{Err: ctx.Err()}))
+}
+
+func TestAnthropicMessages(t *testing.T) {
+	text := func(s string) anthropicBlock { return anthropicBlock{Type: "text", Text: s} }
+	system, messages := anthropicMessages([]Message{
+		{Role: RoleSystem, Content: "你是分析师"},
+		{Role: RoleAssistant, Content: "当前时间"},
+		{Role: RoleAssistant, Content: "行情"},
+		{Role: RoleUser, Content: "问题1"},
+		{Role: RoleUser, Content: "问题2"},
+		{Role: RoleAssistant, Content: ""},
+	})
+	assert.Equal(t, "你是分析师", system)
+	assert.Equal(t, []anthropicMessage{
+		{Role: RoleUser, Content: []anthropicBlock{text("你好")}},
+		{Role: RoleAssistant, Content: []anthropicBlock{text("当前时间\n\n行情")}},
+		{Role: RoleUser, Content: []anthropicBlock{text("问题1\n\n问题2")}},
+	}, messages)
+
+	//工具调用和结果
+	_, messages = anthropicMessages([]Message{
+		{Role: RoleUser, Content: "分析"},
+		{Role: RoleAssistant, Content: "先看K线", ToolCalls: []ToolCall{{ID: "t1", Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`}, {ID: "t2", Name: "GetIndustryRank"}}},
+		{Role: RoleTool, ToolCallID: "t1", Name: "GetKLineData", Content: "K线"},
+		{Role: RoleTool, ToolCallID: "t2", Name: "GetIndustryRank", Content: "排行"},
+	})
+	assert.Equal(t, []anthropicMessage{
+		{Role: RoleUser, Content: []anthropicBlock{text("分析")}},
+		{Role: RoleAssistant, Content: []anthropicBlock{
+			text("先看K线"),
+			{Type: "tool_use", ID: "t1", Name: "GetKLineData", Input: json.RawMessage(`{"stockCode":"sh600000"}`)},
+			{Type: "tool_use", ID: "t2", Name: "GetIndustryRank", Input: json.RawMessage(`{}`)},
+		}},
+		{Role: RoleUser, Content: []anthropicBlock{
+			{Type: "tool_result", ToolUseID: "t1", Content: "K线"},
+			{Type: "tool_result", ToolUseID: "t2", Content: "排行"},
+		}},
+	}, messages)
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet","usage":{"input_tokens":25}}}`,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"分析"}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"看多"}}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+		`data: {"type":"message_stop"}`,
+	)
+	provider, _ := New(Profile{Provider: TypeAnthropic, BaseUrl: server.URL, ApiKey: "key", Model: "claude-sonnet"})
+	content, reasoning, usage, err := collect(t, provider, Message{Role: RoleSystem, Content: "系统"}, Message{Role: RoleUser, Content: "问题"})
+	assert.NoError(t, err)
+	assert.Equal(t, "看多", content)
+	assert.Equal(t, "分析", reasoning)
+	assert.Equal(t, &Usage{PromptTokens: 25, CompletionTokens: 7, TotalTokens: 32}, usage)
+
+	req := (*requests)[0]
+	assert.Equal(t, "/v1/messages", req.Path)
+	assert.Equal(t, "key", req.Header.Get("x-api-key"))
+	assert.Equal(t, anthropicVersion, req.Header.Get("anthropic-version"))
+	assert.Equal(t, "系统", req.Body["system"])
+	assert.Equal(t, float64(anthropicDefaultMaxTokens), req.Body["max_tokens"])
+	assert.Len(t, req.Body["messages"], 1)
+
+	server, _ = standIn(t, http.StatusOK, `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	provider, _ = New(Profile{Provider: TypeAnthropic, BaseUrl: server.URL, ApiKey: "key", Model: "claude-sonnet"})
+	_, _, _, err = collect(t, provider, Message{Role: RoleUser, Content: "问题"})
+	assert.EqualError(t, err, "Overloaded")
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"嗯"},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":"震荡"},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":4}`,
+	)
+	provider, _ := New(Profile{Provider: TypeOllama, BaseUrl: server.URL, Model: "qwen3", MaxTokens: 256, Temperature: 0.2})
+	content, reasoning, usage, err := collect(t, provider, Message{Role: RoleUser, Content: "问题"})
+	assert.NoError(t, err)
+	assert.Equal(t, "震荡", content)
+	assert.Equal(t, "嗯", reasoning)
+	assert.Equal(t, &Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}, usage)
+
+	req := (*requests)[0]
+	assert.Equal(t, "/api/chat", req.Path)
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Equal(t, map[string]any{"temperature": 0.2, "num_predict": float64(256)}, req.Body["options"])
+
+	server, _ = standIn(t, http.StatusOK, `{"error":"model 'qwen3' not found"}`)
+	provider, _ = New(Profile{Provider: TypeOllama, BaseUrl: server.URL, Model: "qwen3"})
+	_, _, _, err = collect(t, provider, Message{Role: RoleUser, Content: "问题"})
+	assert.EqualError(t, err, "model 'qwen3' not found")
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 4, EstimateTokens("贵州茅台"))
+	assert.Equal(t, 2, EstimateTokens("sh600519"))
+	assert.Equal(t, 7, EstimateTokens("贵州茅台 sh600519"))
+	assert.Equal(t, 2*messageOverhead+6, EstimateMessagesTokens([]Message{{Role: RoleUser, Content: "贵州茅台"}, {Role: RoleAssistant, Content: "sh600519"}}))
+}
+
+func TestUsageEstimator(t *testing.T) {
+	messages := []Message{{Role: RoleUser, Content: "分析贵州茅台"}}
+	estimator := NewUsageEstimator(messages)
+	call := ToolCall{ID: "1", Name: "kline", Arguments: `{"code":"sh600519"}`}
+	estimator.Add(Event{Content: "查询"})
+	estimator.Add(Event{ToolCalls: []ToolCall{call}})
+	estimator.Add(Event{ToolResult: &ToolResult{ID: "1", Name: "kline", Content: "收盘价1500"}})
+	estimator.Add(Event{Content: "看多"})
+	usage := estimator.Usage()
+
+	first := EstimateMessagesTokens(messages)
+	reply := Message{Role: RoleAssistant, Content: "查询", ToolCalls: []ToolCall{call}}
+	second := EstimateMessagesTokens(append(messages, reply, Message{Role: RoleTool, Content: "收盘价1500"}))
+	//第二轮重新发送了首轮消息、工具调用和工具结果
+	assert.Equal(t, first+second, usage.PromptTokens)
+	assert.Equal(t, EstimateMessagesTokens([]Message{reply})-messageOverhead+EstimateTokens("看多"), usage.CompletionTokens)
+	assert.Equal(t, usage.PromptTokens+usage.CompletionTokens, usage.TotalTokens)
+	assert.True(t, estimator.Estimated())
+
+	//返回用量的轮次使用返回值,未返回的轮次按估算累加
+	estimator = NewUsageEstimator(messages)
+	estimator.Add(Event{Content: "查询"})
+	estimator.Add(Event{ToolCalls: []ToolCall{call}})
+	estimator.Add(Event{Usage: &Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}})
+	estimator.Add(Event{ToolResult: &ToolResult{ID: "1", Name: "kline", Content: "收盘价1500"}})
+	estimator.Add(Event{Content: "看多"})
+	usage = estimator.Usage()
+	assert.Equal(t, 100+second, usage.PromptTokens)
+	assert.Equal(t, 20+EstimateTokens("看多"), usage.CompletionTokens)
+	assert.Equal(t, usage.PromptTokens+usage.CompletionTokens, usage.TotalTokens)
+	assert.True(t, estimator.Estimated())
+
+	estimator = NewUsageEstimator(messages)
+	estimator.Add(Event{Content: "看多"})
+	estimator.Add(Event{Usage: &Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}})
+	assert.Equal(t, Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}, estimator.Usage())
+	assert.False(t, estimator.Estimated())
+}
+
+func TestPromptBudget(t *testing.T) {
+	assert.Equal(t, (DefaultContextSize-defaultReplyTokens)*9/10, Profile{}.PromptBudget())
+	assert.Equal(t, 9000, Profile{ContextSize: 12000, MaxTokens: 2000}.PromptBudget())
+	assert.Equal(t, 0, Profile{ContextSize: 1000, MaxTokens: 2000}.PromptBudget())
+}
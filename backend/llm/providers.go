@@ -0,0 +1,513 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// openAIProvider OpenAI 兼容的 /chat/completions 接口(DeepSeek/通义/硅基流动等)
+type openAIProvider struct {
+	httpProvider
+}
+
+// openAITools 工具定义转换为 function calling 格式,Ollama 使用相同格式
+func openAITools(tools []Tool) []map[string]any {
+	res := make([]map[string]any, 0, len(tools))
+	for _, tool := range tools {
+		res = append(res, map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+	return res
+}
+
+func openAIMessages(messages []Message) []map[string]any {
+	res := make([]map[string]any, 0, len(messages))
+	for _, msg := range messages {
+		item := map[string]any{"role": msg.Role, "content": msg.Content}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]any, 0, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, map[string]any{
+					"id":       call.ID,
+					"type":     "function",
+					"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+				})
+			}
+			item["tool_calls"] = calls
+		}
+		if msg.Role == RoleTool {
+			item["tool_call_id"] = msg.ToolCallID
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func (p *openAIProvider) ChatStream(ctx context.Context, req Request) (<-chan Event, error) {
+	body := map[string]any{
+		"model":       p.profile.Model,
+		"temperature": p.profile.Temperature,
+		"stream":      true,
+		"messages":    openAIMessages(req.Messages),
+		//流式响应默认不返回用量
+		"stream_options": map[string]any{"include_usage": true},
+	}
+	if p.profile.MaxTokens > 0 {
+		body["max_tokens"] = p.profile.MaxTokens
+	}
+	if len(req.Tools) > 0 {
+		body["tools"] = openAITools(req.Tools)
+	}
+	headers := map[string]string{}
+	if p.profile.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + p.profile.ApiKey
+	}
+	url := p.baseURL("") + "/chat/completions"
+	raw, err := p.stream(ctx, url, body, headers)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusBadRequest {
+		//部分兼容接口不支持 stream_options,去掉后重试一次
+		delete(body, "stream_options")
+		raw, err = p.stream(ctx, url, body, headers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event, 64)
+	var id, model string
+	//工具调用的参数分多个片段返回,按 index 拼接
+	pending := make([]ToolCall, 0)
+	flush := func() {
+		if len(pending) > 0 {
+			emit(ctx, ch, Event{ID: id, Model: model, ToolCalls: pending})
+			pending = make([]ToolCall, 0)
+		}
+	}
+	go readLines(ctx, raw, ch, func(line string) bool {
+		data, ok := sseData(line)
+		if !ok {
+			//部分接口出错时直接返回 JSON
+			if message := errorMessage(line); message != "" {
+				emit(ctx, ch, Event{Err: errors.New(message)})
+				return false
+			}
+			return true
+		}
+		if data == "[DONE]" {
+			return false
+		}
+		var chunk struct {
+			Id      string `json:"id"`
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+					ToolCalls        []struct {
+						Index    int    `json:"index"`
+						Id       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+				TotalTokens      int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			emit(ctx, ch, Event{Err: fmt.Errorf("响应格式错误: %s", truncate(data, 200))})
+			return false
+		}
+		id, model = chunk.Id, chunk.Model
+		for _, choice := range chunk.Choices {
+			for _, delta := range choice.Delta.ToolCalls {
+				for len(pending) <= delta.Index {
+					pending = append(pending, ToolCall{})
+				}
+				call := &pending[delta.Index]
+				if delta.Id != "" {
+					call.ID = delta.Id
+				}
+				call.Name += delta.Function.Name
+				call.Arguments += delta.Function.Arguments
+			}
+			event := Event{
+				ID:           chunk.Id,
+				Model:        chunk.Model,
+				Content:      choice.Delta.Content,
+				Reasoning:    choice.Delta.ReasoningContent,
+				FinishReason: choice.FinishReason,
+			}
+			if (event.Content != "" || event.Reasoning != "" || event.FinishReason != "") && !emit(ctx, ch, event) {
+				return false
+			}
+			if choice.FinishReason != "" {
+				flush()
+			}
+		}
+		if chunk.Usage != nil {
+			return emit(ctx, ch, Event{ID: chunk.Id, Model: chunk.Model, Usage: &Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}})
+		}
+		return true
+	}, flush)
+	return ch, nil
+}
+
+// errorMessage 解析 {"error":{"message":...}} 或 {"message":...} 格式的错误
+func errorMessage(line string) string {
+	var res struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal([]byte(line), &res) != nil {
+		return ""
+	}
+	if len(res.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(res.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+		var text string
+		if json.Unmarshal(res.Error, &text) == nil && text != "" {
+			return text
+		}
+	}
+	return res.Message
+}
+
+// argumentsObject 工具参数转换为 JSON 对象,为空或不合法时返回空对象
+func argumentsObject(arguments string) json.RawMessage {
+	if json.Valid([]byte(arguments)) && strings.HasPrefix(strings.TrimSpace(arguments), "{") {
+		return json.RawMessage(arguments)
+	}
+	return json.RawMessage("{}")
+}
+
+// anthropicVersion Anthropic Messages API 版本
+const anthropicVersion = "2023-06-01"
+
+// anthropicDefaultMaxTokens Messages API 要求必须指定 max_tokens
+const anthropicDefaultMaxTokens = 4096
+
+// anthropicProvider Anthropic Messages API
+type anthropicProvider struct {
+	httpProvider
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicMessages system 消息合并为顶层 system 参数,工具结果作为 user 消息回传,
+// 连续相同角色的消息合并为一条,第一条消息必须是 user
+func anthropicMessages(messages []Message) (string, []anthropicMessage) {
+	system := make([]string, 0)
+	res := make([]anthropicMessage, 0, len(messages))
+	for _, msg := range messages {
+		role := msg.Role
+		blocks := make([]anthropicBlock, 0, 1+len(msg.ToolCalls))
+		switch msg.Role {
+		case RoleSystem:
+			system = append(system, msg.Content)
+			continue
+		case RoleTool:
+			role = RoleUser
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: argumentsObject(call.Arguments)})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if len(res) > 0 && res[len(res)-1].Role == role {
+			last := &res[len(res)-1]
+			n := len(last.Content)
+			//相邻的文本合并为一段
+			if n > 0 && last.Content[n-1].Type == "text" && blocks[0].Type == "text" {
+				last.Content[n-1].Text += "\n\n" + blocks[0].Text
+				blocks = blocks[1:]
+			}
+			last.Content = append(last.Content, blocks...)
+			continue
+		}
+		if len(res) == 0 && role != RoleUser {
+			res = append(res, anthropicMessage{Role: RoleUser, Content: []anthropicBlock{{Type: "text", Text: "你好"}}})
+		}
+		res = append(res, anthropicMessage{Role: role, Content: blocks})
+	}
+	return strings.Join(system, "\n\n"), res
+}
+
+func (p *anthropicProvider) ChatStream(ctx context.Context, req Request) (<-chan Event, error) {
+	system, messages := anthropicMessages(req.Messages)
+	maxTokens := p.profile.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	body := map[string]any{
+		"model":       p.profile.Model,
+		"max_tokens":  maxTokens,
+		"temperature": p.profile.Temperature,
+		"stream":      true,
+		"messages":    messages,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, map[string]any{"name": tool.Name, "description": tool.Description, "input_schema": tool.Parameters})
+		}
+		body["tools"] = tools
+	}
+	raw, err := p.stream(ctx, p.baseURL("https://api.anthropic.com")+"/v1/messages", body, map[string]string{
+		"x-api-key":         p.profile.ApiKey,
+		"anthropic-version": anthropicVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event, 64)
+	var id, model string
+	usage := &Usage{}
+	//content block 序号 -> 工具调用
+	pending := make(map[int]*ToolCall)
+	order := make([]int, 0)
+	flush := func() {
+		if len(order) == 0 {
+			return
+		}
+		calls := make([]ToolCall, 0, len(order))
+		for _, index := range order {
+			call := *pending[index]
+			if call.Arguments == "" {
+				call.Arguments = "{}"
+			}
+			calls = append(calls, call)
+		}
+		emit(ctx, ch, Event{ID: id, Model: model, ToolCalls: calls})
+		pending = make(map[int]*ToolCall)
+		order = order[:0]
+	}
+	go readLines(ctx, raw, ch, func(line string) bool {
+		data, ok := sseData(line)
+		if !ok {
+			return true
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Index   int    `json:"index"`
+			Message struct {
+				Id    string `json:"id"`
+				Model string `json:"model"`
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			ContentBlock struct {
+				Type string `json:"type"`
+				Id   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"content_block"`
+			Delta struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				Thinking    string `json:"thinking"`
+				PartialJson string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			emit(ctx, ch, Event{Err: fmt.Errorf("响应格式错误: %s", truncate(data, 200))})
+			return false
+		}
+		switch event.Type {
+		case "message_start":
+			id, model = event.Message.Id, event.Message.Model
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				pending[event.Index] = &ToolCall{ID: event.ContentBlock.Id, Name: event.ContentBlock.Name}
+				order = append(order, event.Index)
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return emit(ctx, ch, Event{ID: id, Model: model, Content: event.Delta.Text})
+			case "thinking_delta":
+				return emit(ctx, ch, Event{ID: id, Model: model, Reasoning: event.Delta.Thinking})
+			case "input_json_delta":
+				if call, ok := pending[event.Index]; ok {
+					call.Arguments += event.Delta.PartialJson
+				}
+			}
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+			if event.Delta.StopReason != "" {
+				return emit(ctx, ch, Event{ID: id, Model: model, FinishReason: event.Delta.StopReason})
+			}
+		case "message_stop":
+			flush()
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+			emit(ctx, ch, Event{ID: id, Model: model, Usage: usage})
+			return false
+		case "error":
+			emit(ctx, ch, Event{Err: errors.New(event.Error.Message)})
+			return false
+		}
+		return true
+	}, flush)
+	return ch, nil
+}
+
+// ollamaProvider Ollama 原生 /api/chat 接口,响应为逐行 JSON
+type ollamaProvider struct {
+	httpProvider
+}
+
+// ollamaMessages 工具参数为 JSON 对象,工具结果使用 tool_name 标识
+func ollamaMessages(messages []Message) []map[string]any {
+	res := make([]map[string]any, 0, len(messages))
+	for _, msg := range messages {
+		item := map[string]any{"role": msg.Role, "content": msg.Content}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]any, 0, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, map[string]any{
+					"function": map[string]any{"name": call.Name, "arguments": argumentsObject(call.Arguments)},
+				})
+			}
+			item["tool_calls"] = calls
+		}
+		if msg.Role == RoleTool {
+			item["tool_name"] = msg.Name
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+func (p *ollamaProvider) ChatStream(ctx context.Context, req Request) (<-chan Event, error) {
+	options := map[string]any{"temperature": p.profile.Temperature}
+	if p.profile.MaxTokens > 0 {
+		options["num_predict"] = p.profile.MaxTokens
+	}
+	headers := map[string]string{}
+	if p.profile.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + p.profile.ApiKey
+	}
+	body := map[string]any{
+		"model":    p.profile.Model,
+		"messages": ollamaMessages(req.Messages),
+		"stream":   true,
+		"options":  options,
+	}
+	if len(req.Tools) > 0 {
+		body["tools"] = openAITools(req.Tools)
+	}
+	raw, err := p.stream(ctx, p.baseURL("http://localhost:11434")+"/api/chat", body, headers)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event, 64)
+	//Ollama 不返回调用ID,按顺序生成
+	seq := 0
+	go readLines(ctx, raw, ch, func(line string) bool {
+		var chunk struct {
+			Model   string `json:"model"`
+			Message struct {
+				Content   string `json:"content"`
+				Thinking  string `json:"thinking"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			Done            bool   `json:"done"`
+			DoneReason      string `json:"done_reason"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+			Error           string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			emit(ctx, ch, Event{Err: fmt.Errorf("响应格式错误: %s", truncate(line, 200))})
+			return false
+		}
+		if chunk.Error != "" {
+			emit(ctx, ch, Event{Err: errors.New(chunk.Error)})
+			return false
+		}
+		if (chunk.Message.Content != "" || chunk.Message.Thinking != "") &&
+			!emit(ctx, ch, Event{Model: chunk.Model, Content: chunk.Message.Content, Reasoning: chunk.Message.Thinking}) {
+			return false
+		}
+		if len(chunk.Message.ToolCalls) > 0 {
+			calls := make([]ToolCall, 0, len(chunk.Message.ToolCalls))
+			for _, call := range chunk.Message.ToolCalls {
+				seq++
+				calls = append(calls, ToolCall{ID: "call_" + strconv.Itoa(seq), Name: call.Function.Name, Arguments: string(argumentsObject(string(call.Function.Arguments)))})
+			}
+			if !emit(ctx, ch, Event{Model: chunk.Model, ToolCalls: calls}) {
+				return false
+			}
+		}
+		if chunk.Done {
+			emit(ctx, ch, Event{Model: chunk.Model, FinishReason: chunk.DoneReason, Usage: &Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}})
+			return false
+		}
+		return true
+	}, nil)
+	return ch, nil
+}
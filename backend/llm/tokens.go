@@ -0,0 +1,126 @@
+package llm
+
+import "unicode"
+
+const (
+	// DefaultContextSize 未配置上下文长度时使用
+	DefaultContextSize = 64000
+	// defaultReplyTokens 未配置最大输出时为回复预留的token数
+	defaultReplyTokens = 4096
+)
+
+// messageOverhead 每条消息角色等格式占用的token数
+const messageOverhead = 4
+
+// EstimateTokens 粗略估算文本的token数:中日韩文字每字按1个计算,其他字符每4个按1个计算
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessagesTokens 估算一组消息的token数
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, message := range messages {
+		total += messageOverhead + EstimateTokens(message.Content)
+		for _, call := range message.ToolCalls {
+			total += EstimateTokens(call.Name) + EstimateTokens(call.Arguments)
+		}
+	}
+	return total
+}
+
+// UsageEstimator 按轮统计对话用量,接口返回用量的轮次使用返回值,未返回的轮次按对话事件估算。
+// 工具调用时每一轮都会把之前的消息、工具调用和结果重新发送,每轮分别计算提示词和回复的token数后累加
+type UsageEstimator struct {
+	messages  []Message
+	open      bool
+	prompt    int
+	content   string
+	calls     []ToolCall
+	reported  *Usage
+	usage     Usage
+	estimated bool
+}
+
+// NewUsageEstimator messages 为首轮请求的消息
+func NewUsageEstimator(messages []Message) *UsageEstimator {
+	return &UsageEstimator{messages: append([]Message{}, messages...)}
+}
+
+// Add 按顺序加入 ChatStream/ChatWithTools 输出的事件
+func (e *UsageEstimator) Add(event Event) {
+	if event.ToolResult != nil {
+		e.closeRound()
+		e.messages = append(e.messages, Message{Role: RoleTool, Content: event.ToolResult.Content, ToolCallID: event.ToolResult.ID, Name: event.ToolResult.Name})
+		return
+	}
+	if !e.open {
+		e.open = true
+		e.prompt = EstimateMessagesTokens(e.messages)
+	}
+	e.content += event.Content + event.Reasoning
+	e.calls = append(e.calls, event.ToolCalls...)
+	if event.Usage != nil {
+		if e.reported == nil {
+			e.reported = &Usage{}
+		}
+		e.reported.PromptTokens += event.Usage.PromptTokens
+		e.reported.CompletionTokens += event.Usage.CompletionTokens
+		e.reported.TotalTokens += event.Usage.TotalTokens
+	}
+}
+
+// closeRound 一轮回复结束,累加本轮用量,回复内容和工具调用作为下一轮请求的消息
+func (e *UsageEstimator) closeRound() {
+	if !e.open {
+		return
+	}
+	e.open = false
+	reply := Message{Role: RoleAssistant, Content: e.content, ToolCalls: e.calls}
+	if e.reported != nil {
+		e.usage.PromptTokens += e.reported.PromptTokens
+		e.usage.CompletionTokens += e.reported.CompletionTokens
+		e.usage.TotalTokens += e.reported.TotalTokens
+	} else {
+		completion := EstimateMessagesTokens([]Message{reply}) - messageOverhead
+		e.usage.PromptTokens += e.prompt
+		e.usage.CompletionTokens += completion
+		e.usage.TotalTokens += e.prompt + completion
+		e.estimated = true
+	}
+	e.messages = append(e.messages, reply)
+	e.content, e.calls, e.reported = "", nil, nil
+}
+
+// Usage 截至目前的用量
+func (e *UsageEstimator) Usage() Usage {
+	e.closeRound()
+	return e.usage
+}
+
+// Estimated 是否有轮次的用量是估算的
+func (e *UsageEstimator) Estimated() bool {
+	e.closeRound()
+	return e.estimated
+}
+
+// PromptBudget 提示词可用的token数:上下文长度减去为回复预留的token数,再留出1/10余量弥补估算误差
+func (p Profile) PromptBudget() int {
+	size := p.ContextSize
+	if size <= 0 {
+		size = DefaultContextSize
+	}
+	reply := p.MaxTokens
+	if reply <= 0 {
+		reply = defaultReplyTokens
+	}
+	return max((size-reply)*9/10, 0)
+}
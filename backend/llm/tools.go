@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Schema 工具参数定义,JSON Schema 的子集
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Tool 提供给模型调用的工具
+type Tool struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Parameters  *Schema `json:"parameters"`
+}
+
+// ToolCall 模型请求的工具调用,Arguments 为 JSON 对象
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResult 工具执行结果
+type ToolResult struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Content   string `json:"content"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ToolHandler 工具实现,args 已按参数定义校验
+type ToolHandler func(ctx context.Context, args map[string]any) (string, error)
+
+// ValidateArguments 按参数定义解析和校验工具参数:必填项、类型、枚举和取值范围,
+// 整数参数转换为 int64,未定义的参数忽略
+func ValidateArguments(schema *Schema, arguments string) (map[string]any, error) {
+	raw := make(map[string]any)
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &raw); err != nil {
+			return nil, fmt.Errorf("参数不是合法的JSON对象:%s", truncate(arguments, 100))
+		}
+	}
+	if schema == nil {
+		return raw, nil
+	}
+	args := make(map[string]any, len(raw))
+	for _, name := range schema.Required {
+		if v, ok := raw[name]; !ok || v == nil {
+			return nil, fmt.Errorf("缺少参数 %s", name)
+		}
+	}
+	for name, property := range schema.Properties {
+		v, ok := raw[name]
+		if !ok || v == nil {
+			continue
+		}
+		value, err := validateValue(property, v)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s %w", name, err)
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+func validateValue(schema *Schema, v any) (any, error) {
+	switch schema.Type {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("应为字符串")
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			return nil, fmt.Errorf("应为 %s 之一", strings.Join(schema.Enum, "/"))
+		}
+		return s, nil
+	case "integer", "number":
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("应为数字")
+		}
+		if schema.Type == "integer" && f != math.Trunc(f) {
+			return nil, errors.New("应为整数")
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return nil, fmt.Errorf("不能小于 %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return nil, fmt.Errorf("不能大于 %v", *schema.Maximum)
+		}
+		if schema.Type == "integer" {
+			return int64(f), nil
+		}
+		return f, nil
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("应为布尔值")
+		}
+		return b, nil
+	}
+	return v, nil
+}
+
+// Toolbox 一组可调用的工具
+type Toolbox struct {
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+func NewToolbox() *Toolbox {
+	return &Toolbox{handlers: make(map[string]ToolHandler)}
+}
+
+// Register 注册工具,同名工具覆盖
+func (b *Toolbox) Register(tool Tool, handler ToolHandler) *Toolbox {
+	if _, ok := b.handlers[tool.Name]; !ok {
+		b.tools = append(b.tools, tool)
+	}
+	b.handlers[tool.Name] = handler
+	return b
+}
+
+func (b *Toolbox) Tools() []Tool {
+	return b.tools
+}
+
+// Call 校验参数后执行工具,工具执行时 panic 作为调用失败返回,不影响对话
+func (b *Toolbox) Call(ctx context.Context, call ToolCall) (content string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			content, err = "", fmt.Errorf("工具 %s 执行异常:%v", call.Name, r)
+		}
+	}()
+	handler, ok := b.handlers[call.Name]
+	if !ok {
+		return "", fmt.Errorf("工具 %s 不存在", call.Name)
+	}
+	var schema *Schema
+	for _, tool := range b.tools {
+		if tool.Name == call.Name {
+			schema = tool.Parameters
+		}
+	}
+	args, err := ValidateArguments(schema, call.Arguments)
+	if err != nil {
+		return "", err
+	}
+	return handler(ctx, args)
+}
+
+// ToolCallLimitMessage 超过调用次数时返回给模型的内容
+const ToolCallLimitMessage = "工具调用次数已达上限,请根据已获取的信息直接回答"
+
+// ChatWithTools 带工具调用的流式对话:模型请求调用工具时执行工具并把结果回传给模型,直到模型给出最终回答。
+// maxCalls 为本次对话最多执行的工具调用次数,超过后工具返回 ToolCallLimitMessage,模型仍继续请求时结束对话。
+// 返回的 channel 依次输出模型回复、工具调用请求(ToolCalls)和工具结果(ToolResult)
+func ChatWithTools(ctx context.Context, provider LLMProvider, req Request, box *Toolbox, maxCalls int) (<-chan Event, error) {
+	messages := slices.Clone(req.Messages)
+	req.Tools = box.Tools()
+	events, err := provider.ChatStream(ctx, Request{Messages: messages, Tools: req.Tools})
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event, 64)
+	go func() {
+		defer close(ch)
+		calls := 0
+		limited := false
+		for {
+			var content strings.Builder
+			toolCalls := make([]ToolCall, 0)
+			for event := range events {
+				content.WriteString(event.Content)
+				toolCalls = append(toolCalls, event.ToolCalls...)
+				if !emit(ctx, ch, event) || event.Err != nil {
+					return
+				}
+			}
+			if len(toolCalls) == 0 {
+				return
+			}
+			if limited {
+				emit(ctx, ch, Event{Err: errors.New("模型超过工具调用次数上限仍在请求调用工具")})
+				return
+			}
+			messages = append(messages, Message{Role: RoleAssistant, Content: content.String(), ToolCalls: toolCalls})
+			for _, call := range toolCalls {
+				result := &ToolResult{ID: call.ID, Name: call.Name, Arguments: call.Arguments}
+				if calls >= maxCalls {
+					limited = true
+					result.Content = ToolCallLimitMessage
+				} else {
+					calls++
+					result.Content, err = box.Call(ctx, call)
+					if err != nil {
+						result.Error = err.Error()
+						result.Content = "调用失败:" + err.Error()
+					}
+				}
+				if !emit(ctx, ch, Event{ToolResult: result}) {
+					return
+				}
+				messages = append(messages, Message{Role: RoleTool, Content: result.Content, ToolCallID: call.ID, Name: call.Name})
+			}
+			if ctx.Err() != nil {
+				emit(ctx, ch, Event{Err: ctx.Err()})
+				return
+			}
+			events, err = provider.ChatStream(ctx, Request{Messages: messages, Tools: req.Tools})
+			if err != nil {
+				emit(ctx, ch, Event{Err: err})
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
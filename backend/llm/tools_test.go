@@ -0,0 +1,246 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(v float64) *float64 { return &v }
+
+var klineTool = Tool{
+	Name:        "GetKLineData",
+	Description: "K线",
+	Parameters: &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"stockCode": {Type: "string"},
+			"period":    {Type: "string", Enum: []string{"day", "week"}},
+			"days":      {Type: "integer", Minimum: float(1), Maximum: float(365)},
+			"adjust":    {Type: "boolean"},
+		},
+		Required: []string{"stockCode"},
+	},
+}
+
+func TestValidateArguments(t *testing.T) {
+	args, err := ValidateArguments(klineTool.Parameters, `{"stockCode":"sh600000","days":30,"period":"week","extra":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"stockCode": "sh600000", "days": int64(30), "period": "week"}, args)
+
+	for arguments, message := range map[string]string{
+		`{}`:                                    "缺少参数 stockCode",
+		`{"stockCode":600000}`:                  "参数 stockCode 应为字符串",
+		`{"stockCode":"sh600000","days":1.5}`:   "参数 days 应为整数",
+		`{"stockCode":"sh600000","days":0}`:     "参数 days 不能小于 1",
+		`{"stockCode":"sh600000","days":999}`:   "参数 days 不能大于 365",
+		`{"stockCode":"sh600000","period":"m"}`: "参数 period 应为 day/week 之一",
+		`{"stockCode":"sh600000","adjust":"y"}`: "参数 adjust 应为布尔值",
+	} {
+		_, err := ValidateArguments(klineTool.Parameters, arguments)
+		assert.EqualError(t, err, message, arguments)
+	}
+	_, err = ValidateArguments(klineTool.Parameters, `{"stockCode":`)
+	assert.ErrorContains(t, err, "不是合法的JSON对象")
+	args, err = ValidateArguments(nil, "")
+	assert.NoError(t, err)
+	assert.Empty(t, args)
+}
+
+// scriptedProvider 按顺序返回预设回复的模型替身,记录每次请求
+type scriptedProvider struct {
+	replies  [][]Event
+	requests []Request
+}
+
+func (p *scriptedProvider) Name() string { return "scripted" }
+func (p *scriptedProvider) Type() string { return "scripted" }
+func (p *scriptedProvider) ChatStream(ctx context.Context, req Request) (<-chan Event, error) {
+	p.requests = append(p.requests, req)
+	if len(p.requests) > len(p.replies) {
+		return nil, errors.New("no more replies")
+	}
+	events := p.replies[len(p.requests)-1]
+	ch := make(chan Event, len(events))
+	for _, event := range events {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatWithTools(t *testing.T) {
+	provider := &scriptedProvider{replies: [][]Event{
+		{{Content: "先查K线"}, {ToolCalls: []ToolCall{
+			{ID: "1", Name: "GetKLineData", Arguments: `{"stockCode":"sh600000","days":5}`},
+			{ID: "2", Name: "GetKLineData", Arguments: `{"days":5}`},
+			{ID: "3", Name: "Unknown"},
+		}}},
+		{{Content: "结论:震荡"}},
+	}}
+	calls := 0
+	box := NewToolbox().Register(klineTool, func(ctx context.Context, args map[string]any) (string, error) {
+		calls++
+		return "K线:" + args["stockCode"].(string), nil
+	})
+	events, err := ChatWithTools(context.Background(), provider, Request{Messages: []Message{{Role: RoleUser, Content: "分析"}}}, box, 5)
+	assert.NoError(t, err)
+	var content strings.Builder
+	results := make([]*ToolResult, 0)
+	for event := range events {
+		assert.NoError(t, event.Err)
+		content.WriteString(event.Content)
+		if event.ToolResult != nil {
+			results = append(results, event.ToolResult)
+		}
+	}
+	assert.Equal(t, "先查K线结论:震荡", content.String())
+	assert.Equal(t, 1, calls)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "K线:sh600000", results[0].Content)
+	assert.Equal(t, "缺少参数 stockCode", results[1].Error)
+	assert.Equal(t, "工具 Unknown 不存在", results[2].Error)
+
+	//第二次请求带上工具调用和结果
+	assert.Len(t, provider.requests, 2)
+	assert.Equal(t, box.Tools(), provider.requests[0].Tools)
+	second := provider.requests[1].Messages
+	assert.Len(t, second, 5)
+	assert.Equal(t, Message{Role: RoleAssistant, Content: "先查K线", ToolCalls: provider.replies[0][1].ToolCalls}, second[1])
+	assert.Equal(t, Message{Role: RoleTool, Content: "K线:sh600000", ToolCallID: "1", Name: "GetKLineData"}, second[2])
+}
+
+func TestToolboxCallRecover(t *testing.T) {
+	box := NewToolbox().Register(klineTool, func(ctx context.Context, args map[string]any) (string, error) {
+		var data map[string]any
+		return data["day"].(string), nil
+	})
+	content, err := box.Call(context.Background(), ToolCall{Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`})
+	assert.Empty(t, content)
+	assert.ErrorContains(t, err, "工具 GetKLineData 执行异常")
+}
+
+func TestChatWithToolsBudget(t *testing.T) {
+	call := func(id string) []Event {
+		return []Event{{ToolCalls: []ToolCall{{ID: id, Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`}}}}
+	}
+	box := NewToolbox().Register(klineTool, func(ctx context.Context, args map[string]any) (string, error) {
+		return "K线", nil
+	})
+	//超过次数后模型给出回答
+	provider := &scriptedProvider{replies: [][]Event{call("1"), call("2"), {{Content: "回答"}}}}
+	events, _ := ChatWithTools(context.Background(), provider, Request{}, box, 1)
+	results := make([]string, 0)
+	for event := range events {
+		assert.NoError(t, event.Err)
+		if event.ToolResult != nil {
+			results = append(results, event.ToolResult.Content)
+		}
+	}
+	assert.Equal(t, []string{"K线", ToolCallLimitMessage}, results)
+
+	//超过次数后仍请求调用工具时结束
+	provider = &scriptedProvider{replies: [][]Event{call("1"), call("2"), call("3")}}
+	events, _ = ChatWithTools(context.Background(), provider, Request{}, box, 1)
+	var last error
+	for event := range events {
+		if event.Err != nil {
+			last = event.Err
+		}
+	}
+	assert.ErrorContains(t, last, "工具调用次数上限")
+	assert.Len(t, provider.requests, 3)
+}
+
+func TestOpenAIProviderToolCalls(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`data: {"id":"c1","choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"GetKLineData","arguments":"{\"stock"}}]}}]}`,
+		`data: {"id":"c1","choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"Code\":\"sh600000\"}"}},{"index":1,"id":"call_b","function":{"name":"GetIndustryRank","arguments":"{}"}}]}}]}`,
+		`data: {"id":"c1","choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	)
+	provider, _ := New(Profile{BaseUrl: server.URL, Model: "m"})
+	events, err := provider.ChatStream(context.Background(), Request{
+		Messages: []Message{
+			{Role: RoleUser, Content: "问题"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "x", Name: "GetKLineData", Arguments: "{}"}}},
+			{Role: RoleTool, ToolCallID: "x", Name: "GetKLineData", Content: "K线"},
+		},
+		Tools: []Tool{klineTool},
+	})
+	assert.NoError(t, err)
+	calls := make([]ToolCall, 0)
+	for event := range events {
+		calls = append(calls, event.ToolCalls...)
+	}
+	assert.Equal(t, []ToolCall{
+		{ID: "call_a", Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`},
+		{ID: "call_b", Name: "GetIndustryRank", Arguments: "{}"},
+	}, calls)
+
+	body := (*requests)[0].Body
+	tools := body["tools"].([]any)
+	assert.Equal(t, "GetKLineData", tools[0].(map[string]any)["function"].(map[string]any)["name"])
+	messages := body["messages"].([]any)
+	assert.Equal(t, "x", messages[1].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["id"])
+	assert.Equal(t, "x", messages[2].(map[string]any)["tool_call_id"])
+}
+
+func TestAnthropicProviderToolUse(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude","usage":{"input_tokens":5}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"查询"}}`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"GetKLineData","input":{}}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"stockCode\":"}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"sh600000\"}"}}`,
+		`data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_2","name":"GetIndustryRank","input":{}}}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`data: {"type":"message_stop"}`,
+	)
+	provider, _ := New(Profile{Provider: TypeAnthropic, BaseUrl: server.URL, ApiKey: "key", Model: "claude"})
+	events, err := provider.ChatStream(context.Background(), Request{Messages: []Message{{Role: RoleUser, Content: "问题"}}, Tools: []Tool{klineTool}})
+	assert.NoError(t, err)
+	calls := make([]ToolCall, 0)
+	for event := range events {
+		calls = append(calls, event.ToolCalls...)
+	}
+	assert.Equal(t, []ToolCall{
+		{ID: "toolu_1", Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`},
+		{ID: "toolu_2", Name: "GetIndustryRank", Arguments: "{}"},
+	}, calls)
+	tools := (*requests)[0].Body["tools"].([]any)
+	assert.Equal(t, "GetKLineData", tools[0].(map[string]any)["name"])
+	assert.NotNil(t, tools[0].(map[string]any)["input_schema"])
+}
+
+func TestOllamaProviderToolCalls(t *testing.T) {
+	server, requests := standIn(t, http.StatusOK,
+		`{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"GetKLineData","arguments":{"stockCode":"sh600000"}}}]},"done":false}`,
+		`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`,
+	)
+	provider, _ := New(Profile{Provider: TypeOllama, BaseUrl: server.URL, Model: "qwen3"})
+	events, err := provider.ChatStream(context.Background(), Request{
+		Messages: []Message{
+			{Role: RoleUser, Content: "问题"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "x", Name: "GetKLineData", Arguments: `{"stockCode":"sz000001"}`}}},
+			{Role: RoleTool, ToolCallID: "x", Name: "GetKLineData", Content: "K线"},
+		},
+		Tools: []Tool{klineTool},
+	})
+	assert.NoError(t, err)
+	calls := make([]ToolCall, 0)
+	for event := range events {
+		calls = append(calls, event.ToolCalls...)
+	}
+	assert.Equal(t, []ToolCall{{ID: "call_1", Name: "GetKLineData", Arguments: `{"stockCode":"sh600000"}`}}, calls)
+
+	messages := (*requests)[0].Body["messages"].([]any)
+	arguments := messages[1].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["arguments"]
+	assert.Equal(t, map[string]any{"stockCode": "sz000001"}, arguments)
+	assert.Equal(t, "GetKLineData", messages[2].(map[string]any)["tool_name"])
+}
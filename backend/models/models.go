@@ -57,16 +57,18 @@ type TelegraphTags struct {
 }
 type PromptTemplate struct {
 	gorm.Model
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	Type    string `json:"type"`
-	ID      uint   `json:"id"`
+	Name            string `json:"name"`
+	Content         string `json:"content"`
+	Type            string `json:"type"`
+	ID              uint   `json:"id"`
+	ContextSections string `json:"contextSections"` //AI分析时发送的数据,逗号分隔,为空时发送全部
 }
 type Prompt struct {
-	ID      uint   `json:"id"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	Type    string `json:"type"`
+	ID              uint   `json:"id"`
+	Name            string `json:"name"`
+	Content         string `json:"content"`
+	Type            string `json:"type"`
+	ContextSections string `json:"contextSections"`
 }
 type Tags struct {
 	gorm.Model
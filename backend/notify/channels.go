@@ -0,0 +1,245 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// httpChannel 基于 HTTP 接口的渠道
+type httpChannel struct {
+	config Config
+	client *resty.Client
+}
+
+func (c *httpChannel) Name() string {
+	if c.config.Name != "" {
+		return c.config.Name
+	}
+	return c.config.Type
+}
+
+func (c *httpChannel) Type() string {
+	return c.config.Type
+}
+
+// baseURL 配置的服务器地址,未配置时使用默认地址
+func (c *httpChannel) baseURL(defaultURL string) string {
+	if c.config.URL != "" {
+		return strings.TrimRight(c.config.URL, "/")
+	}
+	return defaultURL
+}
+
+// post 发送 JSON 请求,非2xx状态码时返回错误,result 不为空时解析响应
+func (c *httpChannel) post(ctx context.Context, url string, body any, result any, headers map[string]string) error {
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(headers).
+		SetBody(body).
+		Post(url)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("%s 响应状态码 %d: %s", c.config.Type, resp.StatusCode(), truncate(resp.String(), 200))
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Body(), result); err != nil {
+			return fmt.Errorf("%s 响应格式错误: %s", c.config.Type, truncate(resp.String(), 200))
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+func markdown(msg Message) string {
+	if msg.Title == "" {
+		return msg.Content
+	}
+	return "### " + msg.Title + "\n\n" + msg.Content
+}
+
+// weComChannel 企业微信群机器人
+type weComChannel struct{ httpChannel }
+
+func (c *weComChannel) Send(ctx context.Context, msg Message) error {
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	body := map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": markdown(msg)},
+	}
+	if err := c.post(ctx, c.config.URL, body, &res, nil); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("企业微信发送失败 %d: %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// dingDingChannel 钉钉群机器人
+type dingDingChannel struct{ httpChannel }
+
+// DingTalkSign 钉钉加签:以 secret 为密钥对 timestamp+"\n"+secret 做 HmacSHA256 后 base64,timestamp 为毫秒
+func DingTalkSign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// DingTalkSignedURL 在机器人地址上附加 timestamp 和 sign 参数,secret 为空时原样返回
+func DingTalkSignedURL(robotURL, secret string, now time.Time) string {
+	if secret == "" {
+		return robotURL
+	}
+	timestamp := now.UnixMilli()
+	sep := "&"
+	if !strings.Contains(robotURL, "?") {
+		sep = "?"
+	}
+	return robotURL + sep + "timestamp=" + strconv.FormatInt(timestamp, 10) + "&sign=" + url.QueryEscape(DingTalkSign(timestamp, secret))
+}
+
+func (c *dingDingChannel) Send(ctx context.Context, msg Message) error {
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	body := map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": "go-stock " + msg.Title, "text": markdown(msg)},
+	}
+	if err := c.post(ctx, DingTalkSignedURL(c.config.URL, c.config.Secret, time.Now()), body, &res, nil); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("钉钉发送失败 %d: %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// feishuChannel 飞书/Lark 群机器人
+type feishuChannel struct{ httpChannel }
+
+// feishuSign 飞书签名:以 timestamp+"\n"+secret 为密钥对空字符串做 HmacSHA256 后 base64
+func feishuSign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(strconv.FormatInt(timestamp, 10)+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *feishuChannel) Send(ctx context.Context, msg Message) error {
+	var res struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	body := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": msg.Text()},
+	}
+	if c.config.Secret != "" {
+		timestamp := time.Now().Unix()
+		body["timestamp"] = strconv.FormatInt(timestamp, 10)
+		body["sign"] = feishuSign(timestamp, c.config.Secret)
+	}
+	if err := c.post(ctx, c.config.URL, body, &res, nil); err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("飞书发送失败 %d: %s", res.Code, res.Msg)
+	}
+	return nil
+}
+
+// telegramChannel Telegram Bot API
+type telegramChannel struct{ httpChannel }
+
+func (c *telegramChannel) Send(ctx context.Context, msg Message) error {
+	var res struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	url := c.baseURL("https://api.telegram.org") + "/bot" + c.config.Token + "/sendMessage"
+	body := map[string]any{
+		"chat_id": c.config.ChatID,
+		"text":    msg.Text(),
+	}
+	if err := c.post(ctx, url, body, &res, nil); err != nil {
+		//错误信息中不暴露 bot token
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), c.config.Token, "***"))
+	}
+	if !res.OK {
+		return fmt.Errorf("Telegram发送失败: %s", res.Description)
+	}
+	return nil
+}
+
+// barkChannel Bark(iOS推送)
+type barkChannel struct{ httpChannel }
+
+func (c *barkChannel) Send(ctx context.Context, msg Message) error {
+	var res struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	body := map[string]any{
+		"device_key": c.config.Token,
+		"title":      msg.Title,
+		"body":       msg.Content,
+		"group":      "go-stock",
+	}
+	if err := c.post(ctx, c.baseURL("https://api.day.app")+"/push", body, &res, nil); err != nil {
+		return err
+	}
+	if res.Code != 200 {
+		return fmt.Errorf("Bark发送失败 %d: %s", res.Code, res.Message)
+	}
+	return nil
+}
+
+// ntfyChannel ntfy,使用 JSON 方式发布以支持中文标题
+type ntfyChannel struct{ httpChannel }
+
+func (c *ntfyChannel) Send(ctx context.Context, msg Message) error {
+	headers := map[string]string{}
+	if c.config.Token != "" {
+		headers["Authorization"] = "Bearer " + c.config.Token
+	}
+	body := map[string]any{
+		"topic":   c.config.ChatID,
+		"title":   msg.Title,
+		"message": msg.Content,
+	}
+	return c.post(ctx, c.baseURL("https://ntfy.sh")+"/", body, nil, headers)
+}
+
+// webhookChannel 通用 JSON webhook,请求体为 Message
+type webhookChannel struct{ httpChannel }
+
+func (c *webhookChannel) Send(ctx context.Context, msg Message) error {
+	headers := map[string]string{}
+	if c.config.Token != "" {
+		headers["Authorization"] = "Bearer " + c.config.Token
+	}
+	return c.post(ctx, c.config.URL, msg, nil, headers)
+}
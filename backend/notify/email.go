@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailChannel SMTP 邮件,465端口使用 SSL,其他端口在服务器支持时使用 STARTTLS
+type emailChannel struct {
+	config Config
+}
+
+func (c *emailChannel) Name() string {
+	if c.config.Name != "" {
+		return c.config.Name
+	}
+	return c.config.Type
+}
+
+func (c *emailChannel) Type() string {
+	return c.config.Type
+}
+
+func (c *emailChannel) recipients() []string {
+	res := make([]string, 0)
+	for _, to := range strings.Split(c.config.To, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			res = append(res, to)
+		}
+	}
+	return res
+}
+
+// buildEmail 生成 UTF-8 纯文本邮件
+func buildEmail(from string, to []string, msg Message) []byte {
+	var buf bytes.Buffer
+	date := msg.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", "go-stock "+msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Content))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+func (c *emailChannel) Send(ctx context.Context, msg Message) error {
+	port := c.config.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(c.config.Host, strconv.Itoa(port))
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: c.config.Host}
+	if port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("邮件服务器不支持认证")
+		}
+		if err := client.Auth(smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)); err != nil {
+			return err
+		}
+	}
+	to := c.recipients()
+	if err := client.Mail(c.config.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildEmail(c.config.From, to, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/5/28 9:15
+// @Desc 多渠道消息通知:企业微信/飞书/Telegram/邮件/Bark/ntfy/钉钉/通用Webhook
+// -----------------------------------------------------------------------------------
+
+const (
+	TypeWeCom    = "wecom"
+	TypeFeishu   = "feishu"
+	TypeTelegram = "telegram"
+	TypeEmail    = "email"
+	TypeBark     = "bark"
+	TypeNtfy     = "ntfy"
+	TypeDingDing = "dingding"
+	TypeWebhook  = "webhook"
+)
+
+// Types 支持的渠道类型
+var Types = []string{TypeWeCom, TypeFeishu, TypeTelegram, TypeEmail, TypeBark, TypeNtfy, TypeDingDing, TypeWebhook}
+
+// Message 通知消息,Content 为纯文本(支持 markdown 的渠道按 markdown 发送)
+type Message struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	StockCode string    `json:"stockCode,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Text 标题和正文合并为一段文本,用于不区分标题的渠道
+func (m Message) Text() string {
+	if m.Title == "" {
+		return m.Content
+	}
+	return m.Title + "\n" + m.Content
+}
+
+// NotificationChannel 通知渠道
+type NotificationChannel interface {
+	Name() string
+	Type() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config 渠道配置,各渠道使用的字段:
+//
+//	wecom/dingding/webhook: URL(机器人/回调地址) Token(webhook 的 Bearer token) Secret(钉钉加签密钥,可选)
+//	feishu:   URL(机器人地址) Secret(签名校验密钥,可选)
+//	telegram: Token(bot token) ChatID URL(API地址,默认 https://api.telegram.org)
+//	bark:     Token(device key) URL(服务器地址,默认 https://api.day.app)
+//	ntfy:     ChatID(topic) Token(access token,可选) URL(服务器地址,默认 https://ntfy.sh)
+//	email:    Host Port Username Password From To(多个收件人用逗号分隔)
+type Config struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Token    string `json:"token"`
+	Secret   string `json:"secret"`
+	ChatID   string `json:"chatId"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// 单次发送超时时间
+const sendTimeout = 10 * time.Second
+
+// New 根据配置创建通知渠道
+func New(config Config) (NotificationChannel, error) {
+	required := func(fields ...string) error {
+		for i := 0; i < len(fields); i += 2 {
+			if strings.TrimSpace(fields[i+1]) == "" {
+				return fmt.Errorf("%s渠道缺少配置:%s", config.Type, fields[i])
+			}
+		}
+		return nil
+	}
+	base := httpChannel{config: config, client: resty.New().SetTimeout(sendTimeout)}
+	var err error
+	switch config.Type {
+	case TypeWeCom:
+		err = required("url", config.URL)
+		return &weComChannel{base}, err
+	case TypeFeishu:
+		err = required("url", config.URL)
+		return &feishuChannel{base}, err
+	case TypeTelegram:
+		err = required("token", config.Token, "chatId", config.ChatID)
+		return &telegramChannel{base}, err
+	case TypeBark:
+		err = required("token", config.Token)
+		return &barkChannel{base}, err
+	case TypeNtfy:
+		err = required("chatId", config.ChatID)
+		return &ntfyChannel{base}, err
+	case TypeDingDing:
+		err = required("url", config.URL)
+		return &dingDingChannel{base}, err
+	case TypeWebhook:
+		err = required("url", config.URL)
+		return &webhookChannel{base}, err
+	case TypeEmail:
+		err = required("host", config.Host, "from", config.From, "to", config.To)
+		return &emailChannel{config: config}, err
+	default:
+		return nil, errors.New("不支持的通知渠道类型:" + config.Type)
+	}
+}
+
+// DeliveryResult 单个渠道的发送结果
+type DeliveryResult struct {
+	Channel  string        `json:"channel"`
+	Type     string        `json:"type"`
+	Err      error         `json:"-"`
+	Error    string        `json:"error"`
+	Retrying bool          `json:"retrying"` //被限流,已加入重试队列
+	Duration time.Duration `json:"duration"`
+}
+
+// SendAll 并发发送到多个渠道,按渠道顺序返回结果
+func SendAll(ctx context.Context, channels []NotificationChannel, msg Message) []DeliveryResult {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	results := make([]DeliveryResult, len(channels))
+	done := make(chan struct{})
+	for i, channel := range channels {
+		go func(i int, channel NotificationChannel) {
+			defer func() { done <- struct{}{} }()
+			start := time.Now()
+			err := channel.Send(ctx, msg)
+			results[i] = DeliveryResult{Channel: channel.Name(), Type: channel.Type(), Err: err, Duration: time.Since(start)}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, channel)
+	}
+	for range channels {
+		<-done
+	}
+	return results
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedRequest struct {
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   map[string]any
+}
+
+// standIn 本地 HTTP 替身,记录请求并返回固定响应
+func standIn(t *testing.T, status int, response string) (*httptest.Server, *[]capturedRequest) {
+	requests := make([]capturedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]any)
+		content, _ := io.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		requests = append(requests, capturedRequest{Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Body: body})
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var testMessage = Message{Title: "报警", Content: "浦发银行 突破前高"}
+
+func send(t *testing.T, config Config) error {
+	channel, err := New(config)
+	assert.NoError(t, err)
+	return channel.Send(context.Background(), testMessage)
+}
+
+func TestWeComAndDingDing(t *testing.T) {
+	server, requests := standIn(t, 200, `{"errcode":0,"errmsg":"ok"}`)
+	assert.NoError(t, send(t, Config{Type: TypeWeCom, URL: server.URL + "/cgi-bin/webhook/send"}))
+	assert.Equal(t, "markdown", (*requests)[0].Body["msgtype"])
+	assert.Contains(t, (*requests)[0].Body["markdown"].(map[string]any)["content"], "突破前高")
+
+	assert.NoError(t, send(t, Config{Type: TypeDingDing, URL: server.URL}))
+	assert.Equal(t, "go-stock 报警", (*requests)[1].Body["markdown"].(map[string]any)["title"])
+	assert.Empty(t, (*requests)[1].Query)
+
+	assert.NoError(t, send(t, Config{Type: TypeDingDing, URL: server.URL + "/robot/send?access_token=abc", Secret: "SEC123"}))
+	query := (*requests)[2].Query
+	assert.Equal(t, "abc", query.Get("access_token"))
+	timestamp, _ := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	assert.Equal(t, DingTalkSign(timestamp, "SEC123"), query.Get("sign"))
+
+	server, _ = standIn(t, 200, `{"errcode":310000,"errmsg":"sign not match"}`)
+	assert.ErrorContains(t, send(t, Config{Type: TypeWeCom, URL: server.URL}), "310000")
+}
+
+func TestFeishu(t *testing.T) {
+	server, requests := standIn(t, 200, `{"code":0,"msg":"success"}`)
+	assert.NoError(t, send(t, Config{Type: TypeFeishu, URL: server.URL, Secret: "secret"}))
+	body := (*requests)[0].Body
+	assert.Equal(t, "text", body["msg_type"])
+	timestamp, _ := strconv.ParseInt(body["timestamp"].(string), 10, 64)
+	assert.Equal(t, feishuSign(timestamp, "secret"), body["sign"])
+
+	server, _ = standIn(t, 200, `{"code":19021,"msg":"sign match fail"}`)
+	assert.ErrorContains(t, send(t, Config{Type: TypeFeishu, URL: server.URL}), "19021")
+}
+
+func TestTelegram(t *testing.T) {
+	server, requests := standIn(t, 200, `{"ok":true}`)
+	assert.NoError(t, send(t, Config{Type: TypeTelegram, URL: server.URL, Token: "123:abc", ChatID: "42"}))
+	assert.Equal(t, "/bot123:abc/sendMessage", (*requests)[0].Path)
+	assert.Equal(t, "42", (*requests)[0].Body["chat_id"])
+
+	server, _ = standIn(t, 401, `{"ok":false,"description":"Unauthorized"}`)
+	err := send(t, Config{Type: TypeTelegram, URL: server.URL, Token: "123:abc", ChatID: "42"})
+	assert.ErrorContains(t, err, "401")
+	assert.NotContains(t, err.Error(), "123:abc")
+}
+
+func TestBarkNtfyWebhook(t *testing.T) {
+	server, requests := standIn(t, 200, `{"code":200,"message":"success"}`)
+	assert.NoError(t, send(t, Config{Type: TypeBark, URL: server.URL, Token: "device"}))
+	assert.Equal(t, "/push", (*requests)[0].Path)
+	assert.Equal(t, "device", (*requests)[0].Body["device_key"])
+
+	assert.NoError(t, send(t, Config{Type: TypeNtfy, URL: server.URL, ChatID: "stock", Token: "tk"}))
+	assert.Equal(t, "stock", (*requests)[1].Body["topic"])
+	assert.Equal(t, "Bearer tk", (*requests)[1].Header.Get("Authorization"))
+
+	assert.NoError(t, send(t, Config{Type: TypeWebhook, URL: server.URL + "/hook"}))
+	assert.Equal(t, "报警", (*requests)[2].Body["title"])
+
+	server, _ = standIn(t, 500, `oops`)
+	assert.ErrorContains(t, send(t, Config{Type: TypeWebhook, URL: server.URL}), "500")
+}
+
+func TestDingTalkSign(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	signed := DingTalkSignedURL("https://oapi.dingtalk.com/robot/send?access_token=abc", "secret", now)
+	assert.Equal(t, "https://oapi.dingtalk.com/robot/send?access_token=abc&timestamp=1700000000000&sign="+url.QueryEscape(DingTalkSign(1700000000000, "secret")), signed)
+	assert.Equal(t, "https://oapi.dingtalk.com/robot/send", DingTalkSignedURL("https://oapi.dingtalk.com/robot/send", "", now))
+	assert.Equal(t, "OuzzJR5+xZ4/EYwqtNt6sMYZQMTa/HEGvc9miJe7XzY=", DingTalkSign(1700000000000, "secret"))
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	_, err := New(Config{Type: TypeTelegram, Token: "t"})
+	assert.ErrorContains(t, err, "chatId")
+	_, err = New(Config{Type: "pigeon"})
+	assert.Error(t, err)
+}
+
+// smtpStandIn 最简 SMTP 替身,记录收到的邮件
+func smtpStandIn(t *testing.T) (string, int, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					mails <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, mails
+}
+
+func TestEmail(t *testing.T) {
+	host, port, mails := smtpStandIn(t)
+	assert.NoError(t, send(t, Config{Type: TypeEmail, Host: host, Port: port, From: "bot@example.com", To: "a@example.com, b@example.com"}))
+	mail := <-mails
+	assert.Contains(t, mail, "To: a@example.com, b@example.com")
+	assert.Contains(t, mail, "Subject: =?UTF-8?b?")
+	assert.Contains(t, mail, "Content-Type: text/plain; charset=UTF-8")
+}
+
+func TestSendAll(t *testing.T) {
+	ok, _ := standIn(t, 200, `{"errcode":0}`)
+	fail, _ := standIn(t, 502, ``)
+	channels := make([]NotificationChannel, 0)
+	for _, url := range []string{ok.URL, fail.URL} {
+		channel, _ := New(Config{Type: TypeWeCom, Name: url, URL: url})
+		channels = append(channels, channel)
+	}
+	results := SendAll(context.Background(), channels, testMessage)
+	assert.Len(t, results, 2)
+	assert.Equal(t, ok.URL, results[0].Channel)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.Contains(t, results[1].Error, "502")
+}
@@ -2,6 +2,12 @@
 // This file is automatically generated. DO NOT EDIT
 import {data} from '../models';
 import {models} from '../models';
+import {services} from '../models';
+import {indicator} from '../models';
+
+export function AcknowledgeAlertEvents(arg1:Array<number>):Promise<string>;
+
+export function AddCronTask(arg1:data.FollowedStock):Promise<any>;
 
 export function AddGroup(arg1:data.Group):Promise<string>;
 
@@ -9,10 +15,32 @@ export function AddPrompt(arg1:models.Prompt):Promise<string>;
 
 export function AddStockGroup(arg1:number,arg2:string):Promise<string>;
 
+export function AddTrade(arg1:data.Trade):Promise<string>;
+
+export function CancelAiBatchJob(arg1:number):Promise<string>;
+
 export function CheckUpdate():Promise<void>;
 
+export function ContinueChat(arg1:number,arg2:string,arg3:number):Promise<void>;
+
 export function DelPrompt(arg1:number):Promise<string>;
 
+export function DeleteAiBatchJob(arg1:number):Promise<string>;
+
+export function DeleteAiConversation(arg1:number):Promise<string>;
+
+export function DeleteAiModelProfile(arg1:number):Promise<string>;
+
+export function DeleteAlertRule(arg1:number):Promise<string>;
+
+export function DeleteExitRule(arg1:number):Promise<string>;
+
+export function DeleteNotificationChannel(arg1:number):Promise<string>;
+
+export function DeleteTrade(arg1:number):Promise<string>;
+
+export function EvaluateAiRatings():Promise<string>;
+
 export function ExportConfig():Promise<string>;
 
 export function Follow(arg1:string):Promise<string>;
@@ -21,8 +49,42 @@ export function FollowFund(arg1:string):Promise<string>;
 
 export function GetAIResponseResult(arg1:string):Promise<models.AIResponseResult>;
 
+export function GetAiAccuracy(arg1:string,arg2:number):Promise<Array<data.AiAccuracyStat>>;
+
+export function GetAiBatchJob(arg1:number):Promise<data.AiBatchJob>;
+
+export function GetAiBatchJobs():Promise<Array<data.AiBatchJob>>;
+
+export function GetAiBatchTasks(arg1:number):Promise<Array<data.AiBatchTask>>;
+
+export function GetAiBudgetStatus():Promise<data.AiBudgetStatus>;
+
+export function GetAiContextSections():Promise<Array<data.ContextSection>>;
+
+export function GetAiConversationMessages(arg1:number):Promise<Array<data.AiMessage>>;
+
+export function GetAiConversations(arg1:string):Promise<Array<data.AiConversation>>;
+
+export function GetAiModelProfiles():Promise<Array<data.AiModelProfile>>;
+
+export function GetAiModelProviderTypes():Promise<Array<string>>;
+
+export function GetAiRatings(arg1:data.AiRatingQuery):Promise<Array<data.AiRating>>;
+
+export function GetAiUsageStats(arg1:number):Promise<Array<data.AiUsageStat>>;
+
+export function GetAlertDigest(arg1:string):Promise<string>;
+
+export function GetAlertEvents(arg1:data.AlertEventQuery):Promise<Array<data.AlertEvent>>;
+
+export function GetAlertRuleFields():Promise<Array<services.RuleField>>;
+
+export function GetAlertRules(arg1:string):Promise<Array<data.AlertRule>>;
+
 export function GetConfig():Promise<data.Settings>;
 
+export function GetExitRules():Promise<Array<data.ExitRule>>;
+
 export function GetFollowList(arg1:number):Promise<any>;
 
 export function GetFollowedFund():Promise<Array<data.FollowedFund>>;
@@ -35,12 +97,30 @@ export function GetIndustryMoneyRankSina(arg1:string):Promise<Array<Record<strin
 
 export function GetIndustryRank(arg1:string,arg2:number):Promise<Array<any>>;
 
+export function GetIntradaySnapshots(arg1:string,arg2:string,arg3:string):Promise<Array<data.QuoteSnapshot>>;
+
 export function GetMoneyRankSina(arg1:string):Promise<Array<Record<string, any>>>;
 
+export function GetNotificationChannelTypes():Promise<Array<string>>;
+
+export function GetNotificationChannels():Promise<Array<data.NotificationChannelSetting>>;
+
+export function GetNotificationLogs(arg1:number):Promise<Array<data.NotificationLog>>;
+
+export function GetPortfolioPerformance(arg1:string,arg2:number,arg3:string,arg4:string,arg5:string):Promise<data.PortfolioPerformance>;
+
+export function GetPortfolioSnapshots(arg1:string,arg2:number,arg3:string,arg4:string):Promise<Array<data.PortfolioSnapshot>>;
+
+export function GetPositions(arg1:string):Promise<Array<data.Position>>;
+
 export function GetPromptTemplates(arg1:string,arg2:string):Promise<any>;
 
+export function GetQuoteSourceStatus():Promise<Array<data.QuoteSourceStatus>>;
+
 export function GetStockCommonKLine(arg1:string,arg2:string,arg3:number):Promise<any>;
 
+export function GetStockIndicators(arg1:string,arg2:string,arg3:Array<string>):Promise<indicator.Result>;
+
 export function GetStockKLine(arg1:string,arg2:string,arg3:number):Promise<any>;
 
 export function GetStockList(arg1:string):Promise<Array<data.StockBasic>>;
@@ -51,6 +131,8 @@ export function GetStockMoneyTrendByDay(arg1:string,arg2:number):Promise<Array<R
 
 export function GetTelegraphList(arg1:string):Promise<any>;
 
+export function GetTrades(arg1:string):Promise<Array<data.Trade>>;
+
 export function GetVersionInfo():Promise<models.VersionInfo>;
 
 export function GetfundList(arg1:string):Promise<Array<data.FundBasic>>;
@@ -59,36 +141,64 @@ export function GlobalStockIndexes():Promise<Record<string, any>>;
 
 export function Greet(arg1:string):Promise<data.StockInfo>;
 
-export function NewChat(arg1:string):Promise<string>;
-
 export function NewChatStream(arg1:string,arg2:string,arg3:string,arg4:any):Promise<void>;
 
+export function NewChatStreamWithModel(arg1:string,arg2:string,arg3:string,arg4:any,arg5:number):Promise<void>;
+
 export function ReFleshTelegraphList(arg1:string):Promise<any>;
 
 export function RemoveGroup(arg1:number):Promise<string>;
 
 export function RemoveStockGroup(arg1:string,arg2:string,arg3:number):Promise<string>;
 
+export function RenameAiConversation(arg1:number,arg2:string):Promise<string>;
+
 export function SaveAIResponseResult(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<void>;
 
+export function SaveAiModelProfile(arg1:data.AiModelProfile):Promise<string>;
+
+export function SaveAlertRule(arg1:data.AlertRule):Promise<string>;
+
 export function SaveAsMarkdown(arg1:string,arg2:string):Promise<string>;
 
+export function SaveExitRule(arg1:data.ExitRule):Promise<string>;
+
+export function SaveNotificationChannel(arg1:data.NotificationChannelSetting):Promise<string>;
+
+export function SendAIReportToDingDing(arg1:string,arg2:string,arg3:string):Promise<string>;
+
 export function SendDingDingMessage(arg1:string,arg2:string):Promise<string>;
 
 export function SendDingDingMessageByType(arg1:string,arg2:string,arg3:number):Promise<string>;
 
 export function SetAlarmChangePercent(arg1:number,arg2:number,arg3:string):Promise<string>;
 
+export function SetAlertRuleActive(arg1:number,arg2:boolean):Promise<string>;
+
 export function SetCostPriceAndVolume(arg1:string,arg2:number,arg3:number):Promise<string>;
 
 export function SetStockAICron(arg1:string,arg2:string):Promise<void>;
 
+export function SetStockAIModel(arg1:number,arg2:string):Promise<string>;
+
 export function SetStockSort(arg1:number,arg2:string):Promise<void>;
 
 export function ShareAnalysis(arg1:string,arg2:string):Promise<string>;
 
+export function SnoozeAlertEvent(arg1:number,arg2:number):Promise<string>;
+
+export function StartAiBatchJob(arg1:number,arg2:number,arg3:string,arg4:number,arg5:number,arg6:number):Promise<string>;
+
 export function SummaryStockNews(arg1:string,arg2:any):Promise<void>;
 
+export function SummaryStockNewsWithModel(arg1:string,arg2:any,arg3:number):Promise<void>;
+
+export function TakePortfolioSnapshot():Promise<string>;
+
+export function TestAiModelProfile(arg1:number):Promise<string>;
+
+export function TestNotificationChannel(arg1:number):Promise<string>;
+
 export function UnFollow(arg1:string):Promise<string>;
 
 export function UnFollowFund(arg1:string):Promise<string>;
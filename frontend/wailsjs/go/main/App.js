@@ -2,6 +2,14 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AcknowledgeAlertEvents(arg1) {
+  return window['go']['main']['App']['AcknowledgeAlertEvents'](arg1);
+}
+
+export function AddCronTask(arg1) {
+  return window['go']['main']['App']['AddCronTask'](arg1);
+}
+
 export function AddGroup(arg1) {
   return window['go']['main']['App']['AddGroup'](arg1);
 }
@@ -14,14 +22,58 @@ export function AddStockGroup(arg1, arg2) {
   return window['go']['main']['App']['AddStockGroup'](arg1, arg2);
 }
 
+export function AddTrade(arg1) {
+  return window['go']['main']['App']['AddTrade'](arg1);
+}
+
+export function CancelAiBatchJob(arg1) {
+  return window['go']['main']['App']['CancelAiBatchJob'](arg1);
+}
+
 export function CheckUpdate() {
   return window['go']['main']['App']['CheckUpdate']();
 }
 
+export function ContinueChat(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ContinueChat'](arg1, arg2, arg3);
+}
+
 export function DelPrompt(arg1) {
   return window['go']['main']['App']['DelPrompt'](arg1);
 }
 
+export function DeleteAiBatchJob(arg1) {
+  return window['go']['main']['App']['DeleteAiBatchJob'](arg1);
+}
+
+export function DeleteAiConversation(arg1) {
+  return window['go']['main']['App']['DeleteAiConversation'](arg1);
+}
+
+export function DeleteAiModelProfile(arg1) {
+  return window['go']['main']['App']['DeleteAiModelProfile'](arg1);
+}
+
+export function DeleteAlertRule(arg1) {
+  return window['go']['main']['App']['DeleteAlertRule'](arg1);
+}
+
+export function DeleteExitRule(arg1) {
+  return window['go']['main']['App']['DeleteExitRule'](arg1);
+}
+
+export function DeleteNotificationChannel(arg1) {
+  return window['go']['main']['App']['DeleteNotificationChannel'](arg1);
+}
+
+export function DeleteTrade(arg1) {
+  return window['go']['main']['App']['DeleteTrade'](arg1);
+}
+
+export function EvaluateAiRatings() {
+  return window['go']['main']['App']['EvaluateAiRatings']();
+}
+
 export function ExportConfig() {
   return window['go']['main']['App']['ExportConfig']();
 }
@@ -38,10 +90,78 @@ export function GetAIResponseResult(arg1) {
   return window['go']['main']['App']['GetAIResponseResult'](arg1);
 }
 
+export function GetAiAccuracy(arg1, arg2) {
+  return window['go']['main']['App']['GetAiAccuracy'](arg1, arg2);
+}
+
+export function GetAiBatchJob(arg1) {
+  return window['go']['main']['App']['GetAiBatchJob'](arg1);
+}
+
+export function GetAiBatchJobs() {
+  return window['go']['main']['App']['GetAiBatchJobs']();
+}
+
+export function GetAiBatchTasks(arg1) {
+  return window['go']['main']['App']['GetAiBatchTasks'](arg1);
+}
+
+export function GetAiBudgetStatus() {
+  return window['go']['main']['App']['GetAiBudgetStatus']();
+}
+
+export function GetAiContextSections() {
+  return window['go']['main']['App']['GetAiContextSections']();
+}
+
+export function GetAiConversationMessages(arg1) {
+  return window['go']['main']['App']['GetAiConversationMessages'](arg1);
+}
+
+export function GetAiConversations(arg1) {
+  return window['go']['main']['App']['GetAiConversations'](arg1);
+}
+
+export function GetAiModelProfiles() {
+  return window['go']['main']['App']['GetAiModelProfiles']();
+}
+
+export function GetAiModelProviderTypes() {
+  return window['go']['main']['App']['GetAiModelProviderTypes']();
+}
+
+export function GetAiRatings(arg1) {
+  return window['go']['main']['App']['GetAiRatings'](arg1);
+}
+
+export function GetAiUsageStats(arg1) {
+  return window['go']['main']['App']['GetAiUsageStats'](arg1);
+}
+
+export function GetAlertDigest(arg1) {
+  return window['go']['main']['App']['GetAlertDigest'](arg1);
+}
+
+export function GetAlertEvents(arg1) {
+  return window['go']['main']['App']['GetAlertEvents'](arg1);
+}
+
+export function GetAlertRuleFields() {
+  return window['go']['main']['App']['GetAlertRuleFields']();
+}
+
+export function GetAlertRules(arg1) {
+  return window['go']['main']['App']['GetAlertRules'](arg1);
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetExitRules() {
+  return window['go']['main']['App']['GetExitRules']();
+}
+
 export function GetFollowList(arg1) {
   return window['go']['main']['App']['GetFollowList'](arg1);
 }
@@ -66,18 +186,54 @@ export function GetIndustryRank(arg1, arg2) {
   return window['go']['main']['App']['GetIndustryRank'](arg1, arg2);
 }
 
+export function GetIntradaySnapshots(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetIntradaySnapshots'](arg1, arg2, arg3);
+}
+
 export function GetMoneyRankSina(arg1) {
   return window['go']['main']['App']['GetMoneyRankSina'](arg1);
 }
 
+export function GetNotificationChannelTypes() {
+  return window['go']['main']['App']['GetNotificationChannelTypes']();
+}
+
+export function GetNotificationChannels() {
+  return window['go']['main']['App']['GetNotificationChannels']();
+}
+
+export function GetNotificationLogs(arg1) {
+  return window['go']['main']['App']['GetNotificationLogs'](arg1);
+}
+
+export function GetPortfolioPerformance(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GetPortfolioPerformance'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function GetPortfolioSnapshots(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetPortfolioSnapshots'](arg1, arg2, arg3, arg4);
+}
+
+export function GetPositions(arg1) {
+  return window['go']['main']['App']['GetPositions'](arg1);
+}
+
 export function GetPromptTemplates(arg1, arg2) {
   return window['go']['main']['App']['GetPromptTemplates'](arg1, arg2);
 }
 
+export function GetQuoteSourceStatus() {
+  return window['go']['main']['App']['GetQuoteSourceStatus']();
+}
+
 export function GetStockCommonKLine(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetStockCommonKLine'](arg1, arg2, arg3);
 }
 
+export function GetStockIndicators(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetStockIndicators'](arg1, arg2, arg3);
+}
+
 export function GetStockKLine(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetStockKLine'](arg1, arg2, arg3);
 }
@@ -98,6 +254,10 @@ export function GetTelegraphList(arg1) {
   return window['go']['main']['App']['GetTelegraphList'](arg1);
 }
 
+export function GetTrades(arg1) {
+  return window['go']['main']['App']['GetTrades'](arg1);
+}
+
 export function GetVersionInfo() {
   return window['go']['main']['App']['GetVersionInfo']();
 }
@@ -114,14 +274,14 @@ export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
-export function NewChat(arg1) {
-  return window['go']['main']['App']['NewChat'](arg1);
-}
-
 export function NewChatStream(arg1, arg2, arg3, arg4) {
   return window['go']['main']['App']['NewChatStream'](arg1, arg2, arg3, arg4);
 }
 
+export function NewChatStreamWithModel(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['NewChatStreamWithModel'](arg1, arg2, arg3, arg4, arg5);
+}
+
 export function ReFleshTelegraphList(arg1) {
   return window['go']['main']['App']['ReFleshTelegraphList'](arg1);
 }
@@ -134,14 +294,38 @@ export function RemoveStockGroup(arg1, arg2, arg3) {
   return window['go']['main']['App']['RemoveStockGroup'](arg1, arg2, arg3);
 }
 
+export function RenameAiConversation(arg1, arg2) {
+  return window['go']['main']['App']['RenameAiConversation'](arg1, arg2);
+}
+
 export function SaveAIResponseResult(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['main']['App']['SaveAIResponseResult'](arg1, arg2, arg3, arg4, arg5);
 }
 
+export function SaveAiModelProfile(arg1) {
+  return window['go']['main']['App']['SaveAiModelProfile'](arg1);
+}
+
+export function SaveAlertRule(arg1) {
+  return window['go']['main']['App']['SaveAlertRule'](arg1);
+}
+
 export function SaveAsMarkdown(arg1, arg2) {
   return window['go']['main']['App']['SaveAsMarkdown'](arg1, arg2);
 }
 
+export function SaveExitRule(arg1) {
+  return window['go']['main']['App']['SaveExitRule'](arg1);
+}
+
+export function SaveNotificationChannel(arg1) {
+  return window['go']['main']['App']['SaveNotificationChannel'](arg1);
+}
+
+export function SendAIReportToDingDing(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SendAIReportToDingDing'](arg1, arg2, arg3);
+}
+
 export function SendDingDingMessage(arg1, arg2) {
   return window['go']['main']['App']['SendDingDingMessage'](arg1, arg2);
 }
@@ -154,6 +338,10 @@ export function SetAlarmChangePercent(arg1, arg2, arg3) {
   return window['go']['main']['App']['SetAlarmChangePercent'](arg1, arg2, arg3);
 }
 
+export function SetAlertRuleActive(arg1, arg2) {
+  return window['go']['main']['App']['SetAlertRuleActive'](arg1, arg2);
+}
+
 export function SetCostPriceAndVolume(arg1, arg2, arg3) {
   return window['go']['main']['App']['SetCostPriceAndVolume'](arg1, arg2, arg3);
 }
@@ -162,6 +350,10 @@ export function SetStockAICron(arg1, arg2) {
   return window['go']['main']['App']['SetStockAICron'](arg1, arg2);
 }
 
+export function SetStockAIModel(arg1, arg2) {
+  return window['go']['main']['App']['SetStockAIModel'](arg1, arg2);
+}
+
 export function SetStockSort(arg1, arg2) {
   return window['go']['main']['App']['SetStockSort'](arg1, arg2);
 }
@@ -170,10 +362,34 @@ export function ShareAnalysis(arg1, arg2) {
   return window['go']['main']['App']['ShareAnalysis'](arg1, arg2);
 }
 
+export function SnoozeAlertEvent(arg1, arg2) {
+  return window['go']['main']['App']['SnoozeAlertEvent'](arg1, arg2);
+}
+
+export function StartAiBatchJob(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['main']['App']['StartAiBatchJob'](arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
 export function SummaryStockNews(arg1, arg2) {
   return window['go']['main']['App']['SummaryStockNews'](arg1, arg2);
 }
 
+export function SummaryStockNewsWithModel(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SummaryStockNewsWithModel'](arg1, arg2, arg3);
+}
+
+export function TakePortfolioSnapshot() {
+  return window['go']['main']['App']['TakePortfolioSnapshot']();
+}
+
+export function TestAiModelProfile(arg1) {
+  return window['go']['main']['App']['TestAiModelProfile'](arg1);
+}
+
+export function TestNotificationChannel(arg1) {
+  return window['go']['main']['App']['TestNotificationChannel'](arg1);
+}
+
 export function UnFollow(arg1) {
   return window['go']['main']['App']['UnFollow'](arg1);
 }
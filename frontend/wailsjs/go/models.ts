@@ -1,5 +1,729 @@
 export namespace data {
 	
+	export class AiAccuracyStat {
+	    key: string;
+	    name: string;
+	    days: number;
+	    count: number;
+	    hits: number;
+	    hitRate: number;
+	    avgReturn: number;
+	    avgCallReturn: number;
+	    targetCount: number;
+	    targetHits: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiAccuracyStat(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.name = source["name"];
+	        this.days = source["days"];
+	        this.count = source["count"];
+	        this.hits = source["hits"];
+	        this.hitRate = source["hitRate"];
+	        this.avgReturn = source["avgReturn"];
+	        this.avgCallReturn = source["avgCallReturn"];
+	        this.targetCount = source["targetCount"];
+	        this.targetHits = source["targetHits"];
+	    }
+	}
+	export class AiBatchJob {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    groupId: number;
+	    groupName: string;
+	    promptTemplateId: number;
+	    question: string;
+	    aiModelId: number;
+	    modelName: string;
+	    concurrency: number;
+	    maxRetries: number;
+	    status: string;
+	    total: number;
+	    succeeded: number;
+	    failed: number;
+	    report: string;
+	    // Go type: time
+	    startedAt?: any;
+	    // Go type: time
+	    finishedAt?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiBatchJob(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.groupId = source["groupId"];
+	        this.groupName = source["groupName"];
+	        this.promptTemplateId = source["promptTemplateId"];
+	        this.question = source["question"];
+	        this.aiModelId = source["aiModelId"];
+	        this.modelName = source["modelName"];
+	        this.concurrency = source["concurrency"];
+	        this.maxRetries = source["maxRetries"];
+	        this.status = source["status"];
+	        this.total = source["total"];
+	        this.succeeded = source["succeeded"];
+	        this.failed = source["failed"];
+	        this.report = source["report"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.finishedAt = this.convertValues(source["finishedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiBatchTask {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    jobId: number;
+	    stockCode: string;
+	    stockName: string;
+	    status: string;
+	    attempts: number;
+	    error: string;
+	    content: string;
+	    chatId: string;
+	    conversationId: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiBatchTask(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.jobId = source["jobId"];
+	        this.stockCode = source["stockCode"];
+	        this.stockName = source["stockName"];
+	        this.status = source["status"];
+	        this.attempts = source["attempts"];
+	        this.error = source["error"];
+	        this.content = source["content"];
+	        this.chatId = source["chatId"];
+	        this.conversationId = source["conversationId"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiUsageStat {
+	    day: string;
+	    requests: number;
+	    promptTokens: number;
+	    completionTokens: number;
+	    totalTokens: number;
+	    cost: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiUsageStat(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.day = source["day"];
+	        this.requests = source["requests"];
+	        this.promptTokens = source["promptTokens"];
+	        this.completionTokens = source["completionTokens"];
+	        this.totalTokens = source["totalTokens"];
+	        this.cost = source["cost"];
+	    }
+	}
+	export class AiBudgetStatus {
+	    today: AiUsageStat;
+	    month: AiUsageStat;
+	    dailyBudget: number;
+	    monthlyBudget: number;
+	    exceeded: boolean;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiBudgetStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.today = this.convertValues(source["today"], AiUsageStat);
+	        this.month = this.convertValues(source["month"], AiUsageStat);
+	        this.dailyBudget = source["dailyBudget"];
+	        this.monthlyBudget = source["monthlyBudget"];
+	        this.exceeded = source["exceeded"];
+	        this.message = source["message"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiConversation {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    stockCode: string;
+	    stockName: string;
+	    title: string;
+	    modelName: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiConversation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.stockCode = source["stockCode"];
+	        this.stockName = source["stockName"];
+	        this.title = source["title"];
+	        this.modelName = source["modelName"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiMessage {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    conversationId: number;
+	    role: string;
+	    content: string;
+	    chatId: string;
+	    modelName: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiMessage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.conversationId = source["conversationId"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.chatId = source["chatId"];
+	        this.modelName = source["modelName"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiModelProfile {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    name: string;
+	    provider: string;
+	    baseUrl: string;
+	    apiKey: string;
+	    model: string;
+	    temperature: number;
+	    maxTokens: number;
+	    timeOut: number;
+	    contextSize: number;
+	    isDefault: boolean;
+	    inputPrice: number;
+	    outputPrice: number;
+	    rateLimit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiModelProfile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.name = source["name"];
+	        this.provider = source["provider"];
+	        this.baseUrl = source["baseUrl"];
+	        this.apiKey = source["apiKey"];
+	        this.model = source["model"];
+	        this.temperature = source["temperature"];
+	        this.maxTokens = source["maxTokens"];
+	        this.timeOut = source["timeOut"];
+	        this.contextSize = source["contextSize"];
+	        this.isDefault = source["isDefault"];
+	        this.inputPrice = source["inputPrice"];
+	        this.outputPrice = source["outputPrice"];
+	        this.rateLimit = source["rateLimit"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiRating {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    conversationId: number;
+	    chatId: string;
+	    stockCode: string;
+	    stockName: string;
+	    modelName: string;
+	    promptTemplateId: number;
+	    trigger: string;
+	    price: number;
+	    rating: string;
+	    confidence: number;
+	    support: number;
+	    resistance: number;
+	    targetPrice: number;
+	    upside: number;
+	    horizon: string;
+	    keyRisks: string;
+	    repaired: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiRating(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.conversationId = source["conversationId"];
+	        this.chatId = source["chatId"];
+	        this.stockCode = source["stockCode"];
+	        this.stockName = source["stockName"];
+	        this.modelName = source["modelName"];
+	        this.promptTemplateId = source["promptTemplateId"];
+	        this.trigger = source["trigger"];
+	        this.price = source["price"];
+	        this.rating = source["rating"];
+	        this.confidence = source["confidence"];
+	        this.support = source["support"];
+	        this.resistance = source["resistance"];
+	        this.targetPrice = source["targetPrice"];
+	        this.upside = source["upside"];
+	        this.horizon = source["horizon"];
+	        this.keyRisks = source["keyRisks"];
+	        this.repaired = source["repaired"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AiRatingQuery {
+	    groupId: number;
+	    stockCode: string;
+	    ratings: string[];
+	    modelName: string;
+	    startDate: string;
+	    endDate: string;
+	    latestOnly: boolean;
+	    sortBy: string;
+	    desc: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new AiRatingQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.groupId = source["groupId"];
+	        this.stockCode = source["stockCode"];
+	        this.ratings = source["ratings"];
+	        this.modelName = source["modelName"];
+	        this.startDate = source["startDate"];
+	        this.endDate = source["endDate"];
+	        this.latestOnly = source["latestOnly"];
+	        this.sortBy = source["sortBy"];
+	        this.desc = source["desc"];
+	    }
+	}
+	
+	export class AlertEvent {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    ruleId: number;
+	    ruleName: string;
+	    alertType: string;
+	    stockCode: string;
+	    stockName: string;
+	    title: string;
+	    content: string;
+	    price: number;
+	    changePercent: number;
+	    quote: string;
+	    channels: string;
+	    status: string;
+	    deliveryError: string;
+	    // Go type: time
+	    triggeredAt: any;
+	    // Go type: time
+	    acknowledgedAt?: any;
+	    // Go type: time
+	    snoozedUntil?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.alertType = source["alertType"];
+	        this.stockCode = source["stockCode"];
+	        this.stockName = source["stockName"];
+	        this.title = source["title"];
+	        this.content = source["content"];
+	        this.price = source["price"];
+	        this.changePercent = source["changePercent"];
+	        this.quote = source["quote"];
+	        this.channels = source["channels"];
+	        this.status = source["status"];
+	        this.deliveryError = source["deliveryError"];
+	        this.triggeredAt = this.convertValues(source["triggeredAt"], null);
+	        this.acknowledgedAt = this.convertValues(source["acknowledgedAt"], null);
+	        this.snoozedUntil = this.convertValues(source["snoozedUntil"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AlertEventQuery {
+	    stockCode: string;
+	    ruleId: number;
+	    status: string;
+	    unacknowledged: boolean;
+	    startDate: string;
+	    endDate: string;
+	    limit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertEventQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stockCode = source["stockCode"];
+	        this.ruleId = source["ruleId"];
+	        this.status = source["status"];
+	        this.unacknowledged = source["unacknowledged"];
+	        this.startDate = source["startDate"];
+	        this.endDate = source["endDate"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class AlertRule {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    stockCode: string;
+	    name: string;
+	    expression: string;
+	    triggerMode: string;
+	    cooldownSeconds: number;
+	    isActive: boolean;
+	    triggered: boolean;
+	    // Go type: time
+	    lastTriggered?: any;
+	    channels: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.stockCode = source["stockCode"];
+	        this.name = source["name"];
+	        this.expression = source["expression"];
+	        this.triggerMode = source["triggerMode"];
+	        this.cooldownSeconds = source["cooldownSeconds"];
+	        this.isActive = source["isActive"];
+	        this.triggered = source["triggered"];
+	        this.lastTriggered = this.convertValues(source["lastTriggered"], null);
+	        this.channels = source["channels"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ContextSection {
+	    name: string;
+	    label: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ContextSection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.label = source["label"];
+	    }
+	}
+	export class ExitRule {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    stockCode: string;
+	    stockName: string;
+	    enabled: boolean;
+	    trailingPercent: number;
+	    trailingATR: number;
+	    atrPeriod: number;
+	    takeProfitLevels: string;
+	    timeStopDays: number;
+	    timeStopMinProfit: number;
+	    entryDate: string;
+	    highWaterMark: number;
+	    highWaterDate: string;
+	    takeProfitHit: string;
+	    trailingFired: boolean;
+	    timeStopFired: boolean;
+	    lastStopPrice: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExitRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.stockCode = source["stockCode"];
+	        this.stockName = source["stockName"];
+	        this.enabled = source["enabled"];
+	        this.trailingPercent = source["trailingPercent"];
+	        this.trailingATR = source["trailingATR"];
+	        this.atrPeriod = source["atrPeriod"];
+	        this.takeProfitLevels = source["takeProfitLevels"];
+	        this.timeStopDays = source["timeStopDays"];
+	        this.timeStopMinProfit = source["timeStopMinProfit"];
+	        this.entryDate = source["entryDate"];
+	        this.highWaterMark = source["highWaterMark"];
+	        this.highWaterDate = source["highWaterDate"];
+	        this.takeProfitHit = source["takeProfitHit"];
+	        this.trailingFired = source["trailingFired"];
+	        this.timeStopFired = source["timeStopFired"];
+	        this.lastStopPrice = source["lastStopPrice"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class FundBasic {
 	    ID: number;
 	    // Go type: time
@@ -10,30 +734,410 @@ export namespace data {
 	    DeletedAt: any;
 	    code: string;
 	    name: string;
-	    fullName: string;
-	    type: string;
-	    establishment: string;
-	    scale: string;
-	    company: string;
-	    manager: string;
-	    rating: string;
-	    trackingTarget: string;
+	    fullName: string;
+	    type: string;
+	    establishment: string;
+	    scale: string;
+	    company: string;
+	    manager: string;
+	    rating: string;
+	    trackingTarget: string;
+	    netUnitValue?: number;
+	    netUnitValueDate: string;
+	    netEstimatedUnit?: number;
+	    netEstimatedUnitTime: string;
+	    netAccumulated?: number;
+	    netGrowth1?: number;
+	    netGrowth3?: number;
+	    netGrowth6?: number;
+	    netGrowth12?: number;
+	    netGrowth36?: number;
+	    netGrowth60?: number;
+	    netGrowthYTD?: number;
+	    netGrowthAll?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FundBasic(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fullName = source["fullName"];
+	        this.type = source["type"];
+	        this.establishment = source["establishment"];
+	        this.scale = source["scale"];
+	        this.company = source["company"];
+	        this.manager = source["manager"];
+	        this.rating = source["rating"];
+	        this.trackingTarget = source["trackingTarget"];
+	        this.netUnitValue = source["netUnitValue"];
+	        this.netUnitValueDate = source["netUnitValueDate"];
+	        this.netEstimatedUnit = source["netEstimatedUnit"];
+	        this.netEstimatedUnitTime = source["netEstimatedUnitTime"];
+	        this.netAccumulated = source["netAccumulated"];
+	        this.netGrowth1 = source["netGrowth1"];
+	        this.netGrowth3 = source["netGrowth3"];
+	        this.netGrowth6 = source["netGrowth6"];
+	        this.netGrowth12 = source["netGrowth12"];
+	        this.netGrowth36 = source["netGrowth36"];
+	        this.netGrowth60 = source["netGrowth60"];
+	        this.netGrowthYTD = source["netGrowthYTD"];
+	        this.netGrowthAll = source["netGrowthAll"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FollowedFund {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    code: string;
+	    name: string;
 	    netUnitValue?: number;
 	    netUnitValueDate: string;
 	    netEstimatedUnit?: number;
 	    netEstimatedUnitTime: string;
 	    netAccumulated?: number;
-	    netGrowth1?: number;
-	    netGrowth3?: number;
-	    netGrowth6?: number;
-	    netGrowth12?: number;
-	    netGrowth36?: number;
-	    netGrowth60?: number;
-	    netGrowthYTD?: number;
-	    netGrowthAll?: number;
+	    netEstimatedRate?: number;
+	    fundBasic: FundBasic;
+	
+	    static createFrom(source: any = {}) {
+	        return new FollowedFund(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.netUnitValue = source["netUnitValue"];
+	        this.netUnitValueDate = source["netUnitValueDate"];
+	        this.netEstimatedUnit = source["netEstimatedUnit"];
+	        this.netEstimatedUnitTime = source["netEstimatedUnitTime"];
+	        this.netAccumulated = source["netAccumulated"];
+	        this.netEstimatedRate = source["netEstimatedRate"];
+	        this.fundBasic = this.convertValues(source["fundBasic"], FundBasic);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Group {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    name: string;
+	    sort: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Group(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.name = source["name"];
+	        this.sort = source["sort"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class GroupStock {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    stockCode: string;
+	    groupId: number;
+	    groupInfo: Group;
+	
+	    static createFrom(source: any = {}) {
+	        return new GroupStock(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.stockCode = source["stockCode"];
+	        this.groupId = source["groupId"];
+	        this.groupInfo = this.convertValues(source["groupInfo"], Group);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FollowedStock {
+	    StockCode: string;
+	    Name: string;
+	    Volume: number;
+	    CostPrice: number;
+	    Price: number;
+	    PriceChange: number;
+	    ChangePercent: number;
+	    AlarmChangePercent: number;
+	    AlarmPrice: number;
+	    // Go type: time
+	    Time: any;
+	    Sort: number;
+	    Cron?: string;
+	    AiModelId: number;
+	    IsDel: number;
+	    Groups: GroupStock[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FollowedStock(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.StockCode = source["StockCode"];
+	        this.Name = source["Name"];
+	        this.Volume = source["Volume"];
+	        this.CostPrice = source["CostPrice"];
+	        this.Price = source["Price"];
+	        this.PriceChange = source["PriceChange"];
+	        this.ChangePercent = source["ChangePercent"];
+	        this.AlarmChangePercent = source["AlarmChangePercent"];
+	        this.AlarmPrice = source["AlarmPrice"];
+	        this.Time = this.convertValues(source["Time"], null);
+	        this.Sort = source["Sort"];
+	        this.Cron = source["Cron"];
+	        this.AiModelId = source["AiModelId"];
+	        this.IsDel = source["IsDel"];
+	        this.Groups = this.convertValues(source["Groups"], GroupStock);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	export class Lot {
+	    // Go type: time
+	    tradeDate: any;
+	    quantity: number;
+	    costPrice: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Lot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tradeDate = this.convertValues(source["tradeDate"], null);
+	        this.quantity = source["quantity"];
+	        this.costPrice = source["costPrice"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NotificationChannelSetting {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    type: string;
+	    name: string;
+	    url: string;
+	    token: string;
+	    secret: string;
+	    chatId: string;
+	    host: string;
+	    port: number;
+	    username: string;
+	    password: string;
+	    from: string;
+	    to: string;
+	    enabled: boolean;
+	    cooldownSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NotificationChannelSetting(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.type = source["type"];
+	        this.name = source["name"];
+	        this.url = source["url"];
+	        this.token = source["token"];
+	        this.secret = source["secret"];
+	        this.chatId = source["chatId"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.from = source["from"];
+	        this.to = source["to"];
+	        this.enabled = source["enabled"];
+	        this.cooldownSeconds = source["cooldownSeconds"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NotificationLog {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    channelId: number;
+	    channel: string;
+	    type: string;
+	    title: string;
+	    content: string;
+	    stockCode: string;
+	    success: boolean;
+	    error: string;
+	    durationMs: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new FundBasic(source);
+	        return new NotificationLog(source);
 	    }
 	
 	    constructor(source: any = {}) {
@@ -42,29 +1146,15 @@ export namespace data {
 	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
 	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
 	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
-	        this.code = source["code"];
-	        this.name = source["name"];
-	        this.fullName = source["fullName"];
+	        this.channelId = source["channelId"];
+	        this.channel = source["channel"];
 	        this.type = source["type"];
-	        this.establishment = source["establishment"];
-	        this.scale = source["scale"];
-	        this.company = source["company"];
-	        this.manager = source["manager"];
-	        this.rating = source["rating"];
-	        this.trackingTarget = source["trackingTarget"];
-	        this.netUnitValue = source["netUnitValue"];
-	        this.netUnitValueDate = source["netUnitValueDate"];
-	        this.netEstimatedUnit = source["netEstimatedUnit"];
-	        this.netEstimatedUnitTime = source["netEstimatedUnitTime"];
-	        this.netAccumulated = source["netAccumulated"];
-	        this.netGrowth1 = source["netGrowth1"];
-	        this.netGrowth3 = source["netGrowth3"];
-	        this.netGrowth6 = source["netGrowth6"];
-	        this.netGrowth12 = source["netGrowth12"];
-	        this.netGrowth36 = source["netGrowth36"];
-	        this.netGrowth60 = source["netGrowth60"];
-	        this.netGrowthYTD = source["netGrowthYTD"];
-	        this.netGrowthAll = source["netGrowthAll"];
+	        this.title = source["title"];
+	        this.content = source["content"];
+	        this.stockCode = source["stockCode"];
+	        this.success = source["success"];
+	        this.error = source["error"];
+	        this.durationMs = source["durationMs"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -85,43 +1175,219 @@ export namespace data {
 		    return a;
 		}
 	}
-	export class FollowedFund {
-	    ID: number;
-	    // Go type: time
-	    CreatedAt: any;
-	    // Go type: time
-	    UpdatedAt: any;
-	    // Go type: gorm
-	    DeletedAt: any;
+	export class PerformancePoint {
+	    date: string;
+	    marketValue: number;
+	    dailyReturn: number;
+	    nav: number;
+	    drawdown: number;
+	    benchmarkNav: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PerformancePoint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.date = source["date"];
+	        this.marketValue = source["marketValue"];
+	        this.dailyReturn = source["dailyReturn"];
+	        this.nav = source["nav"];
+	        this.drawdown = source["drawdown"];
+	        this.benchmarkNav = source["benchmarkNav"];
+	    }
+	}
+	export class PortfolioPerformance {
+	    account: string;
+	    groupId: number;
+	    benchmark: string;
+	    startDate: string;
+	    endDate: string;
+	    days: number;
+	    totalPnL: number;
+	    cumulativeReturn: number;
+	    annualizedReturn: number;
+	    maxDrawdown: number;
+	    volatility: number;
+	    sharpe: number;
+	    benchmarkReturn: number;
+	    excessReturn: number;
+	    points: PerformancePoint[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PortfolioPerformance(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.account = source["account"];
+	        this.groupId = source["groupId"];
+	        this.benchmark = source["benchmark"];
+	        this.startDate = source["startDate"];
+	        this.endDate = source["endDate"];
+	        this.days = source["days"];
+	        this.totalPnL = source["totalPnL"];
+	        this.cumulativeReturn = source["cumulativeReturn"];
+	        this.annualizedReturn = source["annualizedReturn"];
+	        this.maxDrawdown = source["maxDrawdown"];
+	        this.volatility = source["volatility"];
+	        this.sharpe = source["sharpe"];
+	        this.benchmarkReturn = source["benchmarkReturn"];
+	        this.excessReturn = source["excessReturn"];
+	        this.points = this.convertValues(source["points"], PerformancePoint);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PortfolioSnapshot {
+	    date: string;
+	    account: string;
+	    groupId: number;
+	    marketValue: number;
+	    costBasis: number;
+	    cashFlow: number;
+	    dailyPnL: number;
+	    realizedPnL: number;
+	    positions: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PortfolioSnapshot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.date = source["date"];
+	        this.account = source["account"];
+	        this.groupId = source["groupId"];
+	        this.marketValue = source["marketValue"];
+	        this.costBasis = source["costBasis"];
+	        this.cashFlow = source["cashFlow"];
+	        this.dailyPnL = source["dailyPnL"];
+	        this.realizedPnL = source["realizedPnL"];
+	        this.positions = source["positions"];
+	    }
+	}
+	export class Position {
+	    stockCode: string;
+	    account: string;
+	    method: string;
+	    quantity: number;
+	    costBasis: number;
+	    avgCost: number;
+	    realizedPnL: number;
+	    dividends: number;
+	    fees: number;
+	    lots: Lot[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Position(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stockCode = source["stockCode"];
+	        this.account = source["account"];
+	        this.method = source["method"];
+	        this.quantity = source["quantity"];
+	        this.costBasis = source["costBasis"];
+	        this.avgCost = source["avgCost"];
+	        this.realizedPnL = source["realizedPnL"];
+	        this.dividends = source["dividends"];
+	        this.fees = source["fees"];
+	        this.lots = this.convertValues(source["lots"], Lot);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class QuoteLevel {
+	    price: number;
+	    volume: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new QuoteLevel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.price = source["price"];
+	        this.volume = source["volume"];
+	    }
+	}
+	export class Quote {
 	    code: string;
 	    name: string;
-	    netUnitValue?: number;
-	    netUnitValueDate: string;
-	    netEstimatedUnit?: number;
-	    netEstimatedUnitTime: string;
-	    netAccumulated?: number;
-	    netEstimatedRate?: number;
-	    fundBasic: FundBasic;
+	    market: string;
+	    // Go type: time
+	    time: any;
+	    price: number;
+	    preClose: number;
+	    open: number;
+	    high: number;
+	    low: number;
+	    bid: number;
+	    ask: number;
+	    volume: number;
+	    amount: number;
+	    bids: QuoteLevel[];
+	    asks: QuoteLevel[];
+	    extendedPrice: number;
+	    extendedChangePercent: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new FollowedFund(source);
+	        return new Quote(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.ID = source["ID"];
-	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
-	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
-	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
 	        this.code = source["code"];
 	        this.name = source["name"];
-	        this.netUnitValue = source["netUnitValue"];
-	        this.netUnitValueDate = source["netUnitValueDate"];
-	        this.netEstimatedUnit = source["netEstimatedUnit"];
-	        this.netEstimatedUnitTime = source["netEstimatedUnitTime"];
-	        this.netAccumulated = source["netAccumulated"];
-	        this.netEstimatedRate = source["netEstimatedRate"];
-	        this.fundBasic = this.convertValues(source["fundBasic"], FundBasic);
+	        this.market = source["market"];
+	        this.time = this.convertValues(source["time"], null);
+	        this.price = source["price"];
+	        this.preClose = source["preClose"];
+	        this.open = source["open"];
+	        this.high = source["high"];
+	        this.low = source["low"];
+	        this.bid = source["bid"];
+	        this.ask = source["ask"];
+	        this.volume = source["volume"];
+	        this.amount = source["amount"];
+	        this.bids = this.convertValues(source["bids"], QuoteLevel);
+	        this.asks = this.convertValues(source["asks"], QuoteLevel);
+	        this.extendedPrice = source["extendedPrice"];
+	        this.extendedChangePercent = source["extendedChangePercent"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -143,29 +1409,70 @@ export namespace data {
 		}
 	}
 	
-	export class Group {
-	    ID: number;
+	export class QuoteSnapshot {
+	    id: number;
 	    // Go type: time
-	    CreatedAt: any;
+	    createdAt: any;
+	    code: string;
 	    // Go type: time
-	    UpdatedAt: any;
-	    // Go type: gorm
-	    DeletedAt: any;
-	    name: string;
-	    sort: number;
+	    snapshotTime: any;
+	    price: number;
+	    volume: number;
+	    amount: number;
+	    b1p: number;
+	    b1v: number;
+	    b2p: number;
+	    b2v: number;
+	    b3p: number;
+	    b3v: number;
+	    b4p: number;
+	    b4v: number;
+	    b5p: number;
+	    b5v: number;
+	    a1p: number;
+	    a1v: number;
+	    a2p: number;
+	    a2v: number;
+	    a3p: number;
+	    a3v: number;
+	    a4p: number;
+	    a4v: number;
+	    a5p: number;
+	    a5v: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new Group(source);
+	        return new QuoteSnapshot(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.ID = source["ID"];
-	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
-	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
-	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
-	        this.name = source["name"];
-	        this.sort = source["sort"];
+	        this.id = source["id"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.code = source["code"];
+	        this.snapshotTime = this.convertValues(source["snapshotTime"], null);
+	        this.price = source["price"];
+	        this.volume = source["volume"];
+	        this.amount = source["amount"];
+	        this.b1p = source["b1p"];
+	        this.b1v = source["b1v"];
+	        this.b2p = source["b2p"];
+	        this.b2v = source["b2v"];
+	        this.b3p = source["b3p"];
+	        this.b3v = source["b3v"];
+	        this.b4p = source["b4p"];
+	        this.b4v = source["b4v"];
+	        this.b5p = source["b5p"];
+	        this.b5v = source["b5v"];
+	        this.a1p = source["a1p"];
+	        this.a1v = source["a1v"];
+	        this.a2p = source["a2p"];
+	        this.a2v = source["a2v"];
+	        this.a3p = source["a3p"];
+	        this.a3v = source["a3v"];
+	        this.a4p = source["a4p"];
+	        this.a4v = source["a4v"];
+	        this.a5p = source["a5p"];
+	        this.a5v = source["a5v"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -186,31 +1493,31 @@ export namespace data {
 		    return a;
 		}
 	}
-	export class GroupStock {
-	    ID: number;
+	export class QuoteSourceStatus {
+	    name: string;
+	    healthy: boolean;
+	    failures: number;
+	    lastError: string;
 	    // Go type: time
-	    CreatedAt: any;
+	    lastSuccess: any;
 	    // Go type: time
-	    UpdatedAt: any;
-	    // Go type: gorm
-	    DeletedAt: any;
-	    stockCode: string;
-	    groupId: number;
-	    groupInfo: Group;
+	    lastFailure: any;
+	    // Go type: time
+	    coolDownUntil: any;
 	
 	    static createFrom(source: any = {}) {
-	        return new GroupStock(source);
+	        return new QuoteSourceStatus(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.ID = source["ID"];
-	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
-	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
-	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
-	        this.stockCode = source["stockCode"];
-	        this.groupId = source["groupId"];
-	        this.groupInfo = this.convertValues(source["groupInfo"], Group);
+	        this.name = source["name"];
+	        this.healthy = source["healthy"];
+	        this.failures = source["failures"];
+	        this.lastError = source["lastError"];
+	        this.lastSuccess = this.convertValues(source["lastSuccess"], null);
+	        this.lastFailure = this.convertValues(source["lastFailure"], null);
+	        this.coolDownUntil = this.convertValues(source["coolDownUntil"], null);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -243,6 +1550,9 @@ export namespace data {
 	    localPushEnable: boolean;
 	    dingPushEnable: boolean;
 	    dingRobot: string;
+	    dingSecret: string;
+	    dingAtMobiles: string;
+	    dingAtUserIds: string;
 	    updateBasicInfoOnStart: boolean;
 	    refreshInterval: number;
 	    openAiEnable: boolean;
@@ -263,6 +1573,26 @@ export namespace data {
 	    darkTheme: boolean;
 	    browserPoolSize: number;
 	    enableFund: boolean;
+	    quoteSourcesCN: string;
+	    quoteSourcesHK: string;
+	    quoteSourcesUS: string;
+	    quoteSnapshotEnable: boolean;
+	    quoteSnapshotRetentionDays: number;
+	    kLineIndicatorSummary: boolean;
+	    costMethod: string;
+	    alertChangeCooldown: number;
+	    alertPriceCooldown: number;
+	    alertCostCooldown: number;
+	    alertQuietHours: string;
+	    alertWeekendMute: boolean;
+	    alertHysteresis: number;
+	    limitAlertEnable: boolean;
+	    limitAlertTicks: number;
+	    aiToolsEnable: boolean;
+	    aiToolCallLimit: number;
+	    aiDailyBudget: number;
+	    aiMonthlyBudget: number;
+	    aiStructuredOutput: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new Settings(source);
@@ -278,6 +1608,9 @@ export namespace data {
 	        this.localPushEnable = source["localPushEnable"];
 	        this.dingPushEnable = source["dingPushEnable"];
 	        this.dingRobot = source["dingRobot"];
+	        this.dingSecret = source["dingSecret"];
+	        this.dingAtMobiles = source["dingAtMobiles"];
+	        this.dingAtUserIds = source["dingAtUserIds"];
 	        this.updateBasicInfoOnStart = source["updateBasicInfoOnStart"];
 	        this.refreshInterval = source["refreshInterval"];
 	        this.openAiEnable = source["openAiEnable"];
@@ -298,6 +1631,26 @@ export namespace data {
 	        this.darkTheme = source["darkTheme"];
 	        this.browserPoolSize = source["browserPoolSize"];
 	        this.enableFund = source["enableFund"];
+	        this.quoteSourcesCN = source["quoteSourcesCN"];
+	        this.quoteSourcesHK = source["quoteSourcesHK"];
+	        this.quoteSourcesUS = source["quoteSourcesUS"];
+	        this.quoteSnapshotEnable = source["quoteSnapshotEnable"];
+	        this.quoteSnapshotRetentionDays = source["quoteSnapshotRetentionDays"];
+	        this.kLineIndicatorSummary = source["kLineIndicatorSummary"];
+	        this.costMethod = source["costMethod"];
+	        this.alertChangeCooldown = source["alertChangeCooldown"];
+	        this.alertPriceCooldown = source["alertPriceCooldown"];
+	        this.alertCostCooldown = source["alertCostCooldown"];
+	        this.alertQuietHours = source["alertQuietHours"];
+	        this.alertWeekendMute = source["alertWeekendMute"];
+	        this.alertHysteresis = source["alertHysteresis"];
+	        this.limitAlertEnable = source["limitAlertEnable"];
+	        this.limitAlertTicks = source["limitAlertTicks"];
+	        this.aiToolsEnable = source["aiToolsEnable"];
+	        this.aiToolCallLimit = source["aiToolCallLimit"];
+	        this.aiDailyBudget = source["aiDailyBudget"];
+	        this.aiMonthlyBudget = source["aiMonthlyBudget"];
+	        this.aiStructuredOutput = source["aiStructuredOutput"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -445,10 +1798,15 @@ export namespace data {
 	    profit: number;
 	    profitAmount: number;
 	    profitAmountToday: number;
+	    realizedProfit: number;
+	    limitUpPrice: number;
+	    limitDownPrice: number;
+	    limitStatus: string;
 	    sort: number;
 	    alarmChangePercent: number;
 	    alarmPrice: number;
 	    Groups: GroupStock[];
+	    quote?: Quote;
 	
 	    static createFrom(source: any = {}) {
 	        return new StockInfo(source);
@@ -506,10 +1864,75 @@ export namespace data {
 	        this.profit = source["profit"];
 	        this.profitAmount = source["profitAmount"];
 	        this.profitAmountToday = source["profitAmountToday"];
+	        this.realizedProfit = source["realizedProfit"];
+	        this.limitUpPrice = source["limitUpPrice"];
+	        this.limitDownPrice = source["limitDownPrice"];
+	        this.limitStatus = source["limitStatus"];
 	        this.sort = source["sort"];
 	        this.alarmChangePercent = source["alarmChangePercent"];
 	        this.alarmPrice = source["alarmPrice"];
 	        this.Groups = this.convertValues(source["Groups"], GroupStock);
+	        this.quote = this.convertValues(source["quote"], Quote);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Trade {
+	    ID: number;
+	    // Go type: time
+	    CreatedAt: any;
+	    // Go type: time
+	    UpdatedAt: any;
+	    // Go type: gorm
+	    DeletedAt: any;
+	    stockCode: string;
+	    account: string;
+	    type: string;
+	    price: number;
+	    quantity: number;
+	    ratio: number;
+	    amount: number;
+	    fee: number;
+	    // Go type: time
+	    tradeDate: any;
+	    remark: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Trade(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.CreatedAt = this.convertValues(source["CreatedAt"], null);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	        this.DeletedAt = this.convertValues(source["DeletedAt"], null);
+	        this.stockCode = source["stockCode"];
+	        this.account = source["account"];
+	        this.type = source["type"];
+	        this.price = source["price"];
+	        this.quantity = source["quantity"];
+	        this.ratio = source["ratio"];
+	        this.amount = source["amount"];
+	        this.fee = source["fee"];
+	        this.tradeDate = this.convertValues(source["tradeDate"], null);
+	        this.remark = source["remark"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -533,6 +1956,27 @@ export namespace data {
 
 }
 
+export namespace indicator {
+	
+	export class Result {
+	    days: string[];
+	    close: number[];
+	    values: Record<string, number[]>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Result(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.days = source["days"];
+	        this.close = source["close"];
+	        this.values = source["values"];
+	    }
+	}
+
+}
+
 export namespace models {
 	
 	export class AIResponseResult {
@@ -593,6 +2037,7 @@ export namespace models {
 	    name: string;
 	    content: string;
 	    type: string;
+	    contextSections: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Prompt(source);
@@ -604,6 +2049,7 @@ export namespace models {
 	        this.name = source["name"];
 	        this.content = source["content"];
 	        this.type = source["type"];
+	        this.contextSections = source["contextSections"];
 	    }
 	}
 	export class VersionInfo {
@@ -633,3 +2079,22 @@ export namespace models {
 
 }
 
+export namespace services {
+	
+	export class RuleField {
+	    name: string;
+	    description: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RuleField(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	    }
+	}
+
+}
+
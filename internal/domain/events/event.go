@@ -22,6 +22,9 @@ const (
 	// AlertTriggered is triggered when a stock alert condition is met.
 	AlertTriggered EventType = "ALERT_TRIGGERED"
 
+	// AlertRuleTriggered is triggered when a composite alert rule fires.
+	AlertRuleTriggered EventType = "ALERT_RULE_TRIGGERED"
+
 	// SettingsChanged is triggered when user settings are updated.
 	SettingsChanged EventType = "SETTINGS_CHANGED"
 
@@ -33,6 +36,9 @@ const (
 
 	// MarketClosed is triggered when the market closes.
 	MarketClosed EventType = "MARKET_CLOSED"
+
+	// MarketPhaseChanged is triggered when a market moves to another trading phase.
+	MarketPhaseChanged EventType = "MARKET_PHASE_CHANGED"
 )
 
 // Event is the base interface for all domain events.
@@ -120,6 +126,26 @@ func NewAlertTriggeredEvent(alert *models.StockAlert, stock *models.StockInfo) E
 	return event
 }
 
+// AlertRuleTriggeredEvent is triggered when a composite alert rule fires.
+type AlertRuleTriggeredEvent struct {
+	BaseEvent
+	Rule   *models.AlertRule
+	Values models.RuleValues
+}
+
+// NewAlertRuleTriggeredEvent creates a new alert rule triggered event.
+func NewAlertRuleTriggeredEvent(rule *models.AlertRule, values models.RuleValues) Event {
+	event := AlertRuleTriggeredEvent{
+		Rule:   rule,
+		Values: values,
+	}
+	event.EventType = AlertRuleTriggered
+	event.OccurredAt = time.Now()
+	event.Data = event // Self-reference for payload
+
+	return event
+}
+
 // SettingsChangedEvent is triggered when user settings are updated.
 type SettingsChangedEvent struct {
 	BaseEvent
@@ -143,8 +169,10 @@ func NewSettingsChangedEvent(oldSettings, newSettings *models.Settings) Event {
 // MarketEvent represents market opening or closing events.
 type MarketEvent struct {
 	BaseEvent
-	Exchange string
-	IsOpen   bool
+	Exchange      string
+	IsOpen        bool
+	Phase         string // Trading phase after the transition, empty if unknown
+	PreviousPhase string // Trading phase before the transition, empty if unknown
 }
 
 // NewMarketOpenedEvent creates a new market opened event.
@@ -172,3 +200,18 @@ func NewMarketClosedEvent(exchange string) Event {
 
 	return event
 }
+
+// NewMarketSessionEvent creates a market event that carries the phase transition.
+func NewMarketSessionEvent(eventType EventType, exchange, previousPhase, phase string, isOpen bool) Event {
+	event := MarketEvent{
+		Exchange:      exchange,
+		IsOpen:        isOpen,
+		Phase:         phase,
+		PreviousPhase: previousPhase,
+	}
+	event.EventType = eventType
+	event.OccurredAt = time.Now()
+	event.Data = event // Self-reference for payload
+
+	return event
+}
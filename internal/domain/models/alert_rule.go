@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// TriggerMode determines when a satisfied alert rule fires.
+type TriggerMode string
+
+const (
+	// TriggerEdge fires only when the condition changes from false to true
+	TriggerEdge TriggerMode = "EDGE"
+	// TriggerLevel fires on every evaluation while the condition holds, limited by the cooldown
+	TriggerLevel TriggerMode = "LEVEL"
+)
+
+// AlertRule represents a composite alert expressed over quote fields and derived values,
+// e.g. "price > prev_high AND volume_ratio >= 2".
+type AlertRule struct {
+	ID            string        `json:"id"`            // Unique identifier
+	StockCode     string        `json:"stockCode"`     // Stock code the rule applies to
+	Name          string        `json:"name"`          // Display name
+	Expression    string        `json:"expression"`    // Rule expression
+	TriggerMode   TriggerMode   `json:"triggerMode"`   // Edge or level triggering
+	Cooldown      time.Duration `json:"cooldown"`      // Minimum time between two notifications
+	IsActive      bool          `json:"isActive"`      // Whether the rule is evaluated
+	Triggered     bool          `json:"triggered"`     // Result of the last evaluation
+	LastTriggered time.Time     `json:"lastTriggered"` // When the rule last fired
+	CreatedAt     time.Time     `json:"createdAt"`     // When the rule was created
+	Channels      []string      `json:"channels"`      // Notification channel IDs, empty for all enabled channels
+}
+
+// RuleValues holds the quote fields and derived values of a stock keyed by field name.
+type RuleValues map[string]float64
@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-stock/internal/domain/models"
+)
+
+// AlertRuleRepository defines the interface for alert rule data access.
+type AlertRuleRepository interface {
+	// GetRules retrieves all rules for a stock, all rules when stockCode is empty.
+	GetRules(ctx context.Context, stockCode string) ([]*models.AlertRule, error)
+
+	// GetActiveRules retrieves all active rules.
+	GetActiveRules(ctx context.Context) ([]*models.AlertRule, error)
+
+	// SaveRule creates a rule, or updates it when the ID is set.
+	SaveRule(ctx context.Context, rule *models.AlertRule) error
+
+	// DeleteRule deletes a rule.
+	DeleteRule(ctx context.Context, ruleID string) error
+
+	// UpdateRuleStatus updates the active flag and evaluation state of a rule.
+	UpdateRuleStatus(ctx context.Context, ruleID string, isActive, triggered bool, lastTriggered time.Time) error
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go-stock/internal/domain/events"
+	"go-stock/internal/domain/models"
+	"go-stock/internal/domain/repositories"
+)
+
+// RuleValueSource provides the values of stocks referenced by alert rules.
+type RuleValueSource interface {
+	// RuleValues returns the current values keyed by lower-case stock code.
+	RuleValues(ctx context.Context, stockCodes []string) (map[string]models.RuleValues, error)
+}
+
+// SetAlertRules enables composite alert rules. The source is optional; without it
+// rules are only evaluated when CheckRules is called with values.
+func (s *StockMonitorService) SetAlertRules(
+	ruleRepo repositories.AlertRuleRepository,
+	source RuleValueSource,
+	notifyFunc func(rule *models.AlertRule, values models.RuleValues),
+) *StockMonitorService {
+	s.ruleMutex.Lock()
+	defer s.ruleMutex.Unlock()
+
+	s.ruleRepo = ruleRepo
+	s.ruleSource = source
+	s.ruleNotifyFunc = notifyFunc
+	s.ruleExprs = make(map[string]*RuleExpression)
+	return s
+}
+
+// checkRuleSource evaluates the active rules with values from the rule source.
+func (s *StockMonitorService) checkRuleSource(ctx context.Context) error {
+	if s.ruleRepo == nil || s.ruleSource == nil {
+		return nil
+	}
+	rules, err := s.ruleRepo.GetActiveRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get active rules: %w", err)
+	}
+	codes := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		codes = append(codes, rule.StockCode)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	values, err := s.ruleSource.RuleValues(ctx, codes)
+	if err != nil {
+		return fmt.Errorf("failed to get rule values: %w", err)
+	}
+	return s.CheckRules(ctx, values, time.Now())
+}
+
+// CheckRules evaluates the active rules of the stocks present in values, keyed by lower-case stock code.
+// Rules of other stocks keep their state.
+func (s *StockMonitorService) CheckRules(ctx context.Context, values map[string]models.RuleValues, now time.Time) error {
+	s.ruleMutex.Lock()
+	defer s.ruleMutex.Unlock()
+
+	if s.ruleRepo == nil {
+		return nil
+	}
+	rules, err := s.ruleRepo.GetActiveRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get active rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		stockValues, ok := values[strings.ToLower(rule.StockCode)]
+		if !ok {
+			continue
+		}
+		expr, err := s.ruleExpression(rule)
+		if err != nil {
+			log.Printf("Invalid expression of rule %s: %v", rule.ID, err)
+			continue
+		}
+		matched, err := expr.Evaluate(stockValues)
+		if err != nil {
+			log.Printf("Failed to evaluate rule %s: %v", rule.ID, err)
+			continue
+		}
+
+		fire := matched
+		if rule.TriggerMode != models.TriggerLevel {
+			fire = matched && !rule.Triggered
+		}
+		if fire && rule.Cooldown > 0 && !rule.LastTriggered.IsZero() && now.Sub(rule.LastTriggered) < rule.Cooldown {
+			fire = false
+		}
+		if !fire && matched == rule.Triggered {
+			continue
+		}
+
+		rule.Triggered = matched
+		if fire {
+			rule.LastTriggered = now
+		}
+		if err := s.ruleRepo.UpdateRuleStatus(ctx, rule.ID, rule.IsActive, rule.Triggered, rule.LastTriggered); err != nil {
+			log.Printf("Failed to update rule status: %v", err)
+			continue
+		}
+		if !fire {
+			continue
+		}
+
+		s.eventDispatcher.Dispatch(events.NewAlertRuleTriggeredEvent(rule, stockValues))
+		if s.ruleNotifyFunc != nil {
+			s.ruleNotifyFunc(rule, stockValues)
+		}
+	}
+	return nil
+}
+
+// ruleExpression returns the parsed expression of a rule, reparsing when the expression changed.
+func (s *StockMonitorService) ruleExpression(rule *models.AlertRule) (*RuleExpression, error) {
+	if expr, ok := s.ruleExprs[rule.ID]; ok && expr.String() == rule.Expression {
+		return expr, nil
+	}
+	expr, err := ParseRuleExpression(rule.Expression)
+	if err != nil {
+		return nil, err
+	}
+	s.ruleExprs[rule.ID] = expr
+	return expr, nil
+}
+
+// SaveRule validates and saves an alert rule, creating it when the ID is empty.
+// Saving resets the evaluation state so an edge rule fires again on the next match.
+func (s *StockMonitorService) SaveRule(ctx context.Context, rule *models.AlertRule) error {
+	if s.ruleRepo == nil {
+		return fmt.Errorf("alert rules are not enabled")
+	}
+	rule.StockCode = strings.TrimSpace(rule.StockCode)
+	if rule.StockCode == "" {
+		return fmt.Errorf("%w: stock code is required", ErrInvalidInput)
+	}
+	if _, err := ParseRuleExpression(rule.Expression); err != nil {
+		return err
+	}
+	switch rule.TriggerMode {
+	case models.TriggerEdge, models.TriggerLevel:
+	case "":
+		rule.TriggerMode = models.TriggerEdge
+	default:
+		return fmt.Errorf("%w: invalid trigger mode: %s", ErrInvalidInput, rule.TriggerMode)
+	}
+	if rule.Cooldown < 0 {
+		return fmt.Errorf("%w: cooldown must not be negative", ErrInvalidInput)
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	rule.Triggered = false
+
+	if err := s.ruleRepo.SaveRule(ctx, rule); err != nil {
+		return fmt.Errorf("failed to save rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule deletes an alert rule.
+func (s *StockMonitorService) DeleteRule(ctx context.Context, ruleID string) error {
+	if s.ruleRepo == nil {
+		return fmt.Errorf("alert rules are not enabled")
+	}
+	if ruleID == "" {
+		return fmt.Errorf("%w: rule ID is required", ErrInvalidInput)
+	}
+	if err := s.ruleRepo.DeleteRule(ctx, ruleID); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return nil
+}
+
+// SetRuleActive enables or disables an alert rule and resets its evaluation state.
+func (s *StockMonitorService) SetRuleActive(ctx context.Context, ruleID string, active bool) error {
+	if s.ruleRepo == nil {
+		return fmt.Errorf("alert rules are not enabled")
+	}
+	rules, err := s.ruleRepo.GetRules(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get rules: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.ID == ruleID {
+			if err := s.ruleRepo.UpdateRuleStatus(ctx, ruleID, active, false, rule.LastTriggered); err != nil {
+				return fmt.Errorf("failed to update rule status: %w", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: rule with ID %s not found", ErrInvalidInput, ruleID)
+}
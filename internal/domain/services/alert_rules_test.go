@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-stock/internal/domain/events"
+	"go-stock/internal/domain/models"
+)
+
+func TestParseRuleExpression(t *testing.T) {
+	values := models.RuleValues{"price": 10.5, "prev_high": 10.2, "volume_ratio": 2.5, "change_pct": -3.2, "new_high": 1}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"price > prev_high", true},
+		{"price > prev_high AND volume_ratio >= 3", false},
+		{"price > prev_high and volume_ratio >= 3 or change_pct <= -3", true},
+		{"price > prev_high && (volume_ratio >= 3 || change_pct < -3)", true},
+		{"NOT new_high", false},
+		{"!(price < 10) && new_high", true},
+		{"change_pct > -3.5", true},
+		{"price = 10.5", true},
+		{"price != 10.5", false},
+	}
+	for _, c := range cases {
+		expr, err := ParseRuleExpression(c.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.expr, err)
+		}
+		got, err := expr.Evaluate(values)
+		if err != nil {
+			t.Fatalf("evaluate %q: %v", c.expr, err)
+		}
+		if got != c.want {
+			t.Errorf("%q = %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "price >", "price > unknown", "(price > 1", "price > 1 2", "price # 1"} {
+		if _, err := ParseRuleExpression(invalid); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: expected invalid input, got %v", invalid, err)
+		}
+	}
+
+	expr, _ := ParseRuleExpression("ma20_distance > 5 or price > ma20")
+	if _, err := expr.Evaluate(values); err == nil {
+		t.Error("expected error for missing value")
+	}
+	if fields := expr.Fields(); len(fields) != 3 || fields[0] != "ma20" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
+
+type memoryRuleRepo struct {
+	rules map[string]*models.AlertRule
+}
+
+func (r *memoryRuleRepo) GetRules(ctx context.Context, stockCode string) ([]*models.AlertRule, error) {
+	res := make([]*models.AlertRule, 0)
+	for _, rule := range r.rules {
+		if stockCode == "" || rule.StockCode == stockCode {
+			copied := *rule
+			res = append(res, &copied)
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryRuleRepo) GetActiveRules(ctx context.Context) ([]*models.AlertRule, error) {
+	rules, _ := r.GetRules(ctx, "")
+	res := make([]*models.AlertRule, 0)
+	for _, rule := range rules {
+		if rule.IsActive {
+			res = append(res, rule)
+		}
+	}
+	return res, nil
+}
+
+func (r *memoryRuleRepo) SaveRule(ctx context.Context, rule *models.AlertRule) error {
+	if rule.ID == "" {
+		rule.ID = rule.Name
+	}
+	copied := *rule
+	r.rules[rule.ID] = &copied
+	return nil
+}
+
+func (r *memoryRuleRepo) DeleteRule(ctx context.Context, ruleID string) error {
+	delete(r.rules, ruleID)
+	return nil
+}
+
+func (r *memoryRuleRepo) UpdateRuleStatus(ctx context.Context, ruleID string, isActive, triggered bool, lastTriggered time.Time) error {
+	rule := r.rules[ruleID]
+	rule.IsActive = isActive
+	rule.Triggered = triggered
+	rule.LastTriggered = lastTriggered
+	return nil
+}
+
+func TestCheckRules(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRuleRepo{rules: make(map[string]*models.AlertRule)}
+	dispatcher := events.NewSimpleEventDispatcher()
+	fired := make([]string, 0)
+	dispatcher.Register(events.AlertRuleTriggered, func(event events.Event) {
+		fired = append(fired, event.(events.AlertRuleTriggeredEvent).Rule.Name)
+	})
+	s := NewStockMonitorService(nil, nil, dispatcher, nil).SetAlertRules(repo, nil, nil)
+
+	for _, rule := range []*models.AlertRule{
+		{Name: "edge", StockCode: "SH600000", Expression: "price > 10", IsActive: true},
+		{Name: "level", StockCode: "sh600000", Expression: "price > 10", TriggerMode: models.TriggerLevel, Cooldown: time.Minute, IsActive: true},
+	} {
+		if err := s.SaveRule(ctx, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveRule(ctx, &models.AlertRule{StockCode: "sh600000", Expression: "price >"}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected invalid input, got %v", err)
+	}
+
+	start := time.Date(2025, 5, 20, 10, 0, 0, 0, time.UTC)
+	check := func(price float64, offset time.Duration) []string {
+		fired = fired[:0]
+		if err := s.CheckRules(ctx, map[string]models.RuleValues{"sh600000": {"price": price}}, start.Add(offset)); err != nil {
+			t.Fatal(err)
+		}
+		res := append([]string{}, fired...)
+		if len(res) == 2 && res[0] == "level" {
+			res[0], res[1] = res[1], res[0]
+		}
+		return res
+	}
+	assertFired := func(got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("fired %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("fired %v, want %v", got, want)
+			}
+		}
+	}
+
+	assertFired(check(9, 0))
+	assertFired(check(11, time.Second), "edge", "level")
+	// 边沿触发只在条件由假变真时触发,电平触发受冷却时间限制
+	assertFired(check(12, 30*time.Second))
+	assertFired(check(12, 2*time.Minute), "level")
+	assertFired(check(9, 3*time.Minute))
+	assertFired(check(11, 4*time.Minute), "edge", "level")
+
+	// 其他股票的值不影响规则状态
+	assertFired(check(11, 10*time.Minute), "level")
+	fired = fired[:0]
+	if err := s.CheckRules(ctx, map[string]models.RuleValues{"sz000001": {"price": 1}}, start.Add(20*time.Minute)); err != nil || len(fired) != 0 {
+		t.Fatalf("unexpected fire %v %v", fired, err)
+	}
+
+	if err := s.SetRuleActive(ctx, "level", false); err != nil {
+		t.Fatal(err)
+	}
+	assertFired(check(11, 30*time.Minute))
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go-stock/backend/calendar"
+	"go-stock/internal/domain/events"
+)
+
+// MarketSessionWatcher tracks the trading phase of each exchange and dispatches
+// MarketOpened/MarketClosed/MarketPhaseChanged events on transitions.
+type MarketSessionWatcher struct {
+	eventDispatcher events.EventDispatcher
+	markets         []string
+	interval        time.Duration
+	phaseAt         func(market string, t time.Time) calendar.Phase
+	phases          map[string]calendar.Phase
+	stopChan        chan struct{}
+	running         bool
+	mutex           sync.Mutex
+}
+
+// NewMarketSessionWatcher creates a watcher for the given markets, all markets when none are given.
+func NewMarketSessionWatcher(eventDispatcher events.EventDispatcher, interval time.Duration, markets ...string) *MarketSessionWatcher {
+	if len(markets) == 0 {
+		markets = []string{calendar.MarketCN, calendar.MarketHK, calendar.MarketUS}
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &MarketSessionWatcher{
+		eventDispatcher: eventDispatcher,
+		markets:         markets,
+		interval:        interval,
+		phaseAt:         calendar.PhaseAt,
+		phases:          make(map[string]calendar.Phase),
+	}
+}
+
+// Start checks the sessions immediately and then on every interval until Stop is called or ctx is done.
+func (w *MarketSessionWatcher) Start(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return errors.New("market session watcher is already running")
+	}
+	w.running = true
+	w.stopChan = make(chan struct{})
+
+	go w.watchLoop(ctx, w.stopChan)
+	return nil
+}
+
+// Stop stops the watch loop.
+func (w *MarketSessionWatcher) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.running {
+		return
+	}
+	close(w.stopChan)
+	w.running = false
+}
+
+func (w *MarketSessionWatcher) watchLoop(ctx context.Context, stopChan chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.Check(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			w.Check(now)
+		case <-stopChan:
+			return
+		case <-ctx.Done():
+			w.Stop()
+			return
+		}
+	}
+}
+
+// Check evaluates every market at the given time and dispatches events for phase transitions.
+// Markets start in the closed phase, so a market that is already open on the first check emits MarketOpened.
+func (w *MarketSessionWatcher) Check(now time.Time) {
+	w.mutex.Lock()
+	pending := make([]events.Event, 0)
+	for _, market := range w.markets {
+		previous, ok := w.phases[market]
+		if !ok {
+			previous = calendar.PhaseClosed
+		}
+		phase := w.phaseAt(market, now)
+		w.phases[market] = phase
+		if phase == previous {
+			continue
+		}
+
+		wasOpen, isOpen := previous.IsOpen(), phase.IsOpen()
+		pending = append(pending, events.NewMarketSessionEvent(events.MarketPhaseChanged, market, string(previous), string(phase), isOpen))
+		if !wasOpen && isOpen {
+			pending = append(pending, events.NewMarketSessionEvent(events.MarketOpened, market, string(previous), string(phase), true))
+		}
+		if wasOpen && !isOpen {
+			pending = append(pending, events.NewMarketSessionEvent(events.MarketClosed, market, string(previous), string(phase), false))
+		}
+	}
+	w.mutex.Unlock()
+
+	// Dispatch outside the lock so handlers may query the watcher
+	for _, event := range pending {
+		w.eventDispatcher.Dispatch(event)
+	}
+}
+
+// Phase returns the last observed phase of a market.
+func (w *MarketSessionWatcher) Phase(market string) calendar.Phase {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if phase, ok := w.phases[market]; ok {
+		return phase
+	}
+	return calendar.PhaseClosed
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go-stock/backend/calendar"
+	"go-stock/internal/domain/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarketSessionWatcher(t *testing.T) {
+	dispatcher := events.NewSimpleEventDispatcher()
+	var received []events.MarketEvent
+	record := func(event events.Event) {
+		received = append(received, event.(events.MarketEvent))
+	}
+	dispatcher.Register(events.MarketOpened, record)
+	dispatcher.Register(events.MarketClosed, record)
+
+	watcher := NewMarketSessionWatcher(dispatcher, time.Second, calendar.MarketCN)
+	location := calendar.GetExchange(calendar.MarketCN).Location
+	at := func(clock string) time.Time {
+		t, _ := time.ParseInLocation(time.DateTime, "2025-05-12 "+clock, location)
+		return t
+	}
+
+	watcher.Check(at("09:00:00"))
+	assert.Empty(t, received)
+
+	watcher.Check(at("09:20:00"))
+	watcher.Check(at("09:31:00"))
+	assert.Len(t, received, 1)
+	assert.Equal(t, events.MarketOpened, received[0].Type())
+	assert.Equal(t, string(calendar.PhaseAuction), received[0].Phase)
+
+	// 午间休市视为休市,下午开市再次触发开市事件
+	watcher.Check(at("12:00:00"))
+	watcher.Check(at("13:00:00"))
+	watcher.Check(at("15:01:00"))
+	assert.Len(t, received, 4)
+	assert.Equal(t, string(calendar.PhaseLunchBreak), received[1].Phase)
+	assert.Equal(t, events.MarketOpened, received[2].Type())
+	assert.Equal(t, events.MarketClosed, received[3].Type())
+	assert.Equal(t, string(calendar.PhaseClosed), received[3].Phase)
+	assert.Equal(t, calendar.PhaseClosed, watcher.Phase(calendar.MarketCN))
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go-stock/internal/domain/models"
+)
+
+// RuleField describes a value that can be referenced in an alert rule expression.
+type RuleField struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RuleFields lists the quote fields and derived values available to alert rules.
+var RuleFields = []RuleField{
+	{"price", "Last price"},
+	{"open", "Open price"},
+	{"high", "Intraday high"},
+	{"low", "Intraday low"},
+	{"pre_close", "Previous close"},
+	{"change", "Price change"},
+	{"change_pct", "Change percent"},
+	{"volume", "Volume"},
+	{"amount", "Turnover"},
+	{"volume_ratio", "Volume against the 5-day average at the same point of the session"},
+	{"ma5", "5-day moving average including the last price"},
+	{"ma10", "10-day moving average including the last price"},
+	{"ma20", "20-day moving average including the last price"},
+	{"ma20_distance", "Distance to MA20 in percent"},
+	{"prev_high", "Previous day high"},
+	{"prev_low", "Previous day low"},
+	{"new_high", "1 when the last price is at the intraday high"},
+	{"new_low", "1 when the last price is at the intraday low"},
+}
+
+func isRuleField(name string) bool {
+	for _, field := range RuleFields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleExpression is a parsed alert rule expression.
+//
+// Grammar:
+//
+//	expr       := and { ("OR" | "||") and }
+//	and        := unary { ("AND" | "&&") unary }
+//	unary      := ("NOT" | "!") unary | "(" expr ")" | comparison
+//	comparison := operand [ (">" | ">=" | "<" | "<=" | "==" | "!=") operand ]
+//	operand    := field | number
+//
+// A comparison without an operator is true when the operand is not zero.
+type RuleExpression struct {
+	source string
+	root   ruleNode
+	fields []string
+}
+
+// ParseRuleExpression parses and validates an alert rule expression.
+func ParseRuleExpression(source string) (*RuleExpression, error) {
+	tokens, err := tokenizeRule(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty rule expression", ErrInvalidInput)
+	}
+	p := &ruleParser{tokens: tokens, fields: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q in rule expression", ErrInvalidInput, p.tokens[p.pos].text)
+	}
+	expr := &RuleExpression{source: source, root: root}
+	for field := range p.fields {
+		expr.fields = append(expr.fields, field)
+	}
+	sort.Strings(expr.fields)
+	return expr, nil
+}
+
+// String returns the source of the expression.
+func (e *RuleExpression) String() string {
+	return e.source
+}
+
+// Fields returns the fields referenced by the expression.
+func (e *RuleExpression) Fields() []string {
+	return e.fields
+}
+
+// Evaluate evaluates the expression, failing when a referenced value is missing.
+func (e *RuleExpression) Evaluate(values models.RuleValues) (bool, error) {
+	return e.root.eval(values)
+}
+
+type ruleNode interface {
+	eval(values models.RuleValues) (bool, error)
+}
+
+type ruleOperand struct {
+	field string
+	value float64
+}
+
+func (o ruleOperand) resolve(values models.RuleValues) (float64, error) {
+	if o.field == "" {
+		return o.value, nil
+	}
+	v, ok := values[o.field]
+	if !ok {
+		return 0, fmt.Errorf("value %s is not available", o.field)
+	}
+	return v, nil
+}
+
+type ruleComparison struct {
+	left, right ruleOperand
+	op          string
+}
+
+func (c ruleComparison) eval(values models.RuleValues) (bool, error) {
+	left, err := c.left.resolve(values)
+	if err != nil {
+		return false, err
+	}
+	if c.op == "" {
+		return left != 0, nil
+	}
+	right, err := c.right.resolve(values)
+	if err != nil {
+		return false, err
+	}
+	switch c.op {
+	case ">":
+		return left > right, nil
+	case ">=":
+		return left >= right, nil
+	case "<":
+		return left < right, nil
+	case "<=":
+		return left <= right, nil
+	case "==":
+		return left == right, nil
+	default:
+		return left != right, nil
+	}
+}
+
+type ruleLogical struct {
+	and         bool
+	left, right ruleNode
+}
+
+func (l ruleLogical) eval(values models.RuleValues) (bool, error) {
+	left, err := l.left.eval(values)
+	if err != nil {
+		return false, err
+	}
+	if l.and && !left {
+		return false, nil
+	}
+	if !l.and && left {
+		return true, nil
+	}
+	return l.right.eval(values)
+}
+
+type ruleNot struct {
+	node ruleNode
+}
+
+func (n ruleNot) eval(values models.RuleValues) (bool, error) {
+	v, err := n.node.eval(values)
+	return !v, err
+}
+
+type ruleTokenKind int
+
+const (
+	tokenIdent ruleTokenKind = iota
+	tokenNumber
+	tokenOperator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	text string
+}
+
+func tokenizeRule(source string) ([]ruleToken, error) {
+	tokens := make([]ruleToken, 0)
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, ruleToken{tokenLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, ruleToken{tokenRParen, ")"})
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			word := string(runes[i:j])
+			switch strings.ToUpper(word) {
+			case "AND":
+				tokens = append(tokens, ruleToken{tokenAnd, word})
+			case "OR":
+				tokens = append(tokens, ruleToken{tokenOr, word})
+			case "NOT":
+				tokens = append(tokens, ruleToken{tokenNot, word})
+			default:
+				tokens = append(tokens, ruleToken{tokenIdent, strings.ToLower(word)})
+			}
+			i = j
+		case unicode.IsDigit(r) || r == '.' || (r == '-' && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleToken{tokenNumber, string(runes[i:j])})
+			i = j
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch {
+			case two == "&&":
+				tokens = append(tokens, ruleToken{tokenAnd, two})
+				i += 2
+			case two == "||":
+				tokens = append(tokens, ruleToken{tokenOr, two})
+				i += 2
+			case two == ">=" || two == "<=" || two == "==" || two == "!=":
+				tokens = append(tokens, ruleToken{tokenOperator, two})
+				i += 2
+			case r == '>' || r == '<':
+				tokens = append(tokens, ruleToken{tokenOperator, string(r)})
+				i++
+			case r == '=':
+				tokens = append(tokens, ruleToken{tokenOperator, "=="})
+				i++
+			case r == '!':
+				tokens = append(tokens, ruleToken{tokenNot, "!"})
+				i++
+			default:
+				return nil, fmt.Errorf("%w: unexpected character %q in rule expression", ErrInvalidInput, r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	fields map[string]bool
+}
+
+func (p *ruleParser) peek() (ruleToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return ruleToken{}, false
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind != tokenOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleLogical{and: false, left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind != tokenAnd {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleLogical{and: true, left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected end of rule expression", ErrInvalidInput)
+	}
+	switch token.kind {
+	case tokenNot:
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return ruleNot{node: node}, nil
+	case tokenLParen:
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if token, ok := p.peek(); !ok || token.kind != tokenRParen {
+			return nil, fmt.Errorf("%w: missing ) in rule expression", ErrInvalidInput)
+		}
+		p.pos++
+		return node, nil
+	default:
+		return p.parseComparison()
+	}
+}
+
+func (p *ruleParser) parseComparison() (ruleNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := p.peek()
+	if !ok || token.kind != tokenOperator {
+		return ruleComparison{left: left}, nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return ruleComparison{left: left, right: right, op: token.text}, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleOperand, error) {
+	token, ok := p.peek()
+	if !ok {
+		return ruleOperand{}, fmt.Errorf("%w: unexpected end of rule expression", ErrInvalidInput)
+	}
+	p.pos++
+	switch token.kind {
+	case tokenIdent:
+		if !isRuleField(token.text) {
+			return ruleOperand{}, fmt.Errorf("%w: unknown field %s", ErrInvalidInput, token.text)
+		}
+		p.fields[token.text] = true
+		return ruleOperand{field: token.text}, nil
+	case tokenNumber:
+		v, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return ruleOperand{}, fmt.Errorf("%w: invalid number %s", ErrInvalidInput, token.text)
+		}
+		return ruleOperand{value: v}, nil
+	default:
+		return ruleOperand{}, fmt.Errorf("%w: unexpected %q in rule expression", ErrInvalidInput, token.text)
+	}
+}
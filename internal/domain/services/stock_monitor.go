@@ -23,6 +23,13 @@ type StockMonitorService struct {
 	running         bool
 	mutex           sync.RWMutex
 	notifyFunc      func(alert *models.StockAlert, stock *models.StockInfo) // Function to call for notifications
+
+	// Composite alert rules, see SetAlertRules
+	ruleRepo       repositories.AlertRuleRepository
+	ruleSource     RuleValueSource
+	ruleNotifyFunc func(rule *models.AlertRule, values models.RuleValues)
+	ruleExprs      map[string]*RuleExpression // Parsed expressions keyed by rule ID
+	ruleMutex      sync.Mutex
 }
 
 // NewStockMonitorService creates a new stock monitor service.
@@ -92,12 +99,19 @@ func (s *StockMonitorService) monitorLoop(ctx context.Context) {
 		log.Printf("Error checking alerts: %v", err)
 	}
 
+	if err := s.checkRuleSource(ctx); err != nil {
+		log.Printf("Error checking alert rules: %v", err)
+	}
+
 	for {
 		select {
 		case <-s.monitorTicker.C:
 			if err := s.checkAlerts(ctx); err != nil {
 				log.Printf("Error checking alerts: %v", err)
 			}
+			if err := s.checkRuleSource(ctx); err != nil {
+				log.Printf("Error checking alert rules: %v", err)
+			}
 		case <-s.stopChan:
 			return
 		case <-ctx.Done():
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"go-stock/backend/calendar"
 	"go-stock/backend/data"
 	"go-stock/backend/db"
 	log "go-stock/backend/logger"
@@ -56,6 +57,10 @@ var VersionCommit string
 
 func main() {
 	checkDir("data")
+	//加载用户更新的节假日数据,覆盖内置数据中的同年份数据
+	if err := calendar.LoadFile("data/holidays.json"); err != nil {
+		log.SugaredLogger.Errorf("加载节假日数据失败:%s", err.Error())
+	}
 	db.Init("")
 	go AutoMigrate()
 	go InitDefaultData()
@@ -204,6 +209,23 @@ func AutoMigrate() {
 	db.Dao.AutoMigrate(&models.Tags{})
 	db.Dao.AutoMigrate(&models.Telegraph{})
 	db.Dao.AutoMigrate(&models.TelegraphTags{})
+	db.Dao.AutoMigrate(&data.QuoteSnapshot{})
+	db.Dao.AutoMigrate(&data.StockKLine{})
+	db.Dao.AutoMigrate(&data.StockKLineSync{})
+	db.Dao.AutoMigrate(&data.Trade{})
+	db.Dao.AutoMigrate(&data.PortfolioSnapshot{})
+	db.Dao.AutoMigrate(&data.AlertRule{})
+	db.Dao.AutoMigrate(&data.NotificationChannelSetting{})
+	db.Dao.AutoMigrate(&data.NotificationLog{})
+	db.Dao.AutoMigrate(&data.AlertEvent{})
+	db.Dao.AutoMigrate(&data.AlertState{})
+	db.Dao.AutoMigrate(&data.ExitRule{})
+	db.Dao.AutoMigrate(&data.AiModelProfile{})
+	data.NewAiModelApi().MigrateLegacyProfile()
+	db.Dao.AutoMigrate(&data.AiConversation{}, &data.AiMessage{})
+	db.Dao.AutoMigrate(&data.AiUsage{})
+	db.Dao.AutoMigrate(&data.AiRating{}, &data.AiRatingEvaluation{})
+	db.Dao.AutoMigrate(&data.AiBatchJob{}, &data.AiBatchTask{})
 }
 
 // InitDefaultData creates default records in the database